@@ -0,0 +1,451 @@
+// Package catalog maintains a searchable index of the image generation
+// parameters of all images in a folder tree.
+//
+// The catalog is stored as an append-only JSONL file. Each line records
+// the state of a single image at the time it was indexed; the last line
+// for a path wins. The file is loaded into an in-memory index when the
+// catalog is opened, and updated incrementally based on the modification
+// time and size of the images:
+//
+//	cat, err := catalog.Open("outputs")
+//	stats, err := cat.Update()
+//	lora := float32(0.5)
+//	for _, entry := range cat.Find(catalog.Query{
+//		Model:           "juggernautXL",
+//		Lora:            "sd_xl_offset",
+//		LoraWeightAbove: &lora,
+//	}) {
+//		fmt.Println(entry.Path)
+//	}
+package catalog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	metadata "github.com/fkleon/fooocus-metadata"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// DefaultStoreName is the file name of the catalog store,
+// created in the root folder unless configured otherwise.
+const DefaultStoreName = ".fooocus-catalog.jsonl"
+
+// privateLogName is the file name of the private log of Fooocus and
+// its forks, in the same folder as the images.
+const privateLogName = "log.html"
+
+type Config struct {
+	StorePath string
+	Extract   func(path string) (types.StructuredMetadata, error)
+}
+type Option func(*Config)
+
+// To store the catalog in a different location than the root folder.
+func WithStorePath(path string) Option {
+	return func(cfg *Config) {
+		cfg.StorePath = path
+	}
+}
+
+// To use a custom function to extract the metadata of an image file,
+// defaults to metadata.ExtractFromFile.
+func WithExtractor(extract func(path string) (types.StructuredMetadata, error)) Option {
+	return func(cfg *Config) {
+		cfg.Extract = extract
+	}
+}
+
+// Stats reports the outcome of a catalog update.
+type Stats struct {
+	Added     int
+	Updated   int
+	Removed   int
+	Unchanged int
+	// Number of images that were indexed without metadata,
+	// because the extraction failed. Images that failed before
+	// and were not extracted again are counted as unchanged.
+	Failed int
+	// Number of files and folders that could not be read,
+	// they are indexed again on the next update.
+	Skipped int
+}
+
+// Catalog is a searchable index of the images in a folder tree.
+// It is safe for concurrent use.
+type Catalog struct {
+	root   string
+	config Config
+
+	// Serialises updates, which only lock the entries
+	// once the folder tree was scanned.
+	update sync.Mutex
+
+	mu      sync.RWMutex
+	entries map[string]Entry
+	// Number of lines in the store, used to decide whether
+	// compaction is worthwhile.
+	lines int
+	// Whether the store ends with a partial line.
+	partial bool
+}
+
+// Open opens the catalog of the given root folder, loading
+// the existing store if present.
+//
+// The catalog is not updated automatically, call Update to
+// index new or modified images.
+func Open(root string, opts ...Option) (*Catalog, error) {
+	cfg := Config{
+		StorePath: filepath.Join(root, DefaultStoreName),
+		Extract:   extractFromFile,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	cat := &Catalog{
+		root:    root,
+		config:  cfg,
+		entries: make(map[string]Entry),
+	}
+	if err := cat.load(); err != nil {
+		return nil, err
+	}
+	return cat, nil
+}
+
+// Root returns the root folder of the catalog.
+func (c *Catalog) Root() string {
+	return c.root
+}
+
+// Len returns the number of images in the catalog.
+func (c *Catalog) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// Get returns the entry of the image at the given path, which
+// may be relative to the root folder or absolute.
+func (c *Catalog) Get(path string) (Entry, bool) {
+	rel, err := c.rel(path)
+	if err != nil {
+		return Entry{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[rel]
+	return entry, ok
+}
+
+// Entries returns all entries of the catalog, ordered by path.
+func (c *Catalog) Entries() []Entry {
+	return c.Find(Query{})
+}
+
+// Find returns the entries matching the query, ordered by path.
+// Entries of images without metadata never match.
+func (c *Catalog) Find(q Query) (result []Entry) {
+	c.mu.RLock()
+	for _, entry := range c.entries {
+		if entry.Error == "" && q.Match(entry) {
+			result = append(result, entry)
+		}
+	}
+	c.mu.RUnlock()
+
+	slices.SortFunc(result, func(a, b Entry) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return result
+}
+
+// Update scans the root folder and indexes all images that are new
+// or were modified since the last update, based on their modification
+// time and size. Images that no longer exist are removed.
+//
+// Images whose metadata could not be extracted are extracted again
+// once the private log in their folder was created or modified, e.g.
+// as Fooocus writes the log after the image.
+// Files and folders that cannot be read are skipped and keep their
+// previous entries. The catalog can be searched while it is updated.
+func (c *Catalog) Update() (stats Stats, err error) {
+	c.update.Lock()
+	defer c.update.Unlock()
+
+	results, keep, err := c.scan(&stats)
+	if err != nil {
+		return stats, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	store, err := os.OpenFile(c.config.StorePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return stats, fmt.Errorf("failed to open catalog store: %w", err)
+	}
+	defer store.Close()
+
+	writer := bufio.NewWriter(store)
+	if c.partial {
+		writer.WriteByte('\n')
+		c.partial = false
+	}
+	encoder := json.NewEncoder(writer)
+	var appendEntry = func(entry Entry) error {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to write catalog store: %w", err)
+		}
+		c.lines++
+		return nil
+	}
+
+	for _, entry := range results {
+		if err = appendEntry(entry); err != nil {
+			break
+		}
+		c.entries[entry.Path] = entry
+	}
+
+	if err == nil {
+		for rel, entry := range c.entries {
+			if keep(rel) {
+				continue
+			}
+			if err = appendEntry(Entry{Path: rel, ModTime: entry.ModTime, Removed: true}); err != nil {
+				break
+			}
+			delete(c.entries, rel)
+			stats.Removed++
+		}
+	}
+
+	if flushErr := writer.Flush(); flushErr != nil && err == nil {
+		err = fmt.Errorf("failed to write catalog store: %w", flushErr)
+	}
+
+	slog.Info("Updated catalog", "root", c.root, "added", stats.Added, "updated", stats.Updated,
+		"removed", stats.Removed, "unchanged", stats.Unchanged, "failed", stats.Failed, "skipped", stats.Skipped)
+	return stats, err
+}
+
+// scan walks the root folder without holding the lock on the entries,
+// and extracts the metadata of new and modified images, and of
+// previously failed images whose private log changed. It returns the entries to store, and a function reporting
+// whether an existing entry was seen or skipped and must be kept.
+func (c *Catalog) scan(stats *Stats) (results []Entry, keep func(rel string) bool, err error) {
+	seen := make(map[string]bool)
+	var skippedDirs []string
+
+	var lookup = func(rel string) (Entry, bool) {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		entry, ok := c.entries[rel]
+		return entry, ok
+	}
+
+	// Modification time of the private log of each folder
+	logModTimes := make(map[string]time.Time)
+	var logModTime = func(dir string) time.Time {
+		modTime, ok := logModTimes[dir]
+		if !ok {
+			if info, err := os.Stat(filepath.Join(dir, privateLogName)); err == nil {
+				modTime = info.ModTime()
+			}
+			logModTimes[dir] = modTime
+		}
+		return modTime
+	}
+
+	err = filepath.WalkDir(c.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == c.root {
+				return err
+			}
+			slog.Warn("Skipping unreadable path", "path", path, "err", err)
+			stats.Skipped++
+			if rel, relErr := filepath.Rel(c.root, path); relErr == nil {
+				skippedDirs = append(skippedDirs, filepath.ToSlash(rel)+"/")
+			}
+			return nil
+		}
+		if d.IsDir() || !metadata.IsImageFile(path) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+
+		info, err := d.Info()
+		if err != nil {
+			slog.Warn("Skipping unreadable image", "path", path, "err", err)
+			stats.Skipped++
+			return nil
+		}
+
+		prev, exists := lookup(rel)
+		changed := !exists || !prev.ModTime.Equal(info.ModTime()) || prev.Size != info.Size()
+		if !changed && (prev.Error == "" || prev.LogModTime.Equal(logModTime(filepath.Dir(path)))) {
+			stats.Unchanged++
+			return nil
+		}
+
+		slog.Debug("Indexing image", "path", path)
+		entry := Entry{
+			Path:    rel,
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		}
+		if meta, err := c.config.Extract(path); err != nil {
+			slog.Info("Failed to extract metadata", "path", path, "err", err)
+			entry.Error = err.Error()
+			entry.LogModTime = logModTime(filepath.Dir(path))
+			stats.Failed++
+		} else {
+			entry.fill(meta)
+		}
+		results = append(results, entry)
+
+		if exists {
+			stats.Updated++
+		} else {
+			stats.Added++
+		}
+		return nil
+	})
+
+	keep = func(rel string) bool {
+		if seen[rel] {
+			return true
+		}
+		for _, dir := range skippedDirs {
+			if strings.HasPrefix(rel, dir) {
+				return true
+			}
+		}
+		return false
+	}
+	return results, keep, err
+}
+
+// Compact rewrites the store to contain only the current entries,
+// discarding the history of modified and removed images.
+func (c *Catalog) Compact() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lines == len(c.entries) {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.config.StorePath), filepath.Base(c.config.StorePath)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to compact catalog store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, rel := range slices.Sorted(maps.Keys(c.entries)) {
+		if err = encoder.Encode(c.entries[rel]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.config.StorePath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to compact catalog store: %w", err)
+	}
+
+	c.lines = len(c.entries)
+	return nil
+}
+
+// load reads the store into the in-memory index.
+func (c *Catalog) load() error {
+	store, err := os.Open(c.config.StorePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to open catalog store: %w", err)
+	}
+	defer store.Close()
+
+	scanner := bufio.NewScanner(store)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		c.lines++
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.Path == "" {
+			// Most likely a partial write, the image
+			// will be indexed again on the next update.
+			slog.Warn("Skipping invalid catalog entry", "store", c.config.StorePath, "line", c.lines, "err", err)
+			continue
+		}
+		if entry.Removed {
+			delete(c.entries, entry.Path)
+		} else {
+			c.entries[entry.Path] = entry
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read catalog store: %w", err)
+	}
+
+	if info, err := store.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := store.ReadAt(last, info.Size()-1); err == nil {
+			c.partial = last[0] != '\n'
+		}
+	}
+	return nil
+}
+
+// rel returns the slash-separated path relative to the root folder,
+// given an absolute path or a path already relative to the root folder.
+func (c *Catalog) rel(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		root, err := filepath.Abs(c.root)
+		if err != nil {
+			return "", err
+		}
+		if path, err = filepath.Rel(root, path); err != nil {
+			return "", err
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path)), nil
+}
+
+func extractFromFile(path string) (types.StructuredMetadata, error) {
+	return metadata.ExtractFromFile(path)
+}
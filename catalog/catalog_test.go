@@ -0,0 +1,296 @@
+package catalog
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/fkleon/fooocus-metadata/fooocus"
+	_ "github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	_ "github.com/fkleon/fooocus-metadata/stablediffusion"
+
+	metadata "github.com/fkleon/fooocus-metadata"
+	"github.com/fkleon/fooocus-metadata/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Configure logging during testing
+func TestMain(m *testing.M) {
+	slog.SetLogLoggerLevel(slog.LevelWarn)
+	exitVal := m.Run()
+	os.Exit(exitVal)
+}
+
+// Populate a folder tree with test images
+func setupRoot(t *testing.T) string {
+	root := t.TempDir()
+	copyFile(t, "../fooocus/testdata/fooocus-meta.png", filepath.Join(root, "2024-01-05", "fooocus.png"))
+	copyFile(t, "../ruinedfooocus/testdata/ruinedfooocus-meta.png", filepath.Join(root, "ruined.png"))
+	copyFile(t, "../internal/image/testdata/sample.png", filepath.Join(root, "sample.png"))
+	copyFile(t, "../fooocus/testdata/meta.json", filepath.Join(root, "meta.json"))
+	return root
+}
+
+func copyFile(t *testing.T, src string, dst string) {
+	data, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(dst), 0o755))
+	require.NoError(t, os.WriteFile(dst, data, 0o644))
+}
+
+func TestUpdate(t *testing.T) {
+	root := setupRoot(t)
+
+	cat, err := Open(root)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cat.Len())
+
+	stats, err := cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Added: 3, Failed: 1}, stats)
+	assert.Equal(t, 3, cat.Len())
+
+	entry, ok := cat.Get("2024-01-05/fooocus.png")
+	require.True(t, ok)
+	assert.Equal(t, "Fooocus", entry.Source)
+	assert.Equal(t, "juggernautXL_v8Rundiffusion", entry.Model)
+	assert.Equal(t, "dpmpp_2m_sde_gpu", entry.Sampler)
+	assert.Equal(t, "karras", entry.Scheduler)
+	assert.Equal(t, "127589946317439009", entry.Seed)
+
+	entry, ok = cat.Get(filepath.Join(root, "sample.png"))
+	require.True(t, ok)
+	assert.NotEmpty(t, entry.Error)
+
+	// Images without metadata are not returned
+	assert.Len(t, cat.Entries(), 2)
+
+	// Nothing changed, the image without metadata is not retried
+	stats, err = cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Unchanged: 3}, stats)
+}
+
+func TestUpdate_RetryFailed(t *testing.T) {
+	root := setupRoot(t)
+	store := filepath.Join(root, DefaultStoreName)
+
+	fail := true
+	var extracted []string
+	cat, err := Open(root, WithExtractor(func(path string) (types.StructuredMetadata, error) {
+		extracted = append(extracted, filepath.Base(path))
+		if fail && filepath.Base(path) == "ruined.png" {
+			return types.StructuredMetadata{}, errors.New("not yet")
+		}
+		return metadata.ExtractFromFile(path)
+	}))
+	require.NoError(t, err)
+
+	stats, err := cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Added: 3, Failed: 2}, stats)
+	assert.Equal(t, 3, countLines(t, store))
+
+	// Failed images are not retried while nothing changed
+	fail = false
+	extracted = nil
+	stats, err = cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Unchanged: 3}, stats)
+	assert.Empty(t, extracted)
+	assert.Equal(t, 3, countLines(t, store))
+
+	// Writing the private log retries the failed images of its folder
+	logfile := filepath.Join(root, "log.html")
+	require.NoError(t, os.WriteFile(logfile, []byte("<html></html>"), 0o644))
+	stats, err = cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Updated: 2, Unchanged: 1, Failed: 1}, stats)
+	assert.ElementsMatch(t, []string{"ruined.png", "sample.png"}, extracted)
+	assert.Equal(t, 5, countLines(t, store))
+
+	entry, ok := cat.Get("ruined.png")
+	require.True(t, ok)
+	assert.Empty(t, entry.Error)
+	assert.Equal(t, "RuinedFooocus", entry.Source)
+
+	info, err := os.Stat(logfile)
+	require.NoError(t, err)
+	entry, ok = cat.Get("sample.png")
+	require.True(t, ok)
+	assert.NotEmpty(t, entry.Error)
+	assert.Equal(t, info.ModTime(), entry.LogModTime)
+
+	// Failing again with the same private log is recorded once
+	extracted = nil
+	stats, err = cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Unchanged: 3}, stats)
+	assert.Empty(t, extracted)
+
+	// Modifying the image retries it
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "sample.png"), modTime, modTime))
+	stats, err = cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Updated: 1, Unchanged: 2, Failed: 1}, stats)
+	assert.Equal(t, []string{"sample.png"}, extracted)
+
+	// The failures are kept in the store
+	cat, err = Open(root)
+	require.NoError(t, err)
+	stats, err = cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Unchanged: 3}, stats)
+}
+
+func TestUpdate_ReadDuringScan(t *testing.T) {
+	root := setupRoot(t)
+
+	var cat *Catalog
+	var lengths []int
+	cat, err := Open(root, WithExtractor(func(path string) (types.StructuredMetadata, error) {
+		// Would deadlock if the entries were locked during the scan
+		lengths = append(lengths, cat.Len())
+		return metadata.ExtractFromFile(path)
+	}))
+	require.NoError(t, err)
+
+	_, err = cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 0, 0}, lengths)
+	assert.Equal(t, 3, cat.Len())
+}
+
+func TestUpdate_UnreadableFolder(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("Folder permissions do not apply to root")
+	}
+	root := setupRoot(t)
+
+	cat, err := Open(root)
+	require.NoError(t, err)
+	_, err = cat.Update()
+	require.NoError(t, err)
+
+	folder := filepath.Join(root, "2024-01-05")
+	require.NoError(t, os.Chmod(folder, 0))
+	t.Cleanup(func() { os.Chmod(folder, 0o755) })
+	require.NoError(t, os.Remove(filepath.Join(root, "sample.png")))
+
+	// The update continues, and keeps the images of the folder
+	stats, err := cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Removed: 1, Unchanged: 1, Skipped: 1}, stats)
+	_, ok := cat.Get("2024-01-05/fooocus.png")
+	assert.True(t, ok)
+
+	require.NoError(t, os.Chmod(folder, 0o755))
+	stats, err = cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Unchanged: 2}, stats)
+}
+
+func TestUpdate_Incremental(t *testing.T) {
+	root := setupRoot(t)
+
+	cat, err := Open(root)
+	require.NoError(t, err)
+	_, err = cat.Update()
+	require.NoError(t, err)
+
+	// Modify, remove and add images
+	mtime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(root, "ruined.png"), mtime, mtime))
+	require.NoError(t, os.Remove(filepath.Join(root, "sample.png")))
+	copyFile(t, "../fooocus/testdata/fooocus-meta.jpeg", filepath.Join(root, "2024-01-06", "fooocus.jpeg"))
+
+	var extracted []string
+	cat, err = Open(root, WithExtractor(func(path string) (meta types.StructuredMetadata, err error) {
+		extracted = append(extracted, path)
+		return metadata.ExtractFromFile(path)
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 3, cat.Len())
+
+	stats, err := cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Added: 1, Updated: 1, Removed: 1, Unchanged: 1}, stats)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(root, "ruined.png"),
+		filepath.Join(root, "2024-01-06", "fooocus.jpeg"),
+	}, extracted)
+
+	_, ok := cat.Get("sample.png")
+	assert.False(t, ok)
+
+	// Reload from store
+	cat, err = Open(root)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cat.Len())
+	entry, ok := cat.Get("ruined.png")
+	require.True(t, ok)
+	assert.True(t, entry.ModTime.Equal(mtime))
+}
+
+func TestCompact(t *testing.T) {
+	root := setupRoot(t)
+	store := filepath.Join(t.TempDir(), "catalog.jsonl")
+
+	cat, err := Open(root, WithStorePath(store))
+	require.NoError(t, err)
+	_, err = cat.Update()
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(filepath.Join(root, "sample.png")))
+	_, err = cat.Update()
+	require.NoError(t, err)
+	assert.Equal(t, 4, countLines(t, store))
+
+	require.NoError(t, cat.Compact())
+	assert.Equal(t, 2, countLines(t, store))
+
+	cat, err = Open(root, WithStorePath(store))
+	require.NoError(t, err)
+	assert.Equal(t, 2, cat.Len())
+}
+
+func TestOpen_PartialWrite(t *testing.T) {
+	root := setupRoot(t)
+
+	cat, err := Open(root)
+	require.NoError(t, err)
+	_, err = cat.Update()
+	require.NoError(t, err)
+
+	store, err := os.OpenFile(filepath.Join(root, DefaultStoreName), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = store.WriteString(`{"path":"ruined.png","mti`)
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	cat, err = Open(root)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cat.Len())
+
+	// Subsequent writes start on a new line
+	require.NoError(t, os.Remove(filepath.Join(root, "ruined.png")))
+	_, err = cat.Update()
+	require.NoError(t, err)
+
+	cat, err = Open(root)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cat.Len())
+}
+
+func countLines(t *testing.T, path string) int {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return bytes.Count(data, []byte("\n"))
+}
@@ -0,0 +1,106 @@
+package catalog
+
+import (
+	"time"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Entry is the indexed state of a single image.
+type Entry struct {
+	// Path of the image, relative to the root folder and
+	// separated by forward slashes.
+	Path string `json:"path"`
+	// Modification time and size of the image at the time
+	// it was indexed, used to detect changes.
+	ModTime time.Time `json:"mtime"`
+	Size    int64     `json:"size"`
+
+	// Marks the image as removed, only used in the store.
+	Removed bool `json:"removed,omitempty"`
+	// Error message if the metadata could not be extracted.
+	Error string `json:"error,omitempty"`
+	// Modification time of the private log in the folder of the image
+	// when the extraction failed, zero if there was none. The image
+	// is extracted again once the log changes.
+	LogModTime time.Time `json:"log_mtime,omitzero"`
+
+	Source  string    `json:"source,omitempty"`
+	Created time.Time `json:"created,omitzero"`
+	// Source of the creation time, see types.TimeSource.
+	CreatedSource types.TimeSource `json:"created_source,omitempty"`
+	Version       string           `json:"version,omitempty"`
+	// Parsed version of the software, see types.SoftwareVersion.
+	SoftwareVersion types.SoftwareVersion `json:"software_version,omitzero"`
+	// Where the metadata was found, e.g. embedded or in the private log.
+	Provenance types.Provenance `json:"provenance,omitzero"`
+
+	// Normalised name of the model, see types.NormaliseModelName.
+	Model string       `json:"model,omitempty"`
+	LoRAs []types.Lora `json:"loras,omitempty"`
+
+	// Sampler and scheduler in Fooocus notation,
+	// e.g. "dpmpp_2m_sde_gpu" and "karras".
+	Sampler   string `json:"sampler,omitempty"`
+	Scheduler string `json:"scheduler,omitempty"`
+
+	Seed           string `json:"seed,omitempty"`
+	PositivePrompt string `json:"prompt,omitempty"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+
+	// Size of the image in pixels, and how it differs from
+	// the generated size, e.g. "upscaled".
+	Width      int              `json:"width,omitempty"`
+	Height     int              `json:"height,omitempty"`
+	SizeChange types.SizeChange `json:"size_change,omitempty"`
+
+	// Fields that differ between the embedded metadata and the private
+	// log, only set if they were merged, see types.PrivateLogMerge.
+	Conflicts []types.Conflict `json:"conflicts,omitempty"`
+}
+
+// fill populates the entry from the extracted metadata.
+func (e *Entry) fill(meta types.StructuredMetadata) {
+	e.Source = meta.Source
+	e.Created = meta.Created
+	e.CreatedSource = meta.CreatedSource
+	e.Provenance = meta.Provenance
+	e.Width, e.Height = meta.Image.Width, meta.Image.Height
+	e.SizeChange = meta.Size.Change
+	if meta.Merge != nil {
+		e.Conflicts = meta.Merge.Conflicts
+	}
+
+	params := meta.Params
+	if params == nil {
+		return
+	}
+
+	e.Version = params.Version()
+	e.SoftwareVersion = params.SoftwareVersion()
+	e.Model = types.NormaliseModelName(params.Model())
+	e.LoRAs = params.LoRAs()
+	e.Seed = params.Seed()
+	e.PositivePrompt = params.PositivePrompt()
+	e.NegativePrompt = params.NegativePrompt()
+	e.Sampler = params.Sampler()
+	e.Scheduler = params.Scheduler()
+}
+
+// Time returns the creation time of the image if known,
+// otherwise its modification time.
+func (e Entry) Time() time.Time {
+	if e.Created.IsZero() {
+		return e.ModTime
+	}
+	return e.Created
+}
+
+// softwareVersion returns the parsed software version, parsing
+// the recorded version for entries indexed without it.
+func (e Entry) softwareVersion() types.SoftwareVersion {
+	if !e.SoftwareVersion.IsZero() {
+		return e.SoftwareVersion
+	}
+	return types.ParseSoftwareVersion(e.Version).WithProduct(e.Source)
+}
@@ -0,0 +1,116 @@
+package catalog
+
+import (
+	"strings"
+	"time"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Query selects catalog entries. All non-zero criteria must match.
+type Query struct {
+	// Case-insensitive substring of the normalised model name.
+	Model string
+	// Case-insensitive substring of the name of any LoRA.
+	Lora string
+	// If set, the matching LoRA must have a weight strictly above it.
+	// Without Lora, any LoRA with a weight above it matches.
+	LoraWeightAbove *float32
+	// Sampler or scheduler, case-insensitive, e.g. "dpmpp_2m_sde_gpu",
+	// "karras" or the combined "dpmpp_2m_sde_gpu karras". Names of other
+	// tools match their equivalent, e.g. "dpmpp_2m_sde" of ComfyUI or
+	// "DPM++ 2M SDE Karras" of A1111, see types.NormaliseSampler.
+	Sampler string
+	// Exact seed.
+	Seed string
+	// Creation time range, From is inclusive and To is exclusive.
+	// Uses the modification time if the creation time is unknown.
+	From time.Time
+	To   time.Time
+	// Case-insensitive substring of the positive prompt.
+	Prompt string
+	// Product and version of the software, e.g. "Fooocus >= 2.3".
+	Version types.VersionConstraint
+	// Only match images whose embedded metadata conflicts with
+	// the private log, e.g. because they were edited.
+	Conflicts bool
+	// How the image size differs from the generated size,
+	// e.g. "upscaled", see types.SizeChange.
+	SizeChange types.SizeChange
+}
+
+// Match returns true if the entry matches all criteria of the query.
+func (q Query) Match(e Entry) bool {
+	if q.Model != "" && !containsFold(e.Model, q.Model) {
+		return false
+	}
+	if (q.Lora != "" || q.LoraWeightAbove != nil) && !q.matchLora(e.LoRAs) {
+		return false
+	}
+	if q.Sampler != "" && !q.matchSampler(e) {
+		return false
+	}
+	if q.Seed != "" && e.Seed != q.Seed {
+		return false
+	}
+	if t := e.Time(); (!q.From.IsZero() && t.Before(q.From)) || (!q.To.IsZero() && !t.Before(q.To)) {
+		return false
+	}
+	if q.Prompt != "" && !containsFold(e.PositivePrompt, q.Prompt) {
+		return false
+	}
+	if !q.Version.IsZero() && !q.Version.Match(e.softwareVersion()) {
+		return false
+	}
+	if q.Conflicts && len(e.Conflicts) == 0 {
+		return false
+	}
+	if q.SizeChange != "" && !strings.EqualFold(string(e.SizeChange), string(q.SizeChange)) {
+		return false
+	}
+	return true
+}
+
+func (q Query) matchLora(loras []types.Lora) bool {
+	for _, lora := range loras {
+		if q.Lora != "" && !containsFold(types.NormaliseModelName(lora.Name), q.Lora) {
+			continue
+		}
+		if q.LoraWeightAbove != nil && lora.Weight <= *q.LoraWeightAbove {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func (q Query) matchSampler(e Entry) bool {
+	sampler, _ := types.NormaliseSampler(e.Sampler)
+	scheduler, _ := types.NormaliseScheduler(e.Scheduler)
+	var match = func(querySampler string, queryScheduler string) bool {
+		querySampler, _ = types.NormaliseSampler(querySampler)
+		queryScheduler, _ = types.NormaliseScheduler(queryScheduler)
+		return strings.EqualFold(querySampler, sampler) && strings.EqualFold(queryScheduler, scheduler)
+	}
+
+	query := strings.TrimSpace(q.Sampler)
+	if id, _ := types.NormaliseSampler(query); strings.EqualFold(id, sampler) {
+		return true
+	}
+	if id, _ := types.NormaliseScheduler(query); strings.EqualFold(id, scheduler) {
+		return true
+	}
+	// Combined A1111 name, e.g. "DPM++ 2M SDE Karras"
+	if querySampler, queryScheduler, ok := types.SplitA1111Sampler(query); ok && match(querySampler, queryScheduler) {
+		return true
+	}
+	// Combined identifiers, e.g. "dpmpp_2m_sde_gpu karras"
+	if querySampler, queryScheduler, ok := strings.Cut(query, " "); ok && match(querySampler, strings.TrimSpace(queryScheduler)) {
+		return true
+	}
+	return false
+}
+
+func containsFold(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,117 @@
+package catalog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fkleon/fooocus-metadata/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var entry = Entry{
+	Path:    "2024-01-05/image.png",
+	ModTime: time.Date(2024, 1, 6, 8, 0, 0, 0, time.UTC),
+	Model:   "juggernautXL_v8Rundiffusion",
+	LoRAs: []types.Lora{
+		{Name: "sd_xl_offset_example-lora_1.0.safetensors", Weight: 0.7},
+		{Name: "detail_tweaker", Weight: 0.2},
+	},
+	Sampler:        "dpmpp_2m_sde_gpu",
+	Scheduler:      "karras",
+	Seed:           "1234",
+	PositivePrompt: "A Sunflower field",
+}
+
+func weight(w float32) *float32 {
+	return &w
+}
+
+func constraint(s string) types.VersionConstraint {
+	c, err := types.ParseVersionConstraint(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func TestQuery_Match(t *testing.T) {
+	created := entry
+	created.Created = time.Date(2024, 1, 5, 23, 11, 48, 0, time.UTC)
+
+	versioned := entry
+	versioned.SoftwareVersion = types.SoftwareVersion{Product: "Fooocus", Version: "2.5.5"}
+
+	// Indexed without the parsed version
+	legacy := entry
+	legacy.Source, legacy.Version = "Fooocus", "v2.1.865"
+
+	conflicting := entry
+	conflicting.Conflicts = []types.Conflict{{Field: "Seed", Primary: "1234", Secondary: "4321"}}
+
+	upscaled := entry
+	upscaled.Width, upscaled.Height, upscaled.SizeChange = 2304, 1792, types.SizeUpscaled
+
+	// Sampler names as stored by other tools
+	comfyui := entry
+	comfyui.Sampler = "dpmpp_2m_sde"
+	a1111 := entry
+	a1111.Sampler, a1111.Scheduler = "DPM++ 2M", "Karras"
+
+	testCases := []struct {
+		name     string
+		query    Query
+		entry    Entry
+		expected bool
+	}{
+		{"empty", Query{}, entry, true},
+		{"model substring", Query{Model: "juggernautxl"}, entry, true},
+		{"model mismatch", Query{Model: "sd_xl_base"}, entry, false},
+		{"lora", Query{Lora: "sd_xl_offset"}, entry, true},
+		{"lora mismatch", Query{Lora: "add_detail"}, entry, false},
+		{"lora above weight", Query{Lora: "sd_xl_offset", LoraWeightAbove: weight(0.5)}, entry, true},
+		{"lora below weight", Query{Lora: "detail_tweaker", LoraWeightAbove: weight(0.5)}, entry, false},
+		{"lora at weight", Query{Lora: "sd_xl_offset", LoraWeightAbove: weight(0.7)}, entry, false},
+		{"any lora above weight", Query{LoraWeightAbove: weight(0.5)}, entry, true},
+		{"sampler", Query{Sampler: "DPMPP_2M_SDE_GPU"}, entry, true},
+		{"scheduler", Query{Sampler: "karras"}, entry, true},
+		{"sampler and scheduler", Query{Sampler: "dpmpp_2m_sde_gpu karras"}, entry, true},
+		{"sampler mismatch", Query{Sampler: "euler"}, entry, false},
+		{"sampler a1111", Query{Sampler: "DPM++ 2M SDE"}, entry, true},
+		{"sampler and scheduler a1111", Query{Sampler: "DPM++ 2M SDE Karras"}, entry, true},
+		{"sampler and scheduler a1111 mismatch", Query{Sampler: "DPM++ 2M SDE Exponential"}, entry, false},
+		{"sampler comfyui", Query{Sampler: "dpmpp_2m_sde"}, entry, true},
+		{"sampler and scheduler comfyui", Query{Sampler: "dpmpp_2m_sde karras"}, entry, true},
+		{"comfyui sampler", Query{Sampler: "dpmpp_2m_sde_gpu"}, comfyui, true},
+		{"comfyui sampler a1111", Query{Sampler: "DPM++ 2M SDE Karras"}, comfyui, true},
+		{"a1111 sampler", Query{Sampler: "dpmpp_2m"}, a1111, true},
+		{"a1111 scheduler", Query{Sampler: "karras"}, a1111, true},
+		{"a1111 sampler and scheduler", Query{Sampler: "DPM++ 2M Karras"}, a1111, true},
+		{"a1111 sampler mismatch", Query{Sampler: "dpmpp_2m_sde"}, a1111, false},
+		{"seed", Query{Seed: "1234"}, entry, true},
+		{"seed mismatch", Query{Seed: "123"}, entry, false},
+		{"prompt", Query{Prompt: "sunflower"}, entry, true},
+		{"prompt mismatch", Query{Prompt: "rose"}, entry, false},
+		{"mtime in range", Query{From: time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC)}, entry, true},
+		{"mtime out of range", Query{To: time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC)}, entry, false},
+		{"created in range", Query{To: time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC)}, created, true},
+		{"created out of range", Query{From: time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC)}, created, false},
+		{"version", Query{Version: constraint("Fooocus >= 2.3")}, versioned, true},
+		{"version mismatch", Query{Version: constraint("Fooocus < 2.3")}, versioned, false},
+		{"version product", Query{Version: constraint("fooocus")}, versioned, true},
+		{"version product mismatch", Query{Version: constraint("FooocusPlus")}, versioned, false},
+		{"version unknown", Query{Version: constraint(">= 1.0")}, entry, false},
+		{"version legacy", Query{Version: constraint("Fooocus = 2.1.865")}, legacy, true},
+		{"conflicts", Query{Conflicts: true}, conflicting, true},
+		{"no conflicts", Query{Conflicts: true}, entry, false},
+		{"size change", Query{SizeChange: "Upscaled"}, upscaled, true},
+		{"size change mismatch", Query{SizeChange: types.SizeCropped}, upscaled, false},
+		{"size change unknown", Query{SizeChange: types.SizeUpscaled}, entry, false},
+		{"combined", Query{Model: "juggernautXL", Lora: "sd_xl_offset", LoraWeightAbove: weight(0.5), Prompt: "field"}, entry, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.query.Match(tc.entry))
+		})
+	}
+}
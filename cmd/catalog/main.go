@@ -0,0 +1,158 @@
+// A command-line tool to index the image generation parameters
+// of a folder tree and search the resulting catalog.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/fkleon/fooocus-metadata/fooocus"
+	_ "github.com/fkleon/fooocus-metadata/fooocusplus"
+	_ "github.com/fkleon/fooocus-metadata/fork"
+	_ "github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	_ "github.com/fkleon/fooocus-metadata/stablediffusion"
+	_ "github.com/fkleon/fooocus-metadata/xmp"
+
+	fooocusmeta "github.com/fkleon/fooocus-metadata"
+	"github.com/fkleon/fooocus-metadata/catalog"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+const dateLayout = "2006-01-02"
+
+func main() {
+
+	var debug, verbose, asJson, noUpdate, compact, mergeLog bool
+	var store, from, to, version string
+	var loraWeight float64
+	var query catalog.Query
+
+	flag.BoolVar(&verbose, "verbose", false, "enable verbose logging")
+	flag.BoolVar(&debug, "debug", false, "enable debug logging")
+	flag.BoolVar(&asJson, "json", false, "print matching entries in JSON format, one per line")
+	flag.BoolVar(&noUpdate, "no-update", false, "search the catalog without updating it first")
+	flag.BoolVar(&compact, "compact", false, "compact the catalog store after updating")
+	flag.BoolVar(&mergeLog, "merge-log", false, "merge embedded metadata with the private log when indexing, to detect edited images")
+	flag.StringVar(&store, "store", "", "the path of the catalog store (default <folder>/"+catalog.DefaultStoreName+")")
+	flag.StringVar(&query.Model, "model", "", "match images by model name (substring)")
+	flag.StringVar(&query.Lora, "lora", "", "match images by LoRA name (substring)")
+	flag.Float64Var(&loraWeight, "lora-above", 0, "match images with a LoRA weight above the given value")
+	flag.StringVar(&query.Sampler, "sampler", "", "match images by sampler or scheduler")
+	flag.StringVar(&query.Seed, "seed", "", "match images by seed")
+	flag.StringVar(&query.Prompt, "prompt", "", "match images by prompt (substring)")
+	flag.StringVar(&from, "from", "", "match images created on or after the given date (YYYY-MM-DD)")
+	flag.StringVar(&to, "to", "", "match images created on or before the given date (YYYY-MM-DD)")
+	flag.StringVar(&version, "version", "", "match images by software and version, e.g. \"Fooocus >= 2.3\"")
+	flag.BoolVar(&query.Conflicts, "conflicts", false, "match images whose embedded metadata conflicts with the private log (requires -merge-log)")
+	flag.StringVar((*string)(&query.SizeChange), "size-change", "", "match images by how their size differs from the generated size: original, upscaled, downscaled, cropped or resized")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: [flags] <folder>")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "folder: The folder to index and search (required)\n")
+	}
+
+	flag.Parse()
+	setLogLevel(debug, verbose)
+
+	root := flag.Arg(0)
+
+	if root == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "lora-above" {
+			weight := float32(loraWeight)
+			query.LoraWeightAbove = &weight
+		}
+	})
+
+	var err error
+	if query.From, err = parseDate(from); err != nil {
+		fmt.Printf("Error: invalid -from date: %s\n", err)
+		os.Exit(1)
+	}
+	if query.To, err = parseDate(to); err != nil {
+		fmt.Printf("Error: invalid -to date: %s\n", err)
+		os.Exit(1)
+	} else if !query.To.IsZero() {
+		// Inclusive end date
+		query.To = query.To.AddDate(0, 0, 1)
+	}
+	if version != "" {
+		if query.Version, err = types.ParseVersionConstraint(version); err != nil {
+			fmt.Printf("Error: invalid -version: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var opts []catalog.Option
+	if store != "" {
+		opts = append(opts, catalog.WithStorePath(store))
+	}
+	if mergeLog {
+		opts = append(opts, catalog.WithExtractor(func(path string) (types.StructuredMetadata, error) {
+			return fooocusmeta.ExtractFromFile(path, fooocusmeta.WithPrivateLog(types.PrivateLogMerge))
+		}))
+	}
+
+	search(root, query, opts, !noUpdate, compact, asJson)
+}
+
+func search(root string, query catalog.Query, opts []catalog.Option, update bool, compact bool, asJson bool) {
+
+	cat, err := catalog.Open(root, opts...)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(2)
+	}
+
+	if update {
+		stats, err := cat.Update()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(2)
+		}
+		slog.Info("Catalog updated", "added", stats.Added, "updated", stats.Updated, "removed", stats.Removed, "failed", stats.Failed, "skipped", stats.Skipped)
+	}
+
+	if compact {
+		if err := cat.Compact(); err != nil {
+			fmt.Printf("Error: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, entry := range cat.Find(query) {
+		if asJson {
+			encoder.Encode(entry)
+		} else {
+			fmt.Println(filepath.Join(root, filepath.FromSlash(entry.Path)))
+		}
+	}
+}
+
+// parseDate parses a date in local time, or returns the zero time if empty.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(dateLayout, value, time.Local)
+}
+
+func setLogLevel(debug bool, verbose bool) {
+	if debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	} else if verbose {
+		slog.SetLogLoggerLevel(slog.LevelInfo)
+	} else {
+		slog.SetLogLoggerLevel(slog.LevelWarn)
+	}
+}
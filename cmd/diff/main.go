@@ -0,0 +1,129 @@
+// A command-line tool to compare the image generation parameters
+// of two image files.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	_ "github.com/fkleon/fooocus-metadata/fooocus"
+	_ "github.com/fkleon/fooocus-metadata/fooocusplus"
+	_ "github.com/fkleon/fooocus-metadata/fork"
+	_ "github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	_ "github.com/fkleon/fooocus-metadata/stablediffusion"
+	_ "github.com/fkleon/fooocus-metadata/xmp"
+
+	fooocusmeta "github.com/fkleon/fooocus-metadata"
+	"github.com/fkleon/fooocus-metadata/diff"
+)
+
+func main() {
+
+	var debug, verbose, asJson, raw bool
+
+	flag.BoolVar(&verbose, "verbose", false, "enable verbose logging")
+	flag.BoolVar(&debug, "debug", false, "enable debug logging")
+	flag.BoolVar(&asJson, "json", false, "print the differences in JSON format")
+	flag.BoolVar(&raw, "raw", false, "include differences of the raw metadata fields")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: [flags] <path a> <path b>")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "path: The files to compare metadata of (required)\n")
+	}
+
+	flag.Parse()
+	setLogLevel(debug, verbose)
+
+	pathA, pathB := flag.Arg(0), flag.Arg(1)
+
+	if pathA == "" || pathB == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	compare(pathA, pathB, asJson, raw)
+}
+
+func compare(pathA string, pathB string, asJson bool, raw bool) {
+
+	a, err := fooocusmeta.ExtractFromFile(pathA)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %s\n", pathA, err)
+		os.Exit(2)
+	}
+	b, err := fooocusmeta.ExtractFromFile(pathB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %s\n", pathB, err)
+		os.Exit(2)
+	}
+
+	result := diff.Compare(a.Params, b.Params)
+	if !raw {
+		result.Raw = nil
+	}
+
+	if asJson {
+		out, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			os.Exit(2)
+		}
+		fmt.Println(string(out))
+	} else {
+		printResult(result)
+	}
+
+	if !result.Equal() {
+		os.Exit(3)
+	}
+}
+
+func printResult(result diff.Result) {
+	if result.Equal() {
+		fmt.Println("No differences found")
+		return
+	}
+
+	var printWords = func(name string, edits []diff.Edit) {
+		if edits != nil {
+			fmt.Printf("%s: %s\n", name, diff.FormatWords(edits))
+		}
+	}
+
+	printWords("prompt", result.Prompt)
+	printWords("negative_prompt", result.NegativePrompt)
+	printWords("full_prompt", result.FullPrompt)
+	printWords("full_negative_prompt", result.FullNegativePrompt)
+
+	for _, change := range result.Fields {
+		fmt.Printf("%s: %s -> %s\n", change.Field, change.A, change.B)
+	}
+
+	for _, lora := range result.LoRAs {
+		switch lora.Kind {
+		case diff.LoraAdded:
+			fmt.Printf("lora: + %s (%g)\n", lora.Name, lora.WeightB)
+		case diff.LoraRemoved:
+			fmt.Printf("lora: - %s (%g)\n", lora.Name, lora.WeightA)
+		case diff.LoraReweighted:
+			fmt.Printf("lora: ~ %s (%g -> %g)\n", lora.Name, lora.WeightA, lora.WeightB)
+		}
+	}
+
+	for _, change := range result.Raw {
+		fmt.Printf("raw.%s: %s -> %s\n", change.Field, change.A, change.B)
+	}
+}
+
+func setLogLevel(debug bool, verbose bool) {
+	if debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	} else if verbose {
+		slog.SetLogLoggerLevel(slog.LevelInfo)
+	} else {
+		slog.SetLogLoggerLevel(slog.LevelWarn)
+	}
+}
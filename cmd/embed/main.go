@@ -3,27 +3,40 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 
 	"github.com/fkleon/fooocus-metadata/fooocus"
 	"github.com/fkleon/fooocus-metadata/fooocusplus"
+	"github.com/fkleon/fooocus-metadata/label"
 	"github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	"github.com/fkleon/fooocus-metadata/types"
+	"github.com/fkleon/fooocus-metadata/xmp"
 )
 
 func main() {
 
 	var debug, verbose bool
-	var embedType, embedIn, embedOut string
+	var embedType, embedIn, embedOut, embedXmp, sidecarStyle string
+	var labelSource string
+	var imageLabel types.Label
 
 	flag.BoolVar(&verbose, "verbose", false, "enable verbose logging")
 	flag.BoolVar(&debug, "debug", false, "enable debug logging")
 	flag.StringVar(&embedType, "type", "fooocus", "the type of metadata to embed (fooocus, fooocusplus, ruinedfooocus)")
 	flag.StringVar(&embedIn, "in", "", "the file to read imagedata from (optional)")
 	flag.StringVar(&embedOut, "out", "", "the file to write metadata to (required)")
+	flag.StringVar(&embedXmp, "xmp", "", "also write the parameters as XMP (sidecar, embed, both)")
+	flag.StringVar(&sidecarStyle, "sidecar", "append", "the name of the XMP sidecar (append: image.png.xmp, replace: image.xmp)")
+	flag.StringVar(&labelSource, "label", "", "label the image as AI-generated (trained, composite)")
+	flag.StringVar(&imageLabel.Artist, "artist", "", "the author to label the image with")
+	flag.StringVar(&imageLabel.Copyright, "copyright", "", "the copyright notice or licence to label the image with")
+	flag.StringVar(&imageLabel.Description, "description", "", "the description to label the image with")
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "usage: [flags] | echo '<meta>'")
 		flag.PrintDefaults()
@@ -37,8 +50,40 @@ func main() {
 		flag.Usage()
 		os.Exit(1)
 	}
+	switch embedXmp {
+	case "", "sidecar", "embed", "both":
+	default:
+		fmt.Printf("Unknown XMP mode: %s\n", embedXmp)
+		os.Exit(1)
+	}
+
+	var style xmp.SidecarStyle
+	switch sidecarStyle {
+	case "append":
+		style = xmp.SidecarAppend
+	case "replace":
+		style = xmp.SidecarReplace
+	default:
+		fmt.Printf("Unknown sidecar style: %s\n", sidecarStyle)
+		os.Exit(1)
+	}
+
+	switch labelSource {
+	case "":
+	case "trained":
+		imageLabel.DigitalSourceType = types.DigitalSourceTrainedAlgorithmicMedia
+	case "composite":
+		imageLabel.DigitalSourceType = types.DigitalSourceCompositeWithTrainedAlgorithmicMedia
+	default:
+		fmt.Printf("Unknown label: %s\n", labelSource)
+		os.Exit(1)
+	}
+	var labelling *types.Label
+	if imageLabel != (types.Label{}) {
+		labelling = &imageLabel
+	}
 
-	err := embed(embedType, embedIn, embedOut)
+	err := embed(embedType, embedIn, embedOut, embedXmp, style, labelling)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err)
 		os.Exit(2)
@@ -46,7 +91,7 @@ func main() {
 	fmt.Printf("Metadata successfully embedded into %s\n", embedOut)
 }
 
-func embed(t string, in string, out string) (err error) {
+func embed(t string, in string, out string, xmpMode string, style xmp.SidecarStyle, labelling *types.Label) (err error) {
 
 	var source, target *os.File
 
@@ -64,38 +109,38 @@ func embed(t string, in string, out string) (err error) {
 	}
 	defer target.Close()
 
+	var payload bytes.Buffer
+	var params types.GenerationParameters
+
 	switch t {
 	case "fooocus":
 		if metadata, err := readMetadataFromStdin[fooocus.Metadata](); err != nil {
 			return fmt.Errorf("failed to unmarshal metadata: %w", err)
 		} else {
-			writer := fooocus.NewFooocusMetadataWriter()
-			if in != "" {
-				return writer.CopyWrite(source, target, metadata)
-			} else {
-				return writer.Write(target, metadata)
+			params = fooocus.Parameters{Metadata: metadata}
+			err = write(fooocus.NewFooocusMetadataWriter(), source, &payload, metadata)
+			if err != nil {
+				return err
 			}
 		}
 	case "fooocusplus":
 		if metadata, err := readMetadataFromStdin[fooocusplus.Metadata](); err != nil {
 			return fmt.Errorf("failed to unmarshal metadata: %w", err)
 		} else {
-			writer := fooocusplus.NewFooocusPlusMetadataWriter()
-			if in != "" {
-				return writer.CopyWrite(source, target, metadata)
-			} else {
-				return writer.Write(target, metadata)
+			params = fooocusplus.Parameters{Metadata: metadata}
+			err = write(fooocusplus.NewFooocusPlusMetadataWriter(), source, &payload, metadata)
+			if err != nil {
+				return err
 			}
 		}
 	case "ruinedfooocus":
 		if metadata, err := readMetadataFromStdin[ruinedfooocus.Metadata](); err != nil {
 			return fmt.Errorf("failed to unmarshal metadata: %w", err)
 		} else {
-			writer := ruinedfooocus.NewRuinedFooocusMetadataWriter()
-			if in != "" {
-				return writer.CopyWrite(source, target, metadata)
-			} else {
-				return writer.Write(target, metadata)
+			params = ruinedfooocus.Parameters{Metadata: metadata}
+			err = write(ruinedfooocus.NewRuinedFooocusMetadataWriter(), source, &payload, metadata)
+			if err != nil {
+				return err
 			}
 		}
 	default:
@@ -103,9 +148,42 @@ func embed(t string, in string, out string) (err error) {
 		os.Exit(1)
 	}
 
+	embedXmp := xmpMode == "embed" || xmpMode == "both"
+	switch {
+	case labelling != nil:
+		// The software defaults to the version of the parameters
+		if labelling.Software == "" {
+			labelling.Software = params.Version()
+		}
+		var opts []label.Option
+		if embedXmp {
+			opts = append(opts, label.WithPacket(xmp.NewPacket(params)))
+		}
+		err = label.NewLabelWriter(opts...).CopyWrite(&payload, target, *labelling)
+	case embedXmp:
+		err = xmp.NewXMPMetadataWriter().CopyWrite(&payload, target, params)
+	default:
+		_, err = payload.WriteTo(target)
+	}
+	if err != nil {
+		return err
+	}
+
+	if xmpMode == "sidecar" || xmpMode == "both" {
+		return xmp.WriteSidecar(out, style, params)
+	}
 	return nil
 }
 
+// write writes the metadata into the source image, or into
+// the writer's template image if there is no source.
+func write[M any](writer types.Writer[M], source *os.File, target io.Writer, metadata M) error {
+	if source != nil {
+		return writer.CopyWrite(source, target, metadata)
+	}
+	return writer.Write(target, metadata)
+}
+
 func readMetadataFromStdin[M fooocus.Metadata | fooocusplus.Metadata | ruinedfooocus.Metadata]() (metadata M, err error) {
 	err = json.NewDecoder(os.Stdin).Decode(&metadata)
 	return metadata, err
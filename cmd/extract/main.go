@@ -1,37 +1,59 @@
 // A command-line tool to extract image generation parameters
-// from an image file or sidecar file.
+// from an image file or sidecar file, or from new images written
+// to one or more folders.
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	_ "github.com/fkleon/fooocus-metadata/fooocus"
 	_ "github.com/fkleon/fooocus-metadata/fooocusplus"
+	_ "github.com/fkleon/fooocus-metadata/fork"
 	_ "github.com/fkleon/fooocus-metadata/ruinedfooocus"
 	_ "github.com/fkleon/fooocus-metadata/stablediffusion"
+	_ "github.com/fkleon/fooocus-metadata/xmp"
 
 	fooocusmeta "github.com/fkleon/fooocus-metadata"
+	"github.com/fkleon/fooocus-metadata/types"
+	"github.com/fkleon/fooocus-metadata/watch"
 )
 
 func main() {
 
-	var debug, verbose bool
+	var debug, verbose, watchMode, mergeLog bool
 
 	flag.BoolVar(&verbose, "verbose", false, "enable verbose logging")
 	flag.BoolVar(&debug, "debug", false, "enable debug logging")
+	flag.BoolVar(&watchMode, "watch", false, "watch folders for new images and print their metadata as NDJSON")
+	flag.BoolVar(&mergeLog, "merge-log", false, "merge embedded metadata with the private log and report conflicts on stderr")
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "usage: [flags] <path>")
+		fmt.Fprintln(os.Stderr, "       -watch [flags] <folder>...")
 		flag.PrintDefaults()
 		fmt.Fprintf(os.Stderr, "path: The file to read metadata from (required)\n")
+		fmt.Fprintf(os.Stderr, "folder: The folders to watch for new images (required with -watch)\n")
 	}
 
 	flag.Parse()
 	setLogLevel(debug, verbose)
 
+	if watchMode {
+		if flag.NArg() == 0 {
+			flag.Usage()
+			os.Exit(1)
+		}
+		watchFolders(flag.Args())
+		return
+	}
+
 	path := flag.Arg(0)
 
 	if path == "" {
@@ -39,12 +61,17 @@ func main() {
 		os.Exit(1)
 	}
 
-	extract(path)
+	extract(path, mergeLog)
 }
 
-func extract(path string) {
+func extract(path string, mergeLog bool) {
 
-	if metadata, err := fooocusmeta.ExtractFromFile(path); err != nil {
+	var opts []fooocusmeta.Option
+	if mergeLog {
+		opts = append(opts, fooocusmeta.WithPrivateLog(types.PrivateLogMerge))
+	}
+
+	if metadata, err := fooocusmeta.ExtractFromFile(path, opts...); err != nil {
 		fmt.Printf("Error: %s\n", err)
 		os.Exit(2)
 	} else {
@@ -52,6 +79,67 @@ func extract(path string) {
 		if err == nil {
 			fmt.Print(string(out))
 		}
+		if metadata.Merge != nil {
+			printMerge(*metadata.Merge)
+		}
+	}
+}
+
+// printMerge prints the fields filled from the private
+// log and the conflicting fields to stderr.
+func printMerge(report types.MergeReport) {
+	if len(report.Filled) > 0 {
+		fmt.Fprintf(os.Stderr, "Filled from %s: %s\n", report.Provenance.Path, strings.Join(report.Filled, ", "))
+	}
+	for _, conflict := range report.Conflicts {
+		fmt.Fprintf(os.Stderr, "Conflict: %s: embedded %q, %s %q\n", conflict.Field, conflict.Primary, report.Provenance.Path, conflict.Secondary)
+	}
+}
+
+// Event is the NDJSON representation of a watch event.
+type Event struct {
+	Path    string     `json:"path"`
+	Source  string     `json:"source,omitempty"`
+	Created *time.Time `json:"created,omitempty"`
+	// Source of the creation time, e.g. "filename" or "exif"
+	CreatedSource string `json:"created_source,omitempty"`
+	// Where the metadata was found, e.g. embedded or in the private log
+	Provenance types.Provenance `json:"provenance,omitzero"`
+	// Labelling as AI-generated and attribution, e.g. the artist
+	Label    *types.Label `json:"label,omitempty"`
+	Metadata any          `json:"metadata,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+func watchFolders(dirs []string) {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	events, err := watch.Watch(ctx, dirs)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(2)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for event := range events {
+		out := Event{Path: event.Path}
+		if event.Err != nil {
+			out.Error = event.Err.Error()
+		} else {
+			out.Source = event.Metadata.Source
+			out.Provenance = event.Metadata.Provenance
+			out.Label = event.Metadata.Label
+			if !event.Metadata.Created.IsZero() {
+				out.Created = &event.Metadata.Created
+				out.CreatedSource = string(event.Metadata.CreatedSource)
+			}
+			out.Metadata = event.Metadata.Params.Raw()
+		}
+		if err := encoder.Encode(out); err != nil {
+			slog.Error("Failed to write event", "path", event.Path, "err", err)
+		}
 	}
 }
 
@@ -0,0 +1,68 @@
+// A command-line tool to run a local HTTP service for
+// extracting and embedding image generation parameters.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	_ "github.com/fkleon/fooocus-metadata/fooocus"
+	_ "github.com/fkleon/fooocus-metadata/fooocusplus"
+	_ "github.com/fkleon/fooocus-metadata/fork"
+	_ "github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	_ "github.com/fkleon/fooocus-metadata/stablediffusion"
+	_ "github.com/fkleon/fooocus-metadata/xmp"
+
+	"github.com/fkleon/fooocus-metadata/server"
+)
+
+func main() {
+
+	var debug, verbose, pathHints bool
+	var addr string
+	var maxUploadSize int64
+
+	flag.BoolVar(&verbose, "verbose", false, "enable verbose logging")
+	flag.BoolVar(&debug, "debug", false, "enable debug logging")
+	flag.StringVar(&addr, "addr", "localhost:8080", "the address to listen on")
+	flag.Int64Var(&maxUploadSize, "max-upload-size", server.DefaultMaxUploadSize, "the maximum size of uploads in bytes")
+	flag.BoolVar(&pathHints, "path-hints", false, "allow clients to pass the original file path of images (trusted clients only)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: [flags]")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	setLogLevel(debug, verbose)
+
+	handler := server.New(
+		server.WithMaxUploadSize(maxUploadSize),
+		server.WithPathHints(pathHints),
+	)
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Listening on %s\n", addr)
+	if err := srv.ListenAndServe(); err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(2)
+	}
+}
+
+func setLogLevel(debug bool, verbose bool) {
+	if debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	} else if verbose {
+		slog.SetLogLoggerLevel(slog.LevelInfo)
+	} else {
+		slog.SetLogLoggerLevel(slog.LevelWarn)
+	}
+}
@@ -0,0 +1,171 @@
+// Package convert implements conversions of image generation parameters
+// between the metadata formats of different tools.
+//
+// Each conversion returns the converted metadata together with a [Report]
+// listing the fields that could not be carried over exactly:
+//
+//	meta, report := convert.FooocusToStableDiffusion(fooocusMeta)
+//	for _, field := range report.Dropped {
+//		fmt.Println("dropped", field.Name, field.Reason)
+//	}
+//
+// Conversions between Fooocus and any other format are implemented directly.
+// Conversions between two non-Fooocus formats use the Fooocus metadata as
+// intermediate representation, so the report of the second step refers to
+// Fooocus field names.
+//
+// The readers identify the software by the recorded version, so converted
+// metadata records a version of the target software instead of the source
+// version, and reports the version as approximated.
+package convert
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/fooocusplus"
+	"github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	"github.com/fkleon/fooocus-metadata/stablediffusion"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Values used by Fooocus to indicate that no refiner or
+// custom VAE was selected.
+const (
+	noRefiner  = "None"
+	defaultVae = "Default (model)"
+)
+
+// Versions recorded in converted metadata.
+const (
+	fooocusVersion       = "Fooocus v2.5.5"
+	fooocusPlusVersion   = "FooocusPlus 1.0.0"
+	ruinedFooocusVersion = ruinedfooocus.Software
+)
+
+// Field describes a metadata field that was affected by a conversion.
+type Field struct {
+	// Name of the field in the source metadata struct.
+	Name string
+	// Reason why the field was dropped or approximated.
+	Reason string
+}
+
+// Report lists the fields that could not be converted exactly.
+type Report struct {
+	// Fields with a value in the source metadata that have no
+	// equivalent in the target metadata.
+	Dropped []Field
+	// Fields that were converted, but whose value in the target
+	// metadata is only an approximation of the source value.
+	Approximated []Field
+}
+
+// Lossless returns true if no fields were dropped or approximated.
+func (r Report) Lossless() bool {
+	return len(r.Dropped) == 0 && len(r.Approximated) == 0
+}
+
+// drop records a dropped field, unless its value is the zero value.
+func (r *Report) drop(name string, value any, reason string) {
+	if isZero(value) {
+		return
+	}
+	r.Dropped = append(r.Dropped, Field{name, reason})
+}
+
+// approximate records an approximated field.
+func (r *Report) approximate(name string, reason string) {
+	r.Approximated = append(r.Approximated, Field{name, reason})
+}
+
+// merge appends the fields of other, skipping duplicates.
+func (r *Report) merge(other Report) {
+	for _, f := range other.Dropped {
+		if !slices.Contains(r.Dropped, f) {
+			r.Dropped = append(r.Dropped, f)
+		}
+	}
+	for _, f := range other.Approximated {
+		if !slices.Contains(r.Approximated, f) {
+			r.Approximated = append(r.Approximated, f)
+		}
+	}
+}
+
+// replaceVersion returns the version of the target software, and records
+// the version as approximated unless it was not set.
+func replaceVersion(version string, target string, report *Report) string {
+	if version != "" && version != target {
+		report.approximate("Version", "replaced by the version of the target software")
+	}
+	return target
+}
+
+func isZero(value any) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// parseSeed converts a string-encoded Fooocus seed to an int.
+func parseSeed(seed string, report *Report) int {
+	if seed == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(seed)
+	if err != nil {
+		report.drop("Seed", seed, "not a valid integer")
+		return 0
+	}
+	if value < 0 || uint64(value) > fooocus.Seeds.Max {
+		report.drop("Seed", seed, "out of range")
+		return 0
+	}
+	return value
+}
+
+// formatSeed converts a seed of a tool with the given seed range to a
+// Fooocus seed. Random seeds are dropped, as Fooocus records the seed
+// of each image.
+func formatSeed(seed int, seeds types.SeedRange, report *Report) string {
+	parsed, err := seeds.Parse(strconv.Itoa(seed))
+	switch {
+	case err != nil:
+		report.drop("Seed", seed, "out of range")
+	case parsed.Random:
+		report.drop("Seed", seed, "random seed")
+	default:
+		return parsed.String()
+	}
+	return ""
+}
+
+// ToFooocus converts the raw metadata of any supported tool to Fooocus
+// metadata, which serves as canonical representation.
+//
+// The boolean result is false if the type of raw is not supported.
+func ToFooocus(raw any) (meta fooocus.Metadata, report Report, ok bool) {
+	switch r := raw.(type) {
+	case fooocus.Metadata:
+		return r, report, true
+	case fooocusplus.Metadata:
+		meta, report = FooocusPlusToFooocus(r)
+	case ruinedfooocus.Metadata:
+		meta, report = RuinedFooocusToFooocus(r)
+	case stablediffusion.Metadata:
+		meta, report = StableDiffusionToFooocus(r)
+	default:
+		return meta, report, false
+	}
+	return meta, report, true
+}
@@ -0,0 +1,297 @@
+package convert
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	metadata "github.com/fkleon/fooocus-metadata"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/fooocusplus"
+	"github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	"github.com/fkleon/fooocus-metadata/stablediffusion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fooocusMeta = fooocus.Metadata{
+	AdmGuidance:   fooocus.AdmGuidanceOf(1.5, 0.8, 0.3),
+	BaseModel:     "juggernautXL_v8Rundiffusion",
+	BaseModelHash: "aeb7e9e689",
+	ClipSkip:      2,
+	GuidanceScale: 4,
+	Loras: []fooocus.Lora{{
+		Name:   "sd_xl_offset_example-lora_1.0",
+		Weight: 0.1,
+		Hash:   "4852686128",
+	}},
+	LoraCombined1:   &fooocus.LoraCombined{Name: "sd_xl_offset_example-lora_1.0", Weight: 0.1},
+	MetadataScheme:  "fooocus",
+	Performance:     "Speed",
+	Prompt:          "A sunflower field",
+	PromptExpansion: "A sunflower field, highly detailed",
+	RefinerModel:    "None",
+	RefinerSwitch:   0.5,
+	Resolution:      fooocus.ResolutionOf(1152, 896),
+	Sampler:         "dpmpp_2m_sde_gpu",
+	Scheduler:       "karras",
+	Seed:            "127589946317439009",
+	Sharpness:       2,
+	Steps:           30,
+	Styles:          fooocus.Styles{"Fooocus V2", "Fooocus Enhance"},
+	Vae:             "Default (model)",
+	Version:         "Fooocus v2.5.5",
+}
+
+var versionReplaced = Field{"Version", "replaced by the version of the target software"}
+
+func TestFooocusToStableDiffusion(t *testing.T) {
+	out, report := FooocusToStableDiffusion(fooocusMeta)
+
+	assert.Equal(t, stablediffusion.Metadata{
+		CfgScale:  4,
+		ClipSkip:  2,
+		Loras:     stablediffusion.Loras{{Name: "sd_xl_offset_example-lora_1.0", Weight: 0.1}},
+		Model:     "juggernautXL_v8Rundiffusion",
+		ModelHash: "aeb7e9e689",
+		Prompt:    "A sunflower field <lora:sd_xl_offset_example-lora_1.0:0.1>",
+		Sampler:   "DPM++ 2M SDE Karras",
+		Seed:      127589946317439009,
+		Size:      &stablediffusion.Size{Width: 1152, Height: 896},
+		Steps:     30,
+		Version:   "Fooocus v2.5.5",
+	}, out)
+
+	assert.ElementsMatch(t, []Field{
+		{"Loras.Hash", "not supported by A1111"},
+		{"AdmGuidance", "not supported by A1111"},
+		{"Performance", "not supported by A1111"},
+		{"PromptExpansion", "not supported by A1111"},
+		{"Sharpness", "not supported by A1111"},
+		{"Styles", "not supported by A1111"},
+	}, report.Dropped)
+	assert.Equal(t, []Field{
+		{"Loras", "referenced in prompt"},
+	}, report.Approximated)
+}
+
+func TestFooocusToStableDiffusion_Refiner(t *testing.T) {
+	in := fooocusMeta
+	in.RefinerModel = "sd_xl_refiner_1.0.safetensors"
+	in.RefinerSwitch = 0.8
+
+	_, report := FooocusToStableDiffusion(in)
+	assert.Contains(t, report.Dropped, Field{"RefinerModel", "not supported by A1111"})
+	assert.Contains(t, report.Dropped, Field{"RefinerSwitch", "not supported by A1111"})
+
+	_, report = FooocusToRuinedFooocus(in)
+	assert.Contains(t, report.Dropped, Field{"RefinerModel", "not supported by RuinedFooocus"})
+	assert.Contains(t, report.Dropped, Field{"RefinerSwitch", "not supported by RuinedFooocus"})
+}
+
+func TestStableDiffusionToFooocus(t *testing.T) {
+	in, err := stablediffusion.ParseParameters("A sunflower field <lora:offset:0.5>\nNegative prompt: blurry\nSteps: 20, Sampler: DPM++ 2M Karras, CFG scale: 7, Seed: 42, Size: 640x480, Model hash: 53d4559a, Model: elldrethSLucidMix_v10, Denoising strength: 0.3, Version: v1.10.1")
+	require.NoError(t, err)
+
+	out, report := StableDiffusionToFooocus(in)
+
+	assert.Equal(t, "A sunflower field", out.Prompt)
+	assert.Equal(t, "blurry", out.NegativePrompt)
+	assert.Equal(t, "dpmpp_2m", out.Sampler)
+	assert.Equal(t, "karras", out.Scheduler)
+	assert.Equal(t, "42", out.Seed)
+	assert.Equal(t, uint8(20), out.Steps)
+	assert.Equal(t, float32(7), out.GuidanceScale)
+	assert.Equal(t, fooocus.ResolutionOf(640, 480), out.Resolution)
+	assert.Equal(t, "elldrethSLucidMix_v10", out.BaseModel)
+	assert.Equal(t, []fooocus.Lora{{Name: "offset", Weight: 0.5}}, out.Loras)
+	assert.Equal(t, &fooocus.LoraCombined{Name: "offset", Weight: 0.5}, out.LoraCombined1)
+
+	assert.Equal(t, []Field{
+		{"DenoisingStrength", "not supported by Fooocus"},
+	}, report.Dropped)
+	assert.Equal(t, []Field{versionReplaced}, report.Approximated)
+	assert.Equal(t, "Fooocus v2.5.5", out.Version)
+}
+
+func TestFooocusPlusRoundTrip(t *testing.T) {
+	plus, report := FooocusToFooocusPlus(fooocusMeta)
+	assert.Empty(t, report.Dropped)
+	assert.Equal(t, []Field{versionReplaced}, report.Approximated)
+	assert.Equal(t, "Fooocus", plus.MetadataScheme)
+	assert.Equal(t, "FooocusPlus 1.0.0", plus.Version)
+	assert.Equal(t, fooocusMeta.PromptExpansion, plus.FooocusV2Expansion)
+
+	out, report := FooocusPlusToFooocus(plus)
+	assert.Empty(t, report.Dropped)
+	assert.Equal(t, []Field{versionReplaced}, report.Approximated)
+	assert.Equal(t, fooocusMeta, out)
+}
+
+func TestFooocusPlusDropped(t *testing.T) {
+	in := fooocusplus.Metadata{
+		BackendEngine: "SDXL-Fooocus",
+		User:          "FooocusPlus",
+	}
+	out, report := FooocusPlusToFooocus(in)
+	assert.Equal(t, "FooocusPlus", out.CreatedBy)
+	assert.Equal(t, []Field{
+		{"BackendEngine", "not supported by Fooocus"},
+	}, report.Dropped)
+}
+
+func TestRuinedFooocusToFooocus(t *testing.T) {
+	in := ruinedfooocus.Metadata{
+		BaseModel:     "sd_xl_base_1.0_0.9vae.safetensors",
+		BaseModelHash: "be9edd61",
+		CfgScale:      8.5,
+		ClipSkip:      1,
+		Height:        896,
+		Width:         1152,
+		Loras: []ruinedfooocus.Lora{{
+			Hash:   "4852686128",
+			Name:   "sd_xl_offset_example-lora_1.0.safetensors",
+			Weight: 0.1,
+		}},
+		Prompt:    "A sunflower field",
+		Sampler:   "dpmpp_2m_sde_gpu",
+		Scheduler: "karras",
+		Seed:      3864674281,
+		Steps:     30,
+		Version:   "RuinedFooocus",
+	}
+
+	out, report := RuinedFooocusToFooocus(in)
+	assert.Equal(t, "3864674281", out.Seed)
+	assert.Equal(t, uint16(1152), out.Resolution.Width())
+	assert.Equal(t, uint16(896), out.Resolution.Height())
+	assert.Equal(t, []fooocus.Lora{{
+		Name:   "sd_xl_offset_example-lora_1.0.safetensors",
+		Weight: 0.1,
+		Hash:   "4852686128",
+	}}, out.Loras)
+	assert.Equal(t, []Field{
+		{"BaseModelHash", "incompatible hash format"},
+	}, report.Dropped)
+
+	assert.Equal(t, []Field{versionReplaced}, report.Approximated)
+
+	back, report := FooocusToRuinedFooocus(out)
+	assert.Empty(t, report.Dropped)
+	assert.Equal(t, []Field{versionReplaced}, report.Approximated)
+	in.BaseModelHash = ""
+	assert.Equal(t, in, back)
+}
+
+func TestRuinedFooocusToStableDiffusion(t *testing.T) {
+	in := ruinedfooocus.Metadata{
+		Prompt:    "A sunflower field",
+		Sampler:   "euler_ancestral",
+		Scheduler: "normal",
+		StartStep: 2,
+		Seed:      1,
+	}
+
+	out, report := RuinedFooocusToStableDiffusion(in)
+	assert.Equal(t, "Euler a", out.Sampler)
+	assert.Equal(t, 1, out.Seed)
+	assert.Equal(t, []Field{
+		{"StartStep", "not supported by Fooocus"},
+	}, report.Dropped)
+}
+
+func TestInvalidSeed(t *testing.T) {
+	in := fooocusMeta
+	in.Seed = "not a number"
+
+	out, report := FooocusToRuinedFooocus(in)
+	assert.Zero(t, out.Seed)
+	assert.Contains(t, report.Dropped, Field{"Seed", "not a valid integer"})
+}
+
+func TestRandomSeed(t *testing.T) {
+	out, report := StableDiffusionToFooocus(stablediffusion.Metadata{Sampler: "Euler a", Seed: -1})
+	assert.Empty(t, out.Seed)
+	assert.Contains(t, report.Dropped, Field{"Seed", "random seed"})
+
+	in := fooocusMeta
+	in.Seed = "-1"
+	_, report = FooocusToRuinedFooocus(in)
+	assert.Contains(t, report.Dropped, Field{"Seed", "out of range"})
+}
+
+func TestToFooocus(t *testing.T) {
+	meta, report, ok := ToFooocus(fooocusMeta)
+	assert.True(t, ok)
+	assert.True(t, report.Lossless())
+	assert.Equal(t, fooocusMeta, meta)
+
+	meta, _, ok = ToFooocus(stablediffusion.Metadata{Sampler: "Euler a", Seed: 42})
+	assert.True(t, ok)
+	assert.Equal(t, "euler_ancestral", meta.Sampler)
+	assert.Equal(t, "42", meta.Seed)
+
+	_, _, ok = ToFooocus("unsupported")
+	assert.False(t, ok)
+}
+
+// Converted metadata is read by the reader of the target software
+// after it was written by its writer.
+func TestWriteConverted(t *testing.T) {
+	plusMeta, _ := FooocusToFooocusPlus(fooocusMeta)
+	ruinedMeta, _ := FooocusToRuinedFooocus(fooocusMeta)
+	sdMeta, _ := FooocusToStableDiffusion(fooocusMeta)
+
+	var toFooocus = func(meta fooocus.Metadata, _ Report) fooocus.Metadata { return meta }
+	var toPlus = func(meta fooocusplus.Metadata, _ Report) fooocusplus.Metadata { return meta }
+	var toRuined = func(meta ruinedfooocus.Metadata, _ Report) ruinedfooocus.Metadata { return meta }
+
+	t.Run("Fooocus", func(t *testing.T) {
+		for name, meta := range map[string]fooocus.Metadata{
+			"FooocusPlus":   toFooocus(FooocusPlusToFooocus(plusMeta)),
+			"RuinedFooocus": toFooocus(RuinedFooocusToFooocus(ruinedMeta)),
+			"A1111":         toFooocus(StableDiffusionToFooocus(sdMeta)),
+		} {
+			assertReads(t, name, fooocus.Software, meta.Prompt, func(w io.Writer) error {
+				return fooocus.NewFooocusMetadataWriter().Write(w, meta)
+			})
+		}
+	})
+
+	t.Run("FooocusPlus", func(t *testing.T) {
+		for name, meta := range map[string]fooocusplus.Metadata{
+			"Fooocus":       plusMeta,
+			"RuinedFooocus": toPlus(RuinedFooocusToFooocusPlus(ruinedMeta)),
+			"A1111":         toPlus(StableDiffusionToFooocusPlus(sdMeta)),
+		} {
+			assertReads(t, name, fooocusplus.Software, meta.Prompt, func(w io.Writer) error {
+				return fooocusplus.NewFooocusPlusMetadataWriter().Write(w, meta)
+			})
+		}
+	})
+
+	t.Run("RuinedFooocus", func(t *testing.T) {
+		for name, meta := range map[string]ruinedfooocus.Metadata{
+			"Fooocus":     ruinedMeta,
+			"FooocusPlus": toRuined(FooocusPlusToRuinedFooocus(plusMeta)),
+			"A1111":       toRuined(StableDiffusionToRuinedFooocus(sdMeta)),
+		} {
+			assertReads(t, name, ruinedfooocus.Software, meta.Prompt, func(w io.Writer) error {
+				return ruinedfooocus.NewRuinedFooocusMetadataWriter().Write(w, meta)
+			})
+		}
+	})
+}
+
+func assertReads(t *testing.T, name string, software string, prompt string, write func(io.Writer) error) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, write(&buf), name)
+
+	meta, err := metadata.ExtractFromReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err, name)
+	assert.Equal(t, software, meta.Source, name)
+	assert.Equal(t, prompt, meta.Params.PositivePrompt(), name)
+}
@@ -0,0 +1,137 @@
+package convert
+
+import (
+	"slices"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/fooocusplus"
+	"github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	"github.com/fkleon/fooocus-metadata/stablediffusion"
+)
+
+// FooocusToFooocusPlus converts Fooocus metadata to FooocusPlus metadata.
+func FooocusToFooocusPlus(in fooocus.Metadata) (out fooocusplus.Metadata, report Report) {
+	out = fooocusplus.Metadata{
+		AdmGuidance:        in.AdmGuidance,
+		BaseModel:          in.BaseModel,
+		BaseModelHash:      in.BaseModelHash,
+		ClipSkip:           in.ClipSkip,
+		FooocusV2Expansion: in.PromptExpansion,
+		FullNegativePrompt: slices.Clone(in.FullNegativePrompt),
+		FullPrompt:         slices.Clone(in.FullPrompt),
+		GuidanceScale:      in.GuidanceScale,
+		Loras:              slices.Clone(in.Loras),
+		MetadataScheme:     fooocusplus.Scheme,
+		NegativePrompt:     in.NegativePrompt,
+		Performance:        in.Performance,
+		Prompt:             in.Prompt,
+		RefinerModel:       in.RefinerModel,
+		RefinerModelHash:   in.RefinerModelHash,
+		RefinerSwapMethod:  in.RefinerSwapMethod,
+		RefinerSwitch:      in.RefinerSwitch,
+		Resolution:         in.Resolution,
+		Sampler:            in.Sampler,
+		Scheduler:          in.Scheduler,
+		Seed:               in.Seed,
+		Sharpness:          in.Sharpness,
+		Steps:              in.Steps,
+		Styles:             slices.Clone(in.Styles),
+		User:               in.CreatedBy,
+		Vae:                in.Vae,
+		Version:            replaceVersion(in.Version, fooocusPlusVersion, &report),
+	}
+
+	if out.Loras == nil {
+		out.Loras = []fooocus.Lora{}
+	}
+
+	report.drop("AdaptiveCfg", in.AdaptiveCfg, "not supported by FooocusPlus")
+	report.drop("FreeU", in.FreeU, "not supported by FooocusPlus")
+	report.drop("ImageNumber", in.ImageNumber, "not supported by FooocusPlus")
+	report.drop("Inpaint", in.Inpaint, "not supported by FooocusPlus")
+	report.drop("UpscaleOrVary", in.UpscaleOrVary, "not supported by FooocusPlus")
+	report.drop("ImagePrompts", in.ImagePrompts, "not supported by FooocusPlus")
+	report.drop("Enhance", in.Enhance, "not supported by FooocusPlus")
+
+	return out, report
+}
+
+// FooocusPlusToFooocus converts FooocusPlus metadata to Fooocus metadata.
+func FooocusPlusToFooocus(in fooocusplus.Metadata) (out fooocus.Metadata, report Report) {
+	out = fooocus.Metadata{
+		AdmGuidance:        in.AdmGuidance,
+		BaseModel:          in.BaseModel,
+		BaseModelHash:      in.BaseModelHash,
+		ClipSkip:           in.ClipSkip,
+		CreatedBy:          in.User,
+		FullNegativePrompt: slices.Clone(in.FullNegativePrompt),
+		FullPrompt:         slices.Clone(in.FullPrompt),
+		GuidanceScale:      in.GuidanceScale,
+		MetadataScheme:     fooocus.Fooocus.String(),
+		NegativePrompt:     in.NegativePrompt,
+		Performance:        in.Performance,
+		Prompt:             in.Prompt,
+		PromptExpansion:    in.FooocusV2Expansion,
+		RefinerModel:       in.RefinerModel,
+		RefinerModelHash:   in.RefinerModelHash,
+		RefinerSwapMethod:  in.RefinerSwapMethod,
+		RefinerSwitch:      in.RefinerSwitch,
+		Resolution:         in.Resolution,
+		Sampler:            in.Sampler,
+		Scheduler:          in.Scheduler,
+		Seed:               in.Seed,
+		Sharpness:          in.Sharpness,
+		Steps:              in.Steps,
+		Styles:             slices.Clone(in.Styles),
+		Vae:                in.Vae,
+		Version:            replaceVersion(in.Version, fooocusVersion, &report),
+	}
+	setFooocusLoras(&out, in.Loras)
+
+	report.drop("BackendEngine", in.BackendEngine, "not supported by Fooocus")
+	report.drop("StylesDefinition", in.StylesDefinition, "not supported by Fooocus")
+
+	return out, report
+}
+
+// FooocusPlusToRuinedFooocus converts FooocusPlus metadata to RuinedFooocus
+// metadata, using Fooocus metadata as intermediate representation.
+func FooocusPlusToRuinedFooocus(in fooocusplus.Metadata) (out ruinedfooocus.Metadata, report Report) {
+	intermediate, report := FooocusPlusToFooocus(in)
+	out, next := FooocusToRuinedFooocus(intermediate)
+	report.merge(next)
+	return out, report
+}
+
+// FooocusPlusToStableDiffusion converts FooocusPlus metadata to A1111-style
+// metadata, using Fooocus metadata as intermediate representation.
+func FooocusPlusToStableDiffusion(in fooocusplus.Metadata) (out stablediffusion.Metadata, report Report) {
+	intermediate, report := FooocusPlusToFooocus(in)
+	out, next := FooocusToStableDiffusion(intermediate)
+	report.merge(next)
+	return out, report
+}
+
+// setFooocusLoras sets the LoRAs on Fooocus metadata, including the
+// "lora_combined_N" fields written by Fooocus alongside.
+func setFooocusLoras(meta *fooocus.Metadata, loras []fooocus.Lora) {
+	meta.Loras = make([]fooocus.Lora, len(loras))
+	copy(meta.Loras, loras)
+
+	combined := []**fooocus.LoraCombined{
+		&meta.LoraCombined1,
+		&meta.LoraCombined2,
+		&meta.LoraCombined3,
+		&meta.LoraCombined4,
+		&meta.LoraCombined5,
+	}
+	for i, lora := range loras {
+		if i >= len(combined) {
+			break
+		}
+		*combined[i] = &fooocus.LoraCombined{
+			Name:   lora.Name,
+			Weight: lora.Weight,
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package convert
+
+import (
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/fooocusplus"
+	"github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	"github.com/fkleon/fooocus-metadata/stablediffusion"
+)
+
+// FooocusToRuinedFooocus converts Fooocus metadata to RuinedFooocus metadata.
+func FooocusToRuinedFooocus(in fooocus.Metadata) (out ruinedfooocus.Metadata, report Report) {
+	out = ruinedfooocus.Metadata{
+		BaseModel:      in.BaseModel,
+		CfgScale:       in.GuidanceScale,
+		ClipSkip:       in.ClipSkip,
+		Loras:          make([]ruinedfooocus.Lora, len(in.Loras)),
+		NegativePrompt: in.NegativePrompt,
+		Prompt:         in.Prompt,
+		Sampler:        in.Sampler,
+		Scheduler:      in.Scheduler,
+		Seed:           parseSeed(in.Seed, &report),
+		Steps:          in.Steps,
+		Version:        replaceVersion(in.Version, ruinedFooocusVersion, &report),
+	}
+
+	if in.Resolution != nil {
+		out.Width = in.Resolution.Width()
+		out.Height = in.Resolution.Height()
+	}
+
+	for i, lora := range in.Loras {
+		out.Loras[i] = ruinedfooocus.Lora(lora)
+	}
+
+	// RuinedFooocus uses a different model hash algorithm
+	report.drop("BaseModelHash", in.BaseModelHash, "incompatible hash format")
+	report.drop("AdaptiveCfg", in.AdaptiveCfg, "not supported by RuinedFooocus")
+	report.drop("AdmGuidance", in.AdmGuidance, "not supported by RuinedFooocus")
+	report.drop("CreatedBy", in.CreatedBy, "not supported by RuinedFooocus")
+	report.drop("FreeU", in.FreeU, "not supported by RuinedFooocus")
+	report.drop("FullNegativePrompt", in.FullNegativePrompt, "not supported by RuinedFooocus")
+	report.drop("FullPrompt", in.FullPrompt, "not supported by RuinedFooocus")
+	report.drop("ImageNumber", in.ImageNumber, "not supported by RuinedFooocus")
+	report.drop("Inpaint", in.Inpaint, "not supported by RuinedFooocus")
+	report.drop("UpscaleOrVary", in.UpscaleOrVary, "not supported by RuinedFooocus")
+	report.drop("ImagePrompts", in.ImagePrompts, "not supported by RuinedFooocus")
+	report.drop("Enhance", in.Enhance, "not supported by RuinedFooocus")
+	report.drop("Performance", in.Performance, "not supported by RuinedFooocus")
+	report.drop("PromptExpansion", in.PromptExpansion, "not supported by RuinedFooocus")
+	if in.RefinerModel != noRefiner {
+		report.drop("RefinerModel", in.RefinerModel, "not supported by RuinedFooocus")
+		report.drop("RefinerModelHash", in.RefinerModelHash, "not supported by RuinedFooocus")
+		report.drop("RefinerSwapMethod", in.RefinerSwapMethod, "not supported by RuinedFooocus")
+		report.drop("RefinerSwitch", in.RefinerSwitch, "not supported by RuinedFooocus")
+	}
+	report.drop("Sharpness", in.Sharpness, "not supported by RuinedFooocus")
+	report.drop("Styles", in.Styles, "not supported by RuinedFooocus")
+	if in.Vae != defaultVae {
+		out.Vae = in.Vae
+	}
+
+	return out, report
+}
+
+// RuinedFooocusToFooocus converts RuinedFooocus metadata to Fooocus metadata.
+func RuinedFooocusToFooocus(in ruinedfooocus.Metadata) (out fooocus.Metadata, report Report) {
+	out = fooocus.Metadata{
+		BaseModel:      in.BaseModel,
+		ClipSkip:       in.ClipSkip,
+		GuidanceScale:  in.CfgScale,
+		MetadataScheme: fooocus.Fooocus.String(),
+		NegativePrompt: in.NegativePrompt,
+		Prompt:         in.Prompt,
+		RefinerModel:   noRefiner,
+		Sampler:        in.Sampler,
+		Scheduler:      in.Scheduler,
+		Seed:           formatSeed(in.Seed, ruinedfooocus.Seeds, &report),
+		Steps:          in.Steps,
+		Styles:         fooocus.Styles{},
+		Vae:            in.Vae,
+		Version:        replaceVersion(in.Version, fooocusVersion, &report),
+	}
+
+	if out.Vae == "" {
+		out.Vae = defaultVae
+	}
+
+	if in.Width != 0 || in.Height != 0 {
+		out.Resolution = fooocus.ResolutionOf(in.Width, in.Height)
+	}
+
+	loras := make([]fooocus.Lora, len(in.Loras))
+	for i, lora := range in.Loras {
+		loras[i] = fooocus.Lora(lora)
+	}
+	setFooocusLoras(&out, loras)
+
+	report.drop("BaseModelHash", in.BaseModelHash, "incompatible hash format")
+	report.drop("ClipModel", in.ClipModel, "not supported by Fooocus")
+	report.drop("Denoise", in.Denoise, "not supported by Fooocus")
+	report.drop("LoraKeywords", in.LoraKeywords, "not supported by Fooocus")
+	report.drop("StartStep", in.StartStep, "not supported by Fooocus")
+
+	return out, report
+}
+
+// RuinedFooocusToFooocusPlus converts RuinedFooocus metadata to FooocusPlus
+// metadata, using Fooocus metadata as intermediate representation.
+func RuinedFooocusToFooocusPlus(in ruinedfooocus.Metadata) (out fooocusplus.Metadata, report Report) {
+	intermediate, report := RuinedFooocusToFooocus(in)
+	out, next := FooocusToFooocusPlus(intermediate)
+	report.merge(next)
+	return out, report
+}
+
+// RuinedFooocusToStableDiffusion converts RuinedFooocus metadata to A1111-style
+// metadata, using Fooocus metadata as intermediate representation.
+func RuinedFooocusToStableDiffusion(in ruinedfooocus.Metadata) (out stablediffusion.Metadata, report Report) {
+	intermediate, report := RuinedFooocusToFooocus(in)
+	out, next := FooocusToStableDiffusion(intermediate)
+	report.merge(next)
+	return out, report
+}
@@ -0,0 +1,207 @@
+package convert
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/fooocusplus"
+	"github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	"github.com/fkleon/fooocus-metadata/stablediffusion"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// LoRA references embedded in an A1111 prompt, e.g. "<lora:name:0.5>"
+var loraTag = regexp.MustCompile(`\s*<lora:[^>]+>`)
+
+// FooocusToStableDiffusion converts Fooocus metadata to A1111-style metadata.
+//
+// The sampler and scheduler are combined into the A1111 sampler name,
+// and LoRAs are referenced in the prompt as A1111 does.
+func FooocusToStableDiffusion(in fooocus.Metadata) (out stablediffusion.Metadata, report Report) {
+	out = stablediffusion.Metadata{
+		BatchSize:      int(in.ImageNumber),
+		CfgScale:       in.GuidanceScale,
+		ClipSkip:       int(in.ClipSkip),
+		Model:          trimModelExt(in.BaseModel),
+		ModelHash:      in.BaseModelHash,
+		NegativePrompt: in.NegativePrompt,
+		Prompt:         in.Prompt,
+		Seed:           parseSeed(in.Seed, &report),
+		Steps:          int(in.Steps),
+		Version:        in.Version,
+	}
+
+	if in.Vae != defaultVae {
+		out.Vae = in.Vae
+	}
+
+	if in.Resolution != nil {
+		out.Size = &stablediffusion.Size{
+			Width:  int(in.Resolution.Width()),
+			Height: int(in.Resolution.Height()),
+		}
+	}
+
+	var sampler, ok = types.JoinA1111Sampler(in.Sampler, in.Scheduler)
+	if !ok {
+		report.approximate("Sampler", "no A1111 equivalent")
+	}
+	out.Sampler = sampler
+
+	// A1111 references LoRAs in the prompt
+	for _, lora := range in.Loras {
+		lora := stablediffusion.Lora{
+			Name:   trimModelExt(lora.Name),
+			Weight: lora.Weight,
+		}
+		out.Loras = append(out.Loras, lora)
+		out.Prompt = strings.TrimSpace(fmt.Sprintf("%s <lora:%s:%g>", out.Prompt, lora.Name, lora.Weight))
+	}
+	if len(in.Loras) > 0 {
+		report.approximate("Loras", "referenced in prompt")
+	}
+	report.drop("Loras.Hash", loraHashes(in.Loras), "not supported by A1111")
+
+	report.drop("AdaptiveCfg", in.AdaptiveCfg, "not supported by A1111")
+	report.drop("AdmGuidance", in.AdmGuidance, "not supported by A1111")
+	report.drop("CreatedBy", in.CreatedBy, "not supported by A1111")
+	report.drop("FreeU", in.FreeU, "not supported by A1111")
+	report.drop("FullNegativePrompt", in.FullNegativePrompt, "not supported by A1111")
+	report.drop("FullPrompt", in.FullPrompt, "not supported by A1111")
+	report.drop("Inpaint", in.Inpaint, "not supported by A1111")
+	report.drop("UpscaleOrVary", in.UpscaleOrVary, "not supported by A1111")
+	report.drop("ImagePrompts", in.ImagePrompts, "not supported by A1111")
+	report.drop("Enhance", in.Enhance, "not supported by A1111")
+	report.drop("Performance", in.Performance, "not supported by A1111")
+	report.drop("PromptExpansion", in.PromptExpansion, "not supported by A1111")
+	if in.RefinerModel != noRefiner {
+		report.drop("RefinerModel", in.RefinerModel, "not supported by A1111")
+		report.drop("RefinerModelHash", in.RefinerModelHash, "not supported by A1111")
+		report.drop("RefinerSwapMethod", in.RefinerSwapMethod, "not supported by A1111")
+		report.drop("RefinerSwitch", in.RefinerSwitch, "not supported by A1111")
+	}
+	report.drop("Sharpness", in.Sharpness, "not supported by A1111")
+	report.drop("Styles", in.Styles, "not supported by A1111")
+
+	return out, report
+}
+
+// StableDiffusionToFooocus converts A1111-style metadata to Fooocus metadata.
+//
+// The A1111 sampler name is split into the sampler and scheduler, or the
+// separate schedule type of newer versions is used, and
+// LoRA references are removed from the prompt.
+func StableDiffusionToFooocus(in stablediffusion.Metadata) (out fooocus.Metadata, report Report) {
+	out = fooocus.Metadata{
+		BaseModel:      in.Model,
+		BaseModelHash:  in.ModelHash,
+		ClipSkip:       toUint8(in.ClipSkip, "ClipSkip", &report),
+		GuidanceScale:  in.CfgScale,
+		ImageNumber:    uint(max(in.BatchSize, 0)),
+		MetadataScheme: fooocus.Fooocus.String(),
+		NegativePrompt: in.NegativePrompt,
+		Prompt:         strings.TrimSpace(loraTag.ReplaceAllString(in.Prompt, "")),
+		RefinerModel:   noRefiner,
+		Seed:           formatSeed(in.Seed, stablediffusion.Seeds, &report),
+		Steps:          toUint8(in.Steps, "Steps", &report),
+		Styles:         fooocus.Styles{},
+		Vae:            in.Vae,
+		Version:        replaceVersion(in.Version, fooocusVersion, &report),
+	}
+
+	if out.BaseModel == "" {
+		out.BaseModel = in.Unet
+	}
+	if out.Vae == "" {
+		out.Vae = defaultVae
+	}
+
+	if in.Size != nil {
+		if in.Size.Width > math.MaxUint16 || in.Size.Height > math.MaxUint16 ||
+			in.Size.Width < 0 || in.Size.Height < 0 {
+			report.drop("Size", in.Size, "out of range")
+		} else {
+			out.Resolution = fooocus.ResolutionOf(uint16(in.Size.Width), uint16(in.Size.Height))
+		}
+	}
+
+	var ok bool
+	out.Sampler, out.Scheduler, ok = in.SplitSampler()
+	if !ok {
+		report.approximate("Sampler", "no Fooocus equivalent")
+	}
+
+	loras := make([]fooocus.Lora, len(in.Loras))
+	for i, lora := range in.Loras {
+		loras[i] = fooocus.Lora{
+			Name:   lora.Name,
+			Weight: lora.Weight,
+		}
+	}
+	setFooocusLoras(&out, loras)
+
+	report.drop("BatchPos", in.BatchPos, "not supported by Fooocus")
+	report.drop("DenoisingStrength", in.DenoisingStrength, "not supported by Fooocus")
+	report.drop("Eta", in.Eta, "not supported by Fooocus")
+	report.drop("Guidance", in.Guidance, "not supported by Fooocus")
+	report.drop("HiresSteps", in.HiresSteps, "not supported by Fooocus")
+	report.drop("HiresUpscale", in.HiresUpscale, "not supported by Fooocus")
+	report.drop("HiresUpscaler", in.HiresUpscaler, "not supported by Fooocus")
+	report.drop("ImageNoiseMultiplier", in.ImageNoiseMultiplier, "not supported by Fooocus")
+	report.drop("Rng", in.Rng, "not supported by Fooocus")
+	report.drop("TextEncoder", in.TextEncoder, "not supported by Fooocus")
+	report.drop("VaeHash", in.VaeHash, "not supported by Fooocus")
+
+	return out, report
+}
+
+// StableDiffusionToFooocusPlus converts A1111-style metadata to FooocusPlus
+// metadata, using Fooocus metadata as intermediate representation.
+func StableDiffusionToFooocusPlus(in stablediffusion.Metadata) (out fooocusplus.Metadata, report Report) {
+	intermediate, report := StableDiffusionToFooocus(in)
+	out, next := FooocusToFooocusPlus(intermediate)
+	report.merge(next)
+	return out, report
+}
+
+// StableDiffusionToRuinedFooocus converts A1111-style metadata to RuinedFooocus
+// metadata, using Fooocus metadata as intermediate representation.
+func StableDiffusionToRuinedFooocus(in stablediffusion.Metadata) (out ruinedfooocus.Metadata, report Report) {
+	intermediate, report := StableDiffusionToFooocus(in)
+	out, next := FooocusToRuinedFooocus(intermediate)
+	report.merge(next)
+	return out, report
+}
+
+// trimModelExt removes known model file extensions, but keeps
+// the path as A1111 references models relative to the model folder.
+func trimModelExt(name string) string {
+	ext := filepath.Ext(name)
+	switch strings.ToLower(ext) {
+	case ".safetensors", ".gguf", ".pt", ".pth", ".ckpt":
+		return strings.TrimSuffix(name, ext)
+	default:
+		return name
+	}
+}
+
+func loraHashes(loras []fooocus.Lora) (hashes []string) {
+	for _, lora := range loras {
+		if lora.Hash != "" {
+			hashes = append(hashes, lora.Hash)
+		}
+	}
+	return hashes
+}
+
+func toUint8(value int, name string, report *Report) uint8 {
+	if value < 0 || value > math.MaxUint8 {
+		report.drop(name, value, "out of range")
+		return 0
+	}
+	return uint8(value)
+}
@@ -0,0 +1,203 @@
+// Package diff compares the image generation parameters of two images.
+//
+// Parameters are compared through their canonical field mapping, so
+// metadata from different tools can be compared with each other:
+//
+//	a, err := metadata.ExtractFromFile("a.png")
+//	b, err := metadata.ExtractFromFile("b.png")
+//	result := diff.Compare(a.Params, b.Params)
+//	if change, ok := result.Field(diff.FieldSeed); ok {
+//		fmt.Println("seed changed from", change.A, "to", change.B)
+//	}
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fkleon/fooocus-metadata/convert"
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Names of the canonical fields.
+const (
+	FieldVersion       = "version"
+	FieldModel         = "model"
+	FieldSeed          = "seed"
+	FieldSampler       = "sampler"
+	FieldScheduler     = "scheduler"
+	FieldSteps         = "steps"
+	FieldGuidanceScale = "guidance_scale"
+	FieldResolution    = "resolution"
+	FieldClipSkip      = "clip_skip"
+)
+
+// Change of a single field from value A to value B.
+type Change struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+// LoraChangeKind describes how a LoRA changed.
+type LoraChangeKind string
+
+const (
+	LoraAdded      LoraChangeKind = "added"
+	LoraRemoved    LoraChangeKind = "removed"
+	LoraReweighted LoraChangeKind = "reweighted"
+)
+
+// LoraChange describes a LoRA that was added, removed or re-weighted.
+type LoraChange struct {
+	Name    string         `json:"name"`
+	Kind    LoraChangeKind `json:"kind"`
+	WeightA float32        `json:"weight_a"`
+	WeightB float32        `json:"weight_b"`
+}
+
+// Result is the difference between two sets of generation parameters.
+type Result struct {
+	// Word-level diff of the positive and negative prompts.
+	Prompt         []Edit `json:"prompt,omitempty"`
+	NegativePrompt []Edit `json:"negative_prompt,omitempty"`
+
+	// Word-level diff of the Fooocus prompt expansion, only available
+	// if both parameters contain the full prompt.
+	FullPrompt         []Edit `json:"full_prompt,omitempty"`
+	FullNegativePrompt []Edit `json:"full_negative_prompt,omitempty"`
+
+	// Changes to the LoRAs, matched by normalised name.
+	LoRAs []LoraChange `json:"loras,omitempty"`
+
+	// Changes to the canonical fields, e.g. seed, sampler or steps.
+	Fields []Change `json:"fields,omitempty"`
+
+	// Changes to the fields of the raw metadata structs, only available
+	// if both parameters were generated by the same tool.
+	Raw []Change `json:"raw,omitempty"`
+}
+
+// Equal returns true if no differences were found.
+func (r Result) Equal() bool {
+	return r.Prompt == nil && r.NegativePrompt == nil &&
+		r.FullPrompt == nil && r.FullNegativePrompt == nil &&
+		len(r.LoRAs) == 0 && len(r.Fields) == 0 && len(r.Raw) == 0
+}
+
+// Field returns the change of the named canonical field, if any.
+func (r Result) Field(name string) (Change, bool) {
+	for _, change := range r.Fields {
+		if change.Field == name {
+			return change, true
+		}
+	}
+	return Change{}, false
+}
+
+// Compare computes the difference between the generation parameters a and b.
+func Compare(a types.GenerationParameters, b types.GenerationParameters) (result Result) {
+	result.Prompt = Words(a.PositivePrompt(), b.PositivePrompt())
+	result.NegativePrompt = Words(a.NegativePrompt(), b.NegativePrompt())
+	result.LoRAs = compareLoras(a.LoRAs(), b.LoRAs())
+
+	var fields = func(name string, va string, vb string) {
+		if va != vb {
+			result.Fields = append(result.Fields, Change{name, va, vb})
+		}
+	}
+
+	fields(FieldVersion, a.Version(), b.Version())
+	fields(FieldModel, a.Model(), b.Model())
+	fields(FieldSeed, a.Seed(), b.Seed())
+	fields(FieldSampler, a.Sampler(), b.Sampler())
+	fields(FieldScheduler, a.Scheduler(), b.Scheduler())
+
+	ca, _, okA := convert.ToFooocus(a.Raw())
+	cb, _, okB := convert.ToFooocus(b.Raw())
+	if okA && okB {
+		fields(FieldSteps, strconv.Itoa(int(ca.Steps)), strconv.Itoa(int(cb.Steps)))
+		fields(FieldGuidanceScale, formatFloat(ca.GuidanceScale), formatFloat(cb.GuidanceScale))
+		fields(FieldResolution, formatResolution(ca.Resolution), formatResolution(cb.Resolution))
+		fields(FieldClipSkip, strconv.Itoa(int(ca.ClipSkip)), strconv.Itoa(int(cb.ClipSkip)))
+
+		if len(ca.FullPrompt) > 0 && len(cb.FullPrompt) > 0 {
+			result.FullPrompt = Words(strings.Join(ca.FullPrompt, "\n"), strings.Join(cb.FullPrompt, "\n"))
+		}
+		if len(ca.FullNegativePrompt) > 0 && len(cb.FullNegativePrompt) > 0 {
+			result.FullNegativePrompt = Words(strings.Join(ca.FullNegativePrompt, "\n"), strings.Join(cb.FullNegativePrompt, "\n"))
+		}
+	}
+
+	result.Raw = CompareRaw(a.Raw(), b.Raw())
+	return result
+}
+
+// CompareRaw compares two raw metadata structs field by field.
+//
+// Returns nil if a and b are not structs of the same type.
+func CompareRaw(a any, b any) (changes []Change) {
+	va := reflect.Indirect(reflect.ValueOf(a))
+	vb := reflect.Indirect(reflect.ValueOf(b))
+
+	if !va.IsValid() || !vb.IsValid() || va.Type() != vb.Type() || va.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := range va.NumField() {
+		field := va.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fa := va.Field(i).Interface()
+		fb := vb.Field(i).Interface()
+		if !reflect.DeepEqual(fa, fb) {
+			changes = append(changes, Change{field.Name, formatValue(fa), formatValue(fb)})
+		}
+	}
+	return changes
+}
+
+func compareLoras(a []types.Lora, b []types.Lora) (changes []LoraChange) {
+	for _, la := range a {
+		idx := slices.IndexFunc(b, func(lb types.Lora) bool { return lb.Name == la.Name })
+		if idx < 0 {
+			changes = append(changes, LoraChange{la.Name, LoraRemoved, la.Weight, 0})
+		} else if b[idx].Weight != la.Weight {
+			changes = append(changes, LoraChange{la.Name, LoraReweighted, la.Weight, b[idx].Weight})
+		}
+	}
+	for _, lb := range b {
+		if !slices.ContainsFunc(a, func(la types.Lora) bool { return la.Name == lb.Name }) {
+			changes = append(changes, LoraChange{lb.Name, LoraAdded, 0, lb.Weight})
+		}
+	}
+	return changes
+}
+
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 32)
+}
+
+func formatResolution(r *fooocus.Resolution) string {
+	if r == nil {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", r.Width(), r.Height())
+}
+
+func formatValue(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(out)
+}
@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/stablediffusion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fooocusMeta = fooocus.Metadata{
+	BaseModel:     "juggernautXL_v8Rundiffusion.safetensors",
+	FullPrompt:    []string{"cinematic still A sunflower field", "A sunflower field, highly detailed"},
+	GuidanceScale: 4,
+	Loras: []fooocus.Lora{
+		{Name: "sd_xl_offset_example-lora_1.0.safetensors", Weight: 0.1},
+		{Name: "PetDinosaur-v2.safetensors", Weight: 0.8},
+	},
+	Prompt:     "A sunflower field",
+	Resolution: fooocus.ResolutionOf(1024, 1024),
+	Sampler:    "dpmpp_2m_sde_gpu",
+	Scheduler:  "karras",
+	Seed:       "1234",
+	Steps:      30,
+	Version:    "Fooocus v2.5.5",
+}
+
+func TestCompare_Equal(t *testing.T) {
+	a := fooocus.Parameters{Metadata: fooocusMeta}
+	b := fooocus.Parameters{Metadata: fooocusMeta}
+
+	result := Compare(a, b)
+	assert.True(t, result.Equal())
+}
+
+func TestCompare_Fooocus(t *testing.T) {
+	a := fooocus.Parameters{Metadata: fooocusMeta}
+	b := fooocus.Parameters{Metadata: fooocusMeta}
+	b.Prompt = "A poppy field"
+	b.FullPrompt = []string{"cinematic still A poppy field", "A poppy field, highly detailed"}
+	b.Metadata.Seed = "1235"
+	b.Steps = 60
+	b.Metadata.Sampler = "euler"
+	b.Loras = []fooocus.Lora{
+		{Name: "sd_xl_offset_example-lora_1.0.safetensors", Weight: 0.6},
+		{Name: "sdxl_lightning_4step_lora.safetensors", Weight: 1.0},
+	}
+
+	result := Compare(a, b)
+	require.False(t, result.Equal())
+
+	assert.Equal(t, "A [-sunflower-] {+poppy+} field", FormatWords(result.Prompt))
+	assert.Nil(t, result.NegativePrompt)
+	assert.Equal(t, "cinematic still A [-sunflower-] {+poppy+} field A [-sunflower-] {+poppy+} field, highly detailed", FormatWords(result.FullPrompt))
+
+	assert.Equal(t, []LoraChange{
+		{"sd_xl_offset_example-lora_1.0", LoraReweighted, 0.1, 0.6},
+		{"PetDinosaur-v2", LoraRemoved, 0.8, 0},
+		{"sdxl_lightning_4step_lora", LoraAdded, 0, 1.0},
+	}, result.LoRAs)
+
+	assert.Equal(t, []Change{
+		{FieldSeed, "1234", "1235"},
+		{FieldSampler, "dpmpp_2m_sde_gpu", "euler"},
+		{FieldSteps, "30", "60"},
+	}, result.Fields)
+
+	seed, ok := result.Field(FieldSeed)
+	assert.True(t, ok)
+	assert.Equal(t, "1235", seed.B)
+
+	_, ok = result.Field(FieldModel)
+	assert.False(t, ok)
+
+	var rawFields []string
+	for _, change := range result.Raw {
+		rawFields = append(rawFields, change.Field)
+	}
+	assert.Equal(t, []string{"FullPrompt", "Loras", "Prompt", "Sampler", "Seed", "Steps"}, rawFields)
+}
+
+func TestCompare_AcrossTools(t *testing.T) {
+	a := fooocus.Parameters{Metadata: fooocusMeta}
+
+	sd, err := stablediffusion.ParseParameters("A sunflower field <lora:sd_xl_offset_example-lora_1.0:0.1> <lora:PetDinosaur-v2:0.8>\nSteps: 30, Sampler: DPM++ 2M SDE Karras, CFG scale: 4, Seed: 1234, Size: 1024x1024, Model: juggernautXL_v8Rundiffusion, Version: v1.10.1")
+	require.NoError(t, err)
+	b := stablediffusion.Parameters{Metadata: sd}
+
+	result := Compare(a, b)
+
+	// Prompt and LoRAs match, the sampler is mapped to the canonical name
+	assert.Nil(t, result.LoRAs)
+	assert.Nil(t, result.FullPrompt)
+	assert.Nil(t, result.Raw)
+	assert.Equal(t, []Change{
+		{FieldVersion, "Fooocus v2.5.5", "v1.10.1"},
+	}, result.Fields)
+}
+
+func TestCompare_AcrossTools_ScheduleType(t *testing.T) {
+	a := fooocus.Parameters{Metadata: fooocusMeta}
+
+	// Newer A1111 versions record the scheduler separately
+	sd, err := stablediffusion.ParseParameters("A sunflower field\nSteps: 30, Sampler: DPM++ 2M SDE, Schedule type: Karras, CFG scale: 4, Seed: 1234, Size: 1024x1024, Model: juggernautXL_v8Rundiffusion, Version: v1.10.1")
+	require.NoError(t, err)
+	b := stablediffusion.Parameters{Metadata: sd}
+
+	result := Compare(a, b)
+
+	_, ok := result.Field(FieldSampler)
+	assert.False(t, ok)
+	_, ok = result.Field(FieldScheduler)
+	assert.False(t, ok)
+}
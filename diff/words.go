@@ -0,0 +1,157 @@
+package diff
+
+import (
+	"strings"
+)
+
+// Op is the kind of a word-level edit.
+type Op uint8
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+func (o Op) String() string {
+	switch o {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+func (o Op) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// Edit is a run of words that are equal, inserted or deleted.
+type Edit struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxTableSize is the maximum number of cells of the longest common
+// subsequence table, about 8 MB. Larger changes are reported as
+// replaced instead of computing a minimal diff.
+const maxTableSize = 1 << 20
+
+// Words computes a word-level diff that transforms a into b.
+//
+// Words are separated by whitespace. Consecutive words with the same
+// operation are combined into a single edit. Returns nil if a and b
+// contain the same words.
+//
+// The diff is minimal unless the changed words, after skipping the
+// common prefix and suffix, exceed maxTableSize when multiplied. Then
+// they are reported as deleted and inserted in full.
+func Words(a string, b string) []Edit {
+	wa := strings.Fields(a)
+	wb := strings.Fields(b)
+
+	var edits []Edit
+	var changed bool
+	var add = func(op Op, word string) {
+		changed = changed || op != Equal
+		if n := len(edits); n > 0 && edits[n-1].Op == op {
+			edits[n-1].Text += " " + word
+			return
+		}
+		edits = append(edits, Edit{op, word})
+	}
+
+	// Common prefix and suffix
+	prefix := 0
+	for prefix < len(wa) && prefix < len(wb) && wa[prefix] == wb[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(wa)-prefix && suffix < len(wb)-prefix &&
+		wa[len(wa)-1-suffix] == wb[len(wb)-1-suffix] {
+		suffix++
+	}
+
+	for _, word := range wa[:prefix] {
+		add(Equal, word)
+	}
+	ma, mb := wa[prefix:len(wa)-suffix], wb[prefix:len(wb)-suffix]
+	if len(ma)*len(mb) > maxTableSize {
+		for _, word := range ma {
+			add(Delete, word)
+		}
+		for _, word := range mb {
+			add(Insert, word)
+		}
+	} else {
+		lcsWords(ma, mb, add)
+	}
+	for _, word := range wa[len(wa)-suffix:] {
+		add(Equal, word)
+	}
+
+	if !changed {
+		return nil
+	}
+	return edits
+}
+
+// lcsWords adds the edits that transform wa into wb, based on
+// their longest common subsequence.
+func lcsWords(wa []string, wb []string, add func(op Op, word string)) {
+	// Longest common subsequence table
+	lcs := make([][]int, len(wa)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(wb)+1)
+	}
+	for i := len(wa) - 1; i >= 0; i-- {
+		for j := len(wb) - 1; j >= 0; j-- {
+			if wa[i] == wb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(wa) && j < len(wb) {
+		switch {
+		case wa[i] == wb[j]:
+			add(Equal, wa[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(Delete, wa[i])
+			i++
+		default:
+			add(Insert, wb[j])
+			j++
+		}
+	}
+	for ; i < len(wa); i++ {
+		add(Delete, wa[i])
+	}
+	for ; j < len(wb); j++ {
+		add(Insert, wb[j])
+	}
+}
+
+// FormatWords renders a word-level diff in wdiff style, marking deleted
+// words as [-deleted-] and inserted words as {+inserted+}.
+func FormatWords(edits []Edit) string {
+	var parts = make([]string, len(edits))
+	for i, edit := range edits {
+		switch edit.Op {
+		case Insert:
+			parts[i] = "{+" + edit.Text + "+}"
+		case Delete:
+			parts[i] = "[-" + edit.Text + "-]"
+		default:
+			parts[i] = edit.Text
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,73 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWords(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected []Edit
+	}{
+		{"A sunflower field", "A sunflower field", nil},
+		{"", "", nil},
+		{"A sunflower field", "A  sunflower\nfield", nil},
+		{"A sunflower field", "A poppy field", []Edit{
+			{Equal, "A"}, {Delete, "sunflower"}, {Insert, "poppy"}, {Equal, "field"},
+		}},
+		{"A sunflower field", "A sunflower field at dusk", []Edit{
+			{Equal, "A sunflower field"}, {Insert, "at dusk"},
+		}},
+		{"cinematic still A sunflower field", "A sunflower field", []Edit{
+			{Delete, "cinematic still"}, {Equal, "A sunflower field"},
+		}},
+		{"", "A sunflower field", []Edit{
+			{Insert, "A sunflower field"},
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.a+"|"+tc.b, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Words(tc.a, tc.b))
+		})
+	}
+}
+
+func TestWords_Large(t *testing.T) {
+	var words = func(prefix string, n int) string {
+		var b strings.Builder
+		for i := range n {
+			fmt.Fprintf(&b, "%s%d ", prefix, i)
+		}
+		return b.String()
+	}
+
+	// Too many changed words for a minimal diff
+	a := "A sunflower " + words("a", 1100) + "field"
+	b := "A sunflower " + words("b", 1000) + "field"
+	assert.Equal(t, []Edit{
+		{Equal, "A sunflower"},
+		{Delete, strings.TrimSpace(words("a", 1100))},
+		{Insert, strings.TrimSpace(words("b", 1000))},
+		{Equal, "field"},
+	}, Words(a, b))
+
+	// Long common prefix and suffix are skipped
+	a = words("a", 5000) + "sunflower " + words("c", 5000)
+	b = words("a", 5000) + "poppy " + words("c", 5000)
+	assert.Equal(t, []Edit{
+		{Equal, strings.TrimSpace(words("a", 5000))},
+		{Delete, "sunflower"},
+		{Insert, "poppy"},
+		{Equal, strings.TrimSpace(words("c", 5000))},
+	}, Words(a, b))
+}
+
+func TestFormatWords(t *testing.T) {
+	edits := Words("A sunflower field", "A poppy field at dusk")
+	assert.Equal(t, "A [-sunflower-] {+poppy+} field {+at dusk+}", FormatWords(edits))
+}
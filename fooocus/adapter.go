@@ -1,11 +1,21 @@
 package fooocus
 
 import (
+	"strings"
 	"time"
 
 	"github.com/fkleon/fooocus-metadata/types"
 )
 
+// Input image features of a generation, besides text to image.
+const (
+	ModeUpscale     = "upscale"
+	ModeVary        = "vary"
+	ModeInpaint     = "inpaint"
+	ModeImagePrompt = "image_prompt"
+	ModeEnhance     = "enhance"
+)
+
 // Adapter that implements the types.GenerationParameters
 // interface on top of Fooocus Metadata.
 type Parameters struct {
@@ -17,6 +27,11 @@ func (m Parameters) Version() string {
 	return m.Metadata.Version
 }
 
+// SoftwareVersion returns the parsed version, see Version.
+func (m Parameters) SoftwareVersion() types.SoftwareVersion {
+	return types.ParseSoftwareVersion(m.Version()).WithProduct(Software)
+}
+
 func (m Parameters) Model() string {
 	return types.NormaliseModelName(m.BaseModel)
 }
@@ -44,6 +59,39 @@ func (m Parameters) Seed() string {
 	return m.Metadata.Seed
 }
 
+// SeedValue returns the parsed seed, see Seeds.
+func (m Parameters) SeedValue() types.Seed {
+	seed, _ := Seeds.Parse(m.Metadata.Seed)
+	return seed
+}
+
+// BatchSeeds returns the seeds of the images generated with the same
+// parameters. Fooocus increments the seed for each of the ImageNumber
+// images, so this is only accurate for the first image of the batch.
+func (m Parameters) BatchSeeds() []types.Seed {
+	return m.SeedValue().Batch(int(m.ImageNumber))
+}
+
+// Sampler returns the normalised sampler, see types.NormaliseSampler.
+func (m Parameters) Sampler() string {
+	sampler, _ := types.NormaliseSampler(m.Metadata.Sampler)
+	return sampler
+}
+
+// Scheduler returns the normalised scheduler, see types.NormaliseScheduler.
+func (m Parameters) Scheduler() string {
+	scheduler, _ := types.NormaliseScheduler(m.Metadata.Scheduler)
+	return scheduler
+}
+
+// Size returns the width and height of the Resolution.
+func (m Parameters) Size() (width int, height int) {
+	if m.Resolution == nil {
+		return 0, 0
+	}
+	return int(m.Resolution.Width()), int(m.Resolution.Height())
+}
+
 func (m Parameters) CreatedTime() time.Time {
 	return m.Created
 }
@@ -51,3 +99,51 @@ func (m Parameters) CreatedTime() time.Time {
 func (m Parameters) Raw() interface{} {
 	return m.Metadata
 }
+
+// ModeParameters are the settings of the input image features of a
+// generation. Features that were not used are nil.
+type ModeParameters struct {
+	Upscale      *UpscaleOrVary
+	Vary         *UpscaleOrVary
+	Inpaint      *Inpaint
+	ImagePrompts []ImagePrompt
+	Enhance      *Enhance
+}
+
+// ModeParameters returns the settings of the input image features
+// used for the generation, see Modes.
+func (m Parameters) ModeParameters() (params ModeParameters) {
+	if strings.HasPrefix(m.UovMethod, "Upscale") {
+		params.Upscale = &m.UpscaleOrVary
+	} else if strings.HasPrefix(m.UovMethod, "Vary") {
+		params.Vary = &m.UpscaleOrVary
+	}
+	if m.InpaintMode != "" {
+		params.Inpaint = &m.Inpaint
+	}
+	params.ImagePrompts = m.ImagePrompts
+	params.Enhance = m.Enhance
+	return
+}
+
+// Modes returns the input image features used for the generation,
+// e.g. "vary" and "image_prompt", or nil for text to image.
+func (m Parameters) Modes() (modes []string) {
+	params := m.ModeParameters()
+	if params.Upscale != nil {
+		modes = append(modes, ModeUpscale)
+	}
+	if params.Vary != nil {
+		modes = append(modes, ModeVary)
+	}
+	if params.Inpaint != nil {
+		modes = append(modes, ModeInpaint)
+	}
+	if len(params.ImagePrompts) > 0 {
+		modes = append(modes, ModeImagePrompt)
+	}
+	if params.Enhance != nil {
+		modes = append(modes, ModeEnhance)
+	}
+	return
+}
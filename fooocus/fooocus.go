@@ -1,12 +1,15 @@
 package fooocus
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/bep/imagemeta"
 	m "github.com/fkleon/fooocus-metadata/types"
 )
 
@@ -17,41 +20,62 @@ type FooocusMetadataExtractor struct {
 }
 
 func (e FooocusMetadataExtractor) Decode(file m.ImageMetadataContext) (meta Metadata, err error) {
+	meta, _, err = e.decode(file)
+	return
+}
+
+// decode reads the embedded metadata and returns its provenance.
+func (e FooocusMetadataExtractor) decode(file m.ImageMetadataContext) (meta Metadata, provenance m.Provenance, err error) {
+
+	paramTag, parameters, scheme, err := EmbeddedParameters(file, "Fooocus ")
+	if err != nil {
+		return meta, provenance, fmt.Errorf("%s: %w", Software, err)
+	}
 
-	var data = file.EmbeddedMetadata
-	var scheme, parameters string
+	if meta, err = parseMetadata(scheme, parameters); err != nil {
+		return
+	}
+
+	// Forks write the same scheme, but with their own version
+	if !isFooocusVersion(meta.Version) {
+		return meta, provenance, fmt.Errorf("%s: Unsupported software: %s: %w", Software, meta.Version, m.ErrNoMetadata)
+	}
+
+	provenance = m.EmbeddedProvenance(paramTag)
+	provenance.Scheme = scheme
+	provenance.Version, provenance.Unverified = detectVersion(meta)
+	return
+}
+
+// EmbeddedParameters returns the tag with the embedded parameters, its
+// value and the metadata scheme of an image written by Fooocus or one of
+// its forks. The software is identified by the prefix of the EXIF
+// "Software" tag, e.g. "Fooocus ".
+func EmbeddedParameters(file m.ImageMetadataContext, software string) (paramTag imagemeta.TagInfo, parameters string, scheme string, err error) {
 
 	// Software version from EXIF "Software"
-	if softwareTag, ok := data["Software"]; ok {
-		softwareVersion := softwareTag.Value.(string)
-		if !strings.HasPrefix(softwareVersion, "Fooocus ") {
-			return meta, fmt.Errorf("%s: EXIF: Unsupported software: %s", Software, softwareVersion)
+	if _, softwareVersion, ok := file.StringTag(m.NamespaceEXIF, "Software"); ok {
+		if !strings.HasPrefix(softwareVersion, software) {
+			return paramTag, "", "", fmt.Errorf("EXIF: Unsupported software: %s: %w", softwareVersion, m.ErrNoMetadata)
 		}
 	}
 
 	// Schema from EXIF "MakerNoteApple" or PNG "fooocus_scheme"
-	if schemeTag, ok := data["MakerNoteApple"]; ok {
-		scheme = schemeTag.Value.(string)
-	} else {
-		if schemeTag, ok := data["fooocus_scheme"]; ok {
-			scheme = schemeTag.Value.(string)
-		} else {
-			return meta, fmt.Errorf("%s: Scheme not found", Software)
+	_, scheme, ok := file.StringTag(m.NamespaceEXIF, "MakerNoteApple")
+	if !ok {
+		if _, scheme, ok = file.StringTag(m.NamespacePNG, "fooocus_scheme"); !ok {
+			return paramTag, "", "", fmt.Errorf("Scheme not found: %w", m.ErrNoMetadata)
 		}
 	}
 
 	// Parameters from EXIF "UserComment" or PNG "parameters"
-	if paramTag, ok := data["UserComment"]; ok {
-		parameters = paramTag.Value.(string)
-	} else {
-		if paramTag, ok := data["parameters"]; ok {
-			parameters = paramTag.Value.(string)
-		} else {
-			return meta, fmt.Errorf("%s: Parameters not found", Software)
+	paramTag, parameters, ok = file.StringTag(m.NamespaceEXIF, "UserComment")
+	if !ok {
+		if paramTag, parameters, ok = file.StringTag(m.NamespacePNG, "parameters"); !ok {
+			return paramTag, "", "", fmt.Errorf("Parameters not found: %w", m.ErrNoMetadata)
 		}
 	}
-
-	return parseMetadata(scheme, parameters)
+	return
 }
 
 func (e FooocusMetadataExtractor) Extract(file m.ImageMetadataContext) (m.StructuredMetadata, error) {
@@ -60,40 +84,91 @@ func (e FooocusMetadataExtractor) Extract(file m.ImageMetadataContext) (m.Struct
 		Source: Software,
 	}
 
-	// Try to parse creation time from filename
-	filename := filepath.Base(file.Filepath)
-	meta.Created, _ = e.ParseDateFromFilename(filename)
+	meta.Filename = e.ParseFilename(file)
 
 	slog.Debug("Checking embedded metadata..", "file", file.Filepath)
-	if params, err := e.Decode(file); err == nil {
+	params, provenance, err := e.decode(file)
+	if err == nil {
+		var date time.Time
+		if file.PrivateLog == m.PrivateLogMerge {
+			params, date, meta.Merge = e.mergePrivateLog(file, params)
+		}
+		meta.Created, meta.CreatedSource = e.ResolveCreated(file, date)
+		meta.Provenance = provenance
 		meta.Params = &Parameters{
 			Metadata: params,
+			Created:  meta.Created,
 		}
 		return meta, nil
 	}
 
 	// Fallback to private log
+	params, date, provenance, logErr := e.readPrivateLog(file)
+	if logErr == nil {
+		meta.Created, meta.CreatedSource = e.ResolveCreated(file, date)
+		meta.Provenance = provenance
+		meta.Params = &Parameters{
+			Metadata: params,
+			Created:  meta.Created,
+		}
+		return meta, nil
+	}
+
+	return meta, errors.Join(err, logErr)
+}
+
+// readPrivateLog reads the entry of the image from the private log
+// in the folder of the image.
+func (e FooocusMetadataExtractor) readPrivateLog(file m.ImageMetadataContext) (params Metadata, date time.Time, provenance m.Provenance, err error) {
 	slog.Debug("Checking private log..", "logfile", e.LogfileName)
+	var filename = filepath.Base(file.Filepath)
 	var logfile = filepath.Join(filepath.Dir(file.Filepath), e.LogfileName)
 
-	if log, err := ParsePrivateLog(logfile); err == nil {
-		slog.Debug("Private log file", "file", logfile, "images", len(log))
-		if params, ok := log[filename]; ok {
-			meta.Params = &Parameters{
-				Metadata: params,
-			}
-			return meta, nil
-		}
+	log, date, err := cachedPrivateLog(logfile, file.Time.Loc())
+	if err != nil {
+		return
+	}
+
+	slog.Debug("Private log file", "file", logfile, "images", len(log))
+	params, ok := log[filename]
+	if !ok {
+		err = fmt.Errorf("%s: %s not found in private log: %w", Software, filename, m.ErrNoMetadata)
+		return
 	}
 
-	return meta, fmt.Errorf("%s: No metadata found", Software)
+	provenance = m.PrivateLogProvenance(logfile, filename)
+	provenance.Scheme = Fooocus.String()
+	provenance.Version, provenance.Unverified = detectVersion(params)
+	return params, date, provenance, nil
+}
+
+// mergePrivateLog fills the fields missing from the embedded metadata
+// from the private log entry of the image, and reports the fields with
+// different values. The metadata is returned as is if there is no entry.
+func (e FooocusMetadataExtractor) mergePrivateLog(file m.ImageMetadataContext, params Metadata) (Metadata, time.Time, *m.MergeReport) {
+	logParams, date, provenance, err := e.readPrivateLog(file)
+	if err != nil {
+		slog.Debug("Skipping merge with private log", "file", file.Filepath, "err", err)
+		return params, time.Time{}, nil
+	}
+
+	report := m.MergeReport{Provenance: provenance}
+	report.Filled, report.Conflicts = m.MergeValues(&params, alignPrivateLog(logParams, params))
+	return params, date, &report
+}
+
+// DefaultFilenamePatterns match the file names of Fooocus,
+// e.g. "2024-01-05_23-11-48_9167.png" with a random number suffix.
+var DefaultFilenamePatterns = []*m.FilenamePattern{
+	m.MustFilenameTemplate("{date}_{time}{*}"),
 }
 
 func NewFooocusMetadataExtractor() m.Reader[Metadata] {
 	return FooocusMetadataExtractor{
 		FileMetadataExtractor: &m.FileMetadataExtractor{
-			DateLayout:  "2006-01-02_15-04-05",
-			LogfileName: "log.html",
+			Software:         Software,
+			FilenamePatterns: DefaultFilenamePatterns,
+			LogfileName:      "log.html",
 		},
 	}
 }
@@ -16,21 +16,20 @@ import (
 
 func TestExtractMetadataFromPNG(t *testing.T) {
 
-	var pngData = make(map[string]imagemeta.TagInfo)
-
-	pngData["fooocus_scheme"] =
-		imagemeta.TagInfo{
-			Source:    0,
-			Namespace: "PNG/tEXt",
-			Tag:       "fooocus_scheme",
-			Value:     Fooocus.String(),
-		}
-	pngData["parameters"] = imagemeta.TagInfo{
+	var pngData types.Tags
+
+	pngData.Add(imagemeta.TagInfo{
+		Source:    0,
+		Namespace: "PNG/tEXt",
+		Tag:       "fooocus_scheme",
+		Value:     Fooocus.String(),
+	})
+	pngData.Add(imagemeta.TagInfo{
 		Source:    0,
 		Namespace: "PNG/tEXt",
 		Tag:       "parameters",
 		Value:     metaV23Json,
-	}
+	})
 
 	extractor := NewFooocusMetadataExtractor()
 	fooocusData, err := extractor.Decode(types.ImageMetadataContext{
@@ -42,27 +41,30 @@ func TestExtractMetadataFromPNG(t *testing.T) {
 
 func TestExtractMetadataFromExif(t *testing.T) {
 
-	var exifData imagemeta.Tags
+	var exifData types.Tags
 
 	exifData.Add(imagemeta.TagInfo{
-		Source: imagemeta.EXIF,
-		Tag:    "Software",
-		Value:  "Fooocus v2.5.5",
+		Source:    imagemeta.EXIF,
+		Namespace: "IFD0",
+		Tag:       "Software",
+		Value:     "Fooocus v2.5.5",
 	})
 	exifData.Add(imagemeta.TagInfo{
-		Source: imagemeta.EXIF,
-		Tag:    "MakerNoteApple",
-		Value:  Fooocus.String(),
+		Source:    imagemeta.EXIF,
+		Namespace: "IFD0",
+		Tag:       "MakerNoteApple",
+		Value:     Fooocus.String(),
 	})
 	exifData.Add(imagemeta.TagInfo{
-		Source: imagemeta.EXIF,
-		Tag:    "UserComment",
-		Value:  metaV23Json,
+		Source:    imagemeta.EXIF,
+		Namespace: "IFD0",
+		Tag:       "UserComment",
+		Value:     metaV23Json,
 	})
 
 	extractor := NewFooocusMetadataExtractor()
 	fooocusData, err := extractor.Decode(types.ImageMetadataContext{
-		EmbeddedMetadata: exifData.EXIF(),
+		EmbeddedMetadata: exifData,
 	})
 	require.NoError(t, err)
 	require.Equal(t, *metaV23, fooocusData)
@@ -146,3 +148,183 @@ func TestAdapter(t *testing.T) {
 		})
 	}
 }
+
+func TestAdapterSeed(t *testing.T) {
+	param := Parameters{
+		Metadata: Metadata{Seed: "9223372036854775806", ImageNumber: 3},
+	}
+
+	seed := param.SeedValue()
+	assert.Equal(t, uint64(9223372036854775806), seed.Value)
+	assert.Equal(t, Seeds, seed.Range)
+
+	// Seeds wrap around after 2^63-1
+	seeds := param.BatchSeeds()
+	require.Len(t, seeds, 3)
+	assert.Equal(t, "9223372036854775806", seeds[0].String())
+	assert.Equal(t, "9223372036854775807", seeds[1].String())
+	assert.Equal(t, "0", seeds[2].String())
+
+	param.Metadata.Seed = "not a number"
+	assert.True(t, param.SeedValue().IsZero())
+	assert.Empty(t, param.BatchSeeds())
+}
+
+func TestAdapterSoftwareVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected types.SoftwareVersion
+	}{
+		{"Fooocus v2.5.5", types.SoftwareVersion{Product: "Fooocus", Version: "2.5.5"}},
+		{"v2.1.865", types.SoftwareVersion{Product: "Fooocus", Version: "2.1.865"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			param := Parameters{
+				Metadata: Metadata{Version: tc.version},
+			}
+			assert.Equal(t, tc.expected, param.SoftwareVersion())
+		})
+	}
+}
+
+func TestAdapterModes(t *testing.T) {
+	testCases := []struct {
+		meta  Metadata
+		modes []string
+	}{
+		{*metaV23, nil},
+		{Metadata{UpscaleOrVary: UpscaleOrVary{UovMethod: "Disabled"}}, nil},
+		{Metadata{UpscaleOrVary: UpscaleOrVary{UovMethod: "Vary (Strong)"}}, []string{ModeVary}},
+		{Metadata{UpscaleOrVary: UpscaleOrVary{UovMethod: "Upscale (Fast 2x)"}}, []string{ModeUpscale}},
+		{Metadata{Inpaint: Inpaint{InpaintMode: "Inpaint or Outpaint (default)"}}, []string{ModeInpaint}},
+		{Metadata{
+			ImagePrompts: []ImagePrompt{{Type: "FaceSwap", StopAt: 0.9, Weight: 0.75}},
+			Enhance:      &Enhance{},
+		}, []string{ModeImagePrompt, ModeEnhance}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			param := Parameters{
+				Metadata: tc.meta,
+			}
+			assert.Equal(t, tc.modes, param.Modes())
+		})
+	}
+}
+
+func TestAdapterModeParameters(t *testing.T) {
+	upscale := UpscaleOrVary{UovMethod: "Upscale (Custom)", UpscaleValue: 1.5}
+	inpaint := Inpaint{InpaintMode: "Improve Detail (face, hand, eyes, etc.)", InpaintStrength: 0.5}
+	imagePrompts := []ImagePrompt{{Type: "PyraCanny", StopAt: 0.4, Weight: 1}}
+	enhance := &Enhance{UovMethod: "Disabled"}
+
+	param := Parameters{Metadata: Metadata{
+		UpscaleOrVary: upscale,
+		Inpaint:       inpaint,
+		ImagePrompts:  imagePrompts,
+		Enhance:       enhance,
+	}}
+	assert.Equal(t, ModeParameters{
+		Upscale:      &upscale,
+		Inpaint:      &inpaint,
+		ImagePrompts: imagePrompts,
+		Enhance:      enhance,
+	}, param.ModeParameters())
+
+	vary := UpscaleOrVary{UovMethod: "Vary (Strong)", VaryStrength: 0.85}
+	param = Parameters{Metadata: Metadata{UpscaleOrVary: vary}}
+	assert.Equal(t, ModeParameters{Vary: &vary}, param.ModeParameters())
+
+	param = Parameters{Metadata: *metaV23}
+	assert.Equal(t, ModeParameters{}, param.ModeParameters())
+}
+
+func TestExtractProvenance(t *testing.T) {
+	extractor := NewFooocusMetadataExtractor()
+
+	t.Run("EXIF", func(t *testing.T) {
+		var exifData types.Tags
+		exifData.Add(imagemeta.TagInfo{Source: imagemeta.EXIF, Namespace: "IFD0", Tag: "MakerNoteApple", Value: Fooocus.String()})
+		exifData.Add(imagemeta.TagInfo{Source: imagemeta.EXIF, Namespace: "IFD0", Tag: "UserComment", Value: metaV23Json})
+
+		meta, err := extractor.Extract(types.ImageMetadataContext{EmbeddedMetadata: exifData})
+		require.NoError(t, err)
+		assert.Equal(t, types.Provenance{
+			Location:  types.LocationEmbedded,
+			Container: "EXIF/IFD0",
+			Key:       "UserComment",
+			Scheme:    "fooocus",
+			Version:   "v23",
+		}, meta.Provenance)
+	})
+
+	t.Run("PNG", func(t *testing.T) {
+		meta, err := extractor.Extract(types.ImageMetadataContext{
+			EmbeddedMetadata: types.Tags{
+				{Namespace: "PNG/tEXt", Tag: "fooocus_scheme", Value: Fooocus.String()},
+				{Namespace: "PNG/tEXt", Tag: "parameters", Value: metaV23Json},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, types.Provenance{
+			Location:  types.LocationEmbedded,
+			Container: "PNG/tEXt",
+			Key:       "parameters",
+			Scheme:    "fooocus",
+			Version:   "v23",
+		}, meta.Provenance)
+	})
+
+	t.Run("PrivateLog", func(t *testing.T) {
+		meta, err := extractor.Extract(types.ImageMetadataContext{
+			Filepath: "testdata/fooocus-meta.png",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, types.Provenance{
+			Location:  types.LocationPrivateLog,
+			Path:      "testdata/log.html",
+			Container: types.PrivateLogContainer,
+			Key:       "fooocus-meta.png",
+			Scheme:    "fooocus",
+			Version:   "v23",
+		}, meta.Provenance)
+	})
+}
+
+func TestExtractErrors(t *testing.T) {
+	extractor := NewFooocusMetadataExtractor()
+
+	pngData := func(scheme string, parameters string) types.Tags {
+		return types.Tags{
+			{Namespace: "PNG/tEXt", Tag: "fooocus_scheme", Value: scheme},
+			{Namespace: "PNG/tEXt", Tag: "parameters", Value: parameters},
+		}
+	}
+
+	_, err := extractor.Decode(types.ImageMetadataContext{EmbeddedMetadata: pngData(A1111.String(), "Prompt")})
+	assert.ErrorIs(t, err, types.ErrUnsupportedScheme)
+
+	_, err = extractor.Decode(types.ImageMetadataContext{EmbeddedMetadata: pngData(Fooocus.String(), `{"prompt": }`)})
+	var parseErr *types.ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, Software, parseErr.Software)
+	assert.Equal(t, "parameters", parseErr.Field)
+	assert.Equal(t, int64(12), parseErr.Offset)
+
+	_, err = extractor.Decode(types.ImageMetadataContext{})
+	assert.ErrorIs(t, err, types.ErrNoMetadata)
+
+	// EXIF tags in a PNG text chunk, e.g. written by another tool
+	_, err = extractor.Decode(types.ImageMetadataContext{EmbeddedMetadata: types.Tags{
+		{Namespace: "PNG/tEXt", Tag: "MakerNoteApple", Value: Fooocus.String()},
+		{Namespace: "PNG/tEXt", Tag: "UserComment", Value: metaV23Json},
+	}})
+	assert.ErrorIs(t, err, types.ErrNoMetadata)
+
+	// Neither embedded nor in the private log
+	_, err = extractor.Extract(types.ImageMetadataContext{Filepath: "testdata/missing.png"})
+	assert.ErrorIs(t, err, types.ErrNoMetadata)
+}
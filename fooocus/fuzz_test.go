@@ -0,0 +1,62 @@
+package fooocus
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzParseMetadata(f *testing.F) {
+	for _, seed := range []string{metaV21Json, metaV22Json, metaV23Json, metaV23AltJson} {
+		f.Add(seed)
+	}
+	for _, file := range []string{"testdata/meta.json", "testdata/meta-legacy.json"} {
+		data, err := os.ReadFile(file)
+		require.NoError(f, err)
+		f.Add(string(data))
+	}
+
+	f.Fuzz(func(t *testing.T, parameters string) {
+		_, _ = parseMetadata(Fooocus.String(), parameters)
+
+		var meta metadataAny
+		if err := json.Unmarshal([]byte(parameters), &meta); err == nil {
+			_ = meta.asMetadataV23()
+		}
+	})
+}
+
+func FuzzReadPrivateLog(f *testing.F) {
+	data, err := os.ReadFile("testdata/log.html")
+	require.NoError(f, err)
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _, _ = readPrivateLog(bytes.NewReader(data), "log.html", time.UTC)
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	files, err := filepath.Glob("testdata/*-meta.*")
+	require.NoError(f, err)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+
+	extractor := NewFooocusMetadataExtractor()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ctx, err := image.NewContextFromReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		_, _ = extractor.Decode(*ctx)
+	})
+}
@@ -26,15 +26,23 @@ package fooocus
 import (
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math"
 	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/fkleon/fooocus-metadata/types"
 )
 
 const (
 	Software = "Fooocus"
 )
 
+// Seeds of Fooocus and its forks range from 0 to 2^63-1.
+// The seed of each image is recorded, even if it was random.
+var Seeds = types.SeedRange{Max: math.MaxInt64}
+
 // Fooocus suports encoding metadata with one of two schemes:
 //   - the native JSON scheme
 //   - the AUTOMATIC1111 plaintext format for compatibility with Stable Diffusion web UI
@@ -55,6 +63,9 @@ const (
 	v22                                 // v2.2
 	v23                                 // v2.3+ ("current")
 	unknown = 0
+
+	// Version that unknown versions are read as.
+	latest = v23
 )
 
 var (
@@ -72,20 +83,53 @@ type Version struct {
 	MetadataScheme json.RawMessage `json:"metadata_scheme"`
 }
 
+// Releases that changed the metadata structure.
+var (
+	release21 = types.ParseSoftwareVersion("2.1")
+	release22 = types.ParseSoftwareVersion("2.2")
+	release23 = types.ParseSoftwareVersion("2.3")
+	release26 = types.ParseSoftwareVersion("2.6")
+)
+
 func (v *Version) MetadataVersion() MetadataVersion {
-	if strings.HasPrefix(v.Version, "v2.1") {
+	version := types.ParseSoftwareVersion(v.Version)
+	if !isFooocusVersion(v.Version) || version.Version == "" {
+		return unknown
+	}
+
+	switch {
+	case version.Compare(release21) < 0:
+		return unknown
+	case version.Compare(release22) < 0:
 		return v21
-	} else if strings.HasPrefix(v.Version, "Fooocus v2.2") {
+	case version.Compare(release23) < 0:
 		return v22
-	} else if strings.HasPrefix(v.Version, "Fooocus v2.3") ||
-		strings.HasPrefix(v.Version, "Fooocus v2.4") ||
-		strings.HasPrefix(v.Version, "Fooocus v2.5") {
+	case version.Compare(release26) < 0:
 		return v23
-	} else {
+	default:
 		return unknown
 	}
 }
 
+// isFooocusVersion returns false if the version identifies
+// a fork of Fooocus, e.g. "Fooocus-API v0.4.1".
+func isFooocusVersion(version string) bool {
+	product := types.ParseSoftwareVersion(version).Product
+	return product == "" || product == Software
+}
+
+// detectVersion returns the detected version of the metadata
+// format, e.g. "v23". Unknown versions, including versions before v2.1
+// such as "Fooocus v2.0" and missing versions, are read best-effort as
+// the latest known version and reported as unverified.
+func detectVersion(meta Metadata) (version string, unverified bool) {
+	v := Version{Version: meta.Version}
+	if mv := v.MetadataVersion(); mv != unknown {
+		return mv.String(), false
+	}
+	return latest.String(), true
+}
+
 type metadataAny struct {
 	Version
 	*MetadataV21 // Fooocus v2.1 metadata structure ("legacy")
@@ -113,7 +157,13 @@ func (m *metadataAny) UnmarshalJSON(data []byte) error {
 
 	switch v := m.MetadataVersion(); v {
 	case unknown:
-		return fmt.Errorf("%s: Unknown metadata version", Software)
+		// Forks write their own version and metadata structure
+		if !isFooocusVersion(m.Version.Version) {
+			return fmt.Errorf("%s: %w: %s", Software, types.ErrUnknownVersion, m.Version.Version)
+		}
+		slog.Debug("Unknown metadata version, reading as latest", "version", m.Version.Version, "latest", latest)
+		m.MetadataV23 = &MetadataV23{}
+		return json.Unmarshal(data, m.MetadataV23)
 	case v21:
 		m.MetadataV21 = &MetadataV21{}
 		return json.Unmarshal(data, m.MetadataV21)
@@ -157,49 +207,58 @@ type Metadata = MetadataV23
 // [Serialisation]: https://github.com/lllyasviel/Fooocus/blob/v2.5.5/modules/async_worker.py#L337
 // [Deserialisation]: https://github.com/lllyasviel/Fooocus/blob/v2.5.5/modules/meta_parser.py#L22
 type MetadataV23 struct {
-	AdaptiveCfg          float32       `json:"adaptive_cfg,omitempty"`
-	AdmGuidance          *AdmGuidance  `json:"adm_guidance"`
-	BaseModel            string        `json:"base_model"`
-	BaseModelHash        string        `json:"base_model_hash"`
-	ClipSkip             uint8         `json:"clip_skip"`
-	CreatedBy            string        `json:"created_by,omitempty"`
-	FreeU                *FreeU        `json:"freeu,omitempty"` // string: python tuple (b1: float, b2: float, s1: float, s2: float)
-	FullNegativePrompt   []string      `json:"full_negative_prompt,omitempty"`
-	FullPrompt           []string      `json:"full_prompt,omitempty"`
-	GuidanceScale        float32       `json:"guidance_scale"`
-	ImageNumber          uint          `json:"image_number,omitempty"`
-	InpaintEngineVersion string        `json:"inpaint_engine_version,omitempty"`
-	InpaintMode          string        `json:"inpaint_method,omitempty"`
-	LoraCombined1        *LoraCombined `json:"lora_combined_1,omitempty"`
-	LoraCombined2        *LoraCombined `json:"lora_combined_2,omitempty"`
-	LoraCombined3        *LoraCombined `json:"lora_combined_3,omitempty"`
-	LoraCombined4        *LoraCombined `json:"lora_combined_4,omitempty"`
-	LoraCombined5        *LoraCombined `json:"lora_combined_5,omitempty"`
-	Loras                []Lora        `json:"loras"`
-	MetadataScheme       string        `json:"metadata_scheme"`
-	NegativePrompt       string        `json:"negative_prompt"`
-	Performance          string        `json:"performance"`
-	Prompt               string        `json:"prompt"`
-	PromptExpansion      string        `json:"prompt_expansion"`
-	RefinerModel         string        `json:"refiner_model,omitempty"`
-	RefinerModelHash     string        `json:"refiner_model_hash,omitempty"`
-	RefinerSwapMethod    string        `json:"refiner_swap_method,omitempty"`
-	RefinerSwitch        float32       `json:"refiner_switch"`
-	Resolution           *Resolution   `json:"resolution"`
-	Sampler              string        `json:"sampler"`
-	Scheduler            string        `json:"scheduler"`
-	Seed                 string        `json:"seed"`
-	Sharpness            float32       `json:"sharpness"`
-	Steps                uint8         `json:"steps"`
-	Styles               Styles        `json:"styles"`
-	Vae                  string        `json:"vae"`
-	Version              string        `json:"version"`
+	AdaptiveCfg        float32       `json:"adaptive_cfg,omitempty"`
+	AdmGuidance        *AdmGuidance  `json:"adm_guidance"`
+	BaseModel          string        `json:"base_model"`
+	BaseModelHash      string        `json:"base_model_hash"`
+	ClipSkip           uint8         `json:"clip_skip"`
+	CreatedBy          string        `json:"created_by,omitempty"`
+	FreeU              *FreeU        `json:"freeu,omitempty"` // string: python tuple (b1: float, b2: float, s1: float, s2: float)
+	FullNegativePrompt []string      `json:"full_negative_prompt,omitempty"`
+	FullPrompt         []string      `json:"full_prompt,omitempty"`
+	GuidanceScale      float32       `json:"guidance_scale"`
+	ImageNumber        uint          `json:"image_number,omitempty"`
+	LoraCombined1      *LoraCombined `json:"lora_combined_1,omitempty"`
+	LoraCombined2      *LoraCombined `json:"lora_combined_2,omitempty"`
+	LoraCombined3      *LoraCombined `json:"lora_combined_3,omitempty"`
+	LoraCombined4      *LoraCombined `json:"lora_combined_4,omitempty"`
+	LoraCombined5      *LoraCombined `json:"lora_combined_5,omitempty"`
+	Loras              []Lora        `json:"loras"`
+	MetadataScheme     string        `json:"metadata_scheme" merge:"-"`
+	NegativePrompt     string        `json:"negative_prompt"`
+	Performance        string        `json:"performance"`
+	Prompt             string        `json:"prompt"`
+	PromptExpansion    string        `json:"prompt_expansion"`
+	RefinerModel       string        `json:"refiner_model,omitempty"`
+	RefinerModelHash   string        `json:"refiner_model_hash,omitempty"`
+	RefinerSwapMethod  string        `json:"refiner_swap_method,omitempty"`
+	RefinerSwitch      float32       `json:"refiner_switch"`
+	Resolution         *Resolution   `json:"resolution"`
+	Sampler            string        `json:"sampler"`
+	Scheduler          string        `json:"scheduler"`
+	Seed               string        `json:"seed"`
+	Sharpness          float32       `json:"sharpness"`
+	Steps              uint8         `json:"steps"`
+	Styles             Styles        `json:"styles"`
+	Vae                string        `json:"vae"`
+	Version            string        `json:"version"`
+
+	// Input image features of Fooocus v2.5
+	Inpaint
+	UpscaleOrVary
+	ImagePrompts []ImagePrompt `json:"image_prompts,omitempty"`
+	Enhance      *Enhance      `json:"enhance,omitempty"`
+
+	// Keys that are not part of the scheme, e.g. added by newer
+	// versions of Fooocus or by forks.
+	Extra map[string]json.RawMessage `json:"-" merge:"-"`
 }
 
 // Fooocus v2.2 metadata scheme (json).
 //
 // This format is found in the private log HTML file generated by Fooocus
-// v2.2.x.
+// v2.2.x. It is decoded by the embedded MetadataV23, which also keeps the
+// keys that are not part of the scheme in Extra.
 type MetadataV22 struct {
 	MetadataV23
 	Seed           int  `json:"seed"`
@@ -238,11 +297,30 @@ func (m *MetadataV23) UnmarshalJSON(data []byte) error {
 		m.MetadataScheme = Fooocus.String()
 	}
 
+	// Keep keys that are not part of the scheme
+	extra, err := types.UnknownFields(data, m)
+	if err != nil {
+		return err
+	}
+	m.Extra = extra
+
 	m.fillLoras()
 	m.fillSteps()
 	return nil
 }
 
+func (m MetadataV23) MarshalJSON() ([]byte, error) {
+	// Temporary type without MarshalJSON to avoid infinite
+	// recursion.
+	type metadata MetadataV23
+
+	data, err := json.Marshal(metadata(m))
+	if err != nil {
+		return nil, err
+	}
+	return types.MergeFields(data, m.Extra)
+}
+
 func (meta *MetadataV23) fillLoras() {
 	// If Loras are already set, do not overwrite them
 	if meta.Loras != nil {
@@ -322,6 +400,28 @@ type MetadataV21 struct {
 	Styles               Styles        `json:"Styles"`
 	Vae                  string        `json:"VAE,omitempty"`
 	Version              string        `json:"Version"`
+
+	// Keys that are not part of the scheme.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// metadataV21 has the fields of MetadataV21 without its JSON methods.
+type metadataV21 MetadataV21
+
+func (m *MetadataV21) UnmarshalJSON(data []byte) (err error) {
+	if err = json.Unmarshal(data, (*metadataV21)(m)); err != nil {
+		return
+	}
+	m.Extra, err = types.UnknownFields(data, m)
+	return
+}
+
+func (m MetadataV21) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(metadataV21(m))
+	if err != nil {
+		return nil, err
+	}
+	return types.MergeFields(data, m.Extra)
 }
 
 func ConvertV21ToV23(v21 *MetadataV21) (v23 Metadata) {
@@ -351,38 +451,41 @@ func ConvertV21ToV23(v21 *MetadataV21) (v23 Metadata) {
 	// - FullPrompt
 	// - RefinerModelHash
 	v23 = Metadata{
-		AdaptiveCfg:          legacy.CFGMimicking,
-		AdmGuidance:          legacy.AdmGuidance,
-		BaseModel:            legacy.BaseModel,
-		ClipSkip:             legacy.ClipSkip,
-		FreeU:                legacy.FreeU,
-		GuidanceScale:        legacy.GuidanceScale,
-		ImageNumber:          legacy.ImageNumber,
-		InpaintEngineVersion: legacy.InpaintEngineVersion,
-		InpaintMode:          legacy.InpaintMode,
-		LoraCombined1:        legacy.Lora1,
-		LoraCombined2:        legacy.Lora2,
-		LoraCombined3:        legacy.Lora3,
-		LoraCombined4:        legacy.Lora4,
-		LoraCombined5:        legacy.Lora5,
-		Loras:                loras,
-		MetadataScheme:       Fooocus.String(),
-		NegativePrompt:       legacy.NegativePrompt,
-		Prompt:               legacy.Prompt,
-		PromptExpansion:      legacy.FooocusV2Expansion,
-		Performance:          legacy.Performance,
-		RefinerModel:         legacy.RefinerModel,
-		RefinerSwapMethod:    legacy.RefinerSwapMethod,
-		RefinerSwitch:        legacy.RefinerSwitch,
-		Resolution:           legacy.Resolution,
-		Sampler:              legacy.Sampler,
-		Scheduler:            legacy.Scheduler,
-		Seed:                 strconv.Itoa(legacy.Seed),
-		Sharpness:            legacy.Sharpness,
-		Steps:                legacy.Steps,
-		Styles:               legacy.Styles,
-		Vae:                  legacy.Vae,
-		Version:              legacy.Version,
+		AdaptiveCfg:   legacy.CFGMimicking,
+		AdmGuidance:   legacy.AdmGuidance,
+		BaseModel:     legacy.BaseModel,
+		ClipSkip:      legacy.ClipSkip,
+		FreeU:         legacy.FreeU,
+		GuidanceScale: legacy.GuidanceScale,
+		ImageNumber:   legacy.ImageNumber,
+		Inpaint: Inpaint{
+			InpaintEngineVersion: legacy.InpaintEngineVersion,
+			InpaintMode:          legacy.InpaintMode,
+		},
+		LoraCombined1:     legacy.Lora1,
+		LoraCombined2:     legacy.Lora2,
+		LoraCombined3:     legacy.Lora3,
+		LoraCombined4:     legacy.Lora4,
+		LoraCombined5:     legacy.Lora5,
+		Loras:             loras,
+		MetadataScheme:    Fooocus.String(),
+		NegativePrompt:    legacy.NegativePrompt,
+		Prompt:            legacy.Prompt,
+		PromptExpansion:   legacy.FooocusV2Expansion,
+		Performance:       legacy.Performance,
+		RefinerModel:      legacy.RefinerModel,
+		RefinerSwapMethod: legacy.RefinerSwapMethod,
+		RefinerSwitch:     legacy.RefinerSwitch,
+		Resolution:        legacy.Resolution,
+		Sampler:           legacy.Sampler,
+		Scheduler:         legacy.Scheduler,
+		Seed:              strconv.Itoa(legacy.Seed),
+		Sharpness:         legacy.Sharpness,
+		Steps:             legacy.Steps,
+		Styles:            legacy.Styles,
+		Vae:               legacy.Vae,
+		Version:           legacy.Version,
+		Extra:             legacy.Extra,
 	}
 
 	// Populate missing steps from performance preset
@@ -418,9 +521,21 @@ func NewTuple[T uint16 | float32](data ...T) Tuple[T] {
 }
 
 func (r *Tuple[T]) UnmarshalJSON(p []byte) error {
+	if string(p) == "null" {
+		return nil
+	}
+
+	var tmp string
+	if err := json.Unmarshal(p, &tmp); err != nil {
+		return err
+	}
+
 	// Rewrite String-encoded Python tuple as JSON array:
 	// "(1024, 1024)" -> [1024, 1024]
-	pc := slices.Concat([]byte("["), p[2:len(p)-2], []byte("]"))
+	if len(tmp) < 2 || tmp[0] != '(' || tmp[len(tmp)-1] != ')' {
+		return fmt.Errorf("invalid tuple: %q", tmp)
+	}
+	pc := slices.Concat([]byte("["), []byte(tmp[1:len(tmp)-1]), []byte("]"))
 
 	return json.Unmarshal(pc, &r.data)
 }
@@ -443,16 +558,31 @@ func (r Tuple[T]) MarshalJSON() ([]byte, error) {
 	return json.Marshal(val)
 }
 
+// Resolution is encoded as python tuple (width: int, height: int).
 type Resolution struct {
 	Tuple[uint16]
 }
 
-func ResolutionOf(height uint16, width uint16) *Resolution {
+func ResolutionOf(width uint16, height uint16) *Resolution {
 	return &Resolution{
-		NewTuple(height, width),
+		NewTuple(width, height),
 	}
 }
 
+func (r Resolution) Width() uint16 {
+	if len(r.data) < 1 {
+		return 0
+	}
+	return r.data[0]
+}
+
+func (r Resolution) Height() uint16 {
+	if len(r.data) < 2 {
+		return 0
+	}
+	return r.data[1]
+}
+
 type FreeU struct {
 	Tuple[float32]
 }
@@ -473,6 +603,82 @@ func AdmGuidanceOf(p float32, n float32, e float32) *AdmGuidance {
 	}
 }
 
+// Inpaint or outpaint settings.
+type Inpaint struct {
+	InpaintEngineVersion        string  `json:"inpaint_engine_version,omitempty"`
+	InpaintMode                 string  `json:"inpaint_method,omitempty"`
+	InpaintAdditionalPrompt     string  `json:"inpaint_additional_prompt,omitempty"`
+	InpaintStrength             float32 `json:"inpaint_strength,omitempty"`
+	InpaintRespectiveField      float32 `json:"inpaint_respective_field,omitempty"`
+	InpaintErodeOrDilate        int16   `json:"inpaint_erode_or_dilate,omitempty"`
+	InpaintDisableInitialLatent bool    `json:"inpaint_disable_initial_latent,omitempty"`
+	InpaintMaskInvert           bool    `json:"inpaint_mask_invert,omitempty"`
+}
+
+// Upscale or vary settings.
+type UpscaleOrVary struct {
+	UovMethod       string  `json:"uov_method,omitempty"` // e.g. "Vary (Subtle)" or "Upscale (2x)"
+	UpscaleValue    float32 `json:"uov_upscale_value,omitempty"`
+	VaryStrength    float32 `json:"overwrite_vary_strength,omitempty"`
+	UpscaleStrength float32 `json:"overwrite_upscale_strength,omitempty"`
+}
+
+// Enhance settings, applied after the generation.
+type Enhance struct {
+	UovMethod          string        `json:"uov_method,omitempty"`
+	UovProcessingOrder string        `json:"uov_processing_order,omitempty"` // e.g. "Before First Enhancement"
+	UovPromptType      string        `json:"uov_prompt_type,omitempty"`      // e.g. "Original Prompts"
+	Masks              []EnhanceMask `json:"masks,omitempty"`
+}
+
+// Settings of a single enhance step, which detects a mask and inpaints it.
+type EnhanceMask struct {
+	DetectionPrompt  string  `json:"mask_dino_prompt_text"`
+	Prompt           string  `json:"prompt,omitempty"`
+	NegativePrompt   string  `json:"negative_prompt,omitempty"`
+	MaskModel        string  `json:"mask_model"` // e.g. "sam" or "u2net_cloth_seg"
+	ClothCategory    string  `json:"mask_cloth_category,omitempty"`
+	SamModel         string  `json:"mask_sam_model,omitempty"`
+	TextThreshold    float32 `json:"mask_text_threshold,omitempty"`
+	BoxThreshold     float32 `json:"mask_box_threshold,omitempty"`
+	SamMaxDetections uint8   `json:"mask_sam_max_detections,omitempty"`
+	Inpaint
+}
+
+// Encoded as nested list of format:
+// list [string, float32, float32] (type, stop at, weight)
+//
+// The type is one of "ImagePrompt", "FaceSwap", "PyraCanny" or "CPDS".
+type ImagePrompt struct {
+	Type   string
+	StopAt float32
+	Weight float32
+}
+
+func (ip *ImagePrompt) UnmarshalJSON(p []byte) error {
+	var tmp []json.RawMessage
+	if err := json.Unmarshal(p, &tmp); err != nil {
+		return err
+	}
+	if len(tmp) < 3 {
+		return fmt.Errorf("invalid image prompt, expected type, stop at and weight: %s", p)
+	}
+	if err := json.Unmarshal(tmp[0], &ip.Type); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(tmp[1], &ip.StopAt); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(tmp[2], &ip.Weight); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ip ImagePrompt) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{ip.Type, ip.StopAt, ip.Weight})
+}
+
 // Styles are encoded within a string using single-quoted values, e.g.:
 // "['Fooocus V2', 'Fooocus Enhance', 'Fooocus Sharp']"
 type Styles []string
@@ -524,6 +730,9 @@ func (l *Lora) UnmarshalJSON(p []byte) error {
 	if err := json.Unmarshal(p, &tmp); err != nil {
 		return err
 	}
+	if len(tmp) < 3 {
+		return fmt.Errorf("invalid LoRA, expected name, weight and hash: %s", p)
+	}
 	if err := json.Unmarshal(tmp[0], &l.Name); err != nil {
 		return err
 	}
@@ -570,13 +779,13 @@ func parseMetadata(scheme string, parameters string) (meta Metadata, err error)
 
 	// Scheme is one of 'fooocus' or 'a1111'
 	if scheme != Fooocus.String() {
-		return meta, fmt.Errorf("%s: unsupported metadata scheme: %s", Software, scheme)
+		return meta, fmt.Errorf("%s: %w: %s", Software, types.ErrUnsupportedScheme, scheme)
 	}
 
 	// Parse metadata
 	err = json.Unmarshal([]byte(parameters), &meta)
 	if err != nil {
-		return meta, fmt.Errorf("%s: failed to read parameters: %w", Software, err)
+		return meta, types.NewParseError(Software, "parameters", err)
 	}
 
 	return
@@ -2,8 +2,11 @@ package fooocus
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/fkleon/fooocus-metadata/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -397,6 +400,65 @@ func TestDecodeMetadataAny_V23_Alt(t *testing.T) {
 	assert.Equal(t, metaV23Alt, out.asMetadataV23())
 }
 
+func TestDecodeMetadataAny_Unknown(t *testing.T) {
+	var out *metadataAny
+	err := json.Unmarshal([]byte(`{"version": "Fooocus v3.0.0", "prompt": "a cat", "new_key": [1, 2]}`), &out)
+	require.NoError(t, err)
+	require.NotNil(t, out.MetadataV23)
+	assert.Equal(t, "a cat", out.MetadataV23.Prompt)
+	assert.Equal(t, json.RawMessage(`[1, 2]`), out.MetadataV23.Extra["new_key"])
+
+	err = json.Unmarshal([]byte(`{"version": "Fooocus-API v0.4.1.1"}`), &out)
+	require.ErrorIs(t, err, types.ErrUnknownVersion)
+}
+
+func TestMetadataExtra(t *testing.T) {
+	var meta Metadata
+	err := json.Unmarshal([]byte(`{"prompt": "a cat", "version": "Fooocus v2.6.0", "new_key": "value"}`), &meta)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]json.RawMessage{"new_key": json.RawMessage(`"value"`)}, meta.Extra)
+
+	data, err := json.Marshal(meta)
+	require.NoError(t, err)
+
+	var object map[string]any
+	require.NoError(t, json.Unmarshal(data, &object))
+	assert.Equal(t, "value", object["new_key"])
+	assert.Equal(t, "a cat", object["prompt"])
+}
+
+func TestMetadataExtra_V21(t *testing.T) {
+	var meta MetadataV21
+	err := json.Unmarshal([]byte(`{"Prompt": "a cat", "Version": "v2.1.865", "New Key": "value"}`), &meta)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]json.RawMessage{"New Key": json.RawMessage(`"value"`)}, meta.Extra)
+	assert.Equal(t, meta.Extra, ConvertV21ToV23(&meta).Extra)
+
+	data, err := json.Marshal(meta)
+	require.NoError(t, err)
+
+	var object map[string]any
+	require.NoError(t, json.Unmarshal(data, &object))
+	assert.Equal(t, "value", object["New Key"])
+	assert.Equal(t, "a cat", object["Prompt"])
+}
+
+func TestMetadataExtra_V22(t *testing.T) {
+	var out *metadataAny
+	err := json.Unmarshal([]byte(`{"prompt": "a cat", "seed": 1, "metadata_scheme": false, "version": "Fooocus v2.2.1", "new_key": "value"}`), &out)
+	require.NoError(t, err)
+	require.NotNil(t, out.MetadataV22)
+	assert.Equal(t, map[string]json.RawMessage{"new_key": json.RawMessage(`"value"`)}, out.MetadataV22.Extra)
+	assert.Equal(t, out.MetadataV22.Extra, out.asMetadataV23().Extra)
+
+	data, err := json.Marshal(out.MetadataV22)
+	require.NoError(t, err)
+
+	var object map[string]any
+	require.NoError(t, json.Unmarshal(data, &object))
+	assert.Equal(t, "value", object["new_key"])
+}
+
 func TestEncodeMetadataAny_V21(t *testing.T) {
 	t.Skip("Marshalling via metadataAny is not implemented")
 	assert.Fail(t, "TODO")
@@ -440,3 +502,163 @@ func TestEncodeStyles(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, `"['Fooocus V2', 'Fooocus Enhance']"`, string(encoded))
 }
+
+func TestDetectVersion(t *testing.T) {
+	tc := []struct {
+		meta       Metadata
+		version    string
+		unverified bool
+	}{
+		{*metaV21Converted, "v21", false},
+		{*metaV22Converted, "v22", false},
+		{*metaV23, "v23", false},
+		{Metadata{Version: "Fooocus v3.0.0"}, "v23", true},
+		{Metadata{Version: "Fooocus v2.6.0"}, "v23", true},
+		{Metadata{Version: "Fooocus v2.0"}, "v23", true},
+		{Metadata{Version: "Fooocus v2.0.0"}, "v23", true},
+		{Metadata{Version: ""}, "v23", true},
+		{Metadata{Version: "Fooocus"}, "v23", true},
+	}
+	for _, c := range tc {
+		version, unverified := detectVersion(c.meta)
+		assert.Equal(t, c.version, version, c.meta.Version)
+		assert.Equal(t, c.unverified, unverified, c.meta.Version)
+	}
+}
+
+func TestMetadataVersion(t *testing.T) {
+	tc := []struct {
+		version  string
+		expected MetadataVersion
+	}{
+		{"v2.1.865", v21},
+		{"Fooocus v2.2.1", v22},
+		{"Fooocus v2.5.5", v23},
+		{"Fooocus v2.0", unknown},
+		{"", unknown},
+		{"Fooocus v2.6.0", unknown},
+		{"Fooocus-API v0.4.1.1", unknown},
+	}
+	for _, c := range tc {
+		v := Version{Version: c.version}
+		assert.Equal(t, c.expected, v.MetadataVersion(), c.version)
+	}
+}
+
+func TestIsFooocusVersion(t *testing.T) {
+	assert.True(t, isFooocusVersion(""))
+	assert.True(t, isFooocusVersion("Fooocus v2.5.5"))
+	assert.True(t, isFooocusVersion("v2.1.0"))
+	assert.False(t, isFooocusVersion("Fooocus-API v0.4.1.1"))
+	assert.False(t, isFooocusVersion("Defooocus v1.0.3"))
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tc := map[string]string{
+		"tuple too short":   `{"resolution": ""}`,
+		"tuple number":      `{"resolution": 1}`,
+		"tuple unbalanced":  `{"resolution": "(1024, 1024"}`,
+		"lora too short":    `{"loras": [["name", 0.5]]}`,
+		"lora empty":        `{"loras": [[]]}`,
+		"lora combined bad": `{"lora_combined_1": "name : heavy"}`,
+	}
+	for name, in := range tc {
+		t.Run(name, func(t *testing.T) {
+			var meta Metadata
+			assert.Error(t, json.Unmarshal([]byte(in), &meta))
+		})
+	}
+}
+
+func TestDecodeModes(t *testing.T) {
+	read := func(t *testing.T, file string) (meta Metadata) {
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &meta))
+		assert.Empty(t, meta.Extra)
+		return
+	}
+
+	t.Run("vary", func(t *testing.T) {
+		meta := read(t, "meta-vary.json")
+		assert.Equal(t, UpscaleOrVary{UovMethod: "Vary (Subtle)"}, meta.UpscaleOrVary)
+	})
+
+	t.Run("upscale", func(t *testing.T) {
+		meta := read(t, "meta-upscale.json")
+		assert.Equal(t, UpscaleOrVary{
+			UovMethod:       "Upscale (Custom)",
+			UpscaleValue:    1.5,
+			UpscaleStrength: 0.382,
+		}, meta.UpscaleOrVary)
+	})
+
+	t.Run("inpaint", func(t *testing.T) {
+		meta := read(t, "meta-inpaint.json")
+		assert.Equal(t, Inpaint{
+			InpaintEngineVersion:    "v2.6",
+			InpaintMode:             "Modify Content (add objects, change background, etc.)",
+			InpaintAdditionalPrompt: "a bee",
+			InpaintStrength:         1,
+			InpaintRespectiveField:  0.618,
+			InpaintErodeOrDilate:    8,
+			InpaintMaskInvert:       true,
+		}, meta.Inpaint)
+	})
+
+	t.Run("image prompt", func(t *testing.T) {
+		meta := read(t, "meta-image-prompt.json")
+		assert.Equal(t, []ImagePrompt{
+			{Type: "ImagePrompt", StopAt: 0.5, Weight: 0.6},
+			{Type: "PyraCanny", StopAt: 0.4, Weight: 1.0},
+		}, meta.ImagePrompts)
+	})
+
+	t.Run("enhance", func(t *testing.T) {
+		meta := read(t, "meta-enhance.json")
+		assert.Equal(t, &Enhance{
+			UovMethod:          "Upscale (2x)",
+			UovProcessingOrder: "Before First Enhancement",
+			UovPromptType:      "Original Prompts",
+			Masks: []EnhanceMask{{
+				DetectionPrompt:  "face",
+				Prompt:           "detailed face",
+				MaskModel:        "sam",
+				SamModel:         "vit_b",
+				TextThreshold:    0.25,
+				BoxThreshold:     0.3,
+				SamMaxDetections: 1,
+				Inpaint: Inpaint{
+					InpaintEngineVersion:   "v2.6",
+					InpaintMode:            "Improve Detail (face, hand, eyes, etc.)",
+					InpaintStrength:        1,
+					InpaintRespectiveField: 0.618,
+				},
+			}},
+		}, meta.Enhance)
+	})
+}
+
+func TestEncodeModes(t *testing.T) {
+	for _, file := range []string{"meta-vary.json", "meta-upscale.json", "meta-inpaint.json", "meta-image-prompt.json", "meta-enhance.json"} {
+		t.Run(file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", file))
+			require.NoError(t, err)
+
+			var meta Metadata
+			require.NoError(t, json.Unmarshal(data, &meta))
+
+			encoded, err := json.Marshal(meta)
+			require.NoError(t, err)
+
+			var decoded Metadata
+			require.NoError(t, json.Unmarshal(encoded, &decoded))
+			assert.Equal(t, meta, decoded)
+		})
+	}
+}
+
+func TestDecodeMalformedImagePrompt(t *testing.T) {
+	var meta Metadata
+	assert.Error(t, json.Unmarshal([]byte(`{"image_prompts": [["ImagePrompt", 0.5]]}`), &meta))
+}
@@ -3,36 +3,90 @@ package fooocus
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/antchfx/htmlquery"
+	m "github.com/fkleon/fooocus-metadata/types"
+)
+
+const (
+	privateLogTitle      = "Fooocus Log"
+	privateLogDateLayout = "2006-01-02"
 )
 
 func ParsePrivateLog(filePath string) (map[string]Metadata, error) {
-	doc, err := htmlquery.LoadDoc(filePath)
+	images, _, err := parsePrivateLog(filePath, time.Local)
+	return images, err
+}
+
+// parsePrivateLog parses the private log file, returning the metadata
+// of all images and the date of the log in the given location.
+func parsePrivateLog(filePath string, loc *time.Location) (images map[string]Metadata, date time.Time, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, date, err
+	}
+	defer f.Close()
+
+	return readPrivateLog(f, filePath, loc)
+}
+
+// privateLog is a parsed private log, see cachedPrivateLog.
+type privateLog struct {
+	images map[string]Metadata
+	date   time.Time
+}
+
+// privateLogs caches the private logs, which are read once per image.
+var privateLogs m.FileCache[privateLog]
+
+// cachedPrivateLog parses the private log file like parsePrivateLog,
+// or returns it from the cache if the file did not change.
+func cachedPrivateLog(filePath string, loc *time.Location) (map[string]Metadata, time.Time, error) {
+	log, err := privateLogs.Get(filePath, loc.String(), func() (log privateLog, err error) {
+		log.images, log.date, err = parsePrivateLog(filePath, loc)
+		return
+	})
+	return log.images, log.date, err
+}
+
+// readPrivateLog parses a private log from the reader, see parsePrivateLog.
+func readPrivateLog(r io.Reader, filePath string, loc *time.Location) (images map[string]Metadata, date time.Time, err error) {
+	doc, err := htmlquery.Parse(r)
 	if err != nil {
-		return nil, err
+		return nil, date, err
 	}
 
 	// Check that Log file is compatible with this parser
 	title, err := htmlquery.Query(doc, "//title")
 	if err != nil {
-		return nil, err
+		return nil, date, err
 	}
 
-	if !strings.HasPrefix(htmlquery.InnerText(title), "Fooocus Log") {
-		return nil, fmt.Errorf("%s: file is not a Fooocus private log: %s", Software, filePath)
+	var titleText string
+	if title != nil {
+		titleText = htmlquery.InnerText(title)
 	}
+	if !strings.HasPrefix(titleText, privateLogTitle) {
+		return nil, date, fmt.Errorf("%s: %w: %s", Software, m.ErrNotPrivateLog, filePath)
+	}
+
+	// Log files are created per day, with the date in the title
+	dateText := strings.TrimSpace(strings.TrimPrefix(titleText, privateLogTitle))
+	date, _ = time.ParseInLocation(privateLogDateLayout, dateText, loc)
 
 	// Find all images in the log file
 	nodes, err := htmlquery.QueryAll(doc, "//div[@class='image-container']")
 	if err != nil {
-		return nil, err
+		return nil, date, err
 	}
 
-	var images = make(map[string]Metadata, len(nodes))
+	images = make(map[string]Metadata, len(nodes))
 
 	for _, n := range nodes {
 		img := htmlquery.FindOne(n, "//img")
@@ -43,12 +97,11 @@ func ParsePrivateLog(filePath string) (map[string]Metadata, error) {
 		b := htmlquery.FindOne(n, "//button")
 		bClick := htmlquery.SelectAttr(b, "onclick")
 
-		stripLeft := "to_clipboard("
-		stripRight := "')"
-		clean := bClick[len(stripLeft)+1 : len(bClick)-len(stripRight)]
-		cleanU, err := url.QueryUnescape(clean)
+		cleanU, err := decodeClipboard(bClick)
 		if err != nil {
-			return nil, err
+			err = m.NewParseError(Software, "private log entry "+imgSrc, err)
+			slog.Warn("Skipping item in private log", "file", imgSrc, "err", err)
+			continue
 		}
 
 		// Parse metadata
@@ -62,5 +115,62 @@ func ParsePrivateLog(filePath string) (map[string]Metadata, error) {
 		}
 	}
 
-	return images, nil
+	return images, date, nil
+}
+
+// decodeClipboard extracts the URL-encoded metadata from the onclick
+// handler of the copy button, e.g. "to_clipboard('%7B...%7D')".
+func decodeClipboard(onclick string) (string, error) {
+	const prefix, suffix = "to_clipboard('", "')"
+	if len(onclick) < len(prefix)+len(suffix) ||
+		!strings.HasPrefix(onclick, prefix) || !strings.HasSuffix(onclick, suffix) {
+		return "", fmt.Errorf("invalid copy handler: %q", onclick)
+	}
+	return url.QueryUnescape(onclick[len(prefix) : len(onclick)-len(suffix)])
+}
+
+// alignPrivateLog rewrites a private log entry to the representation of
+// the embedded metadata, so that both can be compared. The private log
+// records model names with their file extension and LoRAs without hash.
+func alignPrivateLog(entry Metadata, embedded Metadata) Metadata {
+	entry.BaseModel = alignModelName(entry.BaseModel, embedded.BaseModel)
+	entry.RefinerModel = alignModelName(entry.RefinerModel, embedded.RefinerModel)
+
+	loras := make([]Lora, len(entry.Loras))
+	copy(loras, entry.Loras)
+	for i := range min(len(loras), len(embedded.Loras)) {
+		loras[i].Name = alignModelName(loras[i].Name, embedded.Loras[i].Name)
+		if loras[i].Hash == "" && loras[i].Name == embedded.Loras[i].Name {
+			loras[i].Hash = embedded.Loras[i].Hash
+		}
+	}
+	entry.Loras = loras
+
+	for _, combined := range []struct {
+		entry    **LoraCombined
+		embedded *LoraCombined
+	}{
+		{&entry.LoraCombined1, embedded.LoraCombined1},
+		{&entry.LoraCombined2, embedded.LoraCombined2},
+		{&entry.LoraCombined3, embedded.LoraCombined3},
+		{&entry.LoraCombined4, embedded.LoraCombined4},
+		{&entry.LoraCombined5, embedded.LoraCombined5},
+	} {
+		if *combined.entry == nil || combined.embedded == nil {
+			continue
+		}
+		lora := **combined.entry
+		lora.Name = alignModelName(lora.Name, combined.embedded.Name)
+		*combined.entry = &lora
+	}
+	return entry
+}
+
+// alignModelName returns the embedded model name if it
+// only differs from name by the path or file extension.
+func alignModelName(name string, embedded string) string {
+	if name != embedded && m.NormaliseModelName(name) == m.NormaliseModelName(embedded) {
+		return embedded
+	}
+	return name
 }
@@ -1,10 +1,16 @@
 package fooocus
 
 import (
+	"encoding/json"
 	"maps"
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
 
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	"github.com/fkleon/fooocus-metadata/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -50,3 +56,106 @@ func TestParsePrivateLog(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePrivateLog_NotPrivateLog(t *testing.T) {
+	logfile := filepath.Join(t.TempDir(), "log.html")
+	err := os.WriteFile(logfile, []byte("<html><head><title>Other Log</title></head></html>"), 0644)
+	require.NoError(t, err)
+
+	_, err = ParsePrivateLog(logfile)
+	assert.ErrorIs(t, err, types.ErrNotPrivateLog)
+}
+
+func TestParsePrivateLog_CorruptEntry(t *testing.T) {
+	data, err := os.ReadFile("./testdata/log.html")
+	require.NoError(t, err)
+
+	// The copy handler of the first entry, a1111-meta.png, is not URL-encoded
+	corrupt := strings.Replace(string(data), "to_clipboard('%7B", "to_clipboard('%ZZ", 1)
+	logfile := filepath.Join(t.TempDir(), "log.html")
+	require.NoError(t, os.WriteFile(logfile, []byte(corrupt), 0644))
+
+	images, err := ParsePrivateLog(logfile)
+	require.NoError(t, err)
+	assert.Len(t, images, 7)
+	assert.NotContains(t, images, "a1111-meta.png")
+	assert.Contains(t, images, "a1111-meta.jpeg")
+	assert.Contains(t, images, "fooocus-meta.png")
+}
+
+func TestDecodeClipboard(t *testing.T) {
+	out, err := decodeClipboard("to_clipboard('%7B%22a%22%3A1%7D')")
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, out)
+
+	for _, in := range []string{"", "to_clipboard(", "to_clipboard(')", "alert('x')"} {
+		_, err := decodeClipboard(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestExtractMergePrivateLog(t *testing.T) {
+	extractor := NewFooocusMetadataExtractor()
+
+	images, err := ParsePrivateLog("testdata/log.html")
+	require.NoError(t, err)
+	entry := images["fooocus-meta.png"]
+
+	// Embedded metadata of an image that was re-saved with a different
+	// seed and without the prompt expansion
+	edited := entry
+	edited.Seed = "1"
+	edited.PromptExpansion = ""
+	// Bookkeeping fields are not compared
+	edited.Extra = map[string]json.RawMessage{"task_id": json.RawMessage(`"1"`)}
+	parameters, err := json.Marshal(edited)
+	require.NoError(t, err)
+
+	ctx := types.ImageMetadataContext{
+		Filepath: "testdata/fooocus-meta.png",
+		EmbeddedMetadata: types.Tags{
+			{Namespace: "PNG/tEXt", Tag: "fooocus_scheme", Value: Fooocus.String()},
+			{Namespace: "PNG/tEXt", Tag: "parameters", Value: string(parameters)},
+		},
+		PrivateLog: types.PrivateLogMerge,
+	}
+
+	meta, err := extractor.Extract(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, meta.Merge)
+	assert.Equal(t, types.LocationEmbedded, meta.Provenance.Location)
+	assert.Equal(t, types.LocationPrivateLog, meta.Merge.Provenance.Location)
+	assert.Equal(t, []string{"PromptExpansion"}, meta.Merge.Filled)
+	assert.Equal(t, []types.Conflict{{Field: "Seed", Primary: "1", Secondary: entry.Seed}}, meta.Merge.Conflicts)
+
+	params := meta.Params.Raw().(Metadata)
+	assert.Equal(t, "1", params.Seed)
+	assert.Equal(t, entry.PromptExpansion, params.PromptExpansion)
+
+	// Fallback mode ignores the private log
+	ctx.PrivateLog = types.PrivateLogFallback
+	meta, err = extractor.Extract(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, meta.Merge)
+
+	// No entry in the private log
+	ctx.PrivateLog = types.PrivateLogMerge
+	ctx.Filepath = "testdata/missing.png"
+	meta, err = extractor.Extract(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, meta.Merge)
+}
+
+func TestExtractMergePrivateLog_Aligned(t *testing.T) {
+	// The private log records model names with file extension
+	// and LoRAs without hash, which are not conflicts
+	ctx, err := image.NewContextFromFile("./testdata/fooocus-meta.png")
+	require.NoError(t, err)
+	ctx.PrivateLog = types.PrivateLogMerge
+
+	meta, err := NewFooocusMetadataExtractor().Extract(*ctx)
+	require.NoError(t, err)
+	require.NotNil(t, meta.Merge)
+	assert.Empty(t, meta.Merge.Filled)
+	assert.Empty(t, meta.Merge.Conflicts)
+}
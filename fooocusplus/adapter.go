@@ -3,6 +3,7 @@ package fooocusplus
 import (
 	"time"
 
+	"github.com/fkleon/fooocus-metadata/fooocus"
 	"github.com/fkleon/fooocus-metadata/types"
 )
 
@@ -17,6 +18,11 @@ func (m Parameters) Version() string {
 	return m.Metadata.Version
 }
 
+// SoftwareVersion returns the parsed version, see Version.
+func (m Parameters) SoftwareVersion() types.SoftwareVersion {
+	return types.ParseSoftwareVersion(m.Version()).WithProduct(Software)
+}
+
 func (m Parameters) Model() string {
 	return types.NormaliseModelName(m.BaseModel)
 }
@@ -44,6 +50,32 @@ func (m Parameters) Seed() string {
 	return m.Metadata.Seed
 }
 
+// SeedValue returns the parsed seed, see fooocus.Seeds.
+func (m Parameters) SeedValue() types.Seed {
+	seed, _ := fooocus.Seeds.Parse(m.Seed())
+	return seed
+}
+
+// Sampler returns the normalised sampler, see types.NormaliseSampler.
+func (m Parameters) Sampler() string {
+	sampler, _ := types.NormaliseSampler(m.Metadata.Sampler)
+	return sampler
+}
+
+// Scheduler returns the normalised scheduler, see types.NormaliseScheduler.
+func (m Parameters) Scheduler() string {
+	scheduler, _ := types.NormaliseScheduler(m.Metadata.Scheduler)
+	return scheduler
+}
+
+// Size returns the width and height of the Resolution.
+func (m Parameters) Size() (width int, height int) {
+	if m.Resolution == nil {
+		return 0, 0
+	}
+	return int(m.Resolution.Width()), int(m.Resolution.Height())
+}
+
 func (m Parameters) CreatedTime() time.Time {
 	return m.Created
 }
@@ -1,11 +1,13 @@
 package fooocusplus
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"path/filepath"
 	"strings"
+	"time"
 
 	m "github.com/fkleon/fooocus-metadata/types"
 )
@@ -17,31 +19,43 @@ type FooocusPlusMetadataExtractor struct {
 }
 
 func (e FooocusPlusMetadataExtractor) Decode(file m.ImageMetadataContext) (meta Metadata, err error) {
+	meta, _, err = e.decode(file)
+	return
+}
+
+// decode reads the embedded metadata and returns its provenance.
+func (e FooocusPlusMetadataExtractor) decode(file m.ImageMetadataContext) (meta Metadata, provenance m.Provenance, err error) {
 
 	// TODO: scheme 'simple' if 'Comment' field exists
-	var data = file.EmbeddedMetadata
-	var parameters string
-
-	// Software version from EXIF "Software"
-	if softwareTag, ok := data["Software"]; ok {
-		softwareVersion := softwareTag.Value.(string)
-		if !strings.HasPrefix(softwareVersion, "FooocusPlus 1.") {
-			return meta, fmt.Errorf("%s: EXIF: Unsupported software: %s", Software, softwareVersion)
+	// Software version from EXIF "Software", which may be followed by
+	// the software that labelled the image, e.g. "FooocusPlus 1.0.0 (Lightroom)"
+	if _, softwareVersion, ok := file.StringTag(m.NamespaceEXIF, "Software"); ok {
+		if !strings.HasPrefix(softwareVersion, Software+" ") {
+			return meta, provenance, fmt.Errorf("%s: EXIF: Unsupported software: %s: %w", Software, softwareVersion, m.ErrNoMetadata)
 		}
 	}
 
 	// Parameters from EXIF "UserComment" or PNG "Comment"
-	if paramTag, ok := data["UserComment"]; ok {
-		parameters = paramTag.Value.(string)
-	} else {
-		if paramTag, ok := data["Comment"]; ok {
-			parameters = paramTag.Value.(string)
-		} else {
-			return meta, fmt.Errorf("%s: Parameters not found", Software)
+	paramTag, parameters, ok := file.StringTag(m.NamespaceEXIF, "UserComment")
+	if !ok {
+		if paramTag, parameters, ok = file.StringTag(m.NamespacePNG, "Comment"); !ok {
+			return meta, provenance, fmt.Errorf("%s: Parameters not found: %w", Software, m.ErrNoMetadata)
 		}
 	}
 
-	return parseMetadata(parameters)
+	if meta, err = parseMetadata(parameters); err != nil {
+		return
+	}
+
+	// SimpleSDXL writes the same keys, but with its own version
+	if !isSupportedVersion(meta.Version) {
+		return meta, provenance, fmt.Errorf("%s: Unsupported software: %s: %w", Software, meta.Version, m.ErrNoMetadata)
+	}
+
+	provenance = m.EmbeddedProvenance(paramTag)
+	// Scheme is in title case, e.g. "Fooocus"
+	provenance.Scheme = strings.ToLower(meta.MetadataScheme)
+	return
 }
 
 func (e FooocusPlusMetadataExtractor) Extract(file m.ImageMetadataContext) (m.StructuredMetadata, error) {
@@ -50,40 +64,103 @@ func (e FooocusPlusMetadataExtractor) Extract(file m.ImageMetadataContext) (m.St
 		Source: Software,
 	}
 
-	// Try to parse creation time from filename
-	filename := filepath.Base(file.Filepath)
-	meta.Created, _ = e.ParseDateFromFilename(filename)
+	meta.Filename = e.ParseFilename(file)
 
-	slog.Debug("Checking embedded metadata..", "file", filename)
-	if params, err := e.Decode(file); err == nil {
+	slog.Debug("Checking embedded metadata..", "file", filepath.Base(file.Filepath))
+	params, provenance, err := e.decode(file)
+	if err == nil {
+		var date time.Time
+		if file.PrivateLog == m.PrivateLogMerge {
+			params, date, meta.Merge = e.mergePrivateLog(file, params)
+		}
+		meta.Created, meta.CreatedSource = e.ResolveCreated(file, date)
+		meta.Provenance = provenance
 		meta.Params = &Parameters{
 			Metadata: params,
+			Created:  meta.Created,
 		}
 		return meta, nil
 	}
 
 	// Fallback to private log
+	params, date, provenance, logErr := e.readPrivateLog(file)
+	if logErr == nil {
+		meta.Created, meta.CreatedSource = e.ResolveCreated(file, date)
+		meta.Provenance = provenance
+		meta.Params = &Parameters{
+			Metadata: params,
+			Created:  meta.Created,
+		}
+		return meta, nil
+	}
+
+	return meta, errors.Join(err, logErr)
+}
+
+// readPrivateLog reads the entry of the image from the private log
+// in the folder of the image.
+func (e FooocusPlusMetadataExtractor) readPrivateLog(file m.ImageMetadataContext) (params Metadata, date time.Time, provenance m.Provenance, err error) {
 	slog.Debug("Checking private log..", "logfile", e.LogfileName)
+	var filename = filepath.Base(file.Filepath)
 	var logfile = filepath.Join(filepath.Dir(file.Filepath), e.LogfileName)
 
-	if log, err := ParsePrivateLog(logfile); err == nil {
-		slog.Debug("Private log file", "file", logfile, "images", len(log))
-		if params, ok := log[filename]; ok {
-			meta.Params = &Parameters{
-				Metadata: params,
-			}
-			return meta, nil
-		}
+	log, date, err := cachedPrivateLog(logfile, file.Time.Loc())
+	if err != nil {
+		return
+	}
+
+	slog.Debug("Private log file", "file", logfile, "images", len(log))
+	params, ok := log[filename]
+	if !ok {
+		err = fmt.Errorf("%s: %s not found in private log: %w", Software, filename, m.ErrNoMetadata)
+		return
+	}
+
+	provenance = m.PrivateLogProvenance(logfile, filename)
+	provenance.Scheme = strings.ToLower(params.MetadataScheme)
+	return params, date, provenance, nil
+}
+
+// mergePrivateLog fills the fields missing from the embedded metadata
+// from the private log entry of the image, and reports the fields with
+// different values. The metadata is returned as is if there is no entry.
+func (e FooocusPlusMetadataExtractor) mergePrivateLog(file m.ImageMetadataContext, params Metadata) (Metadata, time.Time, *m.MergeReport) {
+	logParams, date, provenance, err := e.readPrivateLog(file)
+	if err != nil {
+		slog.Debug("Skipping merge with private log", "file", file.Filepath, "err", err)
+		return params, time.Time{}, nil
 	}
 
-	return meta, fmt.Errorf("%s: No metadata found", Software)
+	// The private log joins the parts of the full prompts
+	if joinPrompt(logParams.FullPrompt) == joinPrompt(params.FullPrompt) {
+		logParams.FullPrompt = params.FullPrompt
+	}
+	if joinPrompt(logParams.FullNegativePrompt) == joinPrompt(params.FullNegativePrompt) {
+		logParams.FullNegativePrompt = params.FullNegativePrompt
+	}
+
+	report := m.MergeReport{Provenance: provenance}
+	report.Filled, report.Conflicts = m.MergeValues(&params, logParams)
+	return params, date, &report
+}
+
+// joinPrompt joins the parts of a full prompt like the private log.
+func joinPrompt(parts []string) string {
+	return strings.Join(parts, ", ")
+}
+
+// DefaultFilenamePatterns match the file names of FooocusPlus,
+// e.g. "2025-04-23_11-27-25_6011.png" with a random number suffix.
+var DefaultFilenamePatterns = []*m.FilenamePattern{
+	m.MustFilenameTemplate("{date}_{time}{*}"),
 }
 
 func NewFooocusPlusMetadataExtractor() m.Reader[Metadata] {
 	return FooocusPlusMetadataExtractor{
 		FileMetadataExtractor: &m.FileMetadataExtractor{
-			DateLayout:  "2006-01-02_15-04-05",
-			LogfileName: "log.html",
+			Software:         Software,
+			FilenamePatterns: DefaultFilenamePatterns,
+			LogfileName:      "log.html",
 		},
 	}
 }
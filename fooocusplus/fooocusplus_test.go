@@ -10,14 +10,14 @@ import (
 )
 
 func TestExtractMetadataFromPNG(t *testing.T) {
-	var pngData = make(map[string]imagemeta.TagInfo)
+	var pngData types.Tags
 
-	pngData["Comment"] = imagemeta.TagInfo{
+	pngData.Add(imagemeta.TagInfo{
 		Source:    0,
 		Namespace: "PNG/tEXt",
 		Tag:       "Comment",
 		Value:     metaJson,
-	}
+	})
 
 	extractor := NewFooocusPlusMetadataExtractor()
 	fooocusData, err := extractor.Decode(types.ImageMetadataContext{
@@ -29,22 +29,24 @@ func TestExtractMetadataFromPNG(t *testing.T) {
 
 func TestExtractMetadataFromExif(t *testing.T) {
 
-	var exifData imagemeta.Tags
+	var exifData types.Tags
 
 	exifData.Add(imagemeta.TagInfo{
-		Source: imagemeta.EXIF,
-		Tag:    "Software",
-		Value:  "FooocusPlus 1.0.0",
+		Source:    imagemeta.EXIF,
+		Namespace: "IFD0",
+		Tag:       "Software",
+		Value:     "FooocusPlus 1.0.0",
 	})
 	exifData.Add(imagemeta.TagInfo{
-		Source: imagemeta.EXIF,
-		Tag:    "UserComment",
-		Value:  metaJson,
+		Source:    imagemeta.EXIF,
+		Namespace: "IFD0",
+		Tag:       "UserComment",
+		Value:     metaJson,
 	})
 
 	extractor := NewFooocusPlusMetadataExtractor()
 	fooocusData, err := extractor.Decode(types.ImageMetadataContext{
-		EmbeddedMetadata: exifData.EXIF(),
+		EmbeddedMetadata: exifData,
 	})
 	require.NoError(t, err)
 	require.Equal(t, *meta, fooocusData)
@@ -68,3 +70,54 @@ func TestAdapter(t *testing.T) {
 	}
 	assert.Equal(t, "elsewhereXL_v10", param.Model())
 }
+
+func TestExtractProvenance(t *testing.T) {
+	extractor := NewFooocusPlusMetadataExtractor()
+
+	t.Run("PNG", func(t *testing.T) {
+		meta, err := extractor.Extract(types.ImageMetadataContext{
+			EmbeddedMetadata: types.Tags{
+				{Namespace: "PNG/tEXt", Tag: "Comment", Value: metaJson},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, types.Provenance{
+			Location:  types.LocationEmbedded,
+			Container: "PNG/tEXt",
+			Key:       "Comment",
+			Scheme:    "fooocus",
+		}, meta.Provenance)
+	})
+
+	t.Run("PrivateLog", func(t *testing.T) {
+		meta, err := extractor.Extract(types.ImageMetadataContext{
+			Filepath: "testdata/fooocusplus-meta.png",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, types.LocationPrivateLog, meta.Provenance.Location)
+		assert.Equal(t, "testdata/log.html", meta.Provenance.Path)
+		assert.Equal(t, types.PrivateLogContainer, meta.Provenance.Container)
+		assert.Equal(t, "fooocusplus-meta.png", meta.Provenance.Key)
+	})
+}
+
+func TestExtractMergePrivateLog(t *testing.T) {
+	extractor := NewFooocusPlusMetadataExtractor()
+
+	meta, err := extractor.Extract(types.ImageMetadataContext{
+		Filepath: "testdata/fooocusplus-meta.png",
+		EmbeddedMetadata: types.Tags{
+			{Namespace: "PNG/tEXt", Tag: "Comment", Value: metaJson},
+		},
+		PrivateLog: types.PrivateLogMerge,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, types.LocationEmbedded, meta.Provenance.Location)
+	require.NotNil(t, meta.Merge)
+	assert.Equal(t, types.LocationPrivateLog, meta.Merge.Provenance.Location)
+	assert.Equal(t, "fooocusplus-meta.png", meta.Merge.Provenance.Key)
+
+	// The private log entry matches the embedded metadata
+	assert.Empty(t, meta.Merge.Filled)
+	assert.Empty(t, meta.Merge.Conflicts)
+}
@@ -0,0 +1,49 @@
+package fooocusplus
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzParseMetadata(f *testing.F) {
+	f.Add(metaJson)
+
+	f.Fuzz(func(t *testing.T, parameters string) {
+		_, _ = parseMetadata(parameters)
+	})
+}
+
+func FuzzReadPrivateLog(f *testing.F) {
+	data, err := os.ReadFile("testdata/log.html")
+	require.NoError(f, err)
+	f.Add(data)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _, _ = readPrivateLog(bytes.NewReader(data), "log.html", time.UTC)
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	files, err := filepath.Glob("testdata/*-meta.*")
+	require.NoError(f, err)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+
+	extractor := NewFooocusPlusMetadataExtractor()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ctx, err := image.NewContextFromReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		_, _ = extractor.Decode(*ctx)
+	})
+}
@@ -6,15 +6,31 @@ package fooocusplus
 
 import (
 	"encoding/json"
-	"fmt"
 
 	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/types"
 )
 
 const (
 	Software = "FooocusPlus"
+
+	// Metadata scheme written by FooocusPlus, in title case.
+	Scheme = "Fooocus"
 )
 
+// Versions of FooocusPlus that are supported.
+var supportedVersions = types.VersionConstraint{
+	Product: Software,
+	Op:      ">=",
+	Version: types.ParseSoftwareVersion("1.0.0"),
+}
+
+// isSupportedVersion returns false if the version identifies another
+// tool, e.g. SimpleSDXL which writes the same keys.
+func isSupportedVersion(version string) bool {
+	return supportedVersions.Match(types.ParseSoftwareVersion(version))
+}
+
 type Metadata struct {
 	AdmGuidance        *fooocus.AdmGuidance `json:"ADM Guidance"`
 	BackendEngine      string               `json:"Backend Engine"`
@@ -26,13 +42,13 @@ type Metadata struct {
 	FullPrompt         []string             `json:"Full Prompt"`
 	GuidanceScale      float32              `json:"Guidance Scale"`
 	Loras              []fooocus.Lora       `json:"LoRAs"`
-	MetadataScheme     string               `json:"Metadata Scheme"`
+	MetadataScheme     string               `json:"Metadata Scheme" merge:"-"`
 	NegativePrompt     string               `json:"Negative Prompt"`
 	Performance        string               `json:"Performance"`
 	Prompt             string               `json:"Prompt"`
 	RefinerModel       string               `json:"Refiner Model,omitempty"`
-	RefinerModelHash   string               `json:"Refiner Model Hash,omitempty"`  // TODO
-	RefinerSwapMethod  string               `json:"Refiner Swap Method,omitempty"` // TODO
+	RefinerModelHash   string               `json:"Refiner Model Hash,omitempty"`  // only with a refiner model
+	RefinerSwapMethod  string               `json:"Refiner Swap Method,omitempty"` // only with a refiner model
 	RefinerSwitch      float32              `json:"Refiner Switch"`
 	Resolution         *fooocus.Resolution  `json:"Resolution"`
 	Sampler            string               `json:"Sampler"`
@@ -47,80 +63,131 @@ type Metadata struct {
 	Version            string               `json:"Version"`
 }
 
+// FooocusPlus private log metadata scheme (json).
+//
+// The private log uses the snake case keys of Fooocus, and records
+// LoRAs in the "lora_combined_N" keys, e.g. "name.safetensors : 0.1".
 type MetadataPrivateLog struct {
-	AdmGuidance        *fooocus.AdmGuidance `json:"adm_guidance"`
-	BackendEngine      string               `json:"backend_engine"`
-	BaseModel          string               `json:"base_model"`
-	BaseModelHash      string               `json:"Base Model Hash"` // TODO
-	ClipSkip           uint8                `json:"clip_skip"`
-	FooocusV2Expansion string               `json:"prompt_expansion"`
-	FullNegativePrompt []string             `json:"Full Negative Prompt"` // TODO
-	FullPrompt         []string             `json:"Full Prompt"`          // TODO
-	GuidanceScale      float32              `json:"guidance_scale"`
-	Loras              []fooocus.Lora       `json:"LoRAs"` // TODO
-	MetadataScheme     string               `json:"metadata_scheme"`
-	NegativePrompt     string               `json:"negative_prompt"`
-	Performance        string               `json:"performance"`
-	Prompt             string               `json:"prompt"`
-	RefinerModel       string               `json:"refiner_model,omitempty"`
-	RefinerModelHash   string               `json:"Refiner Model Hash,omitempty"`  // TODO
-	RefinerSwapMethod  string               `json:"Refiner Swap Method,omitempty"` // TODO
-	RefinerSwitch      float32              `json:"refiner_switch"`
-	Resolution         *fooocus.Resolution  `json:"resolution"`
-	Sampler            string               `json:"sampler"`
-	Scheduler          string               `json:"scheduler"`
-	Seed               string               `json:"seed"`
-	Sharpness          float32              `json:"sharpness"`
-	Steps              uint8                `json:"steps"`
-	Styles             fooocus.Styles       `json:"styles"`
-	StylesDefinition   string               `json:"styles_definition"` //TODO
-	User               string               `json:"user,omitempty"`    // TODO
-	Vae                string               `json:"vae"`
-	Version            string               `json:"version"`
+	AdmGuidance        *fooocus.AdmGuidance  `json:"adm_guidance"`
+	BackendEngine      string                `json:"backend_engine"`
+	BaseModel          string                `json:"base_model"`
+	BaseModelHash      string                `json:"base_model_hash,omitempty"`
+	ClipSkip           uint8                 `json:"clip_skip"`
+	FooocusV2Expansion string                `json:"prompt_expansion"`
+	FullNegativePrompt []string              `json:"full_negative_prompt,omitempty"`
+	FullPrompt         []string              `json:"full_prompt,omitempty"`
+	GuidanceScale      float32               `json:"guidance_scale"`
+	LoraCombined1      *fooocus.LoraCombined `json:"lora_combined_1,omitempty"`
+	LoraCombined2      *fooocus.LoraCombined `json:"lora_combined_2,omitempty"`
+	LoraCombined3      *fooocus.LoraCombined `json:"lora_combined_3,omitempty"`
+	LoraCombined4      *fooocus.LoraCombined `json:"lora_combined_4,omitempty"`
+	LoraCombined5      *fooocus.LoraCombined `json:"lora_combined_5,omitempty"`
+	Loras              []fooocus.Lora        `json:"loras,omitempty"`
+	MetadataScheme     json.RawMessage       `json:"metadata_scheme"` // string: scheme, or bool: false if not embedded
+	NegativePrompt     string                `json:"negative_prompt"`
+	Performance        string                `json:"performance"`
+	Prompt             string                `json:"prompt"`
+	RefinerModel       string                `json:"refiner_model,omitempty"`
+	RefinerModelHash   string                `json:"refiner_model_hash,omitempty"`
+	RefinerSwapMethod  string                `json:"refiner_swap_method,omitempty"`
+	RefinerSwitch      float32               `json:"refiner_switch"`
+	Resolution         *fooocus.Resolution   `json:"resolution"`
+	Sampler            string                `json:"sampler"`
+	Scheduler          string                `json:"scheduler"`
+	Seed               string                `json:"seed"`
+	Sharpness          float32               `json:"sharpness"`
+	Steps              uint8                 `json:"steps"`
+	Styles             fooocus.Styles        `json:"styles"`
+	StylesDefinition   string                `json:"styles_definition,omitempty"`
+	User               string                `json:"user,omitempty"`
+	Vae                string                `json:"vae"`
+	Version            string                `json:"version"`
 }
 
 func (legacy *MetadataPrivateLog) toMetadata() (meta Metadata) {
-	// Unsupported:
-	// - BaseModelHash
-	// - User
-	// - FullNegativePrompt
-	// - FullPrompt
-	// - RefinerModelHash
-	// - RefinerSwapMethod
+	// Embedded metadata has model names without file extension
+	var refinerModel = legacy.RefinerModel
+	if refinerModel != "" {
+		refinerModel = types.NormaliseModelName(refinerModel)
+	}
+
+	// Scheme is missing if metadata was not embedded
+	var scheme string
+	if err := json.Unmarshal(legacy.MetadataScheme, &scheme); err != nil {
+		scheme = Scheme
+	}
+
 	meta = Metadata{
 		AdmGuidance:        legacy.AdmGuidance,
 		BackendEngine:      legacy.BackendEngine,
-		BaseModel:          legacy.BaseModel,
+		BaseModel:          types.NormaliseModelName(legacy.BaseModel),
+		BaseModelHash:      legacy.BaseModelHash,
 		ClipSkip:           legacy.ClipSkip,
 		FooocusV2Expansion: legacy.FooocusV2Expansion,
+		FullNegativePrompt: legacy.FullNegativePrompt,
+		FullPrompt:         legacy.FullPrompt,
 		GuidanceScale:      legacy.GuidanceScale,
-		//Loras:                {}, // TODO: post process default from LoraCombined fields
-		MetadataScheme:    legacy.MetadataScheme,
-		NegativePrompt:    legacy.NegativePrompt,
-		Performance:       legacy.Performance,
-		Prompt:            legacy.Prompt,
-		RefinerModel:      legacy.RefinerModel,
-		RefinerSwapMethod: legacy.RefinerSwapMethod,
-		RefinerSwitch:     legacy.RefinerSwitch,
-		Resolution:        legacy.Resolution,
-		Sampler:           legacy.Sampler,
-		Scheduler:         legacy.Scheduler,
-		Seed:              legacy.Seed,
-		Sharpness:         legacy.Sharpness,
-		Steps:             legacy.Steps,
-		Styles:            legacy.Styles,
-		Vae:               legacy.Vae,
-		Version:           legacy.Version,
+		Loras:              legacy.loras(),
+		MetadataScheme:     scheme,
+		NegativePrompt:     legacy.NegativePrompt,
+		Performance:        legacy.Performance,
+		Prompt:             legacy.Prompt,
+		RefinerModel:       refinerModel,
+		RefinerModelHash:   legacy.RefinerModelHash,
+		RefinerSwapMethod:  legacy.RefinerSwapMethod,
+		RefinerSwitch:      legacy.RefinerSwitch,
+		Resolution:         legacy.Resolution,
+		Sampler:            legacy.Sampler,
+		Scheduler:          legacy.Scheduler,
+		Seed:               legacy.Seed,
+		Sharpness:          legacy.Sharpness,
+		Steps:              legacy.Steps,
+		Styles:             legacy.Styles,
+		StylesDefinition:   legacy.StylesDefinition,
+		User:               legacy.User,
+		Vae:                legacy.Vae,
+		Version:            legacy.Version,
 	}
 	return meta
 }
 
+// loras returns the LoRAs with hashes if recorded, or
+// otherwise from the "lora_combined_N" keys.
+func (legacy *MetadataPrivateLog) loras() []fooocus.Lora {
+	var loras = make([]fooocus.Lora, 0, 5)
+
+	if legacy.Loras != nil {
+		for _, lora := range legacy.Loras {
+			lora.Name = types.NormaliseModelName(lora.Name)
+			loras = append(loras, lora)
+		}
+		return loras
+	}
+
+	var addLora = func(lora *fooocus.LoraCombined) {
+		if lora != nil {
+			loras = append(loras, fooocus.Lora{
+				Name:   types.NormaliseModelName(lora.Name),
+				Weight: lora.Weight,
+				Hash:   lora.Hash,
+			})
+		}
+	}
+	addLora(legacy.LoraCombined1)
+	addLora(legacy.LoraCombined2)
+	addLora(legacy.LoraCombined3)
+	addLora(legacy.LoraCombined4)
+	addLora(legacy.LoraCombined5)
+
+	return loras
+}
+
 func parseMetadata(parameters string) (meta Metadata, err error) {
 
 	// Parse metadata
 	err = json.Unmarshal([]byte(parameters), &meta)
 	if err != nil {
-		return meta, fmt.Errorf("%s: failed to read parameters: %w", Software, err)
+		return meta, types.NewParseError(Software, "parameters", err)
 	}
 
 	return
@@ -90,6 +90,22 @@ func TestDecodeMetadata(t *testing.T) {
 	assert.Equal(t, meta, decoded)
 }
 
+func TestDecodeMetadata_Refiner(t *testing.T) {
+	var decoded Metadata
+	err := json.Unmarshal([]byte(`{
+		"Refiner Model": "sd_xl_refiner_1.0",
+		"Refiner Model Hash": "7440042bbd",
+		"Refiner Swap Method": "joint",
+		"Refiner Switch": 0.8,
+		"Version": "FooocusPlus 1.0.0"
+	}`), &decoded)
+	require.NoError(t, err)
+	assert.Equal(t, "sd_xl_refiner_1.0", decoded.RefinerModel)
+	assert.Equal(t, "7440042bbd", decoded.RefinerModelHash)
+	assert.Equal(t, "joint", decoded.RefinerSwapMethod)
+	assert.Equal(t, float32(0.8), decoded.RefinerSwitch)
+}
+
 func TestEncodeMetadata(t *testing.T) {
 	encoded, err := json.Marshal(meta)
 	require.NoError(t, err)
@@ -3,37 +3,92 @@ package fooocusplus
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/url"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/antchfx/htmlquery"
+	m "github.com/fkleon/fooocus-metadata/types"
+	"golang.org/x/net/html"
+)
+
+const (
+	privateLogTitle      = "Fooocus Log"
+	privateLogDateLayout = "2006-01-02"
 )
 
 func ParsePrivateLog(filePath string) (map[string]Metadata, error) {
-	doc, err := htmlquery.LoadDoc(filePath)
+	images, _, err := parsePrivateLog(filePath, time.Local)
+	return images, err
+}
+
+// parsePrivateLog parses the private log file, returning the metadata
+// of all images and the date of the log in the given location.
+func parsePrivateLog(filePath string, loc *time.Location) (images map[string]Metadata, date time.Time, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, date, err
+	}
+	defer f.Close()
+
+	return readPrivateLog(f, filePath, loc)
+}
+
+// privateLog is a parsed private log, see cachedPrivateLog.
+type privateLog struct {
+	images map[string]Metadata
+	date   time.Time
+}
+
+// privateLogs caches the private logs, which are read once per image.
+var privateLogs m.FileCache[privateLog]
+
+// cachedPrivateLog parses the private log file like parsePrivateLog,
+// or returns it from the cache if the file did not change.
+func cachedPrivateLog(filePath string, loc *time.Location) (map[string]Metadata, time.Time, error) {
+	log, err := privateLogs.Get(filePath, loc.String(), func() (log privateLog, err error) {
+		log.images, log.date, err = parsePrivateLog(filePath, loc)
+		return
+	})
+	return log.images, log.date, err
+}
+
+// readPrivateLog parses a private log from the reader, see parsePrivateLog.
+func readPrivateLog(r io.Reader, filePath string, loc *time.Location) (images map[string]Metadata, date time.Time, err error) {
+	doc, err := htmlquery.Parse(r)
 	if err != nil {
-		return nil, err
+		return nil, date, err
 	}
 
 	// Check that Log file is compatible with this parser
 	title, err := htmlquery.Query(doc, "//title")
 	if err != nil {
-		return nil, err
+		return nil, date, err
 	}
 
-	if !strings.HasPrefix(htmlquery.InnerText(title), "Fooocus Log") {
-		return nil, fmt.Errorf("%s: file is not a Fooocus Plus private log: %s", Software, filePath)
+	var titleText string
+	if title != nil {
+		titleText = htmlquery.InnerText(title)
+	}
+	if !strings.HasPrefix(titleText, privateLogTitle) {
+		return nil, date, fmt.Errorf("%s: %w: %s", Software, m.ErrNotPrivateLog, filePath)
 	}
 
+	// Log files are created per day, with the date in the title
+	dateText := strings.TrimSpace(strings.TrimPrefix(titleText, privateLogTitle))
+	date, _ = time.ParseInLocation(privateLogDateLayout, dateText, loc)
+
 	// Find all images in the log file
 
 	nodes, err := htmlquery.QueryAll(doc, "//div[@class='image-container']")
 	if err != nil {
-		return nil, err
+		return nil, date, err
 	}
 
-	var images = make(map[string]Metadata, len(nodes))
+	images = make(map[string]Metadata, len(nodes))
 
 	for _, n := range nodes {
 		img := htmlquery.FindOne(n, "//img")
@@ -44,25 +99,62 @@ func ParsePrivateLog(filePath string) (map[string]Metadata, error) {
 		b := htmlquery.FindOne(n, "//button")
 		bClick := htmlquery.SelectAttr(b, "onclick")
 
-		stripLeft := "to_clipboard("
-		stripRight := "')"
-		clean := bClick[len(stripLeft)+1 : len(bClick)-len(stripRight)]
-		cleanU, err := url.QueryUnescape(clean)
+		cleanU, err := decodeClipboard(bClick)
 		if err != nil {
-			return nil, err
+			err = m.NewParseError(Software, "private log entry "+imgSrc, err)
+			slog.Warn("Skipping item in private log", "file", imgSrc, "err", err)
+			continue
 		}
 
 		// Parse metadata
 		var metadata MetadataPrivateLog
 
 		if err := json.Unmarshal([]byte(cleanU), &metadata); err == nil {
-			if !strings.HasPrefix(metadata.Version, "FooocusPlus ") {
+			if !isSupportedVersion(metadata.Version) {
 				continue
 			}
+			if metadata.FullPrompt == nil && metadata.FullNegativePrompt == nil {
+				metadata.FullPrompt, metadata.FullNegativePrompt = fullRawPrompt(n)
+			}
 			slog.Debug("Metadata in private log", "file", imgSrc)
 			images[imgSrc] = metadata.toMetadata()
+		} else {
+			slog.Warn("Skipping item in private log", "file", imgSrc, "err", err)
 		}
 	}
 
-	return images, nil
+	return images, date, nil
+}
+
+// fullRawPrompt returns the full prompts from the "Full raw prompt"
+// row of the metadata table. FooocusPlus does not write them to the
+// copied metadata, and joins the parts of each prompt with ", ", so
+// they are returned as a single entry.
+func fullRawPrompt(n *html.Node) (positive []string, negative []string) {
+	for _, details := range htmlquery.Find(n, "//td[@class='label'][text()='Full raw prompt']/following-sibling::td/details") {
+		summary := htmlquery.FindOne(details, "//summary")
+		if summary == nil {
+			continue
+		}
+		text := strings.TrimPrefix(htmlquery.InnerText(details), htmlquery.InnerText(summary))
+
+		switch htmlquery.InnerText(summary) {
+		case "Positive":
+			positive = []string{text}
+		case "Negative":
+			negative = []string{text}
+		}
+	}
+	return
+}
+
+// decodeClipboard extracts the URL-encoded metadata from the onclick
+// handler of the copy button, e.g. "to_clipboard('%7B...%7D')".
+func decodeClipboard(onclick string) (string, error) {
+	const prefix, suffix = "to_clipboard('", "')"
+	if len(onclick) < len(prefix)+len(suffix) ||
+		!strings.HasPrefix(onclick, prefix) || !strings.HasSuffix(onclick, suffix) {
+		return "", fmt.Errorf("invalid copy handler: %q", onclick)
+	}
+	return url.QueryUnescape(onclick[len(prefix) : len(onclick)-len(suffix)])
 }
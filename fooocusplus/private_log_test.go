@@ -1,8 +1,16 @@
 package fooocusplus
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/antchfx/htmlquery"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/internal/image"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -31,8 +39,123 @@ func TestParsePrivateLog(t *testing.T) {
 
 			assert.Equal(t, "FooocusPlus 1.0.0", metadata.Version)
 			assert.Equal(t, "Fooocus", metadata.MetadataScheme)
-			// TODO reference data to compare to
-			//assert.Equal(t, meta, images[image])
 		})
 	}
 }
+
+func TestParsePrivateLog_CorruptEntry(t *testing.T) {
+	data, err := os.ReadFile("./testdata/log.html")
+	require.NoError(t, err)
+
+	// The metadata of the first entry, fooocusplus-meta.webp, is not JSON
+	corrupt := strings.Replace(string(data), "to_clipboard('%7B", "to_clipboard('%5B", 1)
+	logfile := filepath.Join(t.TempDir(), "log.html")
+	require.NoError(t, os.WriteFile(logfile, []byte(corrupt), 0644))
+
+	images, err := ParsePrivateLog(logfile)
+	require.NoError(t, err)
+	assert.Len(t, images, 3)
+	assert.NotContains(t, images, "fooocusplus-meta.webp")
+	assert.Contains(t, images, "fooocusplus-meta.png")
+	assert.Contains(t, images, "fooocusplus-meta.jpeg")
+}
+
+func TestPrivateLogParity(t *testing.T) {
+	images, err := ParsePrivateLog("./testdata/log.html")
+	require.NoError(t, err)
+	logged := images["fooocusplus-meta.png"]
+
+	ctx, err := image.NewContextFromFile("./testdata/fooocusplus-meta.png")
+	require.NoError(t, err)
+	embedded, err := NewFooocusPlusMetadataExtractor().Decode(*ctx)
+	require.NoError(t, err)
+
+	// Read from the "Full raw prompt" row, with the parts joined
+	assert.Equal(t, []string{joinPrompt(embedded.FullPrompt)}, logged.FullPrompt)
+	assert.Equal(t, embedded.FullNegativePrompt, logged.FullNegativePrompt)
+
+	// Not written to the private log by FooocusPlus 1.0.0
+	assert.Equal(t, "79fd29ab43", embedded.BaseModelHash)
+	assert.Empty(t, logged.BaseModelHash)
+	assert.Equal(t, "FooocusPlus", embedded.User)
+	assert.Empty(t, logged.User)
+	assert.Empty(t, embedded.StylesDefinition)
+	assert.Empty(t, logged.StylesDefinition)
+
+	expected := embedded
+	expected.FullPrompt = logged.FullPrompt
+	expected.BaseModelHash = ""
+	expected.User = ""
+	assert.Equal(t, expected, logged)
+}
+
+func TestPrivateLogLoraCombined(t *testing.T) {
+	// FooocusPlus writes the private log of Fooocus, which records
+	// LoRAs as "lora_combined_N", e.g. in the log of Fooocus v2.5.5
+	doc, err := htmlquery.LoadDoc("../fooocus/testdata/log.html")
+	require.NoError(t, err)
+
+	buttons := htmlquery.Find(doc, "//div[@class='image-container']//button")
+	require.NotEmpty(t, buttons)
+
+	var checked int
+	for _, button := range buttons {
+		parameters, err := decodeClipboard(htmlquery.SelectAttr(button, "onclick"))
+		require.NoError(t, err)
+
+		// Fooocus v2.2 wrote the seed as number
+		var version fooocus.Version
+		require.NoError(t, json.Unmarshal([]byte(parameters), &version))
+		if !strings.HasPrefix(version.Version, "Fooocus v2.5") {
+			continue
+		}
+
+		var legacy MetadataPrivateLog
+		require.NoError(t, json.Unmarshal([]byte(parameters), &legacy))
+		require.NotNil(t, legacy.LoraCombined1)
+
+		loras := legacy.toMetadata().Loras
+		require.NotEmpty(t, loras)
+		assert.Equal(t, fooocus.Lora{Name: "sd_xl_offset_example-lora_1.0", Weight: 0.1}, loras[0])
+		checked++
+	}
+	assert.NotZero(t, checked)
+}
+
+func TestPrivateLogToMetadata(t *testing.T) {
+	var legacy MetadataPrivateLog
+	err := json.Unmarshal([]byte(`{
+		"base_model": "elsewhereXL_v10.safetensors",
+		"base_model_hash": "79fd29ab43",
+		"full_prompt": ["A sunflower field"],
+		"full_negative_prompt": [""],
+		"lora_combined_1": "sd_xl_offset_example-lora_1.0.safetensors : 0.1",
+		"lora_combined_2": "detail.safetensors : -0.5",
+		"metadata_scheme": false,
+		"refiner_model": "sd_xl_refiner_1.0.safetensors",
+		"refiner_model_hash": "7440042bbd",
+		"refiner_swap_method": "joint",
+		"user": "guest",
+		"version": "FooocusPlus 1.0.0"
+	}`), &legacy)
+	require.NoError(t, err)
+
+	meta := legacy.toMetadata()
+	assert.Equal(t, "elsewhereXL_v10", meta.BaseModel)
+	assert.Equal(t, "79fd29ab43", meta.BaseModelHash)
+	assert.Equal(t, []string{"A sunflower field"}, meta.FullPrompt)
+	assert.Equal(t, []string{""}, meta.FullNegativePrompt)
+	assert.Equal(t, "Fooocus", meta.MetadataScheme)
+	assert.Equal(t, "sd_xl_refiner_1.0", meta.RefinerModel)
+	assert.Equal(t, "7440042bbd", meta.RefinerModelHash)
+	assert.Equal(t, "joint", meta.RefinerSwapMethod)
+	assert.Equal(t, "guest", meta.User)
+	assert.Equal(t, []fooocus.Lora{
+		{Name: "sd_xl_offset_example-lora_1.0", Weight: 0.1},
+		{Name: "detail", Weight: -0.5},
+	}, meta.Loras)
+
+	// LoRAs with hashes take precedence
+	legacy.Loras = []fooocus.Lora{{Name: "detail.safetensors", Weight: -0.5, Hash: "0d9c5b4a1e"}}
+	assert.Equal(t, []fooocus.Lora{{Name: "detail", Weight: -0.5, Hash: "0d9c5b4a1e"}}, legacy.toMetadata().Loras)
+}
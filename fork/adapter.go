@@ -0,0 +1,85 @@
+package fork
+
+import (
+	"time"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Adapter that implements the types.GenerationParameters
+// interface on top of the Metadata of a fork.
+type Parameters struct {
+	Metadata
+	Created time.Time
+}
+
+// fooocus returns the adapter of the metadata in the Fooocus scheme.
+func (m Parameters) fooocus() fooocus.Parameters {
+	return fooocus.Parameters{Metadata: m.Fooocus(), Created: m.Created}
+}
+
+func (m Parameters) Version() string {
+	return m.version()
+}
+
+// SoftwareVersion returns the parsed version, see Version.
+func (m Parameters) SoftwareVersion() types.SoftwareVersion {
+	return types.ParseSoftwareVersion(m.Version())
+}
+
+func (m Parameters) Model() string {
+	return m.fooocus().Model()
+}
+
+func (m Parameters) LoRAs() []types.Lora {
+	return m.fooocus().LoRAs()
+}
+
+func (m Parameters) PositivePrompt() string {
+	return m.fooocus().PositivePrompt()
+}
+
+func (m Parameters) NegativePrompt() string {
+	return m.fooocus().NegativePrompt()
+}
+
+func (m Parameters) Seed() string {
+	return m.fooocus().Seed()
+}
+
+func (m Parameters) SeedValue() types.Seed {
+	return m.fooocus().SeedValue()
+}
+
+func (m Parameters) Sampler() string {
+	return m.fooocus().Sampler()
+}
+
+func (m Parameters) Scheduler() string {
+	return m.fooocus().Scheduler()
+}
+
+func (m Parameters) Size() (width int, height int) {
+	return m.fooocus().Size()
+}
+
+func (m Parameters) CreatedTime() time.Time {
+	return m.Created
+}
+
+func (m Parameters) Raw() interface{} {
+	return m.Metadata
+}
+
+// Modes returns the input image features used for the generation,
+// see fooocus.Parameters.Modes.
+func (m Parameters) Modes() []string {
+	return m.fooocus().Modes()
+}
+
+// ModeParameters returns the settings of the input image features,
+// see fooocus.Parameters.ModeParameters.
+func (m Parameters) ModeParameters() fooocus.ModeParameters {
+	return m.fooocus().ModeParameters()
+}
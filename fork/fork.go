@@ -0,0 +1,247 @@
+package fork
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bep/imagemeta"
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	m "github.com/fkleon/fooocus-metadata/types"
+)
+
+// Fork describes a fork of Fooocus and how it embeds metadata.
+type Fork struct {
+	// Name of the software, used as Source, e.g. "SimpleSDXL".
+	Software string
+	// Prefix of the version and the EXIF "Software" tag, e.g. "SimpleSDXL ".
+	VersionPrefix string
+	// Patterns of the file names the fork writes.
+	FilenamePatterns []*m.FilenamePattern
+	// Structure of the embedded metadata.
+	Layout Layout
+}
+
+var (
+	Defooocus = Fork{
+		Software:         "Defooocus",
+		VersionPrefix:    "Defooocus ",
+		FilenamePatterns: fooocus.DefaultFilenamePatterns,
+		Layout:           LayoutFooocus,
+	}
+	FooocusAPI = Fork{
+		Software:         "FooocusAPI",
+		VersionPrefix:    "Fooocus-API ",
+		FilenamePatterns: fooocus.DefaultFilenamePatterns,
+		Layout:           LayoutFooocus,
+	}
+	SimpleSDXL = Fork{
+		Software:         "SimpleSDXL",
+		VersionPrefix:    "SimpleSDXL ",
+		FilenamePatterns: fooocus.DefaultFilenamePatterns,
+		Layout:           LayoutSimple,
+	}
+	FooocusMRE = Fork{
+		Software:         "FooocusMRE",
+		VersionPrefix:    "Fooocus-MRE ",
+		FilenamePatterns: fooocus.DefaultFilenamePatterns,
+		Layout:           LayoutMRE,
+	}
+
+	// Forks that are registered as readers.
+	Forks = []Fork{Defooocus, FooocusAPI, SimpleSDXL, FooocusMRE}
+)
+
+// parameters returns the tag with the embedded parameters and its value.
+func (f Fork) parameters(file m.ImageMetadataContext) (paramTag imagemeta.TagInfo, parameters string, err error) {
+
+	if f.Layout == LayoutFooocus {
+		var scheme string
+		if paramTag, parameters, scheme, err = fooocus.EmbeddedParameters(file, f.VersionPrefix); err != nil {
+			return
+		}
+		if scheme != fooocus.Fooocus.String() {
+			return paramTag, "", fmt.Errorf("%w: %s", m.ErrUnsupportedScheme, scheme)
+		}
+		return
+	}
+
+	// Software version from EXIF "Software"
+	if _, softwareVersion, ok := file.StringTag(m.NamespaceEXIF, "Software"); ok {
+		if !strings.HasPrefix(softwareVersion, f.VersionPrefix) {
+			return paramTag, "", fmt.Errorf("EXIF: Unsupported software: %s: %w", softwareVersion, m.ErrNoMetadata)
+		}
+	}
+
+	// Parameters from EXIF "UserComment" or PNG "Comment"
+	paramTag, parameters, ok := file.StringTag(m.NamespaceEXIF, "UserComment")
+	if !ok {
+		if paramTag, parameters, ok = file.StringTag(m.NamespacePNG, "Comment"); !ok {
+			return paramTag, "", fmt.Errorf("Parameters not found: %w", m.ErrNoMetadata)
+		}
+	}
+	return
+}
+
+// parseMetadata parses the parameters in the layout of the fork.
+func (f Fork) parseMetadata(parameters string) (meta Metadata, err error) {
+
+	switch f.Layout {
+	case LayoutSimple:
+		meta, err = unmarshal[SimpleMetadata](parameters)
+	case LayoutMRE:
+		meta, err = unmarshal[MREMetadata](parameters)
+	default:
+		meta, err = unmarshal[FooocusMetadata](parameters)
+	}
+	if err != nil {
+		return meta, m.NewParseError(f.Software, "parameters", err)
+	}
+
+	return
+}
+
+// parseVersion returns the version recorded in the parameters, without
+// parsing the other keys. Versions that are not a string are empty.
+func (f Fork) parseVersion(parameters string) (string, error) {
+	var keys struct {
+		Version  json.RawMessage `json:"version"` // also matches "Version"
+		Software json.RawMessage `json:"software"`
+	}
+	if err := json.Unmarshal([]byte(parameters), &keys); err != nil {
+		return "", m.NewParseError(f.Software, "parameters", err)
+	}
+
+	key := keys.Version
+	if f.Layout == LayoutMRE {
+		key = keys.Software
+	}
+	var version string
+	_ = json.Unmarshal(key, &version)
+	return version, nil
+}
+
+func unmarshal[M Metadata](parameters string) (meta M, err error) {
+	err = json.Unmarshal([]byte(parameters), &meta)
+	return
+}
+
+// values returns the PNG text chunks to embed the metadata in.
+func (f Fork) values(metadata Metadata) map[string]interface{} {
+	if f.Layout == LayoutFooocus {
+		return map[string]interface{}{
+			"fooocus_scheme": fooocus.Fooocus.String(),
+			"parameters":     metadata,
+		}
+	}
+	return map[string]interface{}{
+		"Comment": metadata,
+	}
+}
+
+// ForkMetadataExtractor can decode embedded metadata of a Fork
+// from an image file.
+type ForkMetadataExtractor struct {
+	*m.FileMetadataExtractor
+	Fork Fork
+}
+
+func (e ForkMetadataExtractor) Decode(file m.ImageMetadataContext) (meta Metadata, err error) {
+	meta, _, err = e.decode(file)
+	return
+}
+
+// decode reads the embedded metadata and returns its provenance.
+func (e ForkMetadataExtractor) decode(file m.ImageMetadataContext) (meta Metadata, provenance m.Provenance, err error) {
+
+	paramTag, parameters, err := e.Fork.parameters(file)
+	if err != nil {
+		return meta, provenance, fmt.Errorf("%s: %w", e.Fork.Software, err)
+	}
+
+	// Fooocus and its forks use the same keys, but their own version.
+	// Check the version first, as the metadata of other forks may not
+	// parse in the layout of this fork.
+	version, err := e.Fork.parseVersion(parameters)
+	if err != nil {
+		return
+	}
+	if !strings.HasPrefix(version, e.Fork.VersionPrefix) {
+		return meta, provenance, fmt.Errorf("%s: Unsupported software: %s: %w", e.Fork.Software, version, m.ErrNoMetadata)
+	}
+
+	if meta, err = e.Fork.parseMetadata(parameters); err != nil {
+		return
+	}
+
+	provenance = m.EmbeddedProvenance(paramTag)
+	provenance.Scheme = meta.scheme()
+	return
+}
+
+func (e ForkMetadataExtractor) Extract(file m.ImageMetadataContext) (m.StructuredMetadata, error) {
+
+	var meta = m.StructuredMetadata{
+		Source: e.Fork.Software,
+	}
+
+	filename := filepath.Base(file.Filepath)
+	meta.Filename = e.ParseFilename(file)
+
+	slog.Debug("Checking embedded metadata..", "file", filename)
+	params, provenance, err := e.decode(file)
+	if err == nil {
+		meta.Created, meta.CreatedSource = e.ResolveCreated(file, time.Time{})
+		meta.Provenance = provenance
+		meta.Params = &Parameters{
+			Metadata: params,
+			Created:  meta.Created,
+		}
+		return meta, nil
+	}
+
+	return meta, err
+}
+
+func NewForkMetadataExtractor(fork Fork) m.Reader[Metadata] {
+	return ForkMetadataExtractor{
+		FileMetadataExtractor: &m.FileMetadataExtractor{
+			Software:         fork.Software,
+			FilenamePatterns: fork.FilenamePatterns,
+		},
+		Fork: fork,
+	}
+}
+
+// ForkMetadataWriter can embed metadata of a Fork into a PNG
+// image file.
+type ForkMetadataWriter struct {
+	*m.PngMetadataWriter
+	Fork Fork
+}
+
+func (w ForkMetadataWriter) Write(target io.Writer, metadata Metadata) error {
+	return w.CopyWrite(nil, target, metadata)
+}
+
+func (w ForkMetadataWriter) CopyWrite(source io.Reader, target io.Writer, metadata Metadata) error {
+	return w.Embed(source, target, w.Fork.values(metadata))
+}
+
+func NewForkMetadataWriter(fork Fork) m.Writer[Metadata] {
+	return ForkMetadataWriter{
+		PngMetadataWriter: m.NewPngMetadataWriter(),
+		Fork:              fork,
+	}
+}
+
+func init() {
+	for _, fork := range Forks {
+		extractor := NewForkMetadataExtractor(fork)
+		m.RegisterReader(fork.Software, extractor.Extract)
+	}
+}
@@ -0,0 +1,265 @@
+package fork
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/bep/imagemeta"
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/fooocusplus"
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	"github.com/fkleon/fooocus-metadata/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var forkTests = []struct {
+	fork       Fork
+	image      string
+	metadata   string
+	version    string
+	model      string
+	prompt     string
+	seed       string
+	loras      []types.Lora
+	provenance types.Provenance
+	extraKey   string
+	extraValue any
+	// Reader of the software the fork is based on.
+	upstream func(types.ImageMetadataContext) error
+}{
+	{
+		fork:     Defooocus,
+		image:    "./testdata/defooocus-meta.png",
+		metadata: "./testdata/meta-defooocus.json",
+		version:  "Defooocus v1.0.3",
+		model:    "juggernautXL_v8Rundiffusion",
+		prompt:   "A castle in the clouds",
+		seed:     "1874262158237151743",
+		loras:    []types.Lora{{Name: "sd_xl_offset_example-lora_1.0", Weight: 0.1}},
+		provenance: types.Provenance{
+			Location:  types.LocationEmbedded,
+			Container: "PNG/tEXt",
+			Key:       "parameters",
+			Scheme:    "fooocus",
+		},
+		extraKey:   "theme",
+		extraValue: "dark",
+		upstream:   decodeFooocus,
+	},
+	{
+		fork:     FooocusAPI,
+		image:    "./testdata/fooocusapi-meta.png",
+		metadata: "./testdata/meta-fooocusapi.json",
+		version:  "Fooocus-API v0.4.1.1",
+		model:    "juggernautXL_v8Rundiffusion",
+		prompt:   "A lighthouse at dusk",
+		seed:     "1874262158237151743",
+		loras:    []types.Lora{{Name: "sd_xl_offset_example-lora_1.0", Weight: 0.1}},
+		provenance: types.Provenance{
+			Location:  types.LocationEmbedded,
+			Container: "PNG/tEXt",
+			Key:       "parameters",
+			Scheme:    "fooocus",
+		},
+		extraKey:   "task_id",
+		extraValue: "8c0a1f2e4b7d4e7c9b1a2d3e4f5a6b7c",
+		upstream:   decodeFooocus,
+	},
+	{
+		fork:     SimpleSDXL,
+		image:    "./testdata/simplesdxl-meta.png",
+		metadata: "./testdata/meta-simplesdxl.json",
+		version:  "SimpleSDXL v2.1.1",
+		model:    "juggernautXL_v8Rundiffusion",
+		prompt:   "A koi pond in autumn",
+		seed:     "2846155912305816542",
+		loras:    []types.Lora{{Name: "sd_xl_offset_example-lora_1.0", Weight: 0.1}},
+		provenance: types.Provenance{
+			Location:  types.LocationEmbedded,
+			Container: "PNG/tEXt",
+			Key:       "Comment",
+			Scheme:    "fooocus",
+		},
+		extraKey:   "User",
+		extraValue: "guest",
+		upstream:   decodeFooocusPlus,
+	},
+	{
+		fork:     FooocusMRE,
+		image:    "./testdata/fooocusmre-meta.png",
+		metadata: "./testdata/meta-fooocusmre.json",
+		version:  "Fooocus-MRE v2.0.78.5",
+		model:    "sd_xl_base_1.0_0.9vae",
+		prompt:   "A fox in the snow",
+		seed:     "8735210455713210021",
+		loras:    []types.Lora{{Name: "sd_xl_offset_example-lora_1.0", Weight: 0.5}},
+		provenance: types.Provenance{
+			Location:  types.LocationEmbedded,
+			Container: "PNG/tEXt",
+			Key:       "Comment",
+		},
+		extraKey:   "img2img",
+		extraValue: false,
+		upstream:   decodeFooocusPlus,
+	},
+}
+
+func decodeFooocus(ctx types.ImageMetadataContext) error {
+	_, err := fooocus.NewFooocusMetadataExtractor().Decode(ctx)
+	return err
+}
+
+func decodeFooocusPlus(ctx types.ImageMetadataContext) error {
+	_, err := fooocusplus.NewFooocusPlusMetadataExtractor().Decode(ctx)
+	return err
+}
+
+func TestForks(t *testing.T) {
+	for _, tt := range forkTests {
+		t.Run(tt.fork.Software, func(t *testing.T) {
+			ctx, err := image.NewContextFromFile(tt.image)
+			require.NoError(t, err)
+
+			extractor := NewForkMetadataExtractor(tt.fork)
+
+			t.Run("Extract", func(t *testing.T) {
+				meta, err := extractor.Extract(*ctx)
+				require.NoError(t, err)
+
+				assert.Equal(t, tt.fork.Software, meta.Source)
+				assert.Equal(t, tt.version, meta.Params.Version())
+				assert.Equal(t, tt.model, meta.Params.Model())
+				assert.Equal(t, tt.prompt, meta.Params.PositivePrompt())
+				assert.Equal(t, tt.seed, meta.Params.Seed())
+				assert.Equal(t, tt.loras, meta.Params.LoRAs())
+				assert.Equal(t, tt.provenance, meta.Provenance)
+
+				// Unknown keys are kept
+				data, err := json.Marshal(meta.Params.Raw())
+				require.NoError(t, err)
+				var object map[string]any
+				require.NoError(t, json.Unmarshal(data, &object))
+				assert.Equal(t, tt.extraValue, object[tt.extraKey])
+			})
+
+			t.Run("RejectsOtherForks", func(t *testing.T) {
+				for _, other := range Forks {
+					if other.Software == tt.fork.Software {
+						continue
+					}
+					_, err := NewForkMetadataExtractor(other).Decode(*ctx)
+					assert.ErrorIs(t, err, types.ErrNoMetadata, other.Software)
+
+					var parseErr *types.ParseError
+					assert.False(t, errors.As(err, &parseErr), other.Software)
+				}
+				assert.Error(t, tt.upstream(*ctx))
+			})
+
+			t.Run("Write", func(t *testing.T) {
+				data, err := os.ReadFile(tt.metadata)
+				require.NoError(t, err)
+
+				meta, err := tt.fork.parseMetadata(string(data))
+				require.NoError(t, err)
+
+				var buf bytes.Buffer
+				err = NewForkMetadataWriter(tt.fork).Write(&buf, meta)
+				require.NoError(t, err)
+
+				written, err := image.NewContextFromReader(bytes.NewReader(buf.Bytes()))
+				require.NoError(t, err)
+
+				decoded, err := extractor.Decode(*written)
+				require.NoError(t, err)
+				assert.Equal(t, meta, decoded)
+			})
+		})
+	}
+}
+
+func TestDecodeRejectsOtherVersions(t *testing.T) {
+	for _, tt := range []struct {
+		fork  Fork
+		key   string
+		value string
+	}{
+		{Defooocus, "parameters", `{"version": "Fooocus v2.5.5"}`},
+		{Defooocus, "parameters", `{"version": "Fooocus-API v0.4.1.1"}`},
+		{FooocusAPI, "parameters", `{"version": "Defooocus v1.0.3"}`},
+		{SimpleSDXL, "Comment", `{"Version": "FooocusPlus 1.0.0"}`},
+		{FooocusMRE, "Comment", `{"software": "Fooocus-MRE-fork v1.0.0"}`},
+		// Metadata of other tools that does not parse in the layout of the fork
+		{FooocusMRE, "Comment", `{"Seed": "3864674281", "Version": "FooocusPlus 1.0.0"}`},
+		{FooocusMRE, "Comment", `{"Seed": "3864674281", "Version": "SimpleSDXL v2.1.1"}`},
+		{SimpleSDXL, "Comment", `{"seed": "abc", "software": "Fooocus-MRE v2.0.78.5"}`},
+		{Defooocus, "parameters", `{"steps": "abc", "version": "Fooocus v2.5.5"}`},
+		{FooocusAPI, "parameters", `{"steps": "abc", "version": "Fooocus v2.5.5"}`},
+		{FooocusAPI, "parameters", `{"steps": "abc", "version": 2}`},
+	} {
+		t.Run(tt.fork.Software, func(t *testing.T) {
+			_, err := NewForkMetadataExtractor(tt.fork).Decode(types.ImageMetadataContext{
+				EmbeddedMetadata: types.Tags{
+					{Namespace: "PNG/tEXt", Tag: "fooocus_scheme", Value: "fooocus"},
+					{Namespace: "PNG/tEXt", Tag: tt.key, Value: tt.value},
+				},
+			})
+			assert.ErrorIs(t, err, types.ErrNoMetadata)
+
+			var parseErr *types.ParseError
+			assert.False(t, errors.As(err, &parseErr))
+		})
+	}
+}
+
+func TestDecodeParseError(t *testing.T) {
+	for _, tt := range []struct {
+		fork  Fork
+		key   string
+		value string
+	}{
+		{Defooocus, "parameters", `{"steps": "abc", "version": "Defooocus v1.0.3"}`},
+		{SimpleSDXL, "Comment", `{"Steps": "abc", "Version": "SimpleSDXL v2.1.1"}`},
+		{FooocusMRE, "Comment", `{"seed": "abc", "software": "Fooocus-MRE v2.0.78.5"}`},
+		{FooocusMRE, "Comment", `{"software": "Fooocus-MRE v2.0.78.5"`},
+	} {
+		t.Run(tt.fork.Software, func(t *testing.T) {
+			_, err := NewForkMetadataExtractor(tt.fork).Decode(types.ImageMetadataContext{
+				EmbeddedMetadata: types.Tags{
+					{Namespace: "PNG/tEXt", Tag: "fooocus_scheme", Value: "fooocus"},
+					{Namespace: "PNG/tEXt", Tag: tt.key, Value: tt.value},
+				},
+			})
+			var parseErr *types.ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tt.fork.Software, parseErr.Software)
+		})
+	}
+}
+
+func TestExtractMetadataFromExif(t *testing.T) {
+	var exifData types.Tags
+
+	exifData.Add(imagemeta.TagInfo{
+		Source:    imagemeta.EXIF,
+		Namespace: "IFD0",
+		Tag:       "Software",
+		Value:     "SimpleSDXL v2.1.1",
+	})
+	exifData.Add(imagemeta.TagInfo{
+		Source:    imagemeta.EXIF,
+		Namespace: "IFD0",
+		Tag:       "UserComment",
+		Value:     `{"Prompt": "a cat", "Version": "SimpleSDXL v2.1.1"}`,
+	})
+
+	meta, err := NewForkMetadataExtractor(SimpleSDXL).Decode(types.ImageMetadataContext{
+		EmbeddedMetadata: exifData,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "a cat", meta.Fooocus().Prompt)
+}
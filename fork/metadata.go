@@ -0,0 +1,283 @@
+// Package fork implements reading and writing metadata of forks of
+// [Fooocus] (image generation parameters).
+//
+// Forks embed JSON metadata similar to Fooocus, but with their own
+// version, e.g. "SimpleSDXL v2.1.1", and additional keys of their
+// extra features. Each fork is described by a Fork, which names the
+// software, the prefix of its version, its file names and the Layout
+// of its metadata. Keys that are not part of the layout are kept in
+// the Extra field of the metadata.
+//
+// Supported forks:
+//   - [DefooocusAI], in the native Fooocus scheme
+//   - [Fooocus-API], in the native Fooocus scheme
+//   - [SimpleSDXL], with title case keys
+//   - [Fooocus-MRE], with lower case keys and LoRA slots
+//
+// [Fooocus]: https://github.com/lllyasviel/Fooocus
+// [DefooocusAI]: https://github.com/ehristoforu/DeFooocus
+// [Fooocus-API]: https://github.com/mrhan1993/Fooocus-API
+// [SimpleSDXL]: https://github.com/metercai/SimpleSDXL
+// [Fooocus-MRE]: https://github.com/MoonRide303/Fooocus-MRE
+package fork
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Layout is the structure of the metadata a fork embeds.
+type Layout uint8
+
+const (
+	// Native Fooocus scheme in the PNG "parameters" text chunk,
+	// see FooocusMetadata.
+	LayoutFooocus Layout = iota
+	// Title case keys in the PNG "Comment" text chunk,
+	// see SimpleMetadata.
+	LayoutSimple
+	// Lower case keys with LoRA slots in the PNG "Comment" text chunk,
+	// see MREMetadata.
+	LayoutMRE
+)
+
+// Value of LoRA and refiner model keys of Fooocus-MRE if none was selected.
+const none = "None"
+
+// Metadata is the metadata of a fork in the Layout of the fork.
+type Metadata interface {
+	// Fooocus returns the parameters in the native Fooocus scheme.
+	Fooocus() fooocus.Metadata
+
+	// version returns the version of the fork, e.g. "SimpleSDXL v2.1.1".
+	version() string
+	// scheme returns the name of the metadata scheme, if any.
+	scheme() string
+}
+
+// FooocusMetadata is the metadata of forks that use LayoutFooocus.
+type FooocusMetadata struct {
+	fooocus.Metadata
+}
+
+func (meta FooocusMetadata) Fooocus() fooocus.Metadata {
+	return meta.Metadata
+}
+
+func (meta FooocusMetadata) version() string {
+	return meta.Version
+}
+
+func (meta FooocusMetadata) scheme() string {
+	return fooocus.Fooocus.String()
+}
+
+// SimpleMetadata is the metadata of forks that use LayoutSimple.
+type SimpleMetadata struct {
+	AdmGuidance        *fooocus.AdmGuidance `json:"ADM Guidance"`
+	BaseModel          string               `json:"Base Model"`
+	BaseModelHash      string               `json:"Base Model Hash"`
+	ClipSkip           uint8                `json:"CLIP Skip"`
+	FooocusV2Expansion string               `json:"Fooocus V2 Expansion"`
+	GuidanceScale      float32              `json:"Guidance Scale"`
+	Loras              []fooocus.Lora       `json:"LoRAs"`
+	MetadataScheme     string               `json:"Metadata Scheme"`
+	NegativePrompt     string               `json:"Negative Prompt"`
+	Performance        string               `json:"Performance"`
+	Prompt             string               `json:"Prompt"`
+	RefinerModel       string               `json:"Refiner Model,omitempty"`
+	RefinerSwitch      float32              `json:"Refiner Switch"`
+	Resolution         *fooocus.Resolution  `json:"Resolution"`
+	Sampler            string               `json:"Sampler"`
+	Scheduler          string               `json:"Scheduler"`
+	Seed               string               `json:"Seed"`
+	Sharpness          float32              `json:"Sharpness"`
+	Steps              uint8                `json:"Steps"`
+	Styles             fooocus.Styles       `json:"Styles"`
+	Vae                string               `json:"VAE"`
+	Version            string               `json:"Version"`
+
+	// Keys that are not part of the struct.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// simpleMetadata has the fields of SimpleMetadata without its JSON methods.
+type simpleMetadata SimpleMetadata
+
+func (meta *SimpleMetadata) UnmarshalJSON(data []byte) (err error) {
+	if err = json.Unmarshal(data, (*simpleMetadata)(meta)); err != nil {
+		return
+	}
+	meta.Extra, err = types.UnknownFields(data, meta)
+	return
+}
+
+func (meta SimpleMetadata) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(simpleMetadata(meta))
+	if err != nil {
+		return nil, err
+	}
+	return types.MergeFields(data, meta.Extra)
+}
+
+func (meta SimpleMetadata) Fooocus() fooocus.Metadata {
+	return fooocus.Metadata{
+		AdmGuidance:     meta.AdmGuidance,
+		BaseModel:       meta.BaseModel,
+		BaseModelHash:   meta.BaseModelHash,
+		ClipSkip:        meta.ClipSkip,
+		GuidanceScale:   meta.GuidanceScale,
+		Loras:           meta.Loras,
+		MetadataScheme:  meta.scheme(),
+		NegativePrompt:  meta.NegativePrompt,
+		Performance:     meta.Performance,
+		Prompt:          meta.Prompt,
+		PromptExpansion: meta.FooocusV2Expansion,
+		RefinerModel:    meta.RefinerModel,
+		RefinerSwitch:   meta.RefinerSwitch,
+		Resolution:      meta.Resolution,
+		Sampler:         meta.Sampler,
+		Scheduler:       meta.Scheduler,
+		Seed:            meta.Seed,
+		Sharpness:       meta.Sharpness,
+		Steps:           meta.Steps,
+		Styles:          meta.Styles,
+		Vae:             meta.Vae,
+		Version:         meta.Version,
+	}
+}
+
+func (meta SimpleMetadata) version() string {
+	return meta.Version
+}
+
+// scheme returns the scheme in lower case, e.g. "fooocus".
+func (meta SimpleMetadata) scheme() string {
+	return strings.ToLower(meta.MetadataScheme)
+}
+
+// MREMetadata is the metadata of forks that use LayoutMRE.
+type MREMetadata struct {
+	Prompt          string   `json:"prompt"`
+	NegativePrompt  string   `json:"negative_prompt"`
+	Styles          []string `json:"styles"`
+	Performance     string   `json:"performance"`
+	Width           uint16   `json:"width"`
+	Height          uint16   `json:"height"`
+	Seed            uint64   `json:"seed"`
+	Sampler         string   `json:"sampler"`
+	Scheduler       string   `json:"scheduler"`
+	Steps           uint8    `json:"steps"`
+	Switch          uint8    `json:"switch"`
+	Cfg             float32  `json:"cfg"`
+	Sharpness       float32  `json:"sharpness"`
+	BaseClipSkip    int8     `json:"base_clip_skip"`
+	RefinerClipSkip int8     `json:"refiner_clip_skip"`
+	BaseModel       string   `json:"base_model"`
+	RefinerModel    string   `json:"refiner_model"`
+	L1              string   `json:"l1"`
+	W1              float32  `json:"w1"`
+	L2              string   `json:"l2"`
+	W2              float32  `json:"w2"`
+	L3              string   `json:"l3"`
+	W3              float32  `json:"w3"`
+	L4              string   `json:"l4"`
+	W4              float32  `json:"w4"`
+	L5              string   `json:"l5"`
+	W5              float32  `json:"w5"`
+	Software        string   `json:"software"`
+
+	// Keys that are not part of the struct.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// mreMetadata has the fields of MREMetadata without its JSON methods.
+type mreMetadata MREMetadata
+
+func (meta *MREMetadata) UnmarshalJSON(data []byte) (err error) {
+	if err = json.Unmarshal(data, (*mreMetadata)(meta)); err != nil {
+		return
+	}
+	meta.Extra, err = types.UnknownFields(data, meta)
+	return
+}
+
+func (meta MREMetadata) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(mreMetadata(meta))
+	if err != nil {
+		return nil, err
+	}
+	return types.MergeFields(data, meta.Extra)
+}
+
+// Loras returns the selected LoRAs, skipping unused slots.
+func (meta MREMetadata) Loras() []fooocus.Lora {
+	slots := []struct {
+		name   string
+		weight float32
+	}{
+		{meta.L1, meta.W1},
+		{meta.L2, meta.W2},
+		{meta.L3, meta.W3},
+		{meta.L4, meta.W4},
+		{meta.L5, meta.W5},
+	}
+
+	var loras []fooocus.Lora
+	for _, slot := range slots {
+		if slot.name == "" || slot.name == none {
+			continue
+		}
+		loras = append(loras, fooocus.Lora{Name: slot.name, Weight: slot.weight})
+	}
+	return loras
+}
+
+// Resolution returns the width and height as Fooocus resolution.
+func (meta MREMetadata) Resolution() *fooocus.Resolution {
+	return fooocus.ResolutionOf(meta.Width, meta.Height)
+}
+
+func (meta MREMetadata) Fooocus() fooocus.Metadata {
+	converted := fooocus.Metadata{
+		BaseModel:      meta.BaseModel,
+		GuidanceScale:  meta.Cfg,
+		Loras:          meta.Loras(),
+		NegativePrompt: meta.NegativePrompt,
+		Performance:    meta.Performance,
+		Prompt:         meta.Prompt,
+		Resolution:     meta.Resolution(),
+		Sampler:        meta.Sampler,
+		Scheduler:      meta.Scheduler,
+		Seed:           strconv.FormatUint(meta.Seed, 10),
+		Sharpness:      meta.Sharpness,
+		Steps:          meta.Steps,
+		Styles:         meta.Styles,
+		Version:        meta.Software,
+	}
+	// CLIP skip is the last CLIP layer to use as in ComfyUI, e.g. -2
+	// for a Fooocus CLIP skip of 2. Fooocus has no refiner CLIP skip.
+	if meta.BaseClipSkip < 0 {
+		converted.ClipSkip = uint8(-int(meta.BaseClipSkip))
+	}
+	// Switch is the step at which the refiner takes over
+	if meta.RefinerModel != none {
+		converted.RefinerModel = meta.RefinerModel
+		if meta.Steps > 0 {
+			converted.RefinerSwitch = float32(meta.Switch) / float32(meta.Steps)
+		}
+	}
+	return converted
+}
+
+func (meta MREMetadata) version() string {
+	return meta.Software
+}
+
+func (meta MREMetadata) scheme() string {
+	return ""
+}
@@ -0,0 +1,66 @@
+package fork
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetadataKeepsUnknownKeys(t *testing.T) {
+	meta, err := Defooocus.parseMetadata(`{"prompt": "a cat", "version": "Defooocus v1.0.3", "theme": "dark"}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "a cat", meta.Fooocus().Prompt)
+	assert.Equal(t, map[string]json.RawMessage{"theme": json.RawMessage(`"dark"`)}, meta.Fooocus().Extra)
+
+	data, err := json.Marshal(meta)
+	require.NoError(t, err)
+
+	var object map[string]any
+	require.NoError(t, json.Unmarshal(data, &object))
+	assert.Equal(t, "dark", object["theme"])
+	assert.Equal(t, "Defooocus v1.0.3", object["version"])
+}
+
+func TestMRELoras(t *testing.T) {
+	meta := MREMetadata{
+		L1: "sd_xl_offset_example-lora_1.0.safetensors", W1: 0.5,
+		L2: "None", W2: 0.5,
+		L4: "detail.safetensors", W4: -0.25,
+	}
+	assert.Equal(t, []fooocus.Lora{
+		{Name: "sd_xl_offset_example-lora_1.0.safetensors", Weight: 0.5},
+		{Name: "detail.safetensors", Weight: -0.25},
+	}, meta.Loras())
+
+	assert.Empty(t, MREMetadata{}.Loras())
+}
+
+func TestMREFooocus(t *testing.T) {
+	meta := MREMetadata{
+		Width: 1152, Height: 896,
+		Seed:         8735210455713210021,
+		Steps:        30,
+		Switch:       20,
+		BaseClipSkip: -2,
+		RefinerModel: "sd_xl_refiner_1.0_0.9vae.safetensors",
+		Software:     "Fooocus-MRE v2.0.78.5",
+	}
+	converted := meta.Fooocus()
+	assert.Equal(t, uint8(2), converted.ClipSkip)
+	assert.Equal(t, fooocus.ResolutionOf(1152, 896), converted.Resolution)
+	assert.Equal(t, "8735210455713210021", converted.Seed)
+	assert.Equal(t, "sd_xl_refiner_1.0_0.9vae.safetensors", converted.RefinerModel)
+	assert.InDelta(t, 0.667, converted.RefinerSwitch, 0.001)
+	assert.Equal(t, "Fooocus-MRE v2.0.78.5", converted.Version)
+
+	meta.BaseClipSkip = 0
+	assert.Zero(t, meta.Fooocus().ClipSkip)
+
+	meta.RefinerModel = none
+	assert.Empty(t, meta.Fooocus().RefinerModel)
+	assert.Zero(t, meta.Fooocus().RefinerSwitch)
+}
@@ -0,0 +1,304 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Largest payload of a JPEG APP1 segment, including its signature.
+const maxJpegSegmentSize = 0xffff - 2
+
+// Largest XMP packet that fits into a single JPEG APP1 segment.
+const maxJpegXmpSize = maxJpegSegmentSize - len(jpegXmpSignature)
+
+// Signature of the JPEG APP1 segment with the EXIF data.
+const jpegExifSignature = "Exif\x00\x00"
+
+// container describes where a kind of metadata is stored in each image
+// format, see embed.
+type container struct {
+	name string
+	// PNG chunk type, the prefix of the data of the chunks to replace,
+	// e.g. the keyword, and the header of the data of a new chunk
+	pngType, pngPrefix, pngHeader string
+	// Signature of the JPEG APP1 segment
+	jpegSignature string
+	// WebP chunk FourCC, its VP8X flag and the chunk it must precede
+	webpFourCC, webpBefore string
+	webpFlag               byte
+}
+
+var (
+	xmpContainer = container{
+		name:          "XMP packet",
+		pngType:       "iTXt",
+		pngPrefix:     types.PngXMPKeyword + "\x00",
+		pngHeader:     types.PngXMPKeyword + "\x00\x00\x00\x00\x00",
+		jpegSignature: jpegXmpSignature,
+		webpFourCC:    "XMP ",
+		webpFlag:      0x04,
+	}
+	exifContainer = container{
+		name:          "EXIF data",
+		pngType:       "eXIf",
+		jpegSignature: jpegExifSignature,
+		webpFourCC:    "EXIF",
+		webpBefore:    "XMP ",
+		webpFlag:      0x08,
+	}
+)
+
+// EmbedXMP copies a PNG, JPEG or WebP image from source to target with
+// the given XMP packet, which replaces any XMP packet of the source.
+// The image data and the other metadata are kept.
+func EmbedXMP(source io.Reader, target io.Writer, packet []byte) error {
+	return embed(source, target, xmpContainer, func([]byte) ([]byte, error) {
+		return packet, nil
+	})
+}
+
+// embed copies a PNG, JPEG or WebP image from source to target, with the
+// metadata of the container replaced by the result of update. Update is
+// called with the existing metadata, or nil if there is none.
+func embed(source io.Reader, target io.Writer, c container, update func(old []byte) ([]byte, error)) error {
+	data, err := io.ReadAll(source)
+	if err != nil {
+		return fmt.Errorf("failed to read source: %w", err)
+	}
+
+	switch mime := http.DetectContentType(data); mime {
+	case "image/png":
+		data, err = embedPng(data, c, update)
+	case "image/jpeg":
+		data, err = embedJpeg(data, c, update)
+	case "image/webp":
+		data, err = embedWebp(data, c, update)
+	default:
+		return fmt.Errorf("%w: %s", types.ErrUnsupportedMIME, mime)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = target.Write(data)
+	return err
+}
+
+// embedPng inserts a chunk with the metadata after the IHDR chunk,
+// and removes the existing chunks.
+func embedPng(data []byte, c container, update func(old []byte) ([]byte, error)) ([]byte, error) {
+	// Signature and IHDR chunk
+	const ihdrEnd = 8 + 8 + 13 + 4
+	if len(data) < ihdrEnd || string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("%w: missing IHDR", errInvalidHeader)
+	}
+
+	var old []byte
+	var chunks []byte
+	for offset := ihdrEnd; offset < len(data); {
+		if offset+12 > len(data) {
+			return nil, fmt.Errorf("%w: truncated chunk", errInvalidHeader)
+		}
+		length := int(binary.BigEndian.Uint32(data[offset:]))
+		end := offset + 12 + length
+		if end > len(data) || end < offset {
+			return nil, fmt.Errorf("%w: truncated chunk", errInvalidHeader)
+		}
+		chunkType, chunkData := string(data[offset+4:offset+8]), data[offset+8:end-4]
+		if chunkType == c.pngType && bytes.HasPrefix(chunkData, []byte(c.pngPrefix)) {
+			if old == nil {
+				old = chunkData[len(c.pngPrefix):]
+			}
+		} else {
+			chunks = append(chunks, data[offset:end]...)
+		}
+		offset = end
+	}
+
+	payload, err := update(old)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, data[:ihdrEnd]...)
+	out = appendPngChunk(out, c.pngType, append([]byte(c.pngHeader), payload...))
+	return append(out, chunks...), nil
+}
+
+func appendPngChunk(out []byte, chunkType string, data []byte) []byte {
+	start := len(out)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, chunkType...)
+	out = append(out, data...)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out[start+4:]))
+}
+
+// embedJpeg inserts an APP1 segment with the metadata after the leading
+// APP0 segment and, unless it is the EXIF data itself, the EXIF APP1
+// segment, and removes the existing segments. Metadata larger than a
+// single segment fails with types.ErrMetadataTooLarge, as Extended XMP
+// is not supported.
+func embedJpeg(data []byte, c container, update func(old []byte) ([]byte, error)) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, fmt.Errorf("%w: missing SOI", errInvalidHeader)
+	}
+
+	var old []byte
+	var segments []byte
+	// Offset into segments at which the new segment is inserted
+	insert := -1
+	offset := 2
+	for {
+		if offset+4 > len(data) || data[offset] != 0xff {
+			return nil, fmt.Errorf("%w: invalid marker", errInvalidHeader)
+		}
+		marker := data[offset+1]
+		if marker == 0xd9 || marker == 0xda {
+			// End of image or start of scan, copy the rest
+			break
+		}
+		end := offset + 2 + int(binary.BigEndian.Uint16(data[offset+2:]))
+		if end > len(data) || end < offset+4 {
+			return nil, fmt.Errorf("%w: invalid segment length", errInvalidHeader)
+		}
+
+		payload := data[offset+4 : end]
+		isOld := marker == 0xe1 && bytes.HasPrefix(payload, []byte(c.jpegSignature))
+		isLeading := marker == 0xe0 ||
+			(marker == 0xe1 && c.jpegSignature != jpegExifSignature && bytes.HasPrefix(payload, []byte(jpegExifSignature)))
+		if insert < 0 && !isLeading && !isOld {
+			insert = len(segments)
+		}
+		if isOld {
+			if old == nil {
+				old = payload[len(c.jpegSignature):]
+			}
+		} else {
+			segments = append(segments, data[offset:end]...)
+		}
+		offset = end
+	}
+	if insert < 0 {
+		insert = len(segments)
+	}
+
+	payload, err := update(old)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.jpegSignature)+len(payload) > maxJpegSegmentSize {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes of a JPEG segment", types.ErrMetadataTooLarge, c.name, maxJpegSegmentSize-len(c.jpegSignature))
+	}
+	segment := []byte{0xff, 0xe1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(2+len(c.jpegSignature)+len(payload)))
+	segment = append(segment, c.jpegSignature...)
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segments[:insert]...)
+	out = append(out, segment...)
+	out = append(out, segments[insert:]...)
+	return append(out, data[offset:]...), nil
+}
+
+// embedWebp appends a chunk with the metadata, or inserts it before
+// the chunk it must precede, and removes the existing chunks. Images in
+// the simple format are converted to the extended format, which is
+// required for metadata.
+func embedWebp(data []byte, c container, update func(old []byte) ([]byte, error)) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("%w: missing RIFF WEBP", errInvalidHeader)
+	}
+
+	var old []byte
+	type chunk struct {
+		fourCC string
+		data   []byte
+	}
+	var chunks []chunk
+	if len(data) < 16 || string(data[12:16]) != "VP8X" {
+		props, err := readWebpProperties(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		var flags byte
+		if props.ColorType == types.ColorRGBA {
+			flags |= 0x10
+		}
+		chunks = append(chunks, chunk{"VP8X", vp8x(flags, props.Width, props.Height)})
+	}
+
+	for offset := 12; offset < len(data); {
+		if offset+8 > len(data) {
+			return nil, fmt.Errorf("%w: truncated chunk", errInvalidHeader)
+		}
+		length := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		end := offset + 8 + length
+		if end > len(data) || end < offset {
+			return nil, fmt.Errorf("%w: truncated chunk", errInvalidHeader)
+		}
+
+		fourCC, content := string(data[offset:offset+4]), data[offset+8:end]
+		switch fourCC {
+		case "VP8X":
+			if length != 10 {
+				return nil, fmt.Errorf("%w: VP8X", errInvalidHeader)
+			}
+			chunks = append(chunks, chunk{fourCC, append([]byte{}, content...)})
+		case c.webpFourCC:
+			if old == nil {
+				old = content
+			}
+		default:
+			chunks = append(chunks, chunk{fourCC, content})
+		}
+		// Skip the padding byte, which may be missing after the last chunk
+		offset = min(end+length%2, len(data))
+	}
+
+	payload, err := update(old)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, data[:12]...)
+	inserted := false
+	for _, ch := range chunks {
+		if ch.fourCC == "VP8X" {
+			ch.data[0] |= c.webpFlag
+		}
+		if !inserted && ch.fourCC == c.webpBefore {
+			out = appendWebpChunk(out, c.webpFourCC, payload)
+			inserted = true
+		}
+		out = appendWebpChunk(out, ch.fourCC, ch.data)
+	}
+	if !inserted {
+		out = appendWebpChunk(out, c.webpFourCC, payload)
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
+
+// vp8x returns the content of a VP8X chunk with the given
+// flags and canvas size.
+func vp8x(flags byte, width int, height int) []byte {
+	chunk := []byte{flags, 0, 0, 0}
+	chunk = append(chunk, byte(width-1), byte((width-1)>>8), byte((width-1)>>16))
+	return append(chunk, byte(height-1), byte((height-1)>>8), byte((height-1)>>16))
+}
+
+// appendWebpChunk appends a chunk, padded to an even size.
+func appendWebpChunk(out []byte, fourCC string, data []byte) []byte {
+	out = append(out, fourCC...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	if len(data)%2 != 0 {
+		out = append(out, 0)
+	}
+	return out
+}
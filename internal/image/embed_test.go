@@ -0,0 +1,98 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+func TestEmbedXMP(t *testing.T) {
+	testCases := []struct {
+		path string
+		mime string
+	}{
+		{"testdata/sample.png", "image/png"},
+		{"testdata/sample.jpg", "image/jpeg"},
+		{"testdata/sample.webp", "image/webp"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mime, func(t *testing.T) {
+			source, err := os.ReadFile(tc.path)
+			require.NoError(t, err)
+			original, err := NewContextFromReader(bytes.NewReader(source))
+			require.NoError(t, err)
+
+			var target bytes.Buffer
+			require.NoError(t, EmbedXMP(bytes.NewReader(source), &target, []byte(samplePacket)))
+
+			ctx, err := NewContextFromReader(bytes.NewReader(target.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, tc.mime, ctx.MIME)
+			assert.Equal(t, original.Properties, ctx.Properties)
+			assertXmpPrompt(t, ctx)
+
+			// Other metadata is kept
+			for _, tag := range original.EmbeddedMetadata {
+				assert.Contains(t, ctx.EmbeddedMetadata, tag)
+			}
+
+			// The packet is replaced
+			packet := bytes.ReplaceAll([]byte(samplePacket), []byte("A sunflower field"), []byte("A poppy field"))
+			var replaced bytes.Buffer
+			require.NoError(t, EmbedXMP(bytes.NewReader(target.Bytes()), &replaced, packet))
+
+			ctx, err = NewContextFromReader(bytes.NewReader(replaced.Bytes()))
+			require.NoError(t, err)
+			descriptions := ctx.EmbeddedMetadata.Lookup(types.NamespaceXMPDublinCore, "description")
+			require.Len(t, descriptions, 1)
+			assert.Equal(t, "A poppy field", descriptions[0].Value)
+		})
+	}
+}
+
+func TestEmbedXMP_SimpleWebP(t *testing.T) {
+	webp, err := os.ReadFile("testdata/sample.webp")
+	require.NoError(t, err)
+
+	// Only the VP8 chunk of the extended format
+	vp8 := 30
+	require.Equal(t, "VP8 ", string(webp[vp8:vp8+4]))
+	end := vp8 + 8 + int(binary.LittleEndian.Uint32(webp[vp8+4:]))
+	simple := append([]byte("RIFF\x00\x00\x00\x00WEBP"), webp[vp8:end]...)
+	binary.LittleEndian.PutUint32(simple[4:8], uint32(len(simple)-8))
+
+	var target bytes.Buffer
+	require.NoError(t, EmbedXMP(bytes.NewReader(simple), &target, []byte(samplePacket)))
+	data := target.Bytes()
+	assert.Equal(t, "VP8X", string(data[12:16]))
+
+	ctx, err := NewContextFromReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, types.ImageProperties{Width: 100, Height: 75, ColorType: types.ColorYCbCr, BitDepth: 8, Frames: 1}, ctx.Properties)
+	assertXmpPrompt(t, ctx)
+}
+
+func TestEmbedXMP_Errors(t *testing.T) {
+	var target bytes.Buffer
+	err := EmbedXMP(bytes.NewReader(gifWithComments(t, 1)), &target, []byte(samplePacket))
+	assert.ErrorIs(t, err, types.ErrUnsupportedMIME)
+
+	jpeg, err := os.ReadFile("testdata/sample.jpg")
+	require.NoError(t, err)
+	err = EmbedXMP(bytes.NewReader(jpeg), &target, make([]byte, maxJpegXmpSize+1))
+	assert.ErrorIs(t, err, types.ErrMetadataTooLarge)
+	require.NoError(t, EmbedXMP(bytes.NewReader(jpeg), &target, make([]byte, maxJpegXmpSize)))
+
+	// Truncated chunk
+	png, err := os.ReadFile("testdata/sample.png")
+	require.NoError(t, err)
+	err = EmbedXMP(bytes.NewReader(png[:40]), &target, []byte(samplePacket))
+	assert.ErrorIs(t, err, errInvalidHeader)
+}
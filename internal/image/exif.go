@@ -0,0 +1,98 @@
+package image
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// EXIF tags of IFD0 with ASCII values, see EmbedExif.
+const (
+	ExifImageDescription uint16 = 0x010e
+	ExifSoftware         uint16 = 0x0131
+	ExifArtist           uint16 = 0x013b
+	ExifCopyright        uint16 = 0x8298
+	ExifMakerNote        uint16 = 0x927c
+	ExifUserComment      uint16 = 0x9286
+)
+
+// Empty big-endian TIFF structure with an IFD0 without entries.
+var emptyExif = []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00")
+
+// EmbedExif copies a PNG, JPEG or WebP image from source to target with
+// the given ASCII values set in IFD0 of its EXIF data, e.g. ExifArtist.
+// The other EXIF tags, the image data and the other metadata are kept.
+// EXIF data is added to images without, as PNG eXIf chunk, JPEG APP1
+// segment or WebP EXIF chunk.
+func EmbedExif(source io.Reader, target io.Writer, values map[uint16]string) error {
+	return embed(source, target, exifContainer, func(old []byte) ([]byte, error) {
+		if old == nil {
+			old = emptyExif
+		}
+		return updateExif(old, values)
+	})
+}
+
+// updateExif returns the TIFF structure of EXIF data with the given ASCII
+// values set in IFD0. Rather than rewriting the structure, a new IFD0 is
+// appended and the header pointed to it, so that the offsets of the other
+// IFDs and values remain valid.
+func updateExif(tiff []byte, values map[uint16]string) ([]byte, error) {
+	var order binary.ByteOrder
+	switch {
+	case len(tiff) < 8:
+		return nil, fmt.Errorf("%w: truncated TIFF header", errInvalidHeader)
+	case string(tiff[:4]) == "II\x2a\x00":
+		order = binary.LittleEndian
+	case string(tiff[:4]) == "MM\x00\x2a":
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: invalid TIFF header", errInvalidHeader)
+	}
+
+	// Entries of the IFD0 by tag, and the offset of the next IFD
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return nil, fmt.Errorf("%w: invalid IFD0 offset", errInvalidHeader)
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	end := offset + 2 + 12*count
+	if end+4 > len(tiff) {
+		return nil, fmt.Errorf("%w: truncated IFD0", errInvalidHeader)
+	}
+	entries := make(map[uint16][]byte, count+len(values))
+	for i := offset + 2; i < end; i += 12 {
+		entries[order.Uint16(tiff[i:])] = tiff[i : i+12]
+	}
+	next := tiff[end : end+4]
+
+	// Values longer than four bytes precede the IFD, at word boundaries
+	out := slices.Clone(tiff)
+	for _, tag := range slices.Sorted(maps.Keys(values)) {
+		value := append([]byte(values[tag]), 0)
+		entry := make([]byte, 12)
+		order.PutUint16(entry, tag)
+		// ASCII
+		order.PutUint16(entry[2:], 2)
+		order.PutUint32(entry[4:], uint32(len(value)))
+		if len(value) <= 4 {
+			copy(entry[8:], value)
+		} else {
+			out = append(out, make([]byte, len(out)%2)...)
+			order.PutUint32(entry[8:], uint32(len(out)))
+			out = append(out, value...)
+		}
+		entries[tag] = entry
+	}
+
+	out = append(out, make([]byte, len(out)%2)...)
+	order.PutUint32(out[4:8], uint32(len(out)))
+	out = append(out, 0, 0)
+	order.PutUint16(out[len(out)-2:], uint16(len(entries)))
+	for _, tag := range slices.Sorted(maps.Keys(entries)) {
+		out = append(out, entries[tag]...)
+	}
+	return append(out, next...), nil
+}
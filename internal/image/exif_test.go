@@ -0,0 +1,97 @@
+package image
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/bep/imagemeta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+var exifValues = map[uint16]string{
+	ExifArtist:           "Jane Doe",
+	ExifCopyright:        "CC BY 4.0",
+	ExifImageDescription: "A sunflower field",
+	ExifSoftware:         "Fooo",
+}
+
+func assertExifValues(t *testing.T, tags types.Tags) {
+	for name, value := range map[string]string{
+		"Artist":           "Jane Doe",
+		"Copyright":        "CC BY 4.0",
+		"ImageDescription": "A sunflower field",
+		"Software":         "Fooo",
+	} {
+		tag, ok := tags.Get(types.NamespaceEXIF, name)
+		if assert.True(t, ok, name) {
+			assert.Equal(t, value, tag.Value, name)
+		}
+	}
+}
+
+func TestEmbedExif(t *testing.T) {
+	testCases := []struct {
+		path string
+		mime string
+	}{
+		{"testdata/sample.png", "image/png"},
+		{"testdata/sample.jpg", "image/jpeg"},
+		{"testdata/sample.webp", "image/webp"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mime, func(t *testing.T) {
+			source, err := os.ReadFile(tc.path)
+			require.NoError(t, err)
+			original, err := NewContextFromReader(bytes.NewReader(source))
+			require.NoError(t, err)
+
+			var target bytes.Buffer
+			require.NoError(t, EmbedExif(bytes.NewReader(source), &target, exifValues))
+
+			ctx, err := NewContextFromReader(bytes.NewReader(target.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, tc.mime, ctx.MIME)
+			assert.Equal(t, original.Properties, ctx.Properties)
+			assertExifValues(t, ctx.EmbeddedMetadata)
+
+			// Other tags are kept, including those of sub-IFDs
+			for _, tag := range original.EmbeddedMetadata {
+				if tag.Tag != "Software" {
+					assert.Contains(t, ctx.EmbeddedMetadata, tag)
+				}
+			}
+
+			// XMP is written after the EXIF data
+			var xmp bytes.Buffer
+			require.NoError(t, EmbedXMP(bytes.NewReader(target.Bytes()), &xmp, []byte(samplePacket)))
+			var exif bytes.Buffer
+			require.NoError(t, EmbedExif(bytes.NewReader(xmp.Bytes()), &exif, map[uint16]string{ExifSoftware: "Fooo"}))
+			ctx, err = NewContextFromReader(bytes.NewReader(exif.Bytes()))
+			require.NoError(t, err)
+			assertExifValues(t, ctx.EmbeddedMetadata)
+			assertXmpPrompt(t, ctx)
+			assert.Len(t, ctx.EmbeddedMetadata.Lookup(types.NamespaceEXIF, "Software"), 1)
+		})
+	}
+}
+
+func TestUpdateExif(t *testing.T) {
+	tiff, err := updateExif(emptyExif, map[uint16]string{ExifArtist: "Jo", ExifCopyright: "CC0 1.0"})
+	require.NoError(t, err)
+	tags, err := decodeExif(bytes.NewReader(tiff), imagemeta.TIFF)
+	require.NoError(t, err)
+	artist, _ := tags.Get(types.NamespaceEXIF, "Artist")
+	assert.Equal(t, "Jo", artist.Value)
+	copyright, _ := tags.Get(types.NamespaceEXIF, "Copyright")
+	assert.Equal(t, "CC0 1.0", copyright.Value)
+
+	for _, invalid := range [][]byte{nil, []byte("GIF89a\x00\x00"), emptyExif[:10], []byte("MM\x00\x2a\xff\x00\x00\x00")} {
+		_, err := updateExif(invalid, exifValues)
+		assert.ErrorIs(t, err, errInvalidHeader)
+	}
+}
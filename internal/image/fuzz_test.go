@@ -0,0 +1,49 @@
+package image
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzNewContextFromReader(f *testing.F) {
+	for _, pattern := range []string{
+		"testdata/sample.*",
+		"../../*/testdata/*-meta.*",
+	} {
+		files, err := filepath.Glob(pattern)
+		require.NoError(f, err)
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			require.NoError(f, err)
+			f.Add(data)
+		}
+	}
+	f.Add(heifMetaToEnd())
+	f.Add(heifWithIdatExtent(0xfffffffffffffff0, 0x20))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = NewContextFromReader(bytes.NewReader(data))
+	})
+}
+
+func FuzzParseXmp(f *testing.F) {
+	f.Add([]byte(samplePacket))
+	f.Add([]byte(`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><rdf:Description/></rdf:RDF>`))
+
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		_, _ = parseXmp(packet)
+	})
+}
+
+func FuzzUpdateExif(f *testing.F) {
+	f.Add(emptyExif)
+	f.Add([]byte("II\x2a\x00\x08\x00\x00\x00\x01\x00\x3b\x01\x02\x00\x03\x00\x00\x00Jo\x00\x00\x00\x00\x00\x00"))
+
+	f.Fuzz(func(t *testing.T, tiff []byte) {
+		_, _ = updateExif(tiff, exifValues)
+	})
+}
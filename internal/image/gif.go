@@ -0,0 +1,134 @@
+package image
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/bep/imagemeta"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// readGif reads the comment extensions of a GIF image as tags, and its
+// size and number of frames. The tags read before an error are returned
+// with the error.
+func readGif(in io.ReadSeeker) (tags types.Tags, props types.ImageProperties, err error) {
+	if _, err = in.Seek(0, io.SeekStart); err != nil {
+		return nil, props, err
+	}
+
+	// Header and logical screen descriptor
+	header := make([]byte, 13)
+	if _, err = io.ReadFull(in, header); err != nil {
+		return nil, props, err
+	}
+	if string(header[:3]) != "GIF" {
+		return nil, props, fmt.Errorf("%w: missing GIF signature", errInvalidHeader)
+	}
+	props.Width = int(binary.LittleEndian.Uint16(header[6:8]))
+	props.Height = int(binary.LittleEndian.Uint16(header[8:10]))
+	props.ColorType = types.ColorPalette
+	props.BitDepth = int(header[10]&0x07) + 1
+	if err = skipColorTable(in, header[10]); err != nil {
+		return nil, props, err
+	}
+
+	introducer := make([]byte, 2)
+	for {
+		if _, err = io.ReadFull(in, introducer[:1]); err != nil {
+			return tags, props, err
+		}
+		switch introducer[0] {
+		case 0x21:
+			// Extension label followed by data sub-blocks
+			if _, err = io.ReadFull(in, introducer[1:]); err != nil {
+				return tags, props, err
+			}
+			if introducer[1] != 0xfe {
+				if _, err = readSubBlocks(in, false); err != nil {
+					return tags, props, err
+				}
+				continue
+			}
+			var comment []byte
+			if comment, err = readSubBlocks(in, true); err != nil {
+				return tags, props, err
+			}
+			tags.Add(imagemeta.TagInfo{
+				Namespace: types.NamespaceGIFComment,
+				Tag:       types.GifCommentTag,
+				Value:     decodeGifComment(comment),
+			})
+		case 0x2c:
+			// Image descriptor, optional local colour table,
+			// LZW minimum code size and image data sub-blocks
+			descriptor := make([]byte, 9)
+			if _, err = io.ReadFull(in, descriptor); err != nil {
+				return tags, props, err
+			}
+			if err = skipColorTable(in, descriptor[8]); err != nil {
+				return tags, props, err
+			}
+			if _, err = in.Seek(1, io.SeekCurrent); err != nil {
+				return tags, props, err
+			}
+			if _, err = readSubBlocks(in, false); err != nil {
+				return tags, props, err
+			}
+			props.Frames++
+		case 0x3b:
+			// Trailer
+			return tags, props, nil
+		default:
+			return tags, props, fmt.Errorf("%w: invalid GIF block 0x%02x", errInvalidHeader, introducer[0])
+		}
+	}
+}
+
+// skipColorTable skips the global or local colour table
+// if the flag of the packed field is set.
+func skipColorTable(in io.Seeker, packed byte) error {
+	if packed&0x80 == 0 {
+		return nil
+	}
+	_, err := in.Seek(3<<(packed&0x07+1), io.SeekCurrent)
+	return err
+}
+
+// readSubBlocks reads data sub-blocks up to the block terminator,
+// and returns their data if keep is set.
+func readSubBlocks(in io.ReadSeeker, keep bool) (data []byte, err error) {
+	size := make([]byte, 1)
+	for {
+		if _, err = io.ReadFull(in, size); err != nil {
+			return data, err
+		}
+		if size[0] == 0 {
+			return data, nil
+		}
+		if !keep {
+			if _, err = in.Seek(int64(size[0]), io.SeekCurrent); err != nil {
+				return data, err
+			}
+			continue
+		}
+		block := make([]byte, size[0])
+		if _, err = io.ReadFull(in, block); err != nil {
+			return data, err
+		}
+		data = append(data, block...)
+	}
+}
+
+// decodeGifComment decodes a comment as UTF-8, which is a superset of the
+// ASCII required by the GIF spec, or ISO-8859-1 otherwise.
+func decodeGifComment(comment []byte) string {
+	if utf8.Valid(comment) {
+		return string(comment)
+	}
+	text, _ := charmap.ISO8859_1.NewDecoder().Bytes(comment)
+	return string(text)
+}
@@ -0,0 +1,85 @@
+package image
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// gifWithComments returns an animated GIF with the comment extensions
+// inserted after the global colour table.
+func gifWithComments(t *testing.T, frames int, comments ...[]byte) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for range frames {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, 3, 2), palette))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, anim))
+	data := buf.Bytes()
+
+	offset := 13
+	if packed := data[10]; packed&0x80 != 0 {
+		offset += 3 << (packed&0x07 + 1)
+	}
+	var extensions []byte
+	for _, comment := range comments {
+		extensions = append(extensions, 0x21, 0xfe)
+		for len(comment) > 0 {
+			n := min(len(comment), 255)
+			extensions = append(extensions, byte(n))
+			extensions = append(extensions, comment[:n]...)
+			comment = comment[n:]
+		}
+		extensions = append(extensions, 0)
+	}
+	return append(data[:offset:offset], append(extensions, data[offset:]...)...)
+}
+
+func TestReadGif(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 300)
+	data := gifWithComments(t, 2, []byte("Steps: 20"), []byte("Sonnenblumen \xfcber dem Feld"), long)
+
+	tags, props, err := readGif(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, types.ImageProperties{Width: 3, Height: 2, ColorType: types.ColorPalette, BitDepth: 1, Frames: 2}, props)
+
+	comments := tags.Lookup(types.NamespaceGIFComment, types.GifCommentTag)
+	require.Len(t, comments, 3)
+	assert.Equal(t, "Steps: 20", comments[0].Value)
+	assert.Equal(t, "Sonnenblumen über dem Feld", comments[1].Value)
+	assert.Equal(t, string(long), comments[2].Value)
+}
+
+func TestReadGif_Invalid(t *testing.T) {
+	_, _, err := readGif(bytes.NewReader([]byte("not a GIF image")))
+	assert.ErrorIs(t, err, errInvalidHeader)
+
+	// Truncated after the first comment
+	data := gifWithComments(t, 1, []byte("Steps: 20"))
+	tags, _, err := readGif(bytes.NewReader(data[:len(data)-20]))
+	assert.Error(t, err)
+	assert.Len(t, tags, 1)
+}
+
+func TestNewContext_GIF(t *testing.T) {
+	data := gifWithComments(t, 1, []byte("Steps: 20"))
+
+	ctx, err := NewContextFromReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "image/gif", ctx.MIME)
+	assert.Equal(t, 3, ctx.Properties.Width)
+
+	_, comment, ok := ctx.StringTag(types.NamespaceGIFComment, types.GifCommentTag)
+	assert.True(t, ok)
+	assert.Equal(t, "Steps: 20", comment)
+}
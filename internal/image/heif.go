@@ -0,0 +1,425 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Largest meta box and Exif item of a HEIF image that is read.
+const maxHeifMetaSize = 16 << 20
+
+// heifBrands are the ftyp brands of HEIF images by MIME type.
+var heifBrands = map[string]string{
+	"avif": "image/avif",
+	"avis": "image/avif",
+	"heic": "image/heif",
+	"heix": "image/heif",
+	"heim": "image/heif",
+	"heis": "image/heif",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+}
+
+// sniffHeif returns the MIME type of AVIF and HEIF images from the
+// brands of the ftyp box, which http.DetectContentType does not know,
+// or an empty string for other data.
+func sniffHeif(data []byte) string {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return ""
+	}
+	size := min(int(binary.BigEndian.Uint32(data[:4])), len(data))
+
+	// Major brand, minor version and compatible brands
+	var mime string
+	for i := 8; i+4 <= size; i += 4 {
+		if i == 12 {
+			continue
+		}
+		if m, ok := heifBrands[string(data[i:i+4])]; ok {
+			if m == "image/avif" {
+				return m
+			}
+			mime = m
+		}
+	}
+	return mime
+}
+
+// heifItem is an item of a HEIF image, e.g. an image or its Exif data.
+type heifItem struct {
+	itemType string
+	// Construction method, 0 for file offsets and 1 for the idat box
+	method  uint16
+	extents []heifExtent
+}
+
+type heifExtent struct {
+	offset uint64
+	length uint64
+}
+
+// heifMeta is the content of the meta box of a HEIF image.
+type heifMeta struct {
+	items map[uint32]*heifItem
+	idat  []byte
+	props types.ImageProperties
+}
+
+// readHeif reads the meta box of an AVIF or HEIF image.
+func readHeif(in io.ReadSeeker) (*heifMeta, error) {
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	for {
+		typ, size, err := readBoxHeader(in)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "meta" {
+			return readHeifMeta(in, size)
+		}
+		if size < 0 {
+			return nil, fmt.Errorf("%w: missing meta box", errInvalidHeader)
+		}
+		if _, err := in.Seek(size, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readHeifMeta reads the content of the meta box of the given size, or
+// up to the end of the file if the size is negative.
+func readHeifMeta(in io.Reader, size int64) (*heifMeta, error) {
+	if size > maxHeifMetaSize {
+		return nil, fmt.Errorf("%w: meta box too large", errInvalidHeader)
+	}
+	if size < 0 {
+		data, err := io.ReadAll(io.LimitReader(in, maxHeifMetaSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxHeifMetaSize {
+			return nil, fmt.Errorf("%w: meta box too large", errInvalidHeader)
+		}
+		return parseHeifMeta(data)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(in, data); err != nil {
+		return nil, err
+	}
+	return parseHeifMeta(data)
+}
+
+// readBoxHeader reads the type and size of the next box, excluding its
+// header. The size is negative if the box extends to the end of the file.
+func readBoxHeader(in io.Reader) (typ string, size int64, err error) {
+	header := make([]byte, 8)
+	if _, err = io.ReadFull(in, header); err != nil {
+		return "", 0, err
+	}
+	typ = string(header[4:])
+	switch size := binary.BigEndian.Uint32(header[:4]); size {
+	case 0:
+		return typ, -1, nil
+	case 1:
+		// 64-bit size follows the type
+		if _, err = io.ReadFull(in, header); err != nil {
+			return "", 0, err
+		}
+		large := binary.BigEndian.Uint64(header)
+		if large < 16 || large > 1<<62 {
+			return "", 0, fmt.Errorf("%w: invalid box size", errInvalidHeader)
+		}
+		return typ, int64(large) - 16, nil
+	default:
+		if size < 8 {
+			return "", 0, fmt.Errorf("%w: invalid box size", errInvalidHeader)
+		}
+		return typ, int64(size) - 8, nil
+	}
+}
+
+// boxes returns the child boxes of the box data.
+func boxes(data []byte) map[string][][]byte {
+	children := make(map[string][][]byte)
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		typ, size, err := readBoxHeader(r)
+		if err != nil || size > int64(r.Len()) {
+			break
+		}
+		if size < 0 {
+			size = int64(r.Len())
+		}
+		offset := len(data) - r.Len()
+		children[typ] = append(children[typ], data[offset:offset+int(size)])
+		_, _ = r.Seek(size, io.SeekCurrent)
+	}
+	return children
+}
+
+// parseHeifMeta parses the item information (iinf), item locations (iloc)
+// and item data (idat) boxes, and the image properties (iprp).
+func parseHeifMeta(data []byte) (*heifMeta, error) {
+	// Skip version and flags of the full box
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: meta box", errInvalidHeader)
+	}
+	children := boxes(data[4:])
+
+	meta := &heifMeta{items: make(map[uint32]*heifItem)}
+	if iinf := children["iinf"]; len(iinf) > 0 {
+		if err := meta.parseItemInfo(iinf[0]); err != nil {
+			return nil, err
+		}
+	}
+	if iloc := children["iloc"]; len(iloc) > 0 {
+		if err := meta.parseItemLocations(iloc[0]); err != nil {
+			return nil, err
+		}
+	}
+	if idat := children["idat"]; len(idat) > 0 {
+		meta.idat = idat[0]
+	}
+	if iprp := children["iprp"]; len(iprp) > 0 {
+		meta.parseProperties(iprp[0])
+	}
+	return meta, nil
+}
+
+// parseItemInfo parses the item type of each item info entry (infe).
+func (meta *heifMeta) parseItemInfo(data []byte) error {
+	r := newBoxReader(data)
+	version := r.uint(1)
+	r.skip(3)
+	if version == 0 {
+		r.skip(2)
+	} else {
+		r.skip(4)
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	for _, infe := range boxes(r.rest())["infe"] {
+		r := newBoxReader(infe)
+		version := r.uint(1)
+		r.skip(3)
+		if version < 2 {
+			// Older entries have no item type
+			continue
+		}
+		var id uint32
+		if version == 2 {
+			id = uint32(r.uint(2))
+		} else {
+			id = uint32(r.uint(4))
+		}
+		r.skip(2)
+		itemType := string(r.bytes(4))
+		if r.err != nil {
+			return r.err
+		}
+		meta.item(id).itemType = itemType
+	}
+	return nil
+}
+
+// parseItemLocations parses the extents of each item.
+func (meta *heifMeta) parseItemLocations(data []byte) error {
+	r := newBoxReader(data)
+	version := r.uint(1)
+	r.skip(3)
+	sizes := r.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0f)
+	sizes = r.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0x0f)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	var count uint64
+	if version < 2 {
+		count = r.uint(2)
+	} else {
+		count = r.uint(4)
+	}
+
+	for i := uint64(0); i < count && r.err == nil; i++ {
+		var id uint32
+		if version < 2 {
+			id = uint32(r.uint(2))
+		} else {
+			id = uint32(r.uint(4))
+		}
+		item := meta.item(id)
+		if version > 0 {
+			item.method = uint16(r.uint(2) & 0x0f)
+		}
+		r.skip(2)
+		base := r.uint(baseOffsetSize)
+		extents := r.uint(2)
+		for j := uint64(0); j < extents && r.err == nil; j++ {
+			r.skip(indexSize)
+			offset := r.uint(offsetSize)
+			length := r.uint(lengthSize)
+			item.extents = append(item.extents, heifExtent{base + offset, length})
+		}
+	}
+	return r.err
+}
+
+// parseProperties reads the largest image spatial extents (ispe), which
+// is that of the full image if it consists of tiles, and the bit depth
+// from the pixel information (pixi).
+func (meta *heifMeta) parseProperties(data []byte) {
+	ipco := boxes(data)["ipco"]
+	if len(ipco) == 0 {
+		return
+	}
+	properties := boxes(ipco[0])
+
+	for _, ispe := range properties["ispe"] {
+		r := newBoxReader(ispe)
+		r.skip(4)
+		width, height := int(r.uint(4)), int(r.uint(4))
+		if r.err == nil && width*height > meta.props.Width*meta.props.Height {
+			meta.props.Width, meta.props.Height = width, height
+		}
+	}
+	if meta.props.Width == 0 {
+		return
+	}
+	meta.props.Frames = 1
+
+	if pixi := properties["pixi"]; len(pixi) > 0 {
+		r := newBoxReader(pixi[0])
+		r.skip(4)
+		channels := r.uint(1)
+		depth := r.uint(1)
+		if r.err == nil {
+			meta.props.BitDepth = int(depth)
+			switch channels {
+			case 1:
+				meta.props.ColorType = types.ColorGray
+			case 3:
+				meta.props.ColorType = types.ColorYCbCr
+			}
+		}
+	}
+}
+
+func (meta *heifMeta) item(id uint32) *heifItem {
+	item, ok := meta.items[id]
+	if !ok {
+		item = &heifItem{}
+		meta.items[id] = item
+	}
+	return item
+}
+
+// exif returns the TIFF data of the first Exif item,
+// or nil if the image has no Exif item.
+func (meta *heifMeta) exif(in io.ReadSeeker) ([]byte, error) {
+	for _, item := range meta.items {
+		if item.itemType != "Exif" {
+			continue
+		}
+
+		var data []byte
+		for _, extent := range item.extents {
+			if extent.length > maxHeifMetaSize || uint64(len(data))+extent.length > maxHeifMetaSize {
+				return nil, fmt.Errorf("%w: Exif item too large", errInvalidHeader)
+			}
+			switch item.method {
+			case 0:
+				if _, err := in.Seek(int64(extent.offset), io.SeekStart); err != nil {
+					return nil, err
+				}
+				chunk := make([]byte, extent.length)
+				if _, err := io.ReadFull(in, chunk); err != nil {
+					return nil, err
+				}
+				data = append(data, chunk...)
+			case 1:
+				if extent.length > uint64(len(meta.idat)) || extent.offset > uint64(len(meta.idat))-extent.length {
+					return nil, fmt.Errorf("%w: Exif item outside idat", errInvalidHeader)
+				}
+				data = append(data, meta.idat[extent.offset:extent.offset+extent.length]...)
+			default:
+				return nil, fmt.Errorf("%w: unsupported construction method %d", errInvalidHeader, item.method)
+			}
+		}
+
+		// Offset of the TIFF header, e.g. after an "Exif\0\0" prefix
+		if len(data) < 4 {
+			return nil, fmt.Errorf("%w: Exif item", errInvalidHeader)
+		}
+		offset := uint64(binary.BigEndian.Uint32(data[:4])) + 4
+		if offset > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: Exif item", errInvalidHeader)
+		}
+		return data[offset:], nil
+	}
+	return nil, nil
+}
+
+// extractHeifExif reads the Exif item of an AVIF or HEIF image.
+func extractHeifExif(in io.ReadSeeker) (types.Tags, error) {
+	meta, err := readHeif(in)
+	if err != nil {
+		return nil, err
+	}
+	data, err := meta.exif(in)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return extractExif(bytes.NewReader(data), "image/tiff")
+}
+
+// boxReader reads big-endian integers from box data,
+// and records the first error.
+type boxReader struct {
+	data []byte
+	err  error
+}
+
+var errShortBox = errors.New("box too short")
+
+func newBoxReader(data []byte) *boxReader {
+	return &boxReader{data: data}
+}
+
+func (r *boxReader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.data) {
+		r.err = fmt.Errorf("%w: %w", errInvalidHeader, errShortBox)
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+// uint reads an n-byte unsigned integer, n is 0, 1, 2, 4 or 8.
+func (r *boxReader) uint(n int) uint64 {
+	var v uint64
+	for _, b := range r.bytes(n) {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func (r *boxReader) skip(n int) {
+	r.bytes(n)
+}
+
+func (r *boxReader) rest() []byte {
+	return r.data
+}
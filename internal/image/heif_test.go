@@ -0,0 +1,160 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+func box(typ string, data ...[]byte) []byte {
+	content := bytes.Join(data, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(content)))
+	return append(append(b, typ...), content...)
+}
+
+// fullBox returns a box with a version and zero flags.
+func fullBox(typ string, version byte, data ...[]byte) []byte {
+	return box(typ, append([][]byte{{version, 0, 0, 0}}, data...)...)
+}
+
+// jpegExif returns the TIFF data of the APP1 Exif segment of a JPEG image.
+func jpegExif(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	start := bytes.Index(data, []byte("Exif\x00\x00"))
+	require.Positive(t, start)
+	length := int(binary.BigEndian.Uint16(data[start-2:]))
+	return data[start+6 : start-2+length]
+}
+
+// heifWithExif returns an image with a 64x48 8-bit image item and an
+// Exif item, stored in the mdat box or, for construction method 1,
+// in the idat box.
+func heifWithExif(brand string, method uint16, exif []byte) []byte {
+	// Exif item with the offset of the TIFF header after an "Exif\0\0" prefix
+	item := binary.BigEndian.AppendUint32(nil, 6)
+	item = append(append(item, "Exif\x00\x00"...), exif...)
+
+	ftyp := box("ftyp", []byte(brand), []byte{0, 0, 0, 0}, []byte("mif1"+brand))
+	iinf := fullBox("iinf", 0, []byte{0, 2},
+		fullBox("infe", 2, []byte{0, 1, 0, 0}, []byte(brand[:3]+"1\x00")),
+		fullBox("infe", 2, []byte{0, 2, 0, 0}, []byte("Exif\x00")))
+	iprp := box("iprp", box("ipco",
+		fullBox("ispe", 0, binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 64), 48)),
+		fullBox("pixi", 0, []byte{3, 8, 8, 8})))
+
+	iloc := func(offset uint32) []byte {
+		entry := []byte{0, 2, 0, byte(method), 0, 0, 0, 1}
+		entry = binary.BigEndian.AppendUint32(entry, offset)
+		entry = binary.BigEndian.AppendUint32(entry, uint32(len(item)))
+		return fullBox("iloc", 1, []byte{0x44, 0x00, 0, 1}, entry)
+	}
+
+	if method == 1 {
+		meta := fullBox("meta", 0, iinf, iloc(0), box("idat", item), iprp)
+		return append(ftyp, meta...)
+	}
+	// The offset into the file does not change the size of the meta box
+	size := len(ftyp) + len(fullBox("meta", 0, iinf, iloc(0), iprp)) + 8
+	meta := fullBox("meta", 0, iinf, iloc(uint32(size)), iprp)
+	return append(append(ftyp, meta...), box("mdat", item)...)
+}
+
+// heifMetaToEnd returns an image with a meta box of size 0, which
+// extends to the end of the file.
+func heifMetaToEnd() []byte {
+	ftyp := box("ftyp", []byte("avif\x00\x00\x00\x00mif1"))
+	return append(ftyp, "\x00\x00\x00\x00meta\x00\x00\x00\x00"...)
+}
+
+// heifWithIdatExtent returns an image with an Exif item in the idat box
+// at the given offset and length, which may be outside the box.
+func heifWithIdatExtent(offset uint64, length uint64) []byte {
+	ftyp := box("ftyp", []byte("avif\x00\x00\x00\x00mif1"))
+	iinf := fullBox("iinf", 0, []byte{0, 1}, fullBox("infe", 2, []byte{0, 2, 0, 0}, []byte("Exif\x00")))
+	// 64-bit offsets and lengths
+	entry := []byte{0, 2, 0, 1, 0, 0, 0, 1}
+	entry = binary.BigEndian.AppendUint64(entry, offset)
+	entry = binary.BigEndian.AppendUint64(entry, length)
+	iloc := fullBox("iloc", 1, []byte{0x88, 0x00, 0, 1}, entry)
+	meta := fullBox("meta", 0, iinf, iloc, box("idat", make([]byte, 32)))
+	return append(ftyp, meta...)
+}
+
+func TestSniffHeif(t *testing.T) {
+	exif := []byte("II*\x00")
+	assert.Equal(t, "image/avif", sniffHeif(heifWithExif("avif", 0, exif)))
+	assert.Equal(t, "image/heif", sniffHeif(heifWithExif("heic", 0, exif)))
+	assert.Empty(t, sniffHeif(box("ftyp", []byte("isom\x00\x00\x00\x00mp41"))))
+	assert.Empty(t, sniffHeif([]byte("not an image")))
+}
+
+func TestExtractHeifExif(t *testing.T) {
+	exif := jpegExif(t, "../../fooocus/testdata/fooocus-meta.jpeg")
+
+	testCases := []struct {
+		name   string
+		brand  string
+		method uint16
+		mime   string
+	}{
+		{"avif", "avif", 0, "image/avif"},
+		{"heic", "heic", 0, "image/heif"},
+		{"idat", "avif", 1, "image/avif"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, err := NewContextFromReader(bytes.NewReader(heifWithExif(tc.brand, tc.method, exif)))
+			require.NoError(t, err)
+			assert.Equal(t, tc.mime, ctx.MIME)
+			assert.Equal(t, types.ImageProperties{Width: 64, Height: 48, ColorType: types.ColorYCbCr, BitDepth: 8, Frames: 1}, ctx.Properties)
+
+			_, software, ok := ctx.StringTag(types.NamespaceEXIF, "MakerNoteApple")
+			assert.True(t, ok)
+			assert.Equal(t, "fooocus", software)
+			_, parameters, ok := ctx.StringTag(types.NamespaceEXIF, "UserComment")
+			assert.True(t, ok)
+			assert.Contains(t, parameters, "A sunflower field")
+		})
+	}
+}
+
+func TestExtractHeifExif_Invalid(t *testing.T) {
+	// No Exif item
+	data := append(box("ftyp", []byte("avif\x00\x00\x00\x00mif1")), fullBox("meta", 0)...)
+	tags, err := extractHeifExif(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	// Exif item outside the file
+	exif := []byte("II*\x00\x08\x00\x00\x00\x00\x00")
+	data = heifWithExif("avif", 0, exif)
+	_, err = extractHeifExif(bytes.NewReader(data[:len(data)-4]))
+	assert.Error(t, err)
+
+	// No meta box
+	_, err = extractHeifExif(bytes.NewReader(box("ftyp", []byte("avif"))))
+	assert.Error(t, err)
+
+	// Meta box up to the end of the file
+	tags, err = extractHeifExif(bytes.NewReader(heifMetaToEnd()))
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+	ctx, err := NewContextFromReader(bytes.NewReader(heifMetaToEnd()))
+	require.NoError(t, err)
+	assert.Equal(t, "image/avif", ctx.MIME)
+
+	// Exif item outside the idat box, with and without overflow
+	_, err = extractHeifExif(bytes.NewReader(heifWithIdatExtent(0xfffffffffffffff0, 0x20)))
+	assert.ErrorIs(t, err, errInvalidHeader)
+	_, err = extractHeifExif(bytes.NewReader(heifWithIdatExtent(16, 32)))
+	assert.ErrorIs(t, err, errInvalidHeader)
+}
@@ -1,8 +1,13 @@
-// Package image provides utilities to detect image MIME types
-// and read embedded image metadata (EXIF or PNG tEXt).
+// Package image provides utilities to detect image MIME types, read
+// embedded image metadata (EXIF, XMP or PNG text chunks) and embed EXIF
+// and XMP into images.
 package image
 
 import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,9 +16,9 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/bep/imagemeta"
-	pngembed "github.com/sabhiram/png-embed"
 	"golang.org/x/text/encoding/charmap"
 
 	"github.com/fkleon/fooocus-metadata/types"
@@ -52,7 +57,7 @@ func (file *File) detectMimeType() (err error) {
 		return
 	}
 
-	file.MIME = http.DetectContentType(buffer)
+	file.MIME = detectContentType(buffer)
 
 	// Fallback to file extension if MIME type could not be sniffed
 	if file.MIME == "application/octet-stream" {
@@ -97,10 +102,19 @@ func NewContextFromReader(in io.ReadSeeker) (*types.ImageMetadataContext, error)
 		return nil, err
 	}
 
-	mime := http.DetectContentType(buffer)
+	mime := detectContentType(buffer)
 	return newContext(in, mime)
 }
 
+// detectContentType returns the MIME type of the data, like
+// http.DetectContentType, and also detects AVIF and HEIF images.
+func detectContentType(data []byte) string {
+	if mime := sniffHeif(data); mime != "" {
+		return mime
+	}
+	return http.DetectContentType(data)
+}
+
 func NewContextFromFile(path string) (*types.ImageMetadataContext, error) {
 
 	slog.Debug("Opening image file..", "filepath", path)
@@ -113,6 +127,9 @@ func NewContextFromFile(path string) (*types.ImageMetadataContext, error) {
 
 	if ctx, err := newContext(file, file.MIME); err == nil {
 		ctx.Filepath = file.Path()
+		if info, err := file.Stat(); err == nil {
+			ctx.ModTime = info.ModTime()
+		}
 		return ctx, nil
 	} else {
 		return ctx, err
@@ -122,37 +139,39 @@ func NewContextFromFile(path string) (*types.ImageMetadataContext, error) {
 func newContext(in io.ReadSeeker, mime string) (*types.ImageMetadataContext, error) {
 
 	// Build image metadata and parse additional metadata sources
-	var metadataMap map[string]imagemeta.TagInfo
+	var tags types.Tags
 	var metadataErr error
 
 	switch mime {
-	case "image/jpeg":
-		fallthrough
-	case "image/webp":
-		fallthrough
+	case "image/jpeg", "image/webp":
+		slog.Debug("Metadata source", "mime", mime, "source", "EXIF and XMP")
+		tags, metadataErr = extractExif(in, mime)
+		if xmp, err := extractXmp(in, mime); err == nil {
+			tags = append(tags, xmp...)
+		} else {
+			slog.Warn("Failed to extract XMP packet",
+				"error", err)
+		}
 	case "image/tiff":
 		slog.Debug("Metadata source", "mime", mime, "source", "EXIF")
-		var exif *imagemeta.Tags
-		if exif, metadataErr = extractExif(in, mime); metadataErr == nil {
-			metadataMap = exif.All()
-		}
+		tags, metadataErr = extractExif(in, mime)
 	case "image/png":
-		slog.Debug("Metadata source", "mime", mime, "source", "PNG tEXt")
-		var pngText map[string]string
-		if pngText, metadataErr = extractPngText(in); metadataErr == nil {
-			metadataMap = make(map[string]imagemeta.TagInfo, len(pngText))
-			for k, v := range pngText {
-				metadataMap[k] = imagemeta.TagInfo{
-					Source:    0,
-					Tag:       k,
-					Namespace: "PNG/tEXt",
-					Value:     v,
-				}
-			}
-		}
+		slog.Debug("Metadata source", "mime", mime, "source", "PNG chunks")
+		tags, metadataErr = extractPngChunks(in)
+	case "image/gif":
+		slog.Debug("Metadata source", "mime", mime, "source", "GIF comments")
+		tags, _, metadataErr = readGif(in)
+	case "image/avif", "image/heif":
+		slog.Debug("Metadata source", "mime", mime, "source", "HEIF Exif")
+		tags, metadataErr = extractHeifExif(in)
 	default:
-		slog.Warn("Unsupported MIME type", "mime", mime)
-		return nil, fmt.Errorf("unsupported MIME type: %s", mime)
+		if !strings.HasPrefix(mime, "image/") {
+			slog.Warn("Unsupported MIME type", "mime", mime)
+			return nil, fmt.Errorf("%w: %s", types.ErrUnsupportedMIME, mime)
+		}
+		// No embedded metadata, e.g. BMP, but the metadata
+		// may still be found in a sidecar or the private log
+		slog.Debug("Metadata source", "mime", mime, "source", "none")
 	}
 
 	if metadataErr != nil {
@@ -160,15 +179,20 @@ func newContext(in io.ReadSeeker, mime string) (*types.ImageMetadataContext, err
 			"error", metadataErr)
 	}
 
+	props, err := readProperties(in, mime)
+	if err != nil && !errors.Is(err, types.ErrUnsupportedMIME) {
+		slog.Warn("Failed to read image properties",
+			"error", err)
+	}
+
 	return &types.ImageMetadataContext{
 		MIME:             mime,
-		EmbeddedMetadata: metadataMap,
+		EmbeddedMetadata: tags,
+		Properties:       props,
 	}, nil
 }
 
-func extractExif(fin io.ReadSeeker, mimeType string) (data *imagemeta.Tags, err error) {
-
-	data = &imagemeta.Tags{}
+func extractExif(fin io.ReadSeeker, mimeType string) (tags types.Tags, err error) {
 
 	// Rewind to the start
 	_, err = fin.Seek(0, io.SeekStart)
@@ -189,12 +213,19 @@ func extractExif(fin io.ReadSeeker, mimeType string) (data *imagemeta.Tags, err
 		format = imagemeta.TIFF
 	}
 
+	return decodeExif(fin, format)
+}
+
+// decodeExif reads the EXIF tags of an image, or of the TIFF structure
+// of a PNG eXIf chunk. Errors are logged, and the tags read before the
+// error are returned.
+func decodeExif(fin io.ReadSeeker, format imagemeta.ImageFormat) (tags types.Tags, err error) {
 	err = imagemeta.Decode(imagemeta.Options{
 		R:           fin,
 		ImageFormat: format,
 		Sources:     imagemeta.EXIF,
 		HandleTag: func(info imagemeta.TagInfo) error {
-			data.Add(info)
+			tags.Add(info)
 			return nil
 		},
 		Warnf: func(msg string, args ...any) {
@@ -210,58 +241,153 @@ func extractExif(fin io.ReadSeeker, mimeType string) (data *imagemeta.Tags, err
 	return
 }
 
-func extractPngText(fin io.ReadSeeker) (pngText map[string]string, err error) {
-	// Rewind to the start
-	_, err = fin.Seek(0, io.SeekStart)
-	if err != nil {
+// Largest decompressed text of a zTXt or iTXt chunk.
+const maxPngTextSize = 16 << 20
+
+// extractPngChunks reads the text chunks (tEXt, zTXt and iTXt) and the
+// last modification time chunk (tIME) of a PNG image. The namespace of
+// each tag is the chunk type, e.g. "PNG/iTXt". The XMP packet of the
+// iTXt chunk with types.PngXMPKeyword is also parsed, see parseXmp, and
+// so are the EXIF tags of the eXIf chunk.
+// The tags read before an error are returned with the error.
+func extractPngChunks(fin io.ReadSeeker) (tags types.Tags, err error) {
+	// Skip the PNG signature
+	if _, err = fin.Seek(8, io.SeekStart); err != nil {
 		return nil, err
 	}
 
-	// Extract PNG tEXt data
-	pngText, err = extractPngTextChunks(fin)
-	if err != nil {
-		slog.Debug("Failed to extract PNG tEXt chunks",
-			"error", err)
-	}
+	header := make([]byte, 8)
+	for {
+		if _, err = io.ReadFull(fin, header); err != nil {
+			return tags, err
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		chunkType := string(header[4:])
+
+		switch chunkType {
+		case "tEXt", "zTXt", "iTXt", "tIME":
+			data, err := io.ReadAll(io.LimitReader(fin, length))
+			if err != nil {
+				return tags, err
+			}
+			if int64(len(data)) != length {
+				return tags, io.ErrUnexpectedEOF
+			}
+			if tag, err := decodePngChunk(chunkType, data); err == nil {
+				tags.Add(tag)
+				if tag.Namespace == types.NamespacePNGInternationalText && tag.Tag == types.PngXMPKeyword {
+					tags = append(tags, decodeXmp(tag.Value.(string))...)
+				}
+			} else {
+				slog.Warn("Failed to decode PNG chunk",
+					"type", chunkType,
+					"error", err)
+			}
+			// Skip CRC
+			length = 0
+		case "eXIf":
+			data, err := io.ReadAll(io.LimitReader(fin, length))
+			if err != nil {
+				return tags, err
+			}
+			if int64(len(data)) != length {
+				return tags, io.ErrUnexpectedEOF
+			}
+			exif, _ := decodeExif(bytes.NewReader(data), imagemeta.TIFF)
+			tags = append(tags, exif...)
+			// Skip CRC
+			length = 0
+		case "IEND":
+			return tags, nil
+		}
 
-	return
+		// Skip chunk data and CRC
+		if _, err = fin.Seek(length+4, io.SeekCurrent); err != nil {
+			return tags, err
+		}
+	}
 }
 
-func extractPngTextChunks(fin io.ReadSeeker) (map[string]string, error) {
+// decodePngChunk decodes a PNG text or tIME chunk into a tag.
+func decodePngChunk(chunkType string, data []byte) (tag imagemeta.TagInfo, err error) {
+	tag.Namespace = "PNG/" + chunkType
 
-	data, err := io.ReadAll(fin)
-	if err != nil {
-		return nil, err
+	if chunkType == "tIME" {
+		if len(data) != 7 {
+			return tag, fmt.Errorf("invalid tIME chunk length: %d", len(data))
+		}
+		// Year, month, day, hour, minute, second in UTC
+		year := int(binary.BigEndian.Uint16(data[:2]))
+		tag.Tag = types.PngTimeTag
+		tag.Value = time.Date(year, time.Month(data[2]), int(data[3]),
+			int(data[4]), int(data[5]), int(data[6]), 0, time.UTC)
+		return tag, nil
 	}
 
-	// Extract PNG tEXt chunks
-	textData, err := pngembed.Extract(data)
-	if err != nil {
-		return nil, err
+	// Keyword and text are separated by a null byte
+	keyword, text, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return tag, fmt.Errorf("%s: missing keyword", chunkType)
 	}
 
-	textDataDecoded := make(map[string]string)
-
-	// Decode text with ISO-8859-1 as per PNG spec
+	// Keywords are ISO-8859-1 as per PNG spec
 	decoder := charmap.ISO8859_1.NewDecoder()
-	for k, v := range textData {
-		kd, err := decoder.String(string(k))
-		if err != nil {
-			slog.Warn("failed to decode key",
-				"key", k,
-				"error", err)
-			continue
+	if tag.Tag, err = decoder.String(string(keyword)); err != nil {
+		return tag, err
+	}
+
+	switch chunkType {
+	case "tEXt":
+		tag.Value, err = decoder.String(string(text))
+	case "zTXt":
+		// Compression method followed by the compressed text
+		if len(text) == 0 || text[0] != 0 {
+			return tag, fmt.Errorf("zTXt: unsupported compression method")
 		}
-		vd, err := decoder.String(string(v))
-		if err != nil {
-			slog.Warn("failed to decode value",
-				"key", k, "value", v,
-				"error", err)
-			continue
+		if text, err = inflate(text[1:]); err != nil {
+			return tag, err
 		}
-		textDataDecoded[kd] = vd
-		textDataDecoded[k] = string(v)
+		tag.Value, err = decoder.String(string(text))
+	case "iTXt":
+		// Compression flag and method, language tag and translated
+		// keyword followed by the UTF-8 text
+		if len(text) < 2 {
+			return tag, fmt.Errorf("iTXt: missing compression flag")
+		}
+		compressed, method := text[0], text[1]
+		text = text[2:]
+		for range 2 {
+			if _, text, ok = bytes.Cut(text, []byte{0}); !ok {
+				return tag, fmt.Errorf("iTXt: missing language or translated keyword")
+			}
+		}
+		if compressed != 0 {
+			if method != 0 {
+				return tag, fmt.Errorf("iTXt: unsupported compression method")
+			}
+			if text, err = inflate(text); err != nil {
+				return tag, err
+			}
+		}
+		tag.Value = string(text)
 	}
+	return tag, err
+}
 
-	return textDataDecoded, nil
+// inflate decompresses the zlib stream of a zTXt or iTXt chunk.
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	text, err := io.ReadAll(io.LimitReader(r, maxPngTextSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(text) > maxPngTextSize {
+		return nil, fmt.Errorf("text exceeds %d bytes", maxPngTextSize)
+	}
+	return text, nil
 }
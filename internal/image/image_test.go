@@ -1,13 +1,22 @@
 package image
 
 import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/bep/imagemeta"
+
+	"github.com/fkleon/fooocus-metadata/types"
 )
 
 func TestExtractExif(t *testing.T) {
@@ -19,24 +28,93 @@ func TestExtractExif(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotNil(t, exifData)
 
-	exifVersion := exifData.EXIF()["ExifVersion"]
-	exifVersionStr := exifVersion.Value.(string)
-	assert.Equal(t, "0220", exifVersionStr)
+	exifVersion, ok := exifData.Get(types.NamespaceEXIF, "ExifVersion")
+	require.True(t, ok)
+	assert.Equal(t, "IFD0/ExifIFDP", exifVersion.Namespace)
+	assert.Equal(t, "0220", exifVersion.Value)
 }
 
 func TestExtractPNGTextChunks(t *testing.T) {
 	file, err := os.Open("testdata/sample.png")
 	require.NoError(t, err)
 
-	meta, err := extractPngTextChunks(file)
+	tags, err := extractPngChunks(file)
 	require.NoError(t, err)
 
-	assert.Equal(t, map[string]string{
+	text := make(map[string]any)
+	for _, tag := range tags {
+		if tag.Namespace == types.NamespacePNGText {
+			text[tag.Tag] = tag.Value
+		}
+	}
+	assert.Equal(t, map[string]any{
 		"date:create":    "2025-04-11T09:41:46+00:00",
 		"date:modify":    "2025-04-11T09:41:46+00:00",
 		"date:timestamp": "2025-04-11T11:53:39+00:00",
 		"Software":       "ImageMaker2000(TM)",
-	}, meta)
+	}, text)
+}
+
+func TestExtractPNGTextChunks_Types(t *testing.T) {
+	// ISO-8859-1 for zTXt, UTF-8 for iTXt
+	latin1 := deflate(t, []byte("{\"prompt\": \"Sonnenblumen \xfcber dem Feld\"}"))
+	utf8 := deflate(t, []byte("{\"prompt\": \"Sonnenblumen über dem Feld\"}"))
+
+	data := pngWithChunks(t,
+		pngChunk("tEXt", []byte("Comment\x00caf\xe9")),
+		pngChunk("zTXt", append([]byte("parameters\x00\x00"), latin1...)),
+		pngChunk("iTXt", []byte("parameters\x00\x00\x00de\x00Parameter\x00Sonnenblumen")),
+		pngChunk("iTXt", append([]byte("parameters\x00\x01\x00\x00\x00"), utf8...)),
+		pngChunk("iTXt", []byte("invalid\x00\x00")),
+	)
+
+	tags, err := extractPngChunks(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	assert.Equal(t, types.Tags{
+		{Namespace: types.NamespacePNGText, Tag: "Comment", Value: "café"},
+		{Namespace: types.NamespacePNGCompressedText, Tag: "parameters", Value: "{\"prompt\": \"Sonnenblumen über dem Feld\"}"},
+		{Namespace: types.NamespacePNGInternationalText, Tag: "parameters", Value: "Sonnenblumen"},
+		{Namespace: types.NamespacePNGInternationalText, Tag: "parameters", Value: "{\"prompt\": \"Sonnenblumen über dem Feld\"}"},
+	}, tags)
+
+	// All values of a tag are kept
+	assert.Len(t, tags.Lookup(types.NamespacePNG, "parameters"), 3)
+	assert.Len(t, tags.Lookup(types.NamespacePNGInternationalText, "parameters"), 2)
+}
+
+// deflate compresses data with zlib.
+func deflate(t *testing.T, data []byte) []byte {
+	var compressed bytes.Buffer
+	w := zlib.NewWriter(&compressed)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return compressed.Bytes()
+}
+
+// pngWithChunks returns a 1x1 pixel PNG with the given chunks inserted
+// before the image data.
+func pngWithChunks(t *testing.T, chunks ...[]byte) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+	data := buf.Bytes()
+
+	// Signature and IHDR chunk
+	ihdrEnd := 8 + 8 + 13 + 4
+	result := append([]byte{}, data[:ihdrEnd]...)
+	for _, chunk := range chunks {
+		result = append(result, chunk...)
+	}
+	return append(result, data[ihdrEnd:]...)
+}
+
+// pngChunk encodes a PNG chunk with length and CRC.
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, chunkType...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
 }
 
 func TestExtractImageInfo_JPEG(t *testing.T) {
@@ -59,8 +137,38 @@ func TestExtractImageInfo_PNG(t *testing.T) {
 	assert.Equal(t, "image/png", image.MIME)
 	for _, v := range image.EmbeddedMetadata {
 		assert.Equal(t, imagemeta.Source(0x0), v.Source)
-		assert.Equal(t, "PNG/tEXt", v.Namespace)
+		assert.Contains(t, []string{"PNG/tEXt", "PNG/iTXt", "PNG/tIME"}, v.Namespace)
 	}
+	assert.False(t, image.ModTime.IsZero())
+}
+
+func TestExtractPngTime(t *testing.T) {
+	file, err := os.Open("testdata/sample.png")
+	require.NoError(t, err)
+	defer file.Close()
+
+	tags, err := extractPngChunks(file)
+	require.NoError(t, err)
+	modTime, ok := tags.Get(types.NamespacePNGTime, types.PngTimeTag)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2025, time.April, 11, 11, 53, 39, 0, time.UTC), modTime.Value)
+
+	image, err := NewContextFromFile("testdata/sample.png")
+	require.NoError(t, err)
+	tag, ok := image.EmbeddedMetadata.Get(types.NamespacePNGTime, types.PngTimeTag)
+	require.True(t, ok)
+	assert.Equal(t, modTime.Value, tag.Value)
+}
+
+func TestExtractPngTime_Missing(t *testing.T) {
+	file, err := os.Open("../../fooocus/testdata/fooocus-meta.png")
+	require.NoError(t, err)
+	defer file.Close()
+
+	tags, err := extractPngChunks(file)
+	require.NoError(t, err)
+	_, ok := tags.Get(types.NamespacePNGTime, types.PngTimeTag)
+	assert.False(t, ok)
 }
 
 func TestExtractImageInfo_WEBP(t *testing.T) {
@@ -74,3 +182,17 @@ func TestExtractImageInfo_WEBP(t *testing.T) {
 		assert.Contains(t, v.Namespace, "IFD0")
 	}
 }
+
+func TestNewContext_UnsupportedMIME(t *testing.T) {
+	_, err := NewContextFromReader(bytes.NewReader([]byte("not an image")))
+	assert.ErrorIs(t, err, types.ErrUnsupportedMIME)
+}
+
+func TestNewContext_NoEmbeddedMetadata(t *testing.T) {
+	// Images without a metadata container still have a context
+	ctx, err := NewContextFromFile("../../fooocus/testdata/fooocus-meta.bmp")
+	require.NoError(t, err)
+	assert.Equal(t, "image/bmp", ctx.MIME)
+	assert.Empty(t, ctx.EmbeddedMetadata)
+	assert.Equal(t, 512, ctx.Properties.Width)
+}
@@ -0,0 +1,330 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"golang.org/x/image/tiff"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+var errInvalidHeader = errors.New("invalid image header")
+
+// readProperties reads the size, colour type, bit depth and frame count
+// from the image headers, without decoding the image data.
+func readProperties(in io.ReadSeeker, mime string) (types.ImageProperties, error) {
+	// Rewind to the start
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return types.ImageProperties{}, err
+	}
+
+	switch mime {
+	case "image/png":
+		return readPngProperties(in)
+	case "image/jpeg":
+		return readJpegProperties(in)
+	case "image/webp":
+		return readWebpProperties(in)
+	case "image/tiff":
+		config, err := tiff.DecodeConfig(in)
+		if err != nil {
+			return types.ImageProperties{}, err
+		}
+		return configProperties(config), nil
+	case "image/gif":
+		_, props, err := readGif(in)
+		return props, err
+	case "image/bmp":
+		return readBmpProperties(in)
+	case "image/avif", "image/heif":
+		meta, err := readHeif(in)
+		if err != nil {
+			return types.ImageProperties{}, err
+		}
+		return meta.props, nil
+	}
+	return types.ImageProperties{}, fmt.Errorf("%w: %s", types.ErrUnsupportedMIME, mime)
+}
+
+// readPngProperties reads the IHDR chunk and, for animated PNG,
+// the number of frames from the acTL chunk.
+func readPngProperties(in io.ReadSeeker) (props types.ImageProperties, err error) {
+	// Signature, IHDR chunk length and type
+	header := make([]byte, 16)
+	if _, err = io.ReadFull(in, header); err != nil {
+		return props, err
+	}
+	if string(header[12:]) != "IHDR" {
+		return props, fmt.Errorf("%w: missing IHDR", errInvalidHeader)
+	}
+
+	// Width, height, bit depth, colour type, compression, filter, interlace and CRC
+	ihdr := make([]byte, 17)
+	if _, err = io.ReadFull(in, ihdr); err != nil {
+		return props, err
+	}
+	props.Width = int(binary.BigEndian.Uint32(ihdr[0:4]))
+	props.Height = int(binary.BigEndian.Uint32(ihdr[4:8]))
+	props.BitDepth = int(ihdr[8])
+	props.Frames = 1
+
+	switch ihdr[9] {
+	case 0:
+		props.ColorType = types.ColorGray
+	case 2:
+		props.ColorType = types.ColorRGB
+	case 3:
+		props.ColorType = types.ColorPalette
+	case 4:
+		props.ColorType = types.ColorGrayAlpha
+	case 6:
+		props.ColorType = types.ColorRGBA
+	}
+
+	// The acTL chunk must precede the image data
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(in, chunk); err != nil {
+			return props, nil
+		}
+		length := int64(binary.BigEndian.Uint32(chunk[:4]))
+		switch string(chunk[4:]) {
+		case "acTL":
+			frames := make([]byte, 4)
+			if _, err := io.ReadFull(in, frames); err == nil {
+				props.Frames = int(binary.BigEndian.Uint32(frames))
+			}
+			return props, nil
+		case "IDAT", "IEND":
+			return props, nil
+		}
+		if _, err := in.Seek(length+4, io.SeekCurrent); err != nil {
+			return props, nil
+		}
+	}
+}
+
+// readBmpProperties reads the bitmap file header and the DIB header.
+func readBmpProperties(in io.Reader) (props types.ImageProperties, err error) {
+	// File header, DIB header size and the fields up to the alpha mask
+	header := make([]byte, 14+56)
+	n, err := io.ReadFull(in, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return props, err
+	}
+	header = header[:n]
+	if n < 14+16 || string(header[:2]) != "BM" {
+		return props, fmt.Errorf("%w: missing BMP header", errInvalidHeader)
+	}
+
+	dib := header[14:]
+	var bitCount int
+	if size := binary.LittleEndian.Uint32(dib[:4]); size == 12 {
+		// BITMAPCOREHEADER with 16-bit size
+		props.Width = int(binary.LittleEndian.Uint16(dib[4:6]))
+		props.Height = int(binary.LittleEndian.Uint16(dib[6:8]))
+		bitCount = int(binary.LittleEndian.Uint16(dib[10:12]))
+	} else {
+		// BITMAPINFOHEADER and later, negative heights are top-down
+		props.Width = int(int32(binary.LittleEndian.Uint32(dib[4:8])))
+		props.Height = int(int32(binary.LittleEndian.Uint32(dib[8:12])))
+		props.Height = max(props.Height, -props.Height)
+		bitCount = int(binary.LittleEndian.Uint16(dib[14:16]))
+	}
+	props.Frames = 1
+
+	switch {
+	case bitCount <= 8:
+		props.ColorType, props.BitDepth = types.ColorPalette, bitCount
+	case bitCount == 16:
+		props.ColorType, props.BitDepth = types.ColorRGB, 5
+	default:
+		props.ColorType, props.BitDepth = types.ColorRGB, 8
+		// Alpha mask of BITMAPV3INFOHEADER and later
+		if bitCount == 32 && len(dib) >= 56 && binary.LittleEndian.Uint32(dib[52:56]) != 0 {
+			props.ColorType = types.ColorRGBA
+		}
+	}
+	return props, nil
+}
+
+// readJpegProperties reads the start of frame (SOF) segment.
+func readJpegProperties(in io.ReadSeeker) (props types.ImageProperties, err error) {
+	marker := make([]byte, 2)
+	if _, err = io.ReadFull(in, marker); err != nil {
+		return props, err
+	}
+	if marker[0] != 0xff || marker[1] != 0xd8 {
+		return props, fmt.Errorf("%w: missing SOI", errInvalidHeader)
+	}
+
+	segment := make([]byte, 4)
+	for {
+		if _, err = io.ReadFull(in, segment); err != nil {
+			return props, err
+		}
+		if segment[0] != 0xff {
+			return props, fmt.Errorf("%w: invalid marker", errInvalidHeader)
+		}
+		length := int64(binary.BigEndian.Uint16(segment[2:]))
+
+		switch m := segment[1]; {
+		case m == 0xd9 || m == 0xda:
+			// End of image or start of scan before any frame
+			return props, fmt.Errorf("%w: missing SOF", errInvalidHeader)
+		case m >= 0xc0 && m <= 0xcf && m != 0xc4 && m != 0xc8 && m != 0xcc:
+			// Precision, height, width and number of components
+			sof := make([]byte, 6)
+			if _, err = io.ReadFull(in, sof); err != nil {
+				return props, err
+			}
+			props.BitDepth = int(sof[0])
+			props.Height = int(binary.BigEndian.Uint16(sof[1:3]))
+			props.Width = int(binary.BigEndian.Uint16(sof[3:5]))
+			props.Frames = 1
+			switch sof[5] {
+			case 1:
+				props.ColorType = types.ColorGray
+			case 3:
+				props.ColorType = types.ColorYCbCr
+			case 4:
+				props.ColorType = types.ColorCMYK
+			}
+			return props, nil
+		}
+
+		if length < 2 {
+			return props, fmt.Errorf("%w: invalid segment length", errInvalidHeader)
+		}
+		if _, err = in.Seek(length-2, io.SeekCurrent); err != nil {
+			return props, err
+		}
+	}
+}
+
+// readWebpProperties reads the VP8, VP8L or VP8X chunk and, for animated
+// WebP, counts the ANMF frame chunks.
+func readWebpProperties(in io.ReadSeeker) (props types.ImageProperties, err error) {
+	header := make([]byte, 12)
+	if _, err = io.ReadFull(in, header); err != nil {
+		return props, err
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return props, fmt.Errorf("%w: missing RIFF WEBP", errInvalidHeader)
+	}
+
+	// The extended format (VP8X) has the canvas size, alpha and
+	// animation flags, followed by the VP8 or VP8L image data
+	var extended, alpha bool
+
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(in, chunk); err != nil {
+			if props.Width == 0 {
+				return props, fmt.Errorf("%w: missing VP8 chunk", errInvalidHeader)
+			}
+			return props, nil
+		}
+		length := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		size := min(length, 10)
+		data := make([]byte, size)
+		if _, err := io.ReadFull(in, data); err != nil {
+			return props, err
+		}
+
+		switch string(chunk[:4]) {
+		case "VP8X":
+			// Flags, reserved and canvas size minus one as 24-bit integers
+			if size < 10 {
+				return props, fmt.Errorf("%w: VP8X", errInvalidHeader)
+			}
+			extended, alpha = true, data[0]&0x10 != 0
+			props.Width = int(uint32(data[4])|uint32(data[5])<<8|uint32(data[6])<<16) + 1
+			props.Height = int(uint32(data[7])|uint32(data[8])<<8|uint32(data[9])<<16) + 1
+			props.BitDepth = 8
+			if data[0]&0x02 != 0 {
+				// Animated, count the frames
+				props.ColorType = types.ColorRGB
+				if alpha {
+					props.ColorType = types.ColorRGBA
+				}
+			} else {
+				props.Frames = 1
+			}
+		case "ANMF":
+			props.Frames++
+		case "VP8 ":
+			// Frame tag, start code and 14-bit width and height
+			if size < 10 || !bytes.Equal(data[3:6], []byte{0x9d, 0x01, 0x2a}) {
+				return props, fmt.Errorf("%w: VP8", errInvalidHeader)
+			}
+			if !extended {
+				props.Width = int(binary.LittleEndian.Uint16(data[6:8]) & 0x3fff)
+				props.Height = int(binary.LittleEndian.Uint16(data[8:10]) & 0x3fff)
+			}
+			props.BitDepth = 8
+			props.ColorType = types.ColorYCbCr
+			if alpha {
+				// Lossy with a separate ALPH chunk
+				props.ColorType = types.ColorRGBA
+			}
+			props.Frames = 1
+			return props, nil
+		case "VP8L":
+			// Signature and 14-bit width and height minus one, alpha flag
+			if size < 5 || data[0] != 0x2f {
+				return props, fmt.Errorf("%w: VP8L", errInvalidHeader)
+			}
+			bits := binary.LittleEndian.Uint32(data[1:5])
+			if !extended {
+				props.Width = int(bits&0x3fff) + 1
+				props.Height = int(bits>>14&0x3fff) + 1
+			}
+			props.BitDepth = 8
+			props.ColorType = types.ColorRGB
+			if alpha || bits>>28&1 != 0 {
+				props.ColorType = types.ColorRGBA
+			}
+			props.Frames = 1
+			return props, nil
+		}
+
+		// Skip the rest of the chunk and the padding byte of odd sizes
+		if _, err := in.Seek(length-size+length%2, io.SeekCurrent); err != nil {
+			return props, err
+		}
+	}
+}
+
+// configProperties returns the properties of a decoded image config.
+func configProperties(config image.Config) types.ImageProperties {
+	props := types.ImageProperties{
+		Width:    config.Width,
+		Height:   config.Height,
+		BitDepth: 8,
+		Frames:   1,
+	}
+	switch config.ColorModel {
+	case color.GrayModel:
+		props.ColorType = types.ColorGray
+	case color.Gray16Model:
+		props.ColorType, props.BitDepth = types.ColorGray, 16
+	case color.RGBAModel, color.NRGBAModel:
+		props.ColorType = types.ColorRGBA
+	case color.RGBA64Model, color.NRGBA64Model:
+		props.ColorType, props.BitDepth = types.ColorRGBA, 16
+	case color.CMYKModel:
+		props.ColorType = types.ColorCMYK
+	default:
+		if _, ok := config.ColorModel.(color.Palette); ok {
+			props.ColorType = types.ColorPalette
+		}
+	}
+	return props
+}
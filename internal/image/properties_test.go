@@ -0,0 +1,69 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+func TestReadProperties(t *testing.T) {
+	testCases := []struct {
+		path     string
+		mime     string
+		expected types.ImageProperties
+	}{
+		{"testdata/sample.png", "image/png", types.ImageProperties{Width: 240, Height: 85, ColorType: types.ColorGray, BitDepth: 8, Frames: 1}},
+		{"testdata/sample.jpg", "image/jpeg", types.ImageProperties{Width: 100, Height: 75, ColorType: types.ColorYCbCr, BitDepth: 8, Frames: 1}},
+		{"testdata/sample.webp", "image/webp", types.ImageProperties{Width: 100, Height: 75, ColorType: types.ColorYCbCr, BitDepth: 8, Frames: 1}},
+		{"../../fooocus/testdata/fooocus-meta.bmp", "image/bmp", types.ImageProperties{Width: 512, Height: 512, ColorType: types.ColorRGB, BitDepth: 8, Frames: 1}},
+		{"../../ruinedfooocus/testdata/ruinedfooocus-meta.png", "image/png", types.ImageProperties{Width: 1152, Height: 896, ColorType: types.ColorRGB, BitDepth: 8, Frames: 1}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			file, err := os.Open(tc.path)
+			require.NoError(t, err)
+			defer file.Close()
+
+			props, err := readProperties(file, tc.mime)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, props)
+		})
+	}
+}
+
+func TestReadProperties_AnimatedPNG(t *testing.T) {
+	// Number of frames and plays
+	actl := binary.BigEndian.AppendUint32(nil, 3)
+	actl = binary.BigEndian.AppendUint32(actl, 0)
+
+	data := pngWithChunks(t, pngChunk("acTL", actl))
+	props, err := readProperties(bytes.NewReader(data), "image/png")
+	require.NoError(t, err)
+	assert.Equal(t, types.ImageProperties{Width: 1, Height: 1, ColorType: types.ColorGray, BitDepth: 8, Frames: 3}, props)
+}
+
+func TestReadProperties_Invalid(t *testing.T) {
+	for _, mime := range []string{"image/png", "image/jpeg", "image/webp", "image/gif", "image/bmp", "image/avif"} {
+		t.Run(mime, func(t *testing.T) {
+			_, err := readProperties(bytes.NewReader([]byte("not an image header")), mime)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := readProperties(bytes.NewReader(nil), "image/x-icon")
+	assert.ErrorIs(t, err, types.ErrUnsupportedMIME)
+}
+
+func TestNewContext_Properties(t *testing.T) {
+	ctx, err := NewContextFromFile("../../fooocus/testdata/fooocus-meta.jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, 512, ctx.Properties.Width)
+	assert.Equal(t, 512, ctx.Properties.Height)
+}
@@ -0,0 +1,323 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/bep/imagemeta"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Largest XMP packet that is parsed.
+const maxXmpSize = 16 << 20
+
+const (
+	rdfNS = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlNS = "http://www.w3.org/XML/1998/namespace"
+)
+
+// xmpNode is an element of an XMP packet.
+type xmpNode struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*xmpNode
+	text     strings.Builder
+}
+
+func (n *xmpNode) attr(space string, local string) (string, bool) {
+	for _, attr := range n.attrs {
+		if attr.Name.Space == space && attr.Name.Local == local {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+func (n *xmpNode) is(space string, local string) bool {
+	return n.name.Space == space && n.name.Local == local
+}
+
+// Signature of the JPEG APP1 segment with the XMP packet.
+const jpegXmpSignature = "http://ns.adobe.com/xap/1.0/\x00"
+
+// extractXmp reads and parses the XMP packet of a JPEG image from its
+// APP1 segment, or of a WebP image from its XMP chunk. Extended XMP in
+// further JPEG segments is not read.
+//
+// The segments and chunks are read here rather than by imagemeta, which
+// does not tell XMP from EXIF segments unless EXIF comes first, and does
+// not skip the padding of WebP chunks.
+func extractXmp(in io.ReadSeeker, mime string) (types.Tags, error) {
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var packet []byte
+	var err error
+	switch mime {
+	case "image/jpeg":
+		packet, err = readJpegXmp(in)
+	case "image/webp":
+		packet, err = readWebpXmp(in)
+	default:
+		return nil, fmt.Errorf("%w: %s", types.ErrUnsupportedMIME, mime)
+	}
+	if err != nil || packet == nil {
+		return nil, err
+	}
+	return parseXmp(packet)
+}
+
+// readJpegXmp returns the XMP packet of the first APP1 segment with the
+// XMP signature, or nil if there is none before the image data.
+func readJpegXmp(in io.ReadSeeker) ([]byte, error) {
+	marker := make([]byte, 2)
+	if _, err := io.ReadFull(in, marker); err != nil {
+		return nil, err
+	}
+	if marker[0] != 0xff || marker[1] != 0xd8 {
+		return nil, fmt.Errorf("%w: missing SOI", errInvalidHeader)
+	}
+
+	segment := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(in, segment); err != nil {
+			return nil, err
+		}
+		if segment[0] != 0xff {
+			return nil, fmt.Errorf("%w: invalid marker", errInvalidHeader)
+		}
+		if segment[1] == 0xd9 || segment[1] == 0xda {
+			// End of image or start of scan
+			return nil, nil
+		}
+		length := int64(binary.BigEndian.Uint16(segment[2:])) - 2
+		if length < 0 {
+			return nil, fmt.Errorf("%w: invalid segment length", errInvalidHeader)
+		}
+
+		if segment[1] == 0xe1 && length >= int64(len(jpegXmpSignature)) {
+			signature := make([]byte, len(jpegXmpSignature))
+			if _, err := io.ReadFull(in, signature); err != nil {
+				return nil, err
+			}
+			length -= int64(len(signature))
+			if string(signature) == jpegXmpSignature {
+				packet := make([]byte, length)
+				_, err := io.ReadFull(in, packet)
+				return packet, err
+			}
+		}
+		if _, err := in.Seek(length, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readWebpXmp returns the content of the XMP chunk,
+// or nil if there is none.
+func readWebpXmp(in io.ReadSeeker) ([]byte, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:]) != "WEBP" {
+		return nil, fmt.Errorf("%w: missing RIFF WEBP", errInvalidHeader)
+	}
+
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(in, chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		length := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		if string(chunk[:4]) == "XMP " {
+			if length > maxXmpSize {
+				return nil, fmt.Errorf("XMP packet exceeds %d bytes", maxXmpSize)
+			}
+			packet := make([]byte, length)
+			_, err := io.ReadFull(in, packet)
+			return packet, err
+		}
+		// Skip the chunk and the padding byte of odd sizes
+		if _, err := in.Seek(length+length%2, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// decodeXmp parses an XMP packet. Invalid packets are logged
+// and skipped, so that they do not hide the other metadata.
+func decodeXmp(packet string) types.Tags {
+	tags, err := parseXmp([]byte(packet))
+	if err != nil {
+		slog.Warn("Failed to decode XMP packet", "error", err)
+		return nil
+	}
+	return tags
+}
+
+// ReadXMPSidecar reads and parses an XMP sidecar file, see parseXmp.
+func ReadXMPSidecar(path string) (types.Tags, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	packet, err := io.ReadAll(io.LimitReader(file, maxXmpSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(packet) > maxXmpSize {
+		return nil, fmt.Errorf("XMP sidecar exceeds %d bytes", maxXmpSize)
+	}
+	return parseXmp(packet)
+}
+
+// parseXmp parses the properties of the rdf:Description elements of an
+// XMP packet into tags. The namespace of each tag is "XMP/" followed by
+// the namespace URI of the property, and its name is the local name,
+// e.g. "description" in types.NamespaceXMPDublinCore.
+//
+// Simple properties have a string value. Language alternatives have the
+// string value of the default language, or of the first language if there
+// is no default. Ordered and unordered arrays have a []string value.
+// Structures are skipped.
+func parseXmp(packet []byte) (tags types.Tags, err error) {
+	root, err := decodeXmpTree(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	var walk func(n *xmpNode)
+	walk = func(n *xmpNode) {
+		if !n.is(rdfNS, "RDF") {
+			for _, child := range n.children {
+				walk(child)
+			}
+			return
+		}
+		for _, description := range n.children {
+			if description.is(rdfNS, "Description") {
+				tags = append(tags, xmpProperties(description)...)
+			}
+		}
+	}
+	walk(root)
+	return tags, nil
+}
+
+// decodeXmpTree decodes the elements of an XMP packet into a tree
+// below an unnamed root.
+func decodeXmpTree(packet []byte) (*xmpNode, error) {
+	root := &xmpNode{}
+	stack := []*xmpNode{root}
+
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding XMP: %w", err)
+		}
+
+		parent := stack[len(stack)-1]
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmpNode{name: t.Name, attrs: t.Attr}
+			parent.children = append(parent.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			parent.text.Write(t)
+		}
+	}
+	return root, nil
+}
+
+// xmpProperties returns the properties of an rdf:Description element,
+// written as attributes or as child elements.
+func xmpProperties(description *xmpNode) (tags types.Tags) {
+	for _, attr := range description.attrs {
+		if isXmpSyntax(attr.Name) {
+			continue
+		}
+		tags.Add(xmpTag(attr.Name, attr.Value))
+	}
+	for _, property := range description.children {
+		if value, ok := xmpValue(property); ok {
+			tags.Add(xmpTag(property.name, value))
+		}
+	}
+	return tags
+}
+
+// isXmpSyntax returns true for attributes that are not properties,
+// e.g. namespace declarations, rdf:about and xml:lang.
+func isXmpSyntax(name xml.Name) bool {
+	return name.Space == "xmlns" || (name.Space == "" && name.Local == "xmlns") ||
+		name.Space == rdfNS || name.Space == xmlNS
+}
+
+func xmpTag(name xml.Name, value any) imagemeta.TagInfo {
+	return imagemeta.TagInfo{
+		Source:    imagemeta.XMP,
+		Namespace: types.NamespaceXMP + "/" + name.Space,
+		Tag:       name.Local,
+		Value:     value,
+	}
+}
+
+// xmpValue returns the value of a property element, or false
+// if it is a structure.
+func xmpValue(property *xmpNode) (any, bool) {
+	if resource, ok := property.attr(rdfNS, "resource"); ok {
+		return resource, true
+	}
+	if parseType, _ := property.attr(rdfNS, "parseType"); parseType == "Resource" {
+		return nil, false
+	}
+	if len(property.children) == 0 {
+		return property.text.String(), true
+	}
+
+	container := property.children[0]
+	switch {
+	case container.is(rdfNS, "Alt"):
+		var value string
+		for i, item := range container.children {
+			lang, _ := item.attr(xmlNS, "lang")
+			if i == 0 || lang == "x-default" {
+				value = item.text.String()
+			}
+			if lang == "x-default" {
+				break
+			}
+		}
+		return value, true
+	case container.is(rdfNS, "Seq"), container.is(rdfNS, "Bag"):
+		values := make([]string, 0, len(container.children))
+		for _, item := range container.children {
+			if item.is(rdfNS, "li") && len(item.children) == 0 {
+				values = append(values, item.text.String())
+			}
+		}
+		return values, true
+	}
+	return nil, false
+}
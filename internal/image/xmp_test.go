@@ -0,0 +1,138 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/bep/imagemeta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+const samplePacket = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/"
+    xmlns:Iptc4xmpExt="http://iptc.org/std/Iptc4xmpExt/2008-02-29/"
+    xmlns:gen="http://example.com/gen/1.0/"
+    xmp:CreatorTool="Fooocus v2.5.5"
+    gen:seed="42">
+   <dc:description>
+    <rdf:Alt>
+     <rdf:li xml:lang="de">Ein Sonnenblumenfeld</rdf:li>
+     <rdf:li xml:lang="x-default">A sunflower field</rdf:li>
+    </rdf:Alt>
+   </dc:description>
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>sunflower</rdf:li>
+     <rdf:li>juggernautXL_v8Rundiffusion</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+   <dc:creator><rdf:Seq><rdf:li>Jane Doe</rdf:li></rdf:Seq></dc:creator>
+   <Iptc4xmpExt:DigitalSourceType rdf:resource="http://cv.iptc.org/newscodes/digitalsourcetype/trainedAlgorithmicMedia"/>
+   <gen:sampler>dpmpp_2m_sde_gpu</gen:sampler>
+   <gen:lora rdf:parseType="Resource"><gen:name>sdxl_film_photography_style</gen:name></gen:lora>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestParseXmp(t *testing.T) {
+	tags, err := parseXmp([]byte(samplePacket))
+	require.NoError(t, err)
+
+	gen := types.NamespaceXMP + "/http://example.com/gen/1.0/"
+	assert.Equal(t, types.Tags{
+		{Source: imagemeta.XMP, Namespace: types.NamespaceXMPBasic, Tag: "CreatorTool", Value: "Fooocus v2.5.5"},
+		{Source: imagemeta.XMP, Namespace: gen, Tag: "seed", Value: "42"},
+		{Source: imagemeta.XMP, Namespace: types.NamespaceXMPDublinCore, Tag: "description", Value: "A sunflower field"},
+		{Source: imagemeta.XMP, Namespace: types.NamespaceXMPDublinCore, Tag: "subject", Value: []string{"sunflower", "juggernautXL_v8Rundiffusion"}},
+		{Source: imagemeta.XMP, Namespace: types.NamespaceXMPDublinCore, Tag: "creator", Value: []string{"Jane Doe"}},
+		{Source: imagemeta.XMP, Namespace: types.NamespaceXMPIptcExt, Tag: "DigitalSourceType", Value: "http://cv.iptc.org/newscodes/digitalsourcetype/trainedAlgorithmicMedia"},
+		{Source: imagemeta.XMP, Namespace: gen, Tag: "sampler", Value: "dpmpp_2m_sde_gpu"},
+	}, tags)
+
+	// All XMP properties are found by the XMP namespace
+	assert.Len(t, tags.Lookup(types.NamespaceXMP, "seed"), 1)
+}
+
+func TestParseXmp_Invalid(t *testing.T) {
+	_, err := parseXmp([]byte("<x:xmpmeta><rdf:RDF>"))
+	assert.Error(t, err)
+
+	// Invalid packets do not hide the other metadata
+	data := pngWithChunks(t,
+		pngChunk("tEXt", []byte("parameters\x00{}")),
+		pngChunk("iTXt", []byte(types.PngXMPKeyword+"\x00\x00\x00\x00\x00<x:xmpmeta>")),
+	)
+	tags, err := extractPngChunks(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Len(t, tags, 2)
+}
+
+func TestExtractXmp_PNG(t *testing.T) {
+	data := pngWithChunks(t, pngChunk("iTXt", []byte(types.PngXMPKeyword+"\x00\x00\x00\x00\x00"+samplePacket)))
+
+	ctx, err := NewContextFromReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	assertXmpPrompt(t, ctx)
+}
+
+func TestExtractXmp_JPEG(t *testing.T) {
+	jpeg, err := os.ReadFile("testdata/sample.jpg")
+	require.NoError(t, err)
+
+	// APP1 segment with the XMP namespace after the APP0 and EXIF APP1 segments
+	exif := bytes.Index(jpeg, []byte("Exif\x00\x00")) - 4
+	require.Equal(t, []byte{0xff, 0xe1}, jpeg[exif:exif+2])
+	end := exif + 2 + int(binary.BigEndian.Uint16(jpeg[exif+2:]))
+
+	payload := append([]byte(jpegXmpSignature), samplePacket...)
+	segment := binary.BigEndian.AppendUint16([]byte{0xff, 0xe1}, uint16(len(payload)+2))
+	data := append(append(append([]byte{}, jpeg[:end]...), segment...), payload...)
+	data = append(data, jpeg[end:]...)
+
+	ctx, err := NewContextFromReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	assertXmpPrompt(t, ctx)
+
+	// EXIF is still read
+	_, ok := ctx.EmbeddedMetadata.Get(types.NamespaceEXIF, "ExifVersion")
+	assert.True(t, ok)
+}
+
+func TestExtractXmp_WebP(t *testing.T) {
+	webp, err := os.ReadFile("testdata/sample.webp")
+	require.NoError(t, err)
+	require.Equal(t, "VP8X", string(webp[12:16]))
+
+	// Set the XMP flag of VP8X and append the XMP chunk
+	data := append([]byte{}, webp...)
+	data[20] |= 0x04
+	data = append(data, "XMP "...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(samplePacket)))
+	data = append(data, samplePacket...)
+	if len(samplePacket)%2 != 0 {
+		data = append(data, 0)
+	}
+	binary.LittleEndian.PutUint32(data[4:8], uint32(len(data)-8))
+
+	ctx, err := NewContextFromReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	assertXmpPrompt(t, ctx)
+}
+
+func assertXmpPrompt(t *testing.T, ctx *types.ImageMetadataContext) {
+	t.Helper()
+	tag, prompt, ok := ctx.StringTag(types.NamespaceXMPDublinCore, "description")
+	require.True(t, ok)
+	assert.Equal(t, "A sunflower field", prompt)
+	assert.Equal(t, imagemeta.XMP, tag.Source)
+}
@@ -0,0 +1,131 @@
+// Package label implements labelling images as AI-generated, as required
+// by platforms that publish them, together with their attribution.
+//
+// The IPTC digital source type is written to XMP, and the artist,
+// copyright, description and software to EXIF and XMP, next to the
+// metadata of the software that generated a PNG, JPEG or WebP image.
+// Readers identify the software that generated an image by the EXIF
+// Software tag, so the software of the label is written after the
+// version of the generating software, e.g. "Fooocus v2.5.5 (Lightroom)".
+// The label of an image is read into types.StructuredMetadata by any
+// reader.
+package label
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	m "github.com/fkleon/fooocus-metadata/types"
+	"github.com/fkleon/fooocus-metadata/xmp"
+)
+
+type Config struct {
+	Packet *xmp.Packet
+}
+type Option func(*Config)
+
+// WithPacket adds the label to the given XMP packet, e.g. the generation
+// parameters of xmp.NewPacket, instead of writing a packet with the label
+// only. The packet is not modified.
+func WithPacket(packet *xmp.Packet) Option {
+	return func(cfg *Config) {
+		cfg.Packet = packet
+	}
+}
+
+// LabelWriter can write the label of an image. Empty fields of the
+// label are skipped and keep the value of the source image, if any.
+type LabelWriter struct {
+	cfg Config
+}
+
+// Write writes the label into a blank PNG image.
+func (w LabelWriter) Write(target io.Writer, label m.Label) error {
+	return w.CopyWrite(bytes.NewReader(m.PngTemplate), target, label)
+}
+
+// CopyWrite copies the PNG, JPEG or WebP image with the label. The EXIF
+// tags are set in the existing EXIF data, and the XMP packet replaces any
+// XMP packet of the source image.
+func (w LabelWriter) CopyWrite(source io.Reader, target io.Writer, label m.Label) error {
+	data, err := io.ReadAll(source)
+	if err != nil {
+		return fmt.Errorf("failed to read source: %w", err)
+	}
+
+	values := exifValues(label)
+	if label.Software != "" {
+		values[image.ExifSoftware] = exifSoftware(data, label.Software)
+	}
+
+	var exif bytes.Buffer
+	if err := image.EmbedExif(bytes.NewReader(data), &exif, values); err != nil {
+		return fmt.Errorf("failed to write EXIF: %w", err)
+	}
+
+	packet := &xmp.Packet{}
+	if w.cfg.Packet != nil {
+		packet = w.cfg.Packet.Clone()
+	}
+	packet.SetLabel(label)
+	if err := image.EmbedXMP(&exif, target, packet.Bytes()); err != nil {
+		return fmt.Errorf("failed to write XMP: %w", err)
+	}
+	return nil
+}
+
+func NewLabelWriter(opts ...Option) m.Writer[m.Label] {
+	cfg := Config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return LabelWriter{cfg: cfg}
+}
+
+// exifValues returns the EXIF tags of the non-empty fields of the
+// label, except for the software, see exifSoftware.
+func exifValues(label m.Label) map[uint16]string {
+	values := make(map[uint16]string)
+	for tag, value := range map[uint16]string{
+		image.ExifArtist:           label.Artist,
+		image.ExifCopyright:        label.Copyright,
+		image.ExifImageDescription: label.Description,
+	} {
+		if value != "" {
+			values[tag] = value
+		}
+	}
+	return values
+}
+
+// exifSoftware returns the EXIF Software tag of the image with the given
+// software. The tag starts with the version of the software that generated
+// the image, if known, as the readers identify the software by it.
+func exifSoftware(data []byte, software string) string {
+	ctx, err := image.NewContextFromReader(bytes.NewReader(data))
+	if err != nil {
+		return software
+	}
+
+	// Version from the existing tag, or from the generation parameters
+	_, generator, ok := ctx.StringTag(m.NamespaceEXIF, "Software")
+	if !ok {
+		meta, err := m.Decode(*ctx)
+		if err != nil || meta.Params == nil {
+			return software
+		}
+		generator = meta.Params.Version()
+		if m.ParseSoftwareVersion(generator).Product == "" {
+			// e.g. "v2.1.865" of Fooocus
+			generator = strings.TrimSpace(meta.Source + " " + generator)
+		}
+	}
+
+	if generator == "" || strings.HasPrefix(software, generator) {
+		return software
+	}
+	return fmt.Sprintf("%s (%s)", generator, software)
+}
@@ -0,0 +1,169 @@
+package label
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metadata "github.com/fkleon/fooocus-metadata"
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	_ "github.com/fkleon/fooocus-metadata/fooocusplus"
+	_ "github.com/fkleon/fooocus-metadata/fork"
+	_ "github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	_ "github.com/fkleon/fooocus-metadata/stablediffusion"
+	m "github.com/fkleon/fooocus-metadata/types"
+	"github.com/fkleon/fooocus-metadata/xmp"
+)
+
+var testLabel = m.Label{
+	DigitalSourceType: m.DigitalSourceTrainedAlgorithmicMedia,
+	Artist:            "Jane Doe",
+	Copyright:         "CC BY 4.0",
+	Description:       "A sunflower field",
+	Software:          "Fooocus v2.5.5",
+}
+
+func TestCopyWrite(t *testing.T) {
+	for _, path := range []string{
+		"../fooocus/testdata/fooocus-meta.png",
+		"../fooocus/testdata/fooocus-meta.jpeg",
+		"../fooocus/testdata/fooocus-meta.webp",
+	} {
+		t.Run(path, func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			require.NoError(t, err)
+
+			var target bytes.Buffer
+			require.NoError(t, NewLabelWriter().CopyWrite(bytes.NewReader(source), &target, testLabel))
+
+			// The Fooocus metadata is kept
+			meta, err := metadata.ExtractFromReader(bytes.NewReader(target.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, fooocus.Software, meta.Source)
+			assert.Equal(t, "A sunflower field", meta.Params.PositivePrompt())
+			assert.Equal(t, &testLabel, meta.Label)
+		})
+	}
+}
+
+// TestCopyWrite_AllReaders labels the images of every reader and checks
+// that the metadata is read as before, with a software name that no
+// reader accepts.
+func TestCopyWrite_AllReaders(t *testing.T) {
+	files, err := filepath.Glob("../*/testdata/*-meta.*")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	label := testLabel
+	label.Software = "v2.1.865"
+
+	for _, path := range files {
+		if filepath.Ext(path) == ".bmp" {
+			// No embedded metadata
+			continue
+		}
+		t.Run(path, func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			require.NoError(t, err)
+			want, err := metadata.ExtractFromReader(bytes.NewReader(source))
+			require.NoError(t, err)
+
+			var target bytes.Buffer
+			require.NoError(t, NewLabelWriter().CopyWrite(bytes.NewReader(source), &target, label))
+
+			got, err := metadata.ExtractFromReader(bytes.NewReader(target.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, want.Source, got.Source)
+			assert.Equal(t, want.Provenance, got.Provenance)
+			assert.Equal(t, want.Params.Raw(), got.Params.Raw())
+			require.NotNil(t, got.Label)
+			assert.Equal(t, label.Artist, got.Label.Artist)
+			assert.Equal(t, label.DigitalSourceType, got.Label.DigitalSourceType)
+			assert.Contains(t, got.Label.Software, label.Software)
+			if want.Label != nil && want.Label.Software != "" {
+				// The software that generated the image comes first
+				assert.Equal(t, want.Label.Software+" ("+label.Software+")", got.Label.Software)
+			}
+		})
+	}
+}
+
+func TestCopyWrite_Software(t *testing.T) {
+	for _, tt := range []struct {
+		path     string
+		software string
+		expected string
+	}{
+		// From the generation parameters of PNG images without EXIF
+		{"../fooocus/testdata/fooocus-meta.png", "Lightroom", "Fooocus v2.5.5 (Lightroom)"},
+		{"../fooocusplus/testdata/fooocusplus-meta.png", "Lightroom", "FooocusPlus 1.0.0 (Lightroom)"},
+		{"../ruinedfooocus/testdata/ruinedfooocus-meta.png", "Lightroom", "RuinedFooocus (Lightroom)"},
+		// From the existing EXIF tag
+		{"../fooocus/testdata/fooocus-meta.jpeg", "Lightroom", "Fooocus v2.5.5 (Lightroom)"},
+		{"../fooocusplus/testdata/fooocusplus-meta.jpg", "Lightroom", "FooocusPlus 1.0.0 (Lightroom)"},
+		// Already starts with the software that generated the image
+		{"../fooocus/testdata/fooocus-meta.jpeg", "Fooocus v2.5.5 edited", "Fooocus v2.5.5 edited"},
+	} {
+		t.Run(tt.path, func(t *testing.T) {
+			source, err := os.ReadFile(tt.path)
+			require.NoError(t, err)
+			want, err := metadata.ExtractFromReader(bytes.NewReader(source))
+			require.NoError(t, err)
+
+			var target bytes.Buffer
+			require.NoError(t, NewLabelWriter().CopyWrite(bytes.NewReader(source), &target, m.Label{Software: tt.software}))
+
+			// The labelled image is read by the same reader
+			got, err := metadata.ExtractFromReader(bytes.NewReader(target.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, want.Source, got.Source)
+			require.NotNil(t, got.Label)
+			assert.Equal(t, tt.expected, got.Label.Software)
+		})
+	}
+}
+
+func TestCopyWrite_KeepsFields(t *testing.T) {
+	source, err := os.ReadFile("../fooocus/testdata/fooocus-meta.jpeg")
+	require.NoError(t, err)
+
+	// Empty fields keep the EXIF values of the source
+	var target bytes.Buffer
+	require.NoError(t, NewLabelWriter().CopyWrite(bytes.NewReader(source), &target, m.Label{Artist: "Jane Doe"}))
+
+	meta, err := metadata.ExtractFromReader(bytes.NewReader(target.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, &m.Label{Artist: "Jane Doe", Software: "Fooocus v2.5.5"}, meta.Label)
+}
+
+func TestWrite(t *testing.T) {
+	var target bytes.Buffer
+	require.NoError(t, NewLabelWriter().Write(&target, testLabel))
+
+	meta, err := metadata.ExtractFromReader(bytes.NewReader(target.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, xmp.Software, meta.Source)
+	assert.Equal(t, &testLabel, meta.Label)
+}
+
+func TestWithPacket(t *testing.T) {
+	source, err := os.ReadFile("../fooocus/testdata/fooocus-meta.png")
+	require.NoError(t, err)
+	var packet xmp.Packet
+	packet.SetArray(xmp.DublinCore, "description", xmp.Alt, "A sunflower field")
+
+	var target bytes.Buffer
+	writer := NewLabelWriter(WithPacket(&packet))
+	require.NoError(t, writer.CopyWrite(bytes.NewReader(source), &target, testLabel))
+	assert.Contains(t, target.String(), "<dc:description>")
+	assert.Contains(t, target.String(), "trainedAlgorithmicMedia")
+
+	// Images that cannot hold EXIF and XMP
+	err = writer.CopyWrite(strings.NewReader("GIF89a"), &target, testLabel)
+	assert.ErrorIs(t, err, m.ErrUnsupportedMIME)
+}
@@ -37,6 +37,10 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	// Required image decoders
 	_ "image/jpeg"
@@ -48,6 +52,16 @@ import (
 	"github.com/fkleon/fooocus-metadata/types"
 )
 
+// ImageExtensions are the extensions of the image files that are
+// supported, e.g. when indexing or watching folders.
+var ImageExtensions = []string{".png", ".jpg", ".jpeg", ".webp", ".gif", ".bmp", ".avif", ".heic", ".heif"}
+
+// IsImageFile returns true if the file has one of the ImageExtensions,
+// in any case.
+func IsImageFile(path string) bool {
+	return slices.Contains(ImageExtensions, strings.ToLower(filepath.Ext(path)))
+}
+
 // ExtractOptions contains the options for the Extract function.
 type ExtractOptions struct {
 	// The path of the file to read image metadata from.
@@ -55,7 +69,10 @@ type ExtractOptions struct {
 }
 
 type Config struct {
-	Path string
+	Path             string
+	Time             types.TimeOptions
+	FilenamePatterns map[string][]*types.FilenamePattern
+	PrivateLog       types.PrivateLogMode
 }
 type Option func(*Config)
 
@@ -67,13 +84,67 @@ func WithPath(path string) Option {
 	}
 }
 
-func ExtractFromFile(path string) (params types.StructuredMetadata, err error) {
+// To configure the sources of the creation time and their order
+// of precedence, defaults to types.DefaultTimeSources. Passing no
+// sources disables the resolution of the creation time.
+func WithTimeSources(sources ...types.TimeSource) Option {
+	return func(cfg *Config) {
+		cfg.Time.Sources = append([]types.TimeSource{}, sources...)
+	}
+}
+
+// To configure the location of times without zone information,
+// e.g. the date in the file name, defaults to time.Local.
+func WithLocation(loc *time.Location) Option {
+	return func(cfg *Config) {
+		cfg.Time.Location = loc
+	}
+}
+
+// To replace the default file name patterns of the reader for the given
+// software, e.g. "Fooocus". Patterns for the empty software name apply to
+// all readers without specific patterns.
+func WithFilenamePatterns(software string, patterns ...*types.FilenamePattern) Option {
+	return func(cfg *Config) {
+		if cfg.FilenamePatterns == nil {
+			cfg.FilenamePatterns = make(map[string][]*types.FilenamePattern)
+		}
+		cfg.FilenamePatterns[software] = patterns
+	}
+}
+
+// To configure how the private log of the output folder is used,
+// defaults to types.PrivateLogFallback. With types.PrivateLogMerge,
+// embedded metadata is merged with the private log entry of the image.
+func WithPrivateLog(mode types.PrivateLogMode) Option {
+	return func(cfg *Config) {
+		cfg.PrivateLog = mode
+	}
+}
+
+// ExtractFromFile reads the metadata of the image file at path.
+// The WithPath option is ignored.
+//
+// Created falls back to the modification time of the file if no other
+// source of the creation time applies, see types.DefaultTimeSources.
+// Pass WithTimeSources without sources to leave Created unset, as
+// before the creation time was resolved.
+func ExtractFromFile(path string, opts ...Option) (params types.StructuredMetadata, err error) {
 	slog.Info("ExtractFromFile", "path", path)
 
+	// Customise config
+	cfg := Config{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	imageFile, err := image.NewContextFromFile(path)
 	if err != nil {
 		return
 	}
+	imageFile.Time = cfg.Time
+	imageFile.FilenamePatterns = cfg.FilenamePatterns
+	imageFile.PrivateLog = cfg.PrivateLog
 
 	return types.Decode(*imageFile)
 }
@@ -97,6 +168,9 @@ func ExtractFromReader(reader io.ReadSeeker, opts ...Option) (params types.Struc
 		return
 	}
 	imageCtx.Filepath = cfg.Path
+	imageCtx.Time = cfg.Time
+	imageCtx.FilenamePatterns = cfg.FilenamePatterns
+	imageCtx.PrivateLog = cfg.PrivateLog
 
 	return types.Decode(*imageCtx)
 }
@@ -1,6 +1,9 @@
 package metadata
 
 import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"log/slog"
 	"os"
@@ -11,8 +14,12 @@ import (
 
 	_ "github.com/fkleon/fooocus-metadata/fooocus"
 	_ "github.com/fkleon/fooocus-metadata/fooocusplus"
+	_ "github.com/fkleon/fooocus-metadata/fork"
 	_ "github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	_ "github.com/fkleon/fooocus-metadata/xmp"
 
+	"github.com/fkleon/fooocus-metadata/types"
+	pngembed "github.com/sabhiram/png-embed"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -24,6 +31,13 @@ func TestMain(m *testing.M) {
 	os.Exit(exitVal)
 }
 
+func TestIsImageFile(t *testing.T) {
+	assert.True(t, IsImageFile("outputs/image.png"))
+	assert.True(t, IsImageFile("outputs/IMAGE.HEIC"))
+	assert.False(t, IsImageFile("outputs/log.html"))
+	assert.False(t, IsImageFile("outputs/image.png.xmp"))
+}
+
 func TestExtractOne_Fooocus(t *testing.T) {
 	const testpath = "./fooocus/testdata/"
 	var files = []string{
@@ -43,6 +57,25 @@ func TestExtractOne_Fooocus(t *testing.T) {
 	}
 }
 
+func TestExtract_ImageSize(t *testing.T) {
+	meta, err := ExtractFromFile("./fooocus/testdata/fooocus-meta.png")
+	require.NoError(t, err)
+	assert.Equal(t, types.ImageProperties{Width: 512, Height: 512, ColorType: types.ColorRGB, BitDepth: 8, Frames: 1}, meta.Image)
+	assert.Equal(t, types.SizeOriginal, meta.Size.Change)
+
+	// Generated at 1024x1024 and downscaled
+	meta, err = ExtractFromFile("./ruinedfooocus/testdata/ruinedfooocus-meta.jpeg")
+	require.NoError(t, err)
+	assert.Equal(t, types.SizeCheck{
+		GeneratedWidth:  1024,
+		GeneratedHeight: 1024,
+		Width:           512,
+		Height:          512,
+		Change:          types.SizeDownscaled,
+		Scale:           0.5,
+	}, meta.Size)
+}
+
 func TestExtractOne_FooocusPlus(t *testing.T) {
 	const testpath = "./fooocusplus/testdata/"
 	var files = []string{
@@ -81,6 +114,39 @@ func TestExtractOne_RuinedFooocus(t *testing.T) {
 	}
 }
 
+func TestExtractOne_Forks(t *testing.T) {
+	var files = []struct {
+		path    string
+		source  string
+		version string
+	}{
+		{"./fork/testdata/defooocus-meta.png", "Defooocus", "Defooocus v1.0.3"},
+		{"./fork/testdata/fooocusapi-meta.png", "FooocusAPI", "Fooocus-API v0.4.1.1"},
+		{"./fork/testdata/fooocusmre-meta.png", "FooocusMRE", "Fooocus-MRE v2.0.78.5"},
+		{"./fork/testdata/simplesdxl-meta.png", "SimpleSDXL", "SimpleSDXL v2.1.1"},
+	}
+
+	for _, file := range files {
+		t.Run(path.Base(file.path), func(t *testing.T) {
+			meta, err := ExtractFromFile(file.path)
+			require.NoError(t, err)
+			assert.Equal(t, file.source, meta.Source)
+			assert.Equal(t, file.version, meta.Params.Version())
+			assert.NotZero(t, meta.Params.Raw())
+		})
+	}
+}
+
+// assertCreatedFromModTime asserts that the creation time of the
+// metadata is the modification time of the file.
+func assertCreatedFromModTime(t *testing.T, path string, meta types.StructuredMetadata) {
+	t.Helper()
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, types.TimeFromModTime, meta.CreatedSource)
+	assert.True(t, info.ModTime().Equal(meta.Created), "expected %s, got %s", info.ModTime(), meta.Created)
+}
+
 func TestExtractMetadata_Fooocus(t *testing.T) {
 	const testpath = "./fooocus/testdata/"
 	testCases := []struct {
@@ -103,7 +169,8 @@ func TestExtractMetadata_Fooocus(t *testing.T) {
 
 			assert.Equal(t, tc.source, meta.Source)
 			assert.Equal(t, tc.software, meta.Params.Version())
-			assert.Zero(t, meta.Created)
+			// No date in file name or embedded metadata
+			assertCreatedFromModTime(t, path, meta)
 		})
 	}
 }
@@ -130,7 +197,8 @@ func TestExtractMetadata_FooocusPlus(t *testing.T) {
 
 			assert.Equal(t, tc.source, meta.Source)
 			assert.Equal(t, tc.software, meta.Params.Version())
-			assert.Zero(t, meta.Created)
+			// No date in file name or embedded metadata
+			assertCreatedFromModTime(t, path, meta)
 		})
 	}
 }
@@ -143,6 +211,8 @@ func TestExtractMetadata_RuinedFooocus(t *testing.T) {
 		software string
 	}{
 		{"ruinedfooocus-meta.png", "RuinedFooocus", "RuinedFooocus"},
+		{"ruinedfooocus-meta.jpeg", "RuinedFooocus", "RuinedFooocus 2.1.0"},
+		{"ruinedfooocus-meta.webp", "RuinedFooocus", "RuinedFooocus 2.1.0"},
 	}
 
 	for _, tc := range testCases {
@@ -155,7 +225,8 @@ func TestExtractMetadata_RuinedFooocus(t *testing.T) {
 
 			assert.Equal(t, tc.source, meta.Source)
 			assert.Equal(t, tc.software, meta.Params.Version())
-			assert.Zero(t, meta.Created)
+			// No date in file name or embedded metadata
+			assertCreatedFromModTime(t, path, meta)
 		})
 	}
 }
@@ -176,14 +247,167 @@ func TestExtractCreatedTime(t *testing.T) {
 			_, err = io.Copy(out, in)
 			require.NoError(t, err)
 
-			meta, err := ExtractFromFile(out.Name())
+			meta, err := ExtractFromFile(out.Name(), WithLocation(time.UTC))
 			require.NoError(t, err)
 			require.NotNil(t, meta)
 			assert.Equal(t, expectedCreatedTime, meta.Created)
+			assert.Equal(t, types.TimeFromFilename, meta.CreatedSource)
+
+			params, ok := meta.Params.(interface{ CreatedTime() time.Time })
+			require.True(t, ok)
+			assert.Equal(t, expectedCreatedTime, params.CreatedTime())
 		})
 	}
 }
 
+func TestExtractCreatedTime_Location(t *testing.T) {
+	loc := time.FixedZone("UTC+13", 13*60*60)
+	out := createTemp(t, "2024-01-05_23-11-48_9167_*.png")
+	copyFile(t, "./fooocus/testdata/fooocus-meta.png", out)
+
+	meta, err := ExtractFromFile(out.Name(), WithLocation(loc))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, time.January, 5, 23, 11, 48, 0, loc), meta.Created)
+	assert.Equal(t, time.Date(2024, time.January, 5, 10, 11, 48, 0, time.UTC), meta.Created.UTC())
+}
+
+func TestExtractCreatedTime_Sources(t *testing.T) {
+	out := createTemp(t, "2024-01-05_23-11-48_9167_*.png")
+	copyFile(t, "./fooocus/testdata/fooocus-meta.png", out)
+
+	mtime := time.Date(2025, time.February, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(out.Name(), mtime, mtime))
+
+	meta, err := ExtractFromFile(out.Name(), WithLocation(time.UTC),
+		WithTimeSources(types.TimeFromModTime, types.TimeFromFilename))
+	require.NoError(t, err)
+	assert.Equal(t, mtime, meta.Created)
+	assert.Equal(t, types.TimeFromModTime, meta.CreatedSource)
+
+	// No sources, Created is unset as before the creation time was resolved
+	meta, err = ExtractFromFile(out.Name(), WithTimeSources())
+	require.NoError(t, err)
+	assert.Zero(t, meta.Created)
+	assert.Empty(t, meta.CreatedSource)
+}
+
+func TestExtractCreatedTime_PrivateLog(t *testing.T) {
+	dir := t.TempDir()
+	out, err := os.Create(filepath.Join(dir, "renamed.png"))
+	require.NoError(t, err)
+	copyFile(t, "./internal/image/testdata/sample.png", out)
+
+	// Image has no embedded metadata, the log entry is found by file name
+	log, err := os.ReadFile("./fooocus/testdata/log.html")
+	require.NoError(t, err)
+	log = bytes.ReplaceAll(log, []byte("2024-01-05_23-11-48_9167.png"), []byte("renamed.png"))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "log.html"), log, 0o644))
+
+	meta, err := ExtractFromFile(out.Name(), WithLocation(time.UTC),
+		WithTimeSources(types.TimeFromFilename, types.TimeFromPrivateLog))
+	require.NoError(t, err)
+	assert.Equal(t, "Fooocus", meta.Source)
+	assert.Equal(t, time.Date(2025, time.January, 20, 0, 0, 0, 0, time.UTC), meta.Created)
+	assert.Equal(t, types.TimeFromPrivateLog, meta.CreatedSource)
+
+	// PNG tIME of the sample image takes precedence by default
+	meta, err = ExtractFromFile(out.Name(), WithLocation(time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, time.April, 11, 11, 53, 39, 0, time.UTC), meta.Created)
+	assert.Equal(t, types.TimeFromPNG, meta.CreatedSource)
+}
+
+func TestExtract_PrivateLogFallback(t *testing.T) {
+	dir := t.TempDir()
+	out, err := os.Create(filepath.Join(dir, "fooocus-meta.bmp"))
+	require.NoError(t, err)
+	copyFile(t, "./fooocus/testdata/fooocus-meta.bmp", out)
+
+	// BMP has no metadata container, the log entry is found by file name
+	log, err := os.ReadFile("./fooocus/testdata/log.html")
+	require.NoError(t, err)
+	log = bytes.ReplaceAll(log, []byte("fooocus-meta.png"), []byte("fooocus-meta.bmp"))
+	log = bytes.ReplaceAll(log, []byte("fooocus-meta_png"), []byte("fooocus-meta_bmp"))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "log.html"), log, 0o644))
+
+	meta, err := ExtractFromFile(out.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "Fooocus", meta.Source)
+	assert.Equal(t, types.LocationPrivateLog, meta.Provenance.Location)
+	assert.Equal(t, "juggernautXL_v8Rundiffusion", meta.Params.Model())
+	assert.Equal(t, types.SizeOriginal, meta.Size.Change)
+}
+
+func TestExtract_XMPFallback(t *testing.T) {
+	png, err := os.ReadFile("./internal/image/testdata/sample.png")
+	require.NoError(t, err)
+
+	// iTXt chunk with an XMP packet after the IHDR chunk
+	packet := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmp:CreatorTool="Fooocus v2.5.5">
+<dc:description><rdf:Alt><rdf:li xml:lang="x-default">A sunflower field</rdf:li></rdf:Alt></dc:description>
+</rdf:Description></rdf:RDF></x:xmpmeta>`
+	data := []byte(types.PngXMPKeyword + "\x00\x00\x00\x00\x00" + packet)
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(append(chunk, "iTXt"...), data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	png = append(append(append([]byte{}, png[:33]...), chunk...), png[33:]...)
+
+	meta, err := ExtractFromReader(bytes.NewReader(png))
+	require.NoError(t, err)
+	assert.Equal(t, "XMP", meta.Source)
+	assert.Equal(t, "A sunflower field", meta.Params.PositivePrompt())
+	assert.Equal(t, "Fooocus v2.5.5", meta.Params.Version())
+}
+
+func TestExtract_ParseError(t *testing.T) {
+	png, err := os.ReadFile("./internal/image/testdata/sample.png")
+	require.NoError(t, err)
+
+	// Fooocus metadata with an invalid value
+	png, err = pngembed.Embed(png, "fooocus_scheme", "fooocus")
+	require.NoError(t, err)
+	png, err = pngembed.Embed(png, "parameters", `{"prompt": "A sunflower field", "steps": "abc", "version": "Fooocus v2.5.5"}`)
+	require.NoError(t, err)
+
+	_, err = ExtractFromReader(bytes.NewReader(png))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, types.ErrNoMetadata)
+
+	var parseErr *types.ParseError
+	require.ErrorAs(t, err, &parseErr)
+	assert.Contains(t, err.Error(), "Fooocus: failed to read parameters at offset 46")
+}
+
+func TestExtractFilenamePatterns(t *testing.T) {
+	out := createTemp(t, "sunflower-20240105-231148-*.png")
+	copyFile(t, "./fooocus/testdata/fooocus-meta.png", out)
+
+	// Renamed file does not match the default pattern
+	meta, err := ExtractFromFile(out.Name(), WithLocation(time.UTC))
+	require.NoError(t, err)
+	assert.Empty(t, meta.Filename.Pattern)
+	assert.Equal(t, types.TimeFromModTime, meta.CreatedSource)
+
+	pattern, err := types.FilenameTemplate("sunflower-{date:20060102}-{time:150405}-{*}")
+	require.NoError(t, err)
+
+	meta, err = ExtractFromFile(out.Name(), WithLocation(time.UTC), WithFilenamePatterns("Fooocus", pattern))
+	require.NoError(t, err)
+	assert.Equal(t, "sunflower-{date:20060102}-{time:150405}-{*}", meta.Filename.Pattern)
+	assert.Equal(t, time.Date(2024, time.January, 5, 23, 11, 48, 0, time.UTC), meta.Created)
+	assert.Equal(t, types.TimeFromFilename, meta.CreatedSource)
+}
+
+func copyFile(t *testing.T, src string, out *os.File) {
+	in, err := os.Open(src)
+	require.NoError(t, err)
+	defer in.Close()
+	_, err = io.Copy(out, in)
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+}
+
 /*
 func TestEmbedMetadata_Fooocus(t *testing.T) {
 
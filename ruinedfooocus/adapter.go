@@ -2,6 +2,7 @@ package ruinedfooocus
 
 import (
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fkleon/fooocus-metadata/types"
@@ -14,8 +15,22 @@ type Parameters struct {
 	Created time.Time
 }
 
+// Version returns the software and its release if recorded,
+// e.g. "RuinedFooocus 2.1.0".
 func (m Parameters) Version() string {
-	return m.Metadata.Version
+	software := m.Metadata.Version
+	if software == "" {
+		software = Software
+	}
+	if m.Release == "" || strings.Contains(software, m.Release) {
+		return software
+	}
+	return software + " " + m.Release
+}
+
+// SoftwareVersion returns the parsed version, see Version.
+func (m Parameters) SoftwareVersion() types.SoftwareVersion {
+	return types.ParseSoftwareVersion(m.Version()).WithProduct(Software)
 }
 
 func (m Parameters) Model() string {
@@ -45,6 +60,28 @@ func (m Parameters) Seed() string {
 	return strconv.Itoa(m.Metadata.Seed)
 }
 
+// SeedValue returns the parsed seed, see Seeds.
+func (m Parameters) SeedValue() types.Seed {
+	seed, _ := Seeds.Parse(m.Seed())
+	return seed
+}
+
+// Sampler returns the normalised sampler, see types.NormaliseSampler.
+func (m Parameters) Sampler() string {
+	sampler, _ := types.NormaliseSampler(m.Metadata.Sampler)
+	return sampler
+}
+
+// Scheduler returns the normalised scheduler, see types.NormaliseScheduler.
+func (m Parameters) Scheduler() string {
+	scheduler, _ := types.NormaliseScheduler(m.Metadata.Scheduler)
+	return scheduler
+}
+
+func (m Parameters) Size() (width int, height int) {
+	return int(m.Width), int(m.Height)
+}
+
 func (m Parameters) CreatedTime() time.Time {
 	return m.Created
 }
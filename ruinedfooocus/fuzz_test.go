@@ -0,0 +1,33 @@
+package ruinedfooocus
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzParseMetadata(f *testing.F) {
+	f.Add(metaJson)
+
+	f.Fuzz(func(t *testing.T, parameters string) {
+		_, _ = parseMetadata(parameters)
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	data, err := os.ReadFile("testdata/ruinedfooocus-meta.png")
+	require.NoError(f, err)
+	f.Add(data)
+
+	extractor := NewRuinedFooocusMetadataExtractor()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ctx, err := image.NewContextFromReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		_, _ = extractor.Decode(*ctx)
+	})
+}
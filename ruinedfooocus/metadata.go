@@ -1,26 +1,37 @@
 // Package ruinedfooocus implements reading and writing [RuinedFooocus] metadata
 // (image generation parameters).
 //
+// RuinedFooocus embeds JSON metadata in the PNG "parameters" chunk, or in
+// the EXIF "UserComment" of JPEG and WebP images. Keys that are not part
+// of the struct are kept in Metadata.Extra.
+//
 // [RuinedFooocus]: https://github.com/runew0lf/RuinedFooocus
 package ruinedfooocus
 
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"path/filepath"
 	"strconv"
 	"strings"
+
+	"github.com/fkleon/fooocus-metadata/types"
 )
 
 const (
 	Software = "RuinedFooocus"
 )
 
+// Seeds of RuinedFooocus, where "-1" selects a random seed.
+var Seeds = types.SeedRange{Max: math.MaxInt64, Random: "-1"}
+
 type Metadata struct {
-	BaseModel      string  `json:"base_model_name"`
+	BaseModel      string  `json:"base_model_name"` // e.g. "sd_xl_base_1.0.safetensors" or "flux1-dev-Q8_0.gguf"
 	BaseModelHash  string  `json:"base_model_hash"`
 	CfgScale       float32 `json:"cfg"`
 	ClipSkip       uint8   `json:"clip_skip"`
-	Denoise        any     `json:"denoise"`
+	Denoise        Denoise `json:"denoise"` // null for text to image
 	Height         uint16  `json:"height"`
 	Loras          []Lora  `json:"loras"`
 	NegativePrompt string  `json:"Negative"`
@@ -32,6 +43,91 @@ type Metadata struct {
 	Steps          uint8   `json:"steps"`
 	Version        string  `json:"software"`
 	Width          uint16  `json:"width"`
+
+	// Added by newer releases
+	Release      string `json:"version,omitempty"` // e.g. "2.1.0"
+	LoraKeywords string `json:"lora_keywords,omitempty"`
+	Vae          string `json:"vae_name,omitempty"`
+	ClipModel    string `json:"clip_name,omitempty"` // text encoder of GGUF models
+
+	// Keys that are not part of the struct.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// metadata has the fields of Metadata without its JSON methods.
+type metadata Metadata
+
+func (meta *Metadata) UnmarshalJSON(data []byte) (err error) {
+	if err = json.Unmarshal(data, (*metadata)(meta)); err != nil {
+		return
+	}
+	meta.Extra, err = types.UnknownFields(data, meta)
+	return
+}
+
+func (meta Metadata) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(metadata(meta))
+	if err != nil {
+		return nil, err
+	}
+	return types.MergeFields(data, meta.Extra)
+}
+
+// ModelFormat returns the file format of the base model,
+// e.g. "safetensors" or "gguf", or an empty string if unknown.
+func (meta Metadata) ModelFormat() string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(meta.BaseModel), "."))
+}
+
+// Denoise is the denoising strength of image to image generations.
+//
+// Releases of RuinedFooocus record it as a number, a numeric string or
+// null, so the value is kept as is and read with Float.
+type Denoise json.RawMessage
+
+// DenoiseOf returns the denoising strength as a number.
+func DenoiseOf(strength float32) Denoise {
+	return Denoise(strconv.FormatFloat(float64(strength), 'g', -1, 32))
+}
+
+// Float returns the denoising strength, or false if it is not set
+// or not a number.
+func (d Denoise) Float() (float32, bool) {
+	var value any
+	if err := json.Unmarshal(d, &value); err != nil {
+		return 0, false
+	}
+
+	var strength float64
+	switch v := value.(type) {
+	case float64:
+		strength = v
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 32)
+		if err != nil {
+			return 0, false
+		}
+		strength = parsed
+	default:
+		return 0, false
+	}
+	return float32(strength), true
+}
+
+func (d *Denoise) UnmarshalJSON(p []byte) error {
+	if string(p) == "null" {
+		*d = nil
+		return nil
+	}
+	*d = append((*d)[:0], p...)
+	return nil
+}
+
+func (d Denoise) MarshalJSON() ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("null"), nil
+	}
+	return d, nil
 }
 
 // Encoded as nested list of format:
@@ -47,6 +143,9 @@ func (l *Lora) UnmarshalJSON(p []byte) error {
 	if err := json.Unmarshal(p, &tmp); err != nil {
 		return err
 	}
+	if len(tmp) < 2 {
+		return fmt.Errorf("invalid LoRA, expected hash and details: %s", p)
+	}
 	if err := json.Unmarshal(tmp[0], &l.Hash); err != nil {
 		return err
 	}
@@ -58,6 +157,9 @@ func (l *Lora) UnmarshalJSON(p []byte) error {
 	}
 
 	loraCombined := strings.SplitN(details, " - ", 2)
+	if len(loraCombined) < 2 {
+		return fmt.Errorf("invalid LoRA details, expected \"<weight> - <name>\": %q", details)
+	}
 
 	weight, err := strconv.ParseFloat(loraCombined[0], 32)
 	if err != nil {
@@ -81,7 +183,7 @@ func parseMetadata(parameters string) (meta Metadata, err error) {
 	// Parse metadata
 	err = json.Unmarshal([]byte(parameters), &meta)
 	if err != nil {
-		return meta, fmt.Errorf("%s: failed to read parameters: %w", Software, err)
+		return meta, types.NewParseError(Software, "parameters", err)
 	}
 
 	return
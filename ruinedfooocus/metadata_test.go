@@ -2,6 +2,7 @@ package ruinedfooocus
 
 import (
 	"encoding/json"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -68,3 +69,85 @@ func TestEncodeMetadata(t *testing.T) {
 	require.NoError(t, err)
 	assert.JSONEq(t, metaJson, string(encoded))
 }
+
+func TestDecodeMalformedLora(t *testing.T) {
+	for _, in := range []string{`[]`, `["hash"]`, `["hash", "no weight"]`, `["hash", "heavy - name"]`} {
+		var lora Lora
+		assert.Error(t, json.Unmarshal([]byte(in), &lora), in)
+	}
+}
+
+func TestDecodeNewerMetadata(t *testing.T) {
+	data, err := os.ReadFile("./testdata/meta.json")
+	require.NoError(t, err)
+
+	var decoded Metadata
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	denoise, ok := decoded.Denoise.Float()
+	assert.True(t, ok)
+	assert.Equal(t, float32(0.75), denoise)
+	assert.Equal(t, "2.1.0", decoded.Release)
+	assert.Equal(t, "ae.safetensors", decoded.Vae)
+	assert.Equal(t, map[string]json.RawMessage{"guidance": json.RawMessage(`3.5`)}, decoded.Extra)
+
+	encoded, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(encoded))
+}
+
+func TestDecodeDenoise(t *testing.T) {
+	testCases := []struct {
+		json     string
+		expected float32
+		ok       bool
+	}{
+		{`0.75`, 0.75, true},
+		{`1`, 1, true},
+		{`"0.5"`, 0.5, true},
+		{`null`, 0, false},
+		{`"auto"`, 0, false},
+		{`[0.5]`, 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.json, func(t *testing.T) {
+			var decoded Metadata
+			require.NoError(t, json.Unmarshal([]byte(`{"denoise": `+tc.json+`}`), &decoded))
+
+			denoise, ok := decoded.Denoise.Float()
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, denoise)
+
+			// The value is kept as is
+			encoded, err := json.Marshal(decoded)
+			require.NoError(t, err)
+			var object map[string]json.RawMessage
+			require.NoError(t, json.Unmarshal(encoded, &object))
+			assert.JSONEq(t, tc.json, string(object["denoise"]))
+		})
+	}
+
+	denoise, ok := DenoiseOf(0.3).Float()
+	assert.True(t, ok)
+	assert.Equal(t, float32(0.3), denoise)
+}
+
+func TestDecodeDenoiseString(t *testing.T) {
+	data, err := os.ReadFile("./testdata/meta-denoise.json")
+	require.NoError(t, err)
+
+	var decoded Metadata
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, Denoise(`"0.5"`), decoded.Denoise)
+
+	encoded, err := json.Marshal(decoded)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(data), string(encoded))
+}
+
+func TestModelFormat(t *testing.T) {
+	assert.Equal(t, "safetensors", meta.ModelFormat())
+	assert.Equal(t, "gguf", Metadata{BaseModel: "flux1-dev-Q8_0.GGUF"}.ModelFormat())
+	assert.Equal(t, "", Metadata{}.ModelFormat())
+}
@@ -5,6 +5,8 @@ import (
 	"io"
 	"log/slog"
 	"path/filepath"
+	"strings"
+	"time"
 
 	m "github.com/fkleon/fooocus-metadata/types"
 )
@@ -16,18 +18,44 @@ type RuinedFooocusMetadataExtractor struct {
 }
 
 func (e RuinedFooocusMetadataExtractor) Decode(file m.ImageMetadataContext) (meta Metadata, err error) {
+	meta, _, err = e.decode(file)
+	return
+}
+
+// decode reads the embedded metadata and returns its provenance.
+func (e RuinedFooocusMetadataExtractor) decode(file m.ImageMetadataContext) (meta Metadata, provenance m.Provenance, err error) {
+
+	// Software from EXIF "Software" of JPEG and WebP images
+	if _, software, ok := file.StringTag(m.NamespaceEXIF, "Software"); ok {
+		if !strings.HasPrefix(software, Software) {
+			return meta, provenance, fmt.Errorf("%s: EXIF: Unsupported software: %s: %w", Software, software, m.ErrNoMetadata)
+		}
+	}
+
+	// Parameters from EXIF "UserComment" or PNG "parameters"
+	paramTag, parameters, ok := file.StringTag(m.NamespaceEXIF, "UserComment")
+	if !ok {
+		if paramTag, parameters, ok = file.StringTag(m.NamespacePNG, "parameters"); !ok {
+			return meta, provenance, fmt.Errorf("%s: Parameters not found: %w", Software, m.ErrNoMetadata)
+		}
+	}
 
-	var data = file.EmbeddedMetadata
-	var parameters string
+	if meta, err = parseMetadata(parameters); err != nil {
+		return
+	}
 
-	// Parameters from PNG "parameters"
-	if paramTag, ok := data["parameters"]; ok {
-		parameters = paramTag.Value.(string)
-	} else {
-		return meta, fmt.Errorf("%s: Parameters not found", Software)
+	// Other tools use the same keys. Older releases do not record
+	// the software, but always record the base model name.
+	if meta.Version == "" {
+		if meta.BaseModel == "" {
+			return meta, provenance, fmt.Errorf("%s: Unsupported metadata: %w", Software, m.ErrNoMetadata)
+		}
+	} else if !strings.HasPrefix(meta.Version, Software) {
+		return meta, provenance, fmt.Errorf("%s: Unsupported software: %s: %w", Software, meta.Version, m.ErrNoMetadata)
 	}
 
-	return parseMetadata(parameters)
+	provenance = m.EmbeddedProvenance(paramTag)
+	return
 }
 
 func (e RuinedFooocusMetadataExtractor) Extract(file m.ImageMetadataContext) (m.StructuredMetadata, error) {
@@ -36,25 +64,35 @@ func (e RuinedFooocusMetadataExtractor) Extract(file m.ImageMetadataContext) (m.
 		Source: Software,
 	}
 
-	// Try to parse creation time from filename
 	filename := filepath.Base(file.Filepath)
-	meta.Created, _ = e.ParseDateFromFilename(filename)
+	meta.Filename = e.ParseFilename(file)
 
 	slog.Debug("Checking embedded metadata..", "file", filename)
-	if params, err := e.Decode(file); err == nil {
+	params, provenance, err := e.decode(file)
+	if err == nil {
+		meta.Created, meta.CreatedSource = e.ResolveCreated(file, time.Time{})
+		meta.Provenance = provenance
 		meta.Params = &Parameters{
 			Metadata: params,
+			Created:  meta.Created,
 		}
 		return meta, nil
 	}
 
-	return meta, fmt.Errorf("%s: No metadata found", Software)
+	return meta, err
+}
+
+// DefaultFilenamePatterns match the date-based file names
+// of RuinedFooocus, e.g. "2024-01-05_23-11-48_1.png".
+var DefaultFilenamePatterns = []*m.FilenamePattern{
+	m.MustFilenameTemplate("{date}_{time}{*}"),
 }
 
 func NewRuinedFooocusMetadataExtractor() m.Reader[Metadata] {
 	return RuinedFooocusMetadataExtractor{
 		FileMetadataExtractor: &m.FileMetadataExtractor{
-			DateLayout: "2006-01-02_15-04-05",
+			Software:         Software,
+			FilenamePatterns: DefaultFilenamePatterns,
 		},
 	}
 }
@@ -78,7 +116,7 @@ func (w RuinedFooocusMetadataWriter) CopyWrite(source io.Reader, target io.Write
 
 func NewRuinedFooocusMetadataWriter() m.Writer[Metadata] {
 	return RuinedFooocusMetadataWriter{
-		PngMetadataWriter: &m.PngMetadataWriter{},
+		PngMetadataWriter: m.NewPngMetadataWriter(),
 	}
 }
 
@@ -1,9 +1,14 @@
 package ruinedfooocus
 
 import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"os"
 	"testing"
 
 	"github.com/bep/imagemeta"
+	"github.com/fkleon/fooocus-metadata/internal/image"
 	"github.com/fkleon/fooocus-metadata/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -11,14 +16,14 @@ import (
 
 func TestExtractMetadataFromPNG(t *testing.T) {
 
-	var pngData = make(map[string]imagemeta.TagInfo)
+	var pngData types.Tags
 
-	pngData["parameters"] = imagemeta.TagInfo{
+	pngData.Add(imagemeta.TagInfo{
 		Source:    0,
 		Namespace: "PNG/tEXt",
 		Tag:       "parameters",
 		Value:     metaJson,
-	}
+	})
 
 	extractor := NewRuinedFooocusMetadataExtractor()
 	fooocusData, err := extractor.Decode(types.ImageMetadataContext{
@@ -39,3 +44,110 @@ func TestAdapter(t *testing.T) {
 	param.BaseModel = "Pony/ponysdxl.safetensors"
 	assert.Equal(t, "ponysdxl", param.Model())
 }
+
+func TestExtractMetadataFromExif(t *testing.T) {
+	extractor := NewRuinedFooocusMetadataExtractor()
+
+	for _, file := range []string{"ruinedfooocus-meta.jpeg", "ruinedfooocus-meta.webp"} {
+		t.Run(file, func(t *testing.T) {
+			ctx, err := image.NewContextFromFile("./testdata/" + file)
+			require.NoError(t, err)
+
+			meta, err := extractor.Extract(*ctx)
+			require.NoError(t, err)
+			assert.Equal(t, "RuinedFooocus 2.1.0", meta.Params.Version())
+			assert.Equal(t, "flux1-dev-Q8_0", meta.Params.Model())
+			assert.Equal(t, "2718281828", meta.Params.Seed())
+			assert.Equal(t, "euler", meta.Params.Sampler())
+			assert.Equal(t, "simple", meta.Params.Scheduler())
+			assert.Equal(t, types.Provenance{
+				Location:  types.LocationEmbedded,
+				Container: "EXIF/IFD0",
+				Key:       "UserComment",
+			}, meta.Provenance)
+
+			raw := meta.Params.Raw().(Metadata)
+			denoise, ok := raw.Denoise.Float()
+			assert.True(t, ok)
+			assert.Equal(t, float32(0.75), denoise)
+			assert.Equal(t, "gguf", raw.ModelFormat())
+			assert.Equal(t, "realism", raw.LoraKeywords)
+			assert.Equal(t, "t5xxl_fp8_e4m3fn.safetensors", raw.ClipModel)
+			assert.Equal(t, json.RawMessage(`3.5`), raw.Extra["guidance"])
+		})
+	}
+}
+
+func TestDecodeRejectsOtherSoftware(t *testing.T) {
+	extractor := NewRuinedFooocusMetadataExtractor()
+
+	_, err := extractor.Decode(types.ImageMetadataContext{
+		EmbeddedMetadata: types.Tags{
+			{Namespace: "PNG/tEXt", Tag: "parameters", Value: `{"Prompt": "a cat", "software": "Other"}`},
+		},
+	})
+	assert.ErrorIs(t, err, types.ErrNoMetadata)
+
+	var exifData types.Tags
+	exifData.Add(imagemeta.TagInfo{Source: imagemeta.EXIF, Namespace: "IFD0", Tag: "Software", Value: "Fooocus v2.5.5"})
+	exifData.Add(imagemeta.TagInfo{Source: imagemeta.EXIF, Namespace: "IFD0", Tag: "UserComment", Value: metaJson})
+
+	_, err = extractor.Decode(types.ImageMetadataContext{
+		EmbeddedMetadata: exifData,
+	})
+	assert.ErrorIs(t, err, types.ErrNoMetadata)
+}
+
+func TestDecodeWithoutSoftware(t *testing.T) {
+	extractor := NewRuinedFooocusMetadataExtractor()
+
+	data, err := os.ReadFile("./testdata/meta-nosoftware.json")
+	require.NoError(t, err)
+
+	meta, err := extractor.Extract(types.ImageMetadataContext{
+		EmbeddedMetadata: types.Tags{
+			{Namespace: "PNG/tEXt", Tag: "parameters", Value: string(data)},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "RuinedFooocus", meta.Params.Version())
+	assert.Equal(t, "sd_xl_base_1.0_0.9vae", meta.Params.Model())
+	assert.Equal(t, "1234", meta.Params.Seed())
+
+	// Other metadata without software is not accepted
+	_, err = extractor.Decode(types.ImageMetadataContext{
+		EmbeddedMetadata: types.Tags{
+			{Namespace: "PNG/tEXt", Tag: "parameters", Value: `{"Prompt": "a cat", "steps": 30}`},
+		},
+	})
+	assert.ErrorIs(t, err, types.ErrNoMetadata)
+}
+
+func TestEmbedMetadataIntoPNG_Write(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewRuinedFooocusMetadataWriter().Write(&buf, *meta)
+	require.NoError(t, err)
+
+	_, err = png.Decode(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	ctx, err := image.NewContextFromReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	decoded, err := NewRuinedFooocusMetadataExtractor().Decode(*ctx)
+	require.NoError(t, err)
+	assert.Equal(t, *meta, decoded)
+}
+
+func TestAdapterVersion(t *testing.T) {
+	param := Parameters{Metadata: Metadata{Version: "RuinedFooocus"}}
+	assert.Equal(t, "RuinedFooocus", param.Version())
+	assert.Equal(t, types.SoftwareVersion{Product: "RuinedFooocus"}, param.SoftwareVersion())
+
+	param.Release = "2.1.0"
+	assert.Equal(t, "RuinedFooocus 2.1.0", param.Version())
+	assert.Equal(t, types.SoftwareVersion{Product: "RuinedFooocus", Version: "2.1.0"}, param.SoftwareVersion())
+
+	param.Metadata.Version = "RuinedFooocus 2.1.0"
+	assert.Equal(t, "RuinedFooocus 2.1.0", param.Version())
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/fooocusplus"
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	"github.com/fkleon/fooocus-metadata/ruinedfooocus"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// embedMetadata decodes the JSON metadata of the given type and embeds
+// it into the source image of the given format, or the default template
+// if source is nil.
+func embedMetadata(t string, format string, source io.Reader, target io.Writer, metadata []byte) error {
+	switch t {
+	case "", "fooocus":
+		return write(fooocus.NewFooocusMetadataWriter(), format, source, target, metadata, func(meta fooocus.Metadata) map[uint16]string {
+			return map[uint16]string{
+				image.ExifSoftware:  meta.Version,
+				image.ExifMakerNote: fooocus.Fooocus.String(),
+			}
+		})
+	case "fooocusplus":
+		return write(fooocusplus.NewFooocusPlusMetadataWriter(), format, source, target, metadata, func(meta fooocusplus.Metadata) map[uint16]string {
+			return map[uint16]string{
+				image.ExifSoftware: meta.Version,
+			}
+		})
+	case "ruinedfooocus":
+		return write(ruinedfooocus.NewRuinedFooocusMetadataWriter(), format, source, target, metadata, func(meta ruinedfooocus.Metadata) map[uint16]string {
+			return map[uint16]string{
+				image.ExifSoftware: meta.Version,
+			}
+		})
+	default:
+		return &statusError{http.StatusBadRequest, fmt.Errorf("unknown type: %s", t)}
+	}
+}
+
+// write embeds the metadata with the writer, or for JPEG and WebP images
+// into EXIF with the values returned by exif.
+func write[M any](writer types.Writer[M], format string, source io.Reader, target io.Writer, data []byte, exif func(M) map[uint16]string) error {
+	var metadata M
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return &statusError{http.StatusBadRequest, fmt.Errorf("failed to unmarshal metadata: %w", err)}
+	}
+
+	if source == nil {
+		return writer.Write(target, metadata)
+	}
+
+	var err error
+	switch format {
+	case "image/jpeg", "image/webp":
+		err = embedExif(source, target, metadata, exif(metadata))
+	default:
+		err = writer.CopyWrite(source, target, metadata)
+	}
+	if err != nil {
+		return &statusError{errorStatus(err), err}
+	}
+	return nil
+}
+
+// embedExif embeds the metadata as JSON into the EXIF UserComment, along
+// with the given values, which are skipped if empty.
+func embedExif(source io.Reader, target io.Writer, metadata any, values map[uint16]string) error {
+	parameters, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	for tag, value := range values {
+		if value == "" {
+			delete(values, tag)
+		}
+	}
+	values[image.ExifUserComment] = string(parameters)
+	return image.EmbedExif(source, target, values)
+}
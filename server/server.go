@@ -0,0 +1,385 @@
+// Package server provides a [net/http] handler that exposes metadata
+// extraction and embedding as a local HTTP service.
+//
+// Endpoints:
+//
+//	GET  /health   reports the service status
+//	POST /extract  reads an image and returns its structured metadata as JSON
+//	POST /embed    embeds JSON metadata into an image and returns the image
+//
+// Images are uploaded either as the raw request body, or as the "image"
+// part of a multipart form. For raw uploads, the original file name can be
+// given with the "filename" query parameter. The embed endpoint additionally expects a
+// "metadata" part with the JSON-encoded metadata and a "type" query
+// parameter naming the metadata format (fooocus, fooocusplus, ruinedfooocus).
+// JPEG and WebP images are returned in their format with the metadata in
+// EXIF, like the original software writes them; other images as PNG.
+//
+// Uploads are streamed into temporary files rather than held in memory.
+//
+// Example usage:
+//
+//	handler := server.New(server.WithMaxUploadSize(16 << 20))
+//	http.ListenAndServe("localhost:8080", handler)
+//
+// Readers must be registered by importing the appropriate packages, see the
+// documentation of the top-level package.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"time"
+
+	fooocusmeta "github.com/fkleon/fooocus-metadata"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+const (
+	// Default maximum size of an uploaded request body.
+	DefaultMaxUploadSize = 32 << 20
+	// Maximum size of the metadata part of an embed request.
+	maxMetadataSize = 1 << 20
+)
+
+type Config struct {
+	MaxUploadSize int64
+	PathHints     bool
+}
+type Option func(*Config)
+
+// WithMaxUploadSize limits the size of request bodies in bytes.
+func WithMaxUploadSize(size int64) Option {
+	return func(cfg *Config) {
+		cfg.MaxUploadSize = size
+	}
+}
+
+// WithPathHints enables path hints for the extract endpoint. The "path" query
+// parameter or the file name of the upload is passed on as original file path
+// of the image to enable path-based features such as parsing the creation date
+// from the file name, or sidecar and private log support.
+//
+// Only enable this for trusted clients, as it allows reading files from the
+// server's file system.
+func WithPathHints(enabled bool) Option {
+	return func(cfg *Config) {
+		cfg.PathHints = enabled
+	}
+}
+
+// Server is a [http.Handler] for the metadata service.
+type Server struct {
+	cfg Config
+	mux *http.ServeMux
+}
+
+func New(opts ...Option) *Server {
+	cfg := Config{
+		MaxUploadSize: DefaultMaxUploadSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	s := &Server{
+		cfg: cfg,
+		mux: http.NewServeMux(),
+	}
+	s.mux.HandleFunc("GET /health", s.health)
+	s.mux.HandleFunc("POST /extract", s.extract)
+	s.mux.HandleFunc("POST /embed", s.embed)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Metadata is the response body of the extract endpoint.
+type Metadata struct {
+	Source        string     `json:"source"`
+	Created       *time.Time `json:"created,omitempty"`
+	CreatedSource string     `json:"created_source,omitempty"`
+	// Where the metadata was found, e.g. embedded or in the private log
+	Provenance     types.Provenance `json:"provenance,omitzero"`
+	Version        string           `json:"version"`
+	Model          string           `json:"model"`
+	PositivePrompt string           `json:"prompt"`
+	NegativePrompt string           `json:"negative_prompt"`
+	LoRAs          []types.Lora     `json:"loras"`
+	Seed           string           `json:"seed"`
+	Raw            any              `json:"raw"`
+
+	// Labelling as AI-generated and attribution, e.g. the artist
+	Label *types.Label `json:"label,omitempty"`
+}
+
+func newMetadata(meta types.StructuredMetadata) Metadata {
+	out := Metadata{
+		Source:         meta.Source,
+		Provenance:     meta.Provenance,
+		Version:        meta.Params.Version(),
+		Model:          meta.Params.Model(),
+		PositivePrompt: meta.Params.PositivePrompt(),
+		NegativePrompt: meta.Params.NegativePrompt(),
+		LoRAs:          meta.Params.LoRAs(),
+		Seed:           meta.Params.Seed(),
+		Raw:            meta.Params.Raw(),
+		Label:          meta.Label,
+	}
+	if !meta.Created.IsZero() {
+		out.Created = &meta.Created
+		out.CreatedSource = string(meta.CreatedSource)
+	}
+	return out
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) extract(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxUploadSize)
+
+	image, filename, err := readImage(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer closeTemp(image)
+
+	// Path hint for path-based features, either from the "path" query
+	// parameter or the original file name of the upload
+	var path string
+	if s.cfg.PathHints {
+		if path = r.URL.Query().Get("path"); path == "" {
+			path = filename
+		}
+	}
+
+	meta, err := fooocusmeta.ExtractFromReader(image, fooocusmeta.WithPath(path))
+	if err != nil {
+		writeError(w, &statusError{errorStatus(err), err})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newMetadata(meta))
+}
+
+// errorStatus returns the HTTP status code of a reader or writer error.
+func errorStatus(err error) int {
+	if errors.Is(err, types.ErrUnsupportedMIME) {
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusUnprocessableEntity
+}
+
+func (s *Server) embed(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxUploadSize)
+
+	var source *os.File
+	var metadata []byte
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		writeError(w, &statusError{http.StatusUnsupportedMediaType, fmt.Errorf("expected multipart/form-data")})
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, &statusError{http.StatusBadRequest, err})
+		return
+	}
+	defer func() {
+		if source != nil {
+			closeTemp(source)
+		}
+	}()
+
+	// Stream parts; the image is optional, the metadata required
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeError(w, &statusError{http.StatusBadRequest, err})
+			return
+		}
+
+		switch part.FormName() {
+		case "image":
+			if source != nil {
+				closeTemp(source)
+			}
+			if source, err = spool(part); err != nil {
+				writeError(w, err)
+				return
+			}
+		case "metadata":
+			if metadata, err = readMetadata(part); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		_ = part.Close()
+	}
+
+	if metadata == nil {
+		writeError(w, &statusError{http.StatusBadRequest, fmt.Errorf("metadata is required")})
+		return
+	}
+
+	// JPEG and WebP images are kept in their format, others converted to PNG
+	var format = "image/png"
+	var image io.Reader
+	if source != nil {
+		if format, err = sniff(source); err != nil {
+			writeError(w, err)
+			return
+		}
+		if format != "image/jpeg" && format != "image/webp" {
+			format = "image/png"
+		}
+		image = source
+	}
+
+	var out bytes.Buffer
+	if err := embedMetadata(r.URL.Query().Get("type"), format, image, &out, metadata); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format)
+	w.WriteHeader(http.StatusOK)
+	_, _ = out.WriteTo(w)
+}
+
+// readMetadata reads the metadata part of an embed request, which
+// must not exceed maxMetadataSize.
+func readMetadata(part io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(part, maxMetadataSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxMetadataSize {
+		return nil, &statusError{http.StatusRequestEntityTooLarge, fmt.Errorf("metadata exceeds %d bytes", maxMetadataSize)}
+	}
+	return data, nil
+}
+
+// readImage reads the image from either the raw request body,
+// or the "image" part of a multipart form, into a temporary file.
+// Returns the image and its original file name, if known. The
+// caller must close the image with closeTemp.
+func readImage(r *http.Request) (*os.File, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType != "multipart/form-data" {
+		image, err := spool(r.Body)
+		return image, r.URL.Query().Get("filename"), err
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", &statusError{http.StatusBadRequest, err}
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", &statusError{http.StatusBadRequest, fmt.Errorf("image is required")}
+		}
+		if err != nil {
+			return nil, "", &statusError{http.StatusBadRequest, err}
+		}
+		if part.FormName() == "image" {
+			image, err := spool(part)
+			return image, part.FileName(), err
+		}
+		_ = part.Close()
+	}
+}
+
+// spool copies an upload into a temporary file, rather than memory,
+// and rewinds it for reading.
+func spool(in io.Reader) (*os.File, error) {
+	f, err := os.CreateTemp("", "fooocus-metadata-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(f, in); err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		closeTemp(f)
+		return nil, err
+	}
+	return f, nil
+}
+
+// closeTemp closes and removes a temporary file created by spool.
+func closeTemp(f *os.File) {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
+
+// sniff returns the MIME type of the file and rewinds it.
+func sniff(f *os.File) (string, error) {
+	buffer := make([]byte, 512)
+	n, err := io.ReadFull(f, buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buffer[:n]), nil
+}
+
+// statusError is an error with an associated HTTP status code.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var status = http.StatusInternalServerError
+
+	var statusErr *statusError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		status = http.StatusRequestEntityTooLarge
+	case errors.As(err, &statusErr):
+		status = statusErr.status
+	}
+
+	slog.Debug("Request failed", "status", status, "error", err)
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Warn("Failed to write response", "error", err)
+	}
+}
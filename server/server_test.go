@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	pngembed "github.com/sabhiram/png-embed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Configure logging during testing
+func TestMain(m *testing.M) {
+	slog.SetLogLoggerLevel(slog.LevelWarn)
+	exitVal := m.Run()
+	os.Exit(exitVal)
+}
+
+func TestHealth(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/health")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var body map[string]string
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.Equal(t, "ok", body["status"])
+}
+
+func TestExtract_RawBody(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	image, err := os.Open("../fooocus/testdata/fooocus-meta.png")
+	require.NoError(t, err)
+	defer image.Close()
+
+	res, err := http.Post(srv.URL+"/extract", "image/png", image)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var meta Metadata
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&meta))
+	assert.Equal(t, "Fooocus", meta.Source)
+	assert.Equal(t, "Fooocus v2.5.5", meta.Version)
+	assert.Equal(t, "juggernautXL_v8Rundiffusion", meta.Model)
+	assert.Equal(t, "A sunflower field", meta.PositivePrompt)
+	assert.NotNil(t, meta.Raw)
+	assert.Nil(t, meta.Created)
+	assert.Nil(t, meta.Label)
+}
+
+func TestExtract_Multipart(t *testing.T) {
+	srv := httptest.NewServer(New(WithPathHints(true)))
+	defer srv.Close()
+
+	data, err := os.ReadFile("../fooocusplus/testdata/fooocusplus-meta.jpg")
+	require.NoError(t, err)
+
+	body, contentType := multipartBody(t, map[string][]byte{
+		"image": data,
+	}, "2025-04-23_11-27-25_6011.jpg")
+
+	res, err := http.Post(srv.URL+"/extract", contentType, body)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var meta Metadata
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&meta))
+	assert.Equal(t, "FooocusPlus", meta.Source)
+	assert.Equal(t, "elsewhereXL_v10", meta.Model)
+	// Creation date parsed from the file name hint
+	require.NotNil(t, meta.Created)
+	assert.Equal(t, 2025, meta.Created.Year())
+	assert.Equal(t, "filename", meta.CreatedSource)
+}
+
+func TestExtract_NoMetadata(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	image, err := os.Open("../internal/image/testdata/sample.png")
+	require.NoError(t, err)
+	defer image.Close()
+
+	res, err := http.Post(srv.URL+"/extract", "image/png", image)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	var body map[string]string
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	assert.NotEmpty(t, body["error"])
+}
+
+func TestExtract_UnsupportedMIME(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/extract", "text/plain", bytes.NewReader([]byte("not an image")))
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+}
+
+func TestExtract_TooLarge(t *testing.T) {
+	srv := httptest.NewServer(New(WithMaxUploadSize(1024)))
+	defer srv.Close()
+
+	image, err := os.Open("../fooocus/testdata/fooocus-meta.png")
+	require.NoError(t, err)
+	defer image.Close()
+
+	res, err := http.Post(srv.URL+"/extract", "image/png", image)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+}
+
+func TestExtract_MethodNotAllowed(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/extract")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
+
+func TestEmbed(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	metadata, err := json.Marshal(fooocus.Metadata{
+		BaseModel: "juggernautXL_v8Rundiffusion",
+		Prompt:    "A sunflower field",
+		Seed:      "1234",
+		Version:   "Fooocus v2.5.5",
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		source      string
+		contentType string
+	}{
+		{"../internal/image/testdata/sample.png", "image/png"},
+		{"../internal/image/testdata/sample.jpg", "image/jpeg"},
+		{"../internal/image/testdata/sample.webp", "image/webp"},
+		{"", "image/png"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.contentType, func(t *testing.T) {
+			parts := map[string][]byte{"metadata": metadata}
+			if tc.source != "" {
+				source, err := os.ReadFile(tc.source)
+				require.NoError(t, err)
+				parts["image"] = source
+			}
+			body, contentType := multipartBody(t, parts, filepath.Base(tc.source))
+
+			res, err := http.Post(srv.URL+"/embed?type=fooocus", contentType, body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, tc.contentType, res.Header.Get("Content-Type"))
+
+			out, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tc.contentType, http.DetectContentType(out))
+
+			if tc.contentType == "image/png" {
+				chunks, err := pngembed.Extract(out)
+				require.NoError(t, err)
+				assert.Equal(t, []byte("fooocus"), chunks["fooocus_scheme"])
+			}
+
+			// Round-trip through the extract endpoint
+			res, err = http.Post(srv.URL+"/extract", tc.contentType, bytes.NewReader(out))
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			var meta Metadata
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&meta))
+			assert.Equal(t, "Fooocus", meta.Source)
+			assert.Equal(t, "Fooocus v2.5.5", meta.Version)
+			assert.Equal(t, "1234", meta.Seed)
+		})
+	}
+}
+
+func TestEmbed_Errors(t *testing.T) {
+	srv := httptest.NewServer(New())
+	defer srv.Close()
+
+	testCases := []struct {
+		name   string
+		query  string
+		parts  map[string][]byte
+		status int
+	}{
+		{"missing metadata", "?type=fooocus", map[string][]byte{}, http.StatusBadRequest},
+		{"invalid metadata", "?type=fooocus", map[string][]byte{"metadata": []byte("{")}, http.StatusBadRequest},
+		{"unknown type", "?type=unknown", map[string][]byte{"metadata": []byte("{}")}, http.StatusBadRequest},
+		{"metadata too large", "?type=fooocus", map[string][]byte{"metadata": bytes.Repeat([]byte(" "), maxMetadataSize+1)}, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, contentType := multipartBody(t, tc.parts, "")
+			res, err := http.Post(srv.URL+"/embed"+tc.query, contentType, body)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tc.status, res.StatusCode)
+		})
+	}
+
+	// Not multipart
+	res, err := http.Post(srv.URL+"/embed", "application/json", bytes.NewReader([]byte("{}")))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, res.StatusCode)
+}
+
+// Build a multipart body with the given parts
+func multipartBody(t *testing.T, parts map[string][]byte, filename string) (io.Reader, string) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	for name, data := range parts {
+		var part io.Writer
+		var err error
+		if name == "image" {
+			part, err = writer.CreateFormFile(name, filename)
+		} else {
+			part, err = writer.CreateFormField(name)
+		}
+		require.NoError(t, err)
+		_, err = part.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	return &buf, writer.FormDataContentType()
+}
@@ -18,6 +18,15 @@ func (m Parameters) Version() string {
 	return m.Metadata.Version
 }
 
+// SoftwareVersion returns the parsed version, see Version. Versions
+// without a product, e.g. "v1.10.1", are attributed to A1111.
+func (m Parameters) SoftwareVersion() types.SoftwareVersion {
+	if m.Version() == "" {
+		return types.SoftwareVersion{}
+	}
+	return types.ParseSoftwareVersion(m.Version()).WithProduct(a1111Product)
+}
+
 func (m Parameters) Model() string {
 	var model = m.Metadata.Model
 	if model == "" {
@@ -49,6 +58,69 @@ func (m Parameters) Seed() string {
 	return strconv.Itoa(m.Metadata.Seed)
 }
 
+// SeedValue returns the parsed seed, see Seeds.
+func (m Parameters) SeedValue() types.Seed {
+	seed, _ := Seeds.Parse(m.Seed())
+	return seed
+}
+
+// VariationSeed returns the parsed variation seed ("subseed"),
+// zero if the seed was not varied.
+func (m Parameters) VariationSeed() types.Seed {
+	if m.VariationSeedStrength == 0 {
+		return types.Seed{}
+	}
+	seed, _ := Seeds.Parse(strconv.Itoa(m.Metadata.VariationSeed))
+	return seed
+}
+
+// BatchSeeds returns the seeds and variation seeds of the images of
+// the batch. A1111 records the seed of each image and its position in
+// the batch, and increments the variation seed instead of the seed if
+// the variation strength is set.
+func (m Parameters) BatchSeeds() (seeds []types.Seed, variationSeeds []types.Seed) {
+	n, pos := max(m.BatchSize, 1), int64(max(m.BatchPos, 0))
+
+	seed, variationSeed := m.SeedValue(), m.VariationSeed()
+	if variationSeed.IsZero() {
+		return seed.First(pos).Batch(n), nil
+	}
+
+	seeds = make([]types.Seed, n)
+	for i := range seeds {
+		seeds[i] = seed
+	}
+	return seeds, variationSeed.First(pos).Batch(n)
+}
+
+// Sampler returns the sampler split from the A1111 sampler name,
+// see Metadata.SplitSampler.
+func (m Parameters) Sampler() string {
+	sampler, _, _ := m.SplitSampler()
+	return sampler
+}
+
+// Scheduler returns the scheduler split from the A1111 sampler name
+// or the schedule type, see Metadata.SplitSampler.
+func (m Parameters) Scheduler() string {
+	_, scheduler, _ := m.SplitSampler()
+	return scheduler
+}
+
+// Size returns the size of the final image, which is the Size
+// scaled by HiresUpscale if the hires fix was used.
+func (m Parameters) Size() (width int, height int) {
+	if m.Metadata.Size == nil {
+		return 0, 0
+	}
+	width, height = m.Metadata.Size.Width, m.Metadata.Size.Height
+	if m.HiresUpscale > 0 {
+		width = int(float32(width) * m.HiresUpscale)
+		height = int(float32(height) * m.HiresUpscale)
+	}
+	return width, height
+}
+
 func (m Parameters) CreatedTime() time.Time {
 	return m.Created
 }
@@ -0,0 +1,55 @@
+package stablediffusion
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	m "github.com/fkleon/fooocus-metadata/types"
+	"github.com/stretchr/testify/require"
+)
+
+// A1111 metadata written by Fooocus
+var fuzzImages = "../fooocus/testdata/a1111-meta.*"
+
+func FuzzParseParameters(f *testing.F) {
+	f.Add("Astronaut in a jungle, cold color palette, muted colors, detailed, 8k\nSteps: 50, Sampler: DPM++ 2M Karras, CFG scale: 5, Seed: 42, Size: 1024x1024, Model hash: 1f69731261, Model: sd_xl_base_0.9, Clip skip: 2, RNG: CPU, Version: v1.4.1-166-g21aec6f5")
+	f.Add("a cat <lora:cat:0.8>\nNegative prompt: dog\nSteps: 20, Sampler: Euler a, Seed: 1, Size: 512x768")
+
+	files, err := filepath.Glob(fuzzImages)
+	require.NoError(f, err)
+	for _, file := range files {
+		ctx, err := image.NewContextFromFile(file)
+		require.NoError(f, err)
+		for _, key := range [][2]string{{m.NamespacePNG, "parameters"}, {m.NamespaceEXIF, "UserComment"}} {
+			if _, parameters, ok := ctx.StringTag(key[0], key[1]); ok {
+				f.Add(parameters)
+			}
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, parameters string) {
+		_, _ = ParseParameters(parameters)
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	files, err := filepath.Glob(fuzzImages)
+	require.NoError(f, err)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		require.NoError(f, err)
+		f.Add(data)
+	}
+
+	extractor := NewStableDiffusionMetadataExtractor()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ctx, err := image.NewContextFromReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		_, _ = extractor.Decode(*ctx)
+	})
+}
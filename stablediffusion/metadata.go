@@ -10,35 +10,55 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/fkleon/fooocus-metadata/types"
 )
 
 type Metadata struct {
-	BatchSize            int     `json:"batch_size,string,omitempty"`
-	BatchPos             int     `json:"batch_pos,string,omitempty"`
-	CfgScale             float32 `json:"cfg_scale,string"`
-	ClipSkip             int     `json:"clip_skip,string,omitempty"`
-	DenoisingStrength    float32 `json:"denoising_strength,string,omitempty"`
-	Eta                  float32 `json:"eta,string,omitempty"`
-	HiresSteps           int     `json:"hires_steps,string,omitempty"`
-	HiresUpscale         float32 `json:"hires_upscale,string,omitempty"`
-	HiresUpscaler        string  `json:"hires_upscaler,omitempty"`
-	Guidance             float32 `json:"guidance,string,omitempty"`
-	ImageNoiseMultiplier float32 `json:"image_noise_multiplier,string,omitempty"`
-	Loras                Loras   `json:"loras,omitempty"`
-	Model                string  `json:"model,omitempty"`
-	ModelHash            string  `json:"model_hash,omitempty"`
-	NegativePrompt       string  `json:"negative_prompt,omitempty"`
-	Prompt               string  `json:"prompt"`
-	Rng                  string  `json:"rng,omitempty"`
-	Sampler              string  `json:"sampler"` // The Sampler field contains both the sampler and scheduler names
-	Seed                 int     `json:"seed,string"`
-	Size                 *Size   `json:"size,omitempty"`
-	Steps                int     `json:"steps,string"`
-	TextEncoder          string  `json:"TE,omitempty"`
-	Unet                 string  `json:"unet,omitempty"`
-	Vae                  string  `json:"vae,omitempty"`
-	VaeHash              string  `json:"vae_hash,omitempty"`
-	Version              string  `json:"version,omitempty"`
+	BatchSize             int     `json:"batch_size,string,omitempty"`
+	BatchPos              int     `json:"batch_pos,string,omitempty"`
+	CfgScale              float32 `json:"cfg_scale,string"`
+	ClipSkip              int     `json:"clip_skip,string,omitempty"`
+	DenoisingStrength     float32 `json:"denoising_strength,string,omitempty"`
+	Eta                   float32 `json:"eta,string,omitempty"`
+	HiresSteps            int     `json:"hires_steps,string,omitempty"`
+	HiresUpscale          float32 `json:"hires_upscale,string,omitempty"`
+	HiresUpscaler         string  `json:"hires_upscaler,omitempty"`
+	Guidance              float32 `json:"guidance,string,omitempty"`
+	ImageNoiseMultiplier  float32 `json:"image_noise_multiplier,string,omitempty"`
+	Loras                 Loras   `json:"loras,omitempty"`
+	Model                 string  `json:"model,omitempty"`
+	ModelHash             string  `json:"model_hash,omitempty"`
+	NegativePrompt        string  `json:"negative_prompt,omitempty"`
+	Prompt                string  `json:"prompt"`
+	Rng                   string  `json:"rng,omitempty"`
+	Sampler               string  `json:"sampler"`                 // The Sampler field contains both the sampler and scheduler names
+	ScheduleType          string  `json:"schedule_type,omitempty"` // Newer versions record the scheduler separately
+	Seed                  int     `json:"seed,string"`
+	Size                  *Size   `json:"size,omitempty"`
+	Steps                 int     `json:"steps,string"`
+	TextEncoder           string  `json:"TE,omitempty"`
+	Unet                  string  `json:"unet,omitempty"`
+	VariationSeed         int     `json:"variation_seed,string,omitempty"`
+	VariationSeedStrength float32 `json:"variation_seed_strength,string,omitempty"`
+	Vae                   string  `json:"vae,omitempty"`
+	VaeHash               string  `json:"vae_hash,omitempty"`
+	Version               string  `json:"version,omitempty"`
+}
+
+// SplitSampler returns the sampler and scheduler identifiers, e.g.
+// "dpmpp_2m_sde_gpu" and "karras", from either the combined sampler name
+// "DPM++ 2M SDE Karras" or the sampler name and separate schedule type.
+//
+// The boolean result is false if either name could not be mapped.
+func (m Metadata) SplitSampler() (sampler string, scheduler string, ok bool) {
+	sampler, scheduler, ok = types.SplitA1111Sampler(m.Sampler)
+	if m.ScheduleType != "" {
+		var known bool
+		scheduler, known = types.NormaliseScheduler(m.ScheduleType)
+		ok = ok && known
+	}
+	return sampler, scheduler, ok
 }
 
 type Loras []Lora
@@ -110,6 +130,9 @@ func (s *Size) UnmarshalJSON(p []byte) (err error) {
 	}
 
 	size := strings.SplitN(tmp, "x", 2)
+	if len(size) < 2 {
+		return fmt.Errorf("invalid size, expected \"<width>x<height>\": %q", tmp)
+	}
 
 	if s.Width, err = strconv.Atoi(size[0]); err != nil {
 		return err
@@ -129,7 +152,7 @@ func (s Size) MarshalJSON() ([]byte, error) {
 func ParseParameters(in string) (meta Metadata, err error) {
 
 	if json.Valid([]byte(in)) {
-		return meta, fmt.Errorf("input is JSON, not plaintext")
+		return meta, fmt.Errorf("%s: %w: input is JSON, not plaintext", Software, types.ErrUnsupportedScheme)
 	}
 
 	// Parse a1111 parameters string; here be dragons
@@ -191,6 +214,9 @@ func ParseParameters(in string) (meta Metadata, err error) {
 	}
 
 	kvByte, _ := json.Marshal(kv)
-	err = json.Unmarshal(kvByte, &meta)
-	return meta, err
+	if err = json.Unmarshal(kvByte, &meta); err != nil {
+		// Offsets in the intermediate JSON are meaningless to the caller
+		return meta, &types.ParseError{Software: Software, Field: "parameters", Offset: -1, Err: err}
+	}
+	return meta, nil
 }
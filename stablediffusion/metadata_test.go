@@ -1,6 +1,7 @@
 package stablediffusion
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -288,3 +289,41 @@ func TestDecodeMetadata_Fooocus_JSON_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeMalformedSize(t *testing.T) {
+	for _, in := range []string{`""`, `"512"`, `"512x"`, `"x512"`} {
+		var size Size
+		assert.Error(t, json.Unmarshal([]byte(in), &size), in)
+	}
+}
+
+func TestSplitSampler(t *testing.T) {
+	testCases := []struct {
+		in        string
+		sampler   string
+		scheduler string
+		ok        bool
+	}{
+		{"Steps: 20, Sampler: DPM++ 2M Karras, CFG scale: 7", "dpmpp_2m", "karras", true},
+		{"Steps: 20, Sampler: DPM++ 2M, Schedule type: Karras, CFG scale: 7", "dpmpp_2m", "karras", true},
+		{"Steps: 20, Sampler: Euler a, Schedule type: Uniform, CFG scale: 7", "euler_ancestral", "uniform", true},
+		{"Steps: 20, Sampler: Euler, Schedule type: Automatic, CFG scale: 7", "euler", "normal", true},
+		{"Steps: 20, Sampler: Euler a, CFG scale: 7", "euler_ancestral", "normal", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			meta, err := ParseParameters("A cat\n" + tc.in)
+			require.NoError(t, err)
+
+			sampler, scheduler, ok := meta.SplitSampler()
+			assert.Equal(t, tc.sampler, sampler)
+			assert.Equal(t, tc.scheduler, scheduler)
+			assert.Equal(t, tc.ok, ok)
+
+			params := Parameters{Metadata: meta}
+			assert.Equal(t, tc.sampler, params.Sampler())
+			assert.Equal(t, tc.scheduler, params.Scheduler())
+		})
+	}
+}
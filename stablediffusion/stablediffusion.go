@@ -3,15 +3,28 @@ package stablediffusion
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bep/imagemeta"
 
 	m "github.com/fkleon/fooocus-metadata/types"
 )
 
 const (
 	Software = "StableDiffusion"
+	// Scheme is the name of the AUTOMATIC1111 plaintext scheme.
+	Scheme = "a1111"
+
+	// Product of versions that do not record one.
+	a1111Product = "A1111"
 )
 
+// Seeds of A1111, where "-1" selects a random seed.
+var Seeds = m.SeedRange{Max: math.MaxInt64, Random: "-1"}
+
 // StableDiffusionMetadataExtractor can decode embedded A1111 metadata from
 // an image file.
 type StableDiffusionMetadataExtractor struct {
@@ -19,22 +32,43 @@ type StableDiffusionMetadataExtractor struct {
 }
 
 func (e StableDiffusionMetadataExtractor) Decode(file m.ImageMetadataContext) (meta Metadata, err error) {
+	meta, _, err = e.decode(file)
+	return
+}
 
-	var data = file.EmbeddedMetadata
-	var parameters string
-
-	// Parameters from EXIF "UserComment" or PNG "parameters"
-	if paramTag, ok := data["UserComment"]; ok {
-		parameters = paramTag.Value.(string)
-	} else {
-		if paramTag, ok := data["parameters"]; ok {
-			parameters = paramTag.Value.(string)
-		} else {
-			return meta, fmt.Errorf("%s: Parameters not found", Software)
-		}
+// decode reads the embedded metadata and returns its provenance.
+func (e StableDiffusionMetadataExtractor) decode(file m.ImageMetadataContext) (meta Metadata, provenance m.Provenance, err error) {
+
+	// Parameters from EXIF "UserComment", PNG "parameters" or a GIF comment
+	paramTag, parameters, ok := file.StringTag(m.NamespaceEXIF, "UserComment")
+	if !ok {
+		paramTag, parameters, ok = file.StringTag(m.NamespacePNG, "parameters")
+	}
+	if !ok {
+		paramTag, parameters, ok = gifComment(file.EmbeddedMetadata)
+	}
+	if !ok {
+		return meta, provenance, fmt.Errorf("%s: Parameters not found: %w", Software, m.ErrNoMetadata)
 	}
 
-	return ParseParameters(parameters)
+	if meta, err = ParseParameters(parameters); err != nil {
+		return
+	}
+
+	provenance = m.EmbeddedProvenance(paramTag)
+	provenance.Scheme = Scheme
+	return
+}
+
+// gifComment returns the first GIF comment with generation parameters,
+// e.g. written by AnimateDiff. Other comments are skipped.
+func gifComment(tags m.Tags) (tag imagemeta.TagInfo, parameters string, ok bool) {
+	for _, tag := range tags.Lookup(m.NamespaceGIFComment, m.GifCommentTag) {
+		if parameters, ok := tag.Value.(string); ok && strings.Contains(parameters, "Steps: ") {
+			return tag, parameters, true
+		}
+	}
+	return tag, "", false
 }
 
 func (e StableDiffusionMetadataExtractor) Extract(file m.ImageMetadataContext) (m.StructuredMetadata, error) {
@@ -43,25 +77,38 @@ func (e StableDiffusionMetadataExtractor) Extract(file m.ImageMetadataContext) (
 		Source: Software,
 	}
 
-	// Try to parse creation time from filename
 	filename := filepath.Base(file.Filepath)
-	meta.Created, _ = e.ParseDateFromFilename(filename)
+	meta.Filename = e.ParseFilename(file)
 
 	slog.Debug("Checking embedded metadata..", "file", filename)
-	if params, err := e.Decode(file); err == nil {
+	params, provenance, err := e.decode(file)
+	if err == nil {
+		meta.Created, meta.CreatedSource = e.ResolveCreated(file, time.Time{})
+		meta.Provenance = provenance
 		meta.Params = &Parameters{
 			Metadata: params,
+			Created:  meta.Created,
 		}
 		return meta, nil
 	}
 
-	return meta, fmt.Errorf("%s: No metadata found", Software)
+	return meta, err
+}
+
+// DefaultFilenamePatterns match date-based file names and the file
+// names of AUTOMATIC1111, e.g. "00012-1234567.png" or
+// "00012-1234567-a sunflower field.png" with a counter and the seed.
+var DefaultFilenamePatterns = []*m.FilenamePattern{
+	m.MustFilenameTemplate("{date}_{time}{*}"),
+	m.MustFilenameTemplate("{counter}-{seed}"),
+	m.MustFilenameTemplate("{counter}-{seed}-{*}"),
 }
 
 func NewStableDiffusionMetadataExtractor() m.Reader[Metadata] {
 	return StableDiffusionMetadataExtractor{
 		FileMetadataExtractor: &m.FileMetadataExtractor{
-			DateLayout: "2006-01-02_15-04-05",
+			Software:         Software,
+			FilenamePatterns: DefaultFilenamePatterns,
 		},
 	}
 }
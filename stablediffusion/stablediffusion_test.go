@@ -0,0 +1,163 @@
+package stablediffusion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	m "github.com/fkleon/fooocus-metadata/types"
+)
+
+func TestExtract_FilenamePattern(t *testing.T) {
+	extractor := NewStableDiffusionMetadataExtractor()
+
+	testCases := []struct {
+		filename string
+		counter  int
+		seed     string
+	}{
+		{"/outputs/txt2img-images/2025-01-20/00012-1234567.png", 12, "1234567"},
+		{"/outputs/txt2img-images/2025-01-20/00013-42-astronaut in a jungle.png", 13, "42"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.filename, func(t *testing.T) {
+			meta, err := extractor.Extract(m.ImageMetadataContext{
+				Filepath: tc.filename,
+				MIME:     "image/png",
+				EmbeddedMetadata: m.Tags{
+					{Namespace: "PNG/tEXt", Tag: "parameters", Value: "Astronaut in a jungle\nSteps: 20, Sampler: Euler a, CFG scale: 7, Seed: 42"},
+				},
+			})
+			require.NoError(t, err)
+
+			require.NotNil(t, meta.Filename.Counter)
+			assert.Equal(t, tc.counter, *meta.Filename.Counter)
+			assert.Equal(t, tc.seed, meta.Filename.Seed)
+			assert.Zero(t, meta.Filename.Date)
+		})
+	}
+}
+
+func TestExtract_FilenamePattern_Date(t *testing.T) {
+	extractor := NewStableDiffusionMetadataExtractor()
+
+	meta, err := extractor.Extract(m.ImageMetadataContext{
+		Filepath: "/outputs/2025-01-20_14-22-03_1234.png",
+		MIME:     "image/png",
+		EmbeddedMetadata: m.Tags{
+			{Namespace: "PNG/tEXt", Tag: "parameters", Value: "Astronaut in a jungle\nSteps: 20, Sampler: Euler a, CFG scale: 7, Seed: 42"},
+		},
+		Time: m.TimeOptions{Location: time.UTC},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2025, 1, 20, 14, 22, 3, 0, time.UTC), meta.Created)
+	assert.Equal(t, m.TimeFromFilename, meta.CreatedSource)
+	assert.Nil(t, meta.Filename.Counter)
+}
+
+func TestAdapterSoftwareVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected m.SoftwareVersion
+	}{
+		{"", m.SoftwareVersion{}},
+		{"v1.10.1", m.SoftwareVersion{Product: "A1111", Version: "1.10.1"}},
+		{"f2.0.1v1.10.1-previous-313-g8a042934", m.SoftwareVersion{Product: "A1111", Version: "1.10.1", Fork: "f2.0.1", Build: "previous-313-g8a042934"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			param := Parameters{
+				Metadata: Metadata{Version: tc.version},
+			}
+			assert.Equal(t, tc.expected, param.SoftwareVersion())
+		})
+	}
+}
+
+func TestAdapterBatchSeeds(t *testing.T) {
+	seeds := func(values ...uint64) (s []m.Seed) {
+		for _, v := range values {
+			s = append(s, m.Seed{Value: v, Range: Seeds})
+		}
+		return s
+	}
+
+	testCases := []struct {
+		name           string
+		in             string
+		seeds          []m.Seed
+		variationSeeds []m.Seed
+	}{
+		{"single", "Seed: 42", seeds(42), nil},
+		{"batch", "Seed: 43, Batch size: 3, Batch pos: 1", seeds(42, 43, 44), nil},
+		{"random", "Seed: -1, Batch size: 2", []m.Seed{{Random: true, Range: Seeds}, {Random: true, Range: Seeds}}, nil},
+		{"variation", "Seed: 42, Variation seed: 101, Variation seed strength: 0.3, Batch size: 3, Batch pos: 2", seeds(42, 42, 42), seeds(99, 100, 101)},
+		{"variation without strength", "Seed: 42, Variation seed: 101", seeds(42), nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			meta, err := ParseParameters("A cat\nSteps: 20, Sampler: Euler a, " + tc.in)
+			require.NoError(t, err)
+
+			param := Parameters{Metadata: meta}
+			seeds, variationSeeds := param.BatchSeeds()
+			assert.Equal(t, tc.seeds, seeds)
+			assert.Equal(t, tc.variationSeeds, variationSeeds)
+		})
+	}
+}
+
+func TestAdapterSize(t *testing.T) {
+	testCases := []struct {
+		name          string
+		in            string
+		width, height int
+	}{
+		{"size", "Size: 832x1216", 832, 1216},
+		{"hires fix", "Size: 512x768, Hires upscale: 2, Hires upscaler: Latent", 1024, 1536},
+		{"hires fix fraction", "Size: 512x768, Hires upscale: 1.5", 768, 1152},
+		{"unknown", "Seed: 42", 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			meta, err := ParseParameters("A cat\nSteps: 20, Sampler: Euler a, " + tc.in)
+			require.NoError(t, err)
+
+			width, height := Parameters{Metadata: meta}.Size()
+			assert.Equal(t, tc.width, width)
+			assert.Equal(t, tc.height, height)
+		})
+	}
+}
+
+func TestDecode_GifComment(t *testing.T) {
+	extractor := NewStableDiffusionMetadataExtractor()
+
+	// Comments without parameters are skipped
+	meta, err := extractor.Decode(m.ImageMetadataContext{
+		MIME: "image/gif",
+		EmbeddedMetadata: m.Tags{
+			{Namespace: m.NamespaceGIFComment, Tag: m.GifCommentTag, Value: "Created with GIMP"},
+			{Namespace: m.NamespaceGIFComment, Tag: m.GifCommentTag, Value: "Astronaut in a jungle\nSteps: 20, Sampler: Euler a, CFG scale: 7, Seed: 42"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Astronaut in a jungle", meta.Prompt)
+	assert.Equal(t, 20, meta.Steps)
+	assert.Equal(t, 42, meta.Seed)
+
+	_, err = extractor.Decode(m.ImageMetadataContext{
+		MIME: "image/gif",
+		EmbeddedMetadata: m.Tags{
+			{Namespace: m.NamespaceGIFComment, Tag: m.GifCommentTag, Value: "Created with GIMP"},
+		},
+	})
+	assert.ErrorIs(t, err, m.ErrNoMetadata)
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Largest number of files a FileCache keeps.
+const maxFileCacheEntries = 64
+
+// FileCache caches values parsed from files, e.g. the private log of
+// an output folder, which is read once per image. A file is parsed
+// again if its size or modification time changed. Errors are not
+// cached. The zero value is ready to use.
+type FileCache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]fileCacheEntry[V]
+}
+
+type fileCacheEntry[V any] struct {
+	size    int64
+	modTime time.Time
+	value   V
+}
+
+// Get returns the cached value of the file at path, or the result of
+// parse if the file changed. The key distinguishes values parsed from
+// the same file with different settings, e.g. the time zone.
+func (c *FileCache[V]) Get(path string, key string, parse func() (V, error)) (value V, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key = path + "\x00" + key
+	if entry, ok := c.entries[key]; ok && entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+		return entry.value, nil
+	}
+
+	if value, err = parse(); err != nil {
+		delete(c.entries, key)
+		return value, err
+	}
+
+	if c.entries == nil || len(c.entries) >= maxFileCacheEntries {
+		c.entries = make(map[string]fileCacheEntry[V])
+	}
+	c.entries[key] = fileCacheEntry[V]{info.Size(), info.ModTime(), value}
+	return value, nil
+}
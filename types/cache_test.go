@@ -0,0 +1,68 @@
+package types
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.html")
+	require.NoError(t, os.WriteFile(path, []byte("one"), 0644))
+
+	var cache FileCache[string]
+	calls := 0
+	parse := func() (string, error) {
+		calls++
+		data, err := os.ReadFile(path)
+		return string(data), err
+	}
+
+	for range 3 {
+		value, err := cache.Get(path, "UTC", parse)
+		require.NoError(t, err)
+		assert.Equal(t, "one", value)
+	}
+	assert.Equal(t, 1, calls)
+
+	// Other key
+	_, err := cache.Get(path, "Local", parse)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Changed file
+	require.NoError(t, os.WriteFile(path, []byte("two"), 0644))
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+	value, err := cache.Get(path, "UTC", parse)
+	require.NoError(t, err)
+	assert.Equal(t, "two", value)
+	assert.Equal(t, 3, calls)
+}
+
+func TestFileCache_Errors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.html")
+	var cache FileCache[string]
+
+	// Missing file
+	_, err := cache.Get(path, "", func() (string, error) { return "", nil })
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Errors are not cached
+	require.NoError(t, os.WriteFile(path, []byte("one"), 0644))
+	calls := 0
+	parse := func() (string, error) {
+		calls++
+		return "", errors.New("invalid")
+	}
+	for range 2 {
+		_, err = cache.Get(path, "", parse)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 2, calls)
+}
@@ -1,6 +1,10 @@
 package types
 
-import "github.com/bep/imagemeta"
+import (
+	"time"
+
+	"github.com/bep/imagemeta"
+)
 
 type ImageMetadataContext struct {
 
@@ -16,6 +20,35 @@ type ImageMetadataContext struct {
 	MIME string
 
 	// All embedded metadata extracted from the image, usually
-	// from EXIF blocks or PNG tEXt chunks.
-	EmbeddedMetadata map[string]imagemeta.TagInfo
+	// from EXIF blocks or PNG text chunks, by namespace and name.
+	EmbeddedMetadata Tags
+
+	// Properties of the image data, e.g. its size, if known.
+	Properties ImageProperties
+
+	// Modification time of the image file, if known.
+	ModTime time.Time
+
+	// Options for resolving the creation time of the image.
+	Time TimeOptions
+
+	// User-defined file name patterns by software name, which replace
+	// the default patterns of the reader. Patterns for the empty
+	// software name apply to all readers without specific patterns.
+	FilenamePatterns map[string][]*FilenamePattern
+
+	// How readers use the private log of the output folder,
+	// defaults to PrivateLogFallback.
+	PrivateLog PrivateLogMode
+}
+
+// StringTag returns the first embedded tag with the given name in the
+// given namespace that has a string value, or false if there is none.
+func (ctx ImageMetadataContext) StringTag(namespace string, name string) (tag imagemeta.TagInfo, value string, ok bool) {
+	for _, tag = range ctx.EmbeddedMetadata.Lookup(namespace, name) {
+		if value, ok = tag.Value.(string); ok {
+			return
+		}
+	}
+	return imagemeta.TagInfo{}, "", false
 }
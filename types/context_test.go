@@ -0,0 +1,42 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringTag(t *testing.T) {
+	ctx := ImageMetadataContext{
+		EmbeddedMetadata: Tags{
+			{Namespace: NamespacePNGText, Tag: "parameters", Value: "{}"},
+			{Namespace: NamespaceEXIF, Tag: "Software", Value: []byte("Fooocus v2.5.5")},
+			{Namespace: NamespaceEXIF, Tag: "Comment", Value: []byte("binary")},
+			{Namespace: NamespaceEXIF, Tag: "Comment", Value: "text"},
+		},
+	}
+
+	tag, value, ok := ctx.StringTag(NamespacePNG, "parameters")
+	assert.True(t, ok)
+	assert.Equal(t, "parameters", tag.Tag)
+	assert.Equal(t, "{}", value)
+
+	// Not a string
+	_, _, ok = ctx.StringTag(NamespaceEXIF, "Software")
+	assert.False(t, ok)
+
+	// First string value
+	_, value, ok = ctx.StringTag(NamespaceEXIF, "Comment")
+	assert.True(t, ok)
+	assert.Equal(t, "text", value)
+
+	// Other namespace
+	_, _, ok = ctx.StringTag(NamespaceEXIF, "parameters")
+	assert.False(t, ok)
+	_, _, ok = ctx.StringTag(NamespacePNG, "Comment")
+	assert.False(t, ok)
+
+	// Missing
+	_, _, ok = ctx.StringTag(NamespaceEXIF, "UserComment")
+	assert.False(t, ok)
+}
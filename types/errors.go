@@ -0,0 +1,75 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors of the readers and writers, to be used with errors.Is.
+var (
+	// The image does not contain metadata of a supported tool.
+	ErrNoMetadata = errors.New("no metadata found")
+	// The metadata uses a scheme that is not supported, e.g. A1111 for Fooocus.
+	ErrUnsupportedScheme = errors.New("unsupported metadata scheme")
+	// The version of the metadata format is not known.
+	ErrUnknownVersion = errors.New("unknown metadata version")
+	// The image format is not supported.
+	ErrUnsupportedMIME = errors.New("unsupported MIME type")
+	// The file is not a private log of the tool.
+	ErrNotPrivateLog = errors.New("not a private log")
+	// The metadata does not fit into the image format, e.g. XMP packets
+	// larger than a JPEG segment.
+	ErrMetadataTooLarge = errors.New("metadata too large")
+)
+
+// ParseError is returned when metadata was found but could not be parsed.
+type ParseError struct {
+	// Software that wrote the metadata, e.g. "Fooocus".
+	Software string
+	// Field that failed to parse, e.g. "parameters".
+	Field string
+	// Offset of the error in bytes from the start of the field,
+	// or -1 if unknown.
+	Offset int64
+	Err    error
+}
+
+// NewParseError returns a ParseError for the given field, with the
+// offset of JSON syntax and type errors.
+func NewParseError(software string, field string, err error) *ParseError {
+	var offset int64 = -1
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	} else if errors.As(err, &typeErr) {
+		offset = typeErr.Offset
+	}
+
+	return &ParseError{
+		Software: software,
+		Field:    field,
+		Offset:   offset,
+		Err:      err,
+	}
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("failed to read %s", e.Field)
+	if e.Offset >= 0 {
+		msg = fmt.Sprintf("%s at offset %d", msg, e.Offset)
+	}
+	if e.Software != "" {
+		msg = e.Software + ": " + msg
+	}
+	if e.Err != nil {
+		msg = msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,41 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewParseError_Syntax(t *testing.T) {
+	var v map[string]any
+	jsonErr := json.Unmarshal([]byte(`{"prompt": }`), &v)
+
+	err := NewParseError("Fooocus", "parameters", jsonErr)
+	assert.Equal(t, int64(12), err.Offset)
+	assert.Equal(t, "Fooocus: failed to read parameters at offset 12: "+jsonErr.Error(), err.Error())
+	assert.ErrorIs(t, err, jsonErr)
+}
+
+func TestNewParseError_Type(t *testing.T) {
+	var v struct {
+		Seed int `json:"seed"`
+	}
+	jsonErr := json.Unmarshal([]byte(`{"seed": "abc"}`), &v)
+
+	err := NewParseError("Fooocus", "parameters", jsonErr)
+	assert.Equal(t, int64(14), err.Offset)
+}
+
+func TestNewParseError_UnknownOffset(t *testing.T) {
+	cause := fmt.Errorf("invalid escape")
+	err := NewParseError("", "private log entry image.png", cause)
+	assert.Equal(t, int64(-1), err.Offset)
+	assert.Equal(t, "failed to read private log entry image.png: invalid escape", err.Error())
+
+	var target *ParseError
+	require.ErrorAs(t, fmt.Errorf("wrapped: %w", err), &target)
+	assert.Equal(t, "private log entry image.png", target.Field)
+}
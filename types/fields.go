@@ -0,0 +1,80 @@
+package types
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"strings"
+)
+
+// UnknownFields returns the top-level keys of the JSON object that do not
+// match any field of v, which must be a struct or a pointer to a struct.
+// Like encoding/json, keys are matched case-insensitively.
+func UnknownFields(data []byte, v any) (map[string]json.RawMessage, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	known := jsonFieldNames(reflect.TypeOf(v))
+
+	var unknown map[string]json.RawMessage
+	for key, value := range object {
+		if known[strings.ToLower(key)] {
+			continue
+		}
+		if unknown == nil {
+			unknown = make(map[string]json.RawMessage)
+		}
+		unknown[key] = value
+	}
+	return unknown, nil
+}
+
+// MergeFields adds the fields to the encoded JSON object,
+// keeping the value of keys that already exist.
+func MergeFields(data []byte, fields map[string]json.RawMessage) ([]byte, error) {
+	if len(fields) == 0 {
+		return data, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	merged := maps.Clone(fields)
+	maps.Copy(merged, object)
+	return json.Marshal(merged)
+}
+
+// jsonFieldNames returns the lower-case JSON names of the fields of
+// the struct type t, including the fields of embedded structs.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	names := make(map[string]bool)
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		if field.Anonymous && name == "" {
+			maps.Copy(names, jsonFieldNames(field.Type))
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return names
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type embeddedFields struct {
+	Prompt string `json:"prompt"`
+}
+
+type testFields struct {
+	embeddedFields
+	Seed    string `json:"seed,omitempty"`
+	Steps   int
+	Ignored string `json:"-"`
+}
+
+func TestUnknownFields(t *testing.T) {
+	data := []byte(`{"prompt": "a cat", "SEED": "1", "steps": 30, "Ignored": "x", "task_id": "abc"}`)
+
+	unknown, err := UnknownFields(data, &testFields{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]json.RawMessage{
+		"Ignored": json.RawMessage(`"x"`),
+		"task_id": json.RawMessage(`"abc"`),
+	}, unknown)
+
+	unknown, err = UnknownFields([]byte(`{"prompt": "a cat"}`), testFields{})
+	require.NoError(t, err)
+	assert.Nil(t, unknown)
+
+	_, err = UnknownFields([]byte(`[]`), testFields{})
+	assert.Error(t, err)
+}
+
+func TestMergeFields(t *testing.T) {
+	data := []byte(`{"prompt":"a cat","seed":"1"}`)
+
+	merged, err := MergeFields(data, map[string]json.RawMessage{
+		"seed":    json.RawMessage(`"2"`),
+		"task_id": json.RawMessage(`"abc"`),
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"prompt":"a cat","seed":"1","task_id":"abc"}`, string(merged))
+
+	unchanged, err := MergeFields(data, nil)
+	require.NoError(t, err)
+	assert.Equal(t, data, unchanged)
+}
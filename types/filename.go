@@ -0,0 +1,229 @@
+package types
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Names of the capture groups of a file name pattern.
+const (
+	FilenameDate    = "date"
+	FilenameTime    = "time"
+	FilenameCounter = "counter"
+	FilenameSeed    = "seed"
+	FilenameBatch   = "batch"
+)
+
+// Default layouts of the date and time placeholders.
+const (
+	DefaultFilenameDateLayout = "2006-01-02"
+	DefaultFilenameTimeLayout = "15-04-05"
+)
+
+// Regular expressions of the template placeholders,
+// except date and time which depend on the layout.
+var filenamePlaceholders = map[string]string{
+	FilenameCounter: `(?P<counter>\d+)`,
+	FilenameSeed:    `(?P<seed>\d+)`,
+	FilenameBatch:   `(?P<batch>\d+)`,
+	"*":             `.*`,
+}
+
+var filenamePlaceholder = regexp.MustCompile(`\{([a-z]+|\*)(?::([^}]+))?\}`)
+
+// FilenamePattern extracts information from the file name of an image.
+//
+// The pattern is matched against the file name without directory
+// and extension. Information is extracted from the named capture
+// groups "date", "time", "counter", "seed" and "batch".
+type FilenamePattern struct {
+	re     *regexp.Regexp
+	source string
+
+	// Layouts of the date and time groups, see
+	// DefaultFilenameDateLayout and DefaultFilenameTimeLayout.
+	DateLayout string
+	TimeLayout string
+}
+
+// FilenameTemplate compiles a file name template, e.g. "{date}_{time}_{counter}".
+//
+// The placeholders {date}, {time}, {counter}, {seed} and {batch} match
+// the respective information, {*} matches anything. All other characters
+// are matched literally. The layout of numeric dates and times can be
+// given in Go notation, e.g. "{date:20060102}-{time:150405}".
+func FilenameTemplate(template string) (*FilenamePattern, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+
+	dateLayout, timeLayout := DefaultFilenameDateLayout, DefaultFilenameTimeLayout
+
+	last := 0
+	for _, match := range filenamePlaceholder.FindAllStringSubmatchIndex(template, -1) {
+		name := template[match[2]:match[3]]
+		var layout string
+		if match[4] >= 0 {
+			layout = template[match[4]:match[5]]
+		}
+
+		var placeholder string
+		switch name {
+		case FilenameDate:
+			if layout != "" {
+				dateLayout = layout
+			}
+			placeholder = `(?P<date>` + layoutRegexp(dateLayout) + `)`
+		case FilenameTime:
+			if layout != "" {
+				timeLayout = layout
+			}
+			placeholder = `(?P<time>` + layoutRegexp(timeLayout) + `)`
+		default:
+			var ok bool
+			if placeholder, ok = filenamePlaceholders[name]; !ok || layout != "" {
+				return nil, fmt.Errorf("unknown placeholder in file name template: %s", template[match[0]:match[1]])
+			}
+		}
+
+		expr.WriteString(regexp.QuoteMeta(template[last:match[0]]))
+		expr.WriteString(placeholder)
+		last = match[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(template[last:]))
+	expr.WriteString("$")
+
+	pattern, err := FilenameRegexp(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	pattern.source = template
+	pattern.DateLayout = dateLayout
+	pattern.TimeLayout = timeLayout
+	return pattern, nil
+}
+
+// layoutRegexp converts a numeric time layout into a regular expression,
+// matching any digit for each digit of the layout.
+func layoutRegexp(layout string) string {
+	var expr strings.Builder
+	for _, r := range layout {
+		if r >= '0' && r <= '9' {
+			expr.WriteString(`\d`)
+		} else {
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return expr.String()
+}
+
+// FilenameRegexp compiles a regular expression with named capture groups,
+// e.g. `^(?P<counter>\d+)-(?P<seed>\d+)`.
+func FilenameRegexp(expr string) (*FilenamePattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file name pattern: %w", err)
+	}
+	return &FilenamePattern{
+		re:         re,
+		source:     expr,
+		DateLayout: DefaultFilenameDateLayout,
+		TimeLayout: DefaultFilenameTimeLayout,
+	}, nil
+}
+
+// MustFilenameTemplate is like FilenameTemplate but panics on error.
+// It simplifies the initialisation of default patterns.
+func MustFilenameTemplate(template string) *FilenamePattern {
+	pattern, err := FilenameTemplate(template)
+	if err != nil {
+		panic(err)
+	}
+	return pattern
+}
+
+// String returns the template or regular expression of the pattern.
+func (p *FilenamePattern) String() string {
+	return p.source
+}
+
+// FilenameInfo is the information extracted from the file name of an image.
+type FilenameInfo struct {
+	// The pattern that matched the file name, empty if none matched.
+	Pattern string
+
+	// Date and time of the image, only the date if
+	// the time was not part of the file name.
+	Date time.Time
+	// Counter of images in the output folder, e.g. in A1111.
+	Counter *int
+	// Seed used to generate the image.
+	Seed string
+	// Index of the image within a batch.
+	BatchIndex *int
+}
+
+// Parse extracts the information from the file name, parsing dates in
+// the given location. Returns false if the pattern does not match.
+func (p *FilenamePattern) Parse(filename string, loc *time.Location) (info FilenameInfo, ok bool) {
+	name := filepath.Base(filename)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	match := p.re.FindStringSubmatch(name)
+	if match == nil {
+		return info, false
+	}
+
+	groups := make(map[string]string, len(match))
+	for i, group := range p.re.SubexpNames() {
+		if group != "" && match[i] != "" {
+			groups[group] = match[i]
+		}
+	}
+
+	if date, ok := groups[FilenameDate]; ok {
+		layout, value := p.DateLayout, date
+		if t, ok := groups[FilenameTime]; ok {
+			layout, value = layout+" "+p.TimeLayout, value+" "+t
+		}
+		if info.Date, _ = time.ParseInLocation(layout, value, loc); info.Date.IsZero() {
+			// Not a valid date, e.g. a different naming scheme
+			return FilenameInfo{}, false
+		}
+	}
+	if counter, err := strconv.Atoi(groups[FilenameCounter]); err == nil {
+		info.Counter = &counter
+	}
+	if batch, err := strconv.Atoi(groups[FilenameBatch]); err == nil {
+		info.BatchIndex = &batch
+	}
+	info.Seed = groups[FilenameSeed]
+	info.Pattern = p.String()
+	return info, true
+}
+
+// ParseFilename extracts information from the file name of the image,
+// using the first matching pattern. User-defined patterns of the context
+// take precedence over the default patterns of the extractor.
+func (e *FileMetadataExtractor) ParseFilename(file ImageMetadataContext) FilenameInfo {
+	if file.Filepath == "" {
+		return FilenameInfo{}
+	}
+
+	patterns := e.FilenamePatterns
+	if custom, ok := file.FilenamePatterns[e.Software]; ok {
+		patterns = custom
+	} else if custom, ok := file.FilenamePatterns[""]; ok {
+		patterns = custom
+	}
+
+	for _, pattern := range patterns {
+		if info, ok := pattern.Parse(file.Filepath, file.Time.Loc()); ok {
+			return info
+		}
+	}
+	return FilenameInfo{}
+}
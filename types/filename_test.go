@@ -0,0 +1,125 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestFilenamePattern_Parse(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+
+	testCases := []struct {
+		pattern  string
+		filename string
+		ok       bool
+		expected FilenameInfo
+	}{
+		{"{date}_{time}{*}", "/out/2025-01-20_14-22-03_1234.png", true, FilenameInfo{
+			Date: time.Date(2025, 1, 20, 14, 22, 3, 0, loc),
+		}},
+		{"{date}_{time}_{counter}", "2025-01-20_14-22-03_1234.png", true, FilenameInfo{
+			Date:    time.Date(2025, 1, 20, 14, 22, 3, 0, loc),
+			Counter: intPtr(1234),
+		}},
+		{"{date}_{seed}_{batch}", "2025-01-20_1234567_2.jpeg", true, FilenameInfo{
+			Date:       time.Date(2025, 1, 20, 0, 0, 0, 0, loc),
+			Seed:       "1234567",
+			BatchIndex: intPtr(2),
+		}},
+		{"{counter}-{seed}", "00012-1234567.png", true, FilenameInfo{
+			Counter: intPtr(12),
+			Seed:    "1234567",
+		}},
+		{"{counter}-{seed}", "00012-1234567-a sunflower field.png", false, FilenameInfo{}},
+		{"{date}_{time}{*}", "renamed.png", false, FilenameInfo{}},
+		// Matches the pattern, but not a valid date
+		{"{date}_{time}{*}", "2025-13-45_14-22-03.png", false, FilenameInfo{}},
+		{"IMG_{date:20060102}_{time:150405}", "IMG_20250120_142203.png", true, FilenameInfo{
+			Date: time.Date(2025, 1, 20, 14, 22, 3, 0, loc),
+		}},
+		{"{date:02.01.2006}", "20.01.2025.png", true, FilenameInfo{
+			Date: time.Date(2025, 1, 20, 0, 0, 0, 0, loc),
+		}},
+		// Literal characters are escaped
+		{"img.{counter}", "img.42.png", true, FilenameInfo{Counter: intPtr(42)}},
+		{"img.{counter}", "imgx42.png", false, FilenameInfo{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.filename, func(t *testing.T) {
+			pattern, err := FilenameTemplate(tc.pattern)
+			require.NoError(t, err)
+
+			info, ok := pattern.Parse(tc.filename, loc)
+			assert.Equal(t, tc.ok, ok)
+			if tc.ok {
+				tc.expected.Pattern = tc.pattern
+			}
+			assert.Equal(t, tc.expected, info)
+		})
+	}
+}
+
+func TestFilenameTemplate_Invalid(t *testing.T) {
+	_, err := FilenameTemplate("{date}_{unknown}")
+	assert.ErrorContains(t, err, "{unknown}")
+
+	_, err = FilenameTemplate("{seed:2006}")
+	assert.ErrorContains(t, err, "{seed:2006}")
+
+	assert.Panics(t, func() {
+		MustFilenameTemplate("{unknown}")
+	})
+}
+
+func TestFilenameRegexp(t *testing.T) {
+	pattern, err := FilenameRegexp(`^IMG_(?P<date>\d{8})_(?P<counter>\d+)`)
+	require.NoError(t, err)
+	pattern.DateLayout = "20060102"
+
+	info, ok := pattern.Parse("IMG_20250120_0003.webp", time.UTC)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), info.Date)
+	assert.Equal(t, intPtr(3), info.Counter)
+	assert.Equal(t, `^IMG_(?P<date>\d{8})_(?P<counter>\d+)`, info.Pattern)
+
+	_, err = FilenameRegexp(`(?P<date`)
+	assert.Error(t, err)
+}
+
+func TestParseFilename(t *testing.T) {
+	extractor := &FileMetadataExtractor{
+		Software:         "Fooocus",
+		FilenamePatterns: []*FilenamePattern{MustFilenameTemplate("{date}_{time}{*}")},
+	}
+	custom := []*FilenamePattern{MustFilenameTemplate("{counter}-{seed}")}
+
+	file := ImageMetadataContext{Filepath: "2025-01-20_14-22-03_1234.png", Time: TimeOptions{Location: time.UTC}}
+	info := extractor.ParseFilename(file)
+	assert.Equal(t, time.Date(2025, 1, 20, 14, 22, 3, 0, time.UTC), info.Date)
+
+	// User-defined patterns of the software replace the defaults
+	file.FilenamePatterns = map[string][]*FilenamePattern{"Fooocus": custom}
+	assert.Equal(t, FilenameInfo{}, extractor.ParseFilename(file))
+
+	file.Filepath = "00012-1234567.png"
+	assert.Equal(t, "1234567", extractor.ParseFilename(file).Seed)
+
+	// Patterns for all software
+	file.FilenamePatterns = map[string][]*FilenamePattern{"": custom}
+	assert.Equal(t, "1234567", extractor.ParseFilename(file).Seed)
+
+	// Patterns of other software are ignored
+	file.FilenamePatterns = map[string][]*FilenamePattern{"RuinedFooocus": custom}
+	assert.Equal(t, FilenameInfo{}, extractor.ParseFilename(file))
+
+	// Images read from a stream have no file name
+	assert.Equal(t, FilenameInfo{}, extractor.ParseFilename(ImageMetadataContext{}))
+}
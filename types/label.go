@@ -0,0 +1,73 @@
+package types
+
+import "strings"
+
+// IPTC digital source types of AI-generated images, see Label.
+const (
+	// Created by a model trained on sampled content, e.g. text to image.
+	DigitalSourceTrainedAlgorithmicMedia = "http://cv.iptc.org/newscodes/digitalsourcetype/trainedAlgorithmicMedia"
+	// Composite of generated and other content, e.g. inpainting of a photo.
+	DigitalSourceCompositeWithTrainedAlgorithmicMedia = "http://cv.iptc.org/newscodes/digitalsourcetype/compositeWithTrainedAlgorithmicMedia"
+)
+
+// Label is the labelling of an image as AI-generated, and its
+// attribution, as required by platforms that publish the image.
+type Label struct {
+	// IPTC digital source type from the XMP property
+	// Iptc4xmpExt:DigitalSourceType, e.g.
+	// DigitalSourceTrainedAlgorithmicMedia.
+	DigitalSourceType string `json:"digital_source_type,omitempty"`
+	// Author from the EXIF Artist tag, or else the XMP dc:creator.
+	Artist string `json:"artist,omitempty"`
+	// Copyright notice or licence from the EXIF Copyright tag,
+	// or else the XMP dc:rights.
+	Copyright string `json:"copyright,omitempty"`
+	// Description from the EXIF ImageDescription tag.
+	Description string `json:"description,omitempty"`
+	// Software from the EXIF Software tag, or else the XMP
+	// xmp:CreatorTool, e.g. "Fooocus v2.5.5".
+	Software string `json:"software,omitempty"`
+}
+
+// IsAIGenerated returns true if the digital source type labels
+// the image as generated, in full or in part.
+func (l Label) IsAIGenerated() bool {
+	return l.DigitalSourceType == DigitalSourceTrainedAlgorithmicMedia ||
+		l.DigitalSourceType == DigitalSourceCompositeWithTrainedAlgorithmicMedia
+}
+
+// ReadLabel reads the label of an image from its EXIF and XMP tags,
+// or returns nil if it has none of the fields.
+func ReadLabel(tags Tags) *Label {
+	label := Label{
+		DigitalSourceType: labelTag(tags, NamespaceXMPIptcExt, "DigitalSourceType"),
+		Artist:            labelTag(tags, NamespaceEXIF, "Artist", NamespaceXMPDublinCore, "creator"),
+		Copyright:         labelTag(tags, NamespaceEXIF, "Copyright", NamespaceXMPDublinCore, "rights"),
+		Description:       labelTag(tags, NamespaceEXIF, "ImageDescription"),
+		Software:          labelTag(tags, NamespaceEXIF, "Software", NamespaceXMPBasic, "CreatorTool"),
+	}
+	if label == (Label{}) {
+		return nil
+	}
+	return &label
+}
+
+// labelTag returns the value of the first of the tags given as pairs of
+// namespace and name that exists. The values of arrays are joined.
+func labelTag(tags Tags, namespaceAndName ...string) string {
+	for i := 0; i+1 < len(namespaceAndName); i += 2 {
+		for _, tag := range tags.Lookup(namespaceAndName[i], namespaceAndName[i+1]) {
+			switch value := tag.Value.(type) {
+			case string:
+				if value = strings.TrimSpace(value); value != "" {
+					return value
+				}
+			case []string:
+				if len(value) > 0 {
+					return strings.Join(value, "; ")
+				}
+			}
+		}
+	}
+	return ""
+}
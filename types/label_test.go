@@ -0,0 +1,39 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/bep/imagemeta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadLabel(t *testing.T) {
+	assert.Nil(t, ReadLabel(nil))
+	assert.Nil(t, ReadLabel(Tags{{Source: imagemeta.EXIF, Namespace: "IFD0", Tag: "Artist", Value: "  "}}))
+
+	tags := Tags{
+		{Source: imagemeta.EXIF, Namespace: "IFD0", Tag: "Artist", Value: "Jane Doe"},
+		{Source: imagemeta.EXIF, Namespace: "IFD0", Tag: "ImageDescription", Value: "A sunflower field"},
+		{Source: imagemeta.EXIF, Namespace: "IFD0", Tag: "Software", Value: "Fooocus v2.5.5"},
+		{Source: imagemeta.XMP, Namespace: NamespaceXMPDublinCore, Tag: "creator", Value: []string{"John Doe"}},
+		{Source: imagemeta.XMP, Namespace: NamespaceXMPDublinCore, Tag: "rights", Value: "CC BY 4.0"},
+		{Source: imagemeta.XMP, Namespace: NamespaceXMPIptcExt, Tag: "DigitalSourceType", Value: DigitalSourceTrainedAlgorithmicMedia},
+	}
+	label := ReadLabel(tags)
+	assert.Equal(t, &Label{
+		DigitalSourceType: DigitalSourceTrainedAlgorithmicMedia,
+		Artist:            "Jane Doe",
+		Copyright:         "CC BY 4.0",
+		Description:       "A sunflower field",
+		Software:          "Fooocus v2.5.5",
+	}, label)
+	assert.True(t, label.IsAIGenerated())
+
+	// XMP only, with several creators
+	label = ReadLabel(Tags{
+		{Source: imagemeta.XMP, Namespace: NamespaceXMPDublinCore, Tag: "creator", Value: []string{"Jane Doe", "John Doe"}},
+		{Source: imagemeta.XMP, Namespace: NamespaceXMPBasic, Tag: "CreatorTool", Value: "Fooocus v2.5.5"},
+	})
+	assert.Equal(t, &Label{Artist: "Jane Doe; John Doe", Software: "Fooocus v2.5.5"}, label)
+	assert.False(t, label.IsAIGenerated())
+}
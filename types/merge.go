@@ -0,0 +1,107 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// PrivateLogMode controls how readers use the private log
+// of the output folder, e.g. "log.html".
+type PrivateLogMode string
+
+const (
+	// Read the private log only if the image has no embedded metadata.
+	PrivateLogFallback PrivateLogMode = ""
+	// Read both the embedded metadata and the private log, fill fields
+	// missing from the embedded metadata from the log and report the
+	// fields with different values.
+	PrivateLogMerge PrivateLogMode = "merge"
+)
+
+// MergeReport describes how metadata from a secondary
+// source was merged into the primary metadata.
+type MergeReport struct {
+	// Where the secondary metadata was found, e.g. the private log.
+	Provenance Provenance `json:"provenance"`
+	// Fields that were missing from the primary metadata
+	// and filled from the secondary metadata.
+	Filled []string `json:"filled,omitempty"`
+	// Fields with different values, which keep the primary value.
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+// Conflict is a field with different values in the primary and
+// secondary metadata, e.g. after the image was edited.
+type Conflict struct {
+	Field     string `json:"field"`
+	Primary   string `json:"primary"`
+	Secondary string `json:"secondary"`
+}
+
+// MergeValues fills the zero fields of the struct primary with the fields
+// of secondary, and returns the names of the filled fields and the fields
+// with different values. Fields of embedded structs are merged
+// individually, other fields are compared as a whole. Bookkeeping fields
+// that do not describe the generation, e.g. the metadata scheme or the
+// unknown keys, are tagged `merge:"-"` and skipped.
+func MergeValues[T any](primary *T, secondary T) (filled []string, conflicts []Conflict) {
+	merge(reflect.ValueOf(primary).Elem(), reflect.ValueOf(secondary), &filled, &conflicts)
+	return filled, conflicts
+}
+
+func merge(primary reflect.Value, secondary reflect.Value, filled *[]string, conflicts *[]Conflict) {
+	if primary.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := range primary.NumField() {
+		field := primary.Type().Field(i)
+		a, b := primary.Field(i), secondary.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			merge(a, b, filled, conflicts)
+			continue
+		}
+		if !field.IsExported() || field.Tag.Get("merge") == "-" {
+			continue
+		}
+		if isZeroValue(b) || reflect.DeepEqual(a.Interface(), b.Interface()) {
+			continue
+		}
+		if isZeroValue(a) {
+			a.Set(b)
+			*filled = append(*filled, field.Name)
+			continue
+		}
+		*conflicts = append(*conflicts, Conflict{
+			Field:     field.Name,
+			Primary:   formatValue(a),
+			Secondary: formatValue(b),
+		})
+	}
+}
+
+// isZeroValue returns true for zero values and empty slices and maps.
+func isZeroValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// formatValue formats strings and numbers as is, and other values as JSON.
+func formatValue(v reflect.Value) string {
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface())
+	}
+	if data, err := json.Marshal(v.Interface()); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mergeInner struct {
+	Strength float32
+}
+
+type mergeFields struct {
+	mergeInner
+	Prompt string
+	Seed   string
+	Steps  int
+	Styles []string
+	Size   *[2]int
+	Scheme string            `merge:"-"`
+	Extra  map[string]string `merge:"-"`
+	hidden string
+}
+
+func TestMergeValues(t *testing.T) {
+	primary := mergeFields{
+		Prompt: "a cat",
+		Seed:   "1",
+		Styles: []string{"Fooocus V2"},
+		Scheme: "fooocus",
+		hidden: "primary",
+	}
+	secondary := mergeFields{
+		mergeInner: mergeInner{Strength: 0.5},
+		Prompt:     "a cat",
+		Seed:       "2",
+		Steps:      30,
+		Styles:     []string{"Fooocus V2", "Fooocus Sharp"},
+		Size:       &[2]int{1024, 1024},
+		Scheme:     "a1111",
+		Extra:      map[string]string{"task_id": "1"},
+		hidden:     "secondary",
+	}
+
+	filled, conflicts := MergeValues(&primary, secondary)
+	assert.Equal(t, []string{"Strength", "Steps", "Size"}, filled)
+	assert.Equal(t, []Conflict{
+		{Field: "Seed", Primary: "1", Secondary: "2"},
+		{Field: "Styles", Primary: `["Fooocus V2"]`, Secondary: `["Fooocus V2","Fooocus Sharp"]`},
+	}, conflicts)
+
+	assert.Equal(t, mergeFields{
+		mergeInner: mergeInner{Strength: 0.5},
+		Prompt:     "a cat",
+		Seed:       "1",
+		Steps:      30,
+		Styles:     []string{"Fooocus V2"},
+		Size:       &[2]int{1024, 1024},
+		Scheme:     "fooocus",
+		hidden:     "primary",
+	}, primary)
+}
+
+func TestMergeValues_Empty(t *testing.T) {
+	primary := mergeFields{Prompt: "a cat", Styles: []string{}}
+
+	filled, conflicts := MergeValues(&primary, mergeFields{Styles: []string{}})
+	assert.Nil(t, filled)
+	assert.Nil(t, conflicts)
+	assert.Equal(t, mergeFields{Prompt: "a cat", Styles: []string{}}, primary)
+}
@@ -13,8 +13,31 @@ type StructuredMetadata struct {
 	// e.g., "Fooocus", "FooocusPlus" or "RuinedFooocus".
 	Source string
 
-	Created time.Time
-	Params  GenerationParameters
+	// Created is the creation time of the image, if known, and
+	// CreatedSource the source it was resolved from.
+	Created       time.Time
+	CreatedSource TimeSource
+
+	// Filename is the information extracted from the file name.
+	Filename FilenameInfo
+
+	// Image are the properties of the image data, and Size compares the
+	// image size with the generated size, e.g. to find upscaled images.
+	Image ImageProperties
+	Size  SizeCheck
+
+	// Provenance records where the generation parameters were found.
+	Provenance Provenance
+	// Merge describes how metadata from a secondary source was merged
+	// into the generation parameters, nil if it was not merged.
+	// See PrivateLogMerge.
+	Merge *MergeReport
+
+	// Label is the labelling of the image as AI-generated and its
+	// attribution, nil if the image has none, see ReadLabel.
+	Label *Label
+
+	Params GenerationParameters
 }
 
 type Lora struct {
@@ -28,6 +51,8 @@ type GenerationParameters interface {
 
 	// The version of the software that generated the metadata.
 	Version() string
+	// The parsed version of the software, e.g. to compare versions.
+	SoftwareVersion() SoftwareVersion
 
 	// The prompt used for the generation.
 	PositivePrompt() string
@@ -39,6 +64,16 @@ type GenerationParameters interface {
 	LoRAs() []Lora
 	// The seed used for the generation.
 	Seed() string
+	// The parsed seed, e.g. for numeric filtering, zero if unknown.
+	SeedValue() Seed
+	// The sampler in ComfyUI/Fooocus notation, e.g. "dpmpp_2m_sde_gpu",
+	// see NormaliseSampler.
+	Sampler() string
+	// The scheduler in ComfyUI/Fooocus notation, e.g. "karras",
+	// see NormaliseScheduler.
+	Scheduler() string
+	// The size of the generated image in pixels, zero if unknown.
+	Size() (width int, height int)
 
 	// Raw returns the underlying metadata struct (e.g. fooocus.Metadata).
 	// The caller can type-assert it if needed.
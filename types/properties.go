@@ -0,0 +1,113 @@
+package types
+
+import "math"
+
+// ImageProperties are the properties of the image data,
+// read from the image headers without decoding the pixels.
+type ImageProperties struct {
+	// Size of the image in pixels.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Colour type, e.g. "RGB", "RGBA", "Gray" or "Palette".
+	ColorType string `json:"color_type,omitempty"`
+	// Bits per sample, e.g. 8 or 16.
+	BitDepth int `json:"bit_depth,omitempty"`
+	// Number of frames, 1 for still images.
+	Frames int `json:"frames,omitempty"`
+}
+
+// IsZero returns true if the properties are not known.
+func (p ImageProperties) IsZero() bool {
+	return p == ImageProperties{}
+}
+
+// Colour types of ImageProperties.
+const (
+	ColorGray      = "Gray"
+	ColorGrayAlpha = "GrayAlpha"
+	ColorRGB       = "RGB"
+	ColorRGBA      = "RGBA"
+	ColorPalette   = "Palette"
+	ColorYCbCr     = "YCbCr"
+	ColorCMYK      = "CMYK"
+)
+
+// SizeChange describes how the size of an image differs from
+// the size recorded in its generation parameters.
+type SizeChange string
+
+const (
+	// Either size is not known.
+	SizeUnknown SizeChange = ""
+	// The image has the generated size.
+	SizeOriginal SizeChange = "original"
+	// Both sides were scaled up by the same factor, e.g.
+	// by Fooocus "Upscale (2x)" or an external upscaler.
+	SizeUpscaled SizeChange = "upscaled"
+	// Both sides were scaled down by the same factor.
+	SizeDownscaled SizeChange = "downscaled"
+	// The image is smaller than generated and the aspect ratio changed.
+	SizeCropped SizeChange = "cropped"
+	// The aspect ratio changed and the image is larger on either side.
+	SizeResized SizeChange = "resized"
+)
+
+// Largest relative difference of the scale factors of width and height
+// that counts as the same factor, to allow rounding to multiples of 8 or 64.
+const scaleTolerance = 0.01
+
+// SizeCheck is the result of comparing the generated size
+// with the size of the image.
+type SizeCheck struct {
+	// Size recorded in the generation parameters.
+	GeneratedWidth  int `json:"generated_width"`
+	GeneratedHeight int `json:"generated_height"`
+	// Size of the image.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// How the image size differs from the generated size.
+	Change SizeChange `json:"change,omitempty"`
+	// Scale factor of upscaled and downscaled images,
+	// e.g. 2 after Fooocus "Upscale (2x)", otherwise 1.
+	Scale float64 `json:"scale,omitempty"`
+}
+
+// CheckSize compares the generated size with the size of the image, and
+// returns a check with SizeUnknown if either size is not known.
+func CheckSize(generatedWidth int, generatedHeight int, image ImageProperties) SizeCheck {
+	check := SizeCheck{
+		GeneratedWidth:  generatedWidth,
+		GeneratedHeight: generatedHeight,
+		Width:           image.Width,
+		Height:          image.Height,
+	}
+	if generatedWidth <= 0 || generatedHeight <= 0 || image.Width <= 0 || image.Height <= 0 {
+		return check
+	}
+
+	check.Scale = 1
+	if image.Width == generatedWidth && image.Height == generatedHeight {
+		check.Change = SizeOriginal
+		return check
+	}
+
+	scaleX := float64(image.Width) / float64(generatedWidth)
+	scaleY := float64(image.Height) / float64(generatedHeight)
+	sameScale := math.Abs(scaleX-scaleY) <= scaleTolerance*max(scaleX, scaleY)
+	if sameScale && image.Width != generatedWidth && image.Height != generatedHeight {
+		check.Scale = math.Round((scaleX+scaleY)/2*100) / 100
+		if scaleX > 1 {
+			check.Change = SizeUpscaled
+		} else {
+			check.Change = SizeDownscaled
+		}
+		return check
+	}
+
+	if image.Width <= generatedWidth && image.Height <= generatedHeight {
+		check.Change = SizeCropped
+	} else {
+		check.Change = SizeResized
+	}
+	return check
+}
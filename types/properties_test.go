@@ -0,0 +1,38 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSize(t *testing.T) {
+	testCases := []struct {
+		name          string
+		width, height int
+		image         ImageProperties
+		change        SizeChange
+		scale         float64
+	}{
+		{"original", 1152, 896, ImageProperties{Width: 1152, Height: 896}, SizeOriginal, 1},
+		{"upscale 2x", 1152, 896, ImageProperties{Width: 2304, Height: 1792}, SizeUpscaled, 2},
+		{"upscale 1.5x", 1152, 896, ImageProperties{Width: 1728, Height: 1344}, SizeUpscaled, 1.5},
+		{"upscale rounded", 896, 1152, ImageProperties{Width: 1344, Height: 1736}, SizeUpscaled, 1.5},
+		{"downscale", 1024, 1024, ImageProperties{Width: 512, Height: 512}, SizeDownscaled, 0.5},
+		{"crop", 1152, 896, ImageProperties{Width: 896, Height: 896}, SizeCropped, 1},
+		{"crop one side", 1024, 1024, ImageProperties{Width: 1016, Height: 1024}, SizeCropped, 1},
+		{"resize", 1152, 896, ImageProperties{Width: 1024, Height: 1024}, SizeResized, 1},
+		{"unknown image", 1152, 896, ImageProperties{}, SizeUnknown, 0},
+		{"unknown generated", 0, 0, ImageProperties{Width: 1152, Height: 896}, SizeUnknown, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			check := CheckSize(tc.width, tc.height, tc.image)
+			assert.Equal(t, tc.change, check.Change)
+			assert.Equal(t, tc.scale, check.Scale)
+			assert.Equal(t, tc.width, check.GeneratedWidth)
+			assert.Equal(t, tc.image.Width, check.Width)
+		})
+	}
+}
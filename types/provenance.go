@@ -0,0 +1,69 @@
+package types
+
+import (
+	"github.com/bep/imagemeta"
+)
+
+// Location is the kind of location the metadata was read from.
+type Location string
+
+const (
+	// Metadata embedded in the image file, e.g. an EXIF tag or PNG chunk.
+	LocationEmbedded Location = "embedded"
+	// Metadata in a separate file next to the image, e.g. an XMP sidecar.
+	LocationSidecar Location = "sidecar"
+	// Metadata in the private log of the output folder, e.g. "log.html".
+	LocationPrivateLog Location = "private_log"
+)
+
+// Name of the container of private log entries.
+const PrivateLogContainer = "HTML"
+
+// Provenance records where and in which format the metadata of an
+// image was found, to judge how trustworthy it is.
+type Provenance struct {
+	Location Location `json:"location"`
+	// Path of the file the metadata was read from,
+	// empty if it was embedded in the image itself.
+	Path string `json:"path,omitempty"`
+	// Container of the metadata, e.g. "EXIF/IFD0", "PNG/tEXt" or "HTML".
+	Container string `json:"container,omitempty"`
+	// Key of the metadata within the container, e.g. the EXIF tag
+	// "UserComment", the PNG keyword "parameters" or the file name
+	// of the private log entry.
+	Key string `json:"key,omitempty"`
+	// Scheme of the metadata, e.g. "fooocus" or "a1111",
+	// empty if the tool only has a single scheme.
+	Scheme string `json:"scheme,omitempty"`
+	// Detected version of the metadata format, e.g. "v23" for
+	// Fooocus, empty if the format is not versioned or unknown.
+	Version string `json:"version,omitempty"`
+	// Whether the version of the metadata is not known and it was
+	// read best-effort as the latest known version.
+	Unverified bool `json:"unverified,omitempty"`
+}
+
+// EmbeddedProvenance returns the provenance of metadata
+// read from the given embedded tag.
+func EmbeddedProvenance(tag imagemeta.TagInfo) Provenance {
+	container := tag.Namespace
+	if tag.Source == imagemeta.EXIF {
+		container = "EXIF/" + tag.Namespace
+	}
+	return Provenance{
+		Location:  LocationEmbedded,
+		Container: container,
+		Key:       tag.Tag,
+	}
+}
+
+// PrivateLogProvenance returns the provenance of metadata
+// read from the entry of the given private log.
+func PrivateLogProvenance(logfile string, entry string) Provenance {
+	return Provenance{
+		Location:  LocationPrivateLog,
+		Path:      logfile,
+		Container: PrivateLogContainer,
+		Key:       entry,
+	}
+}
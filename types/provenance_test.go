@@ -0,0 +1,32 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/bep/imagemeta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddedProvenance(t *testing.T) {
+	exif := EmbeddedProvenance(imagemeta.TagInfo{
+		Source:    imagemeta.EXIF,
+		Namespace: "IFD0",
+		Tag:       "UserComment",
+	})
+	assert.Equal(t, Provenance{Location: LocationEmbedded, Container: "EXIF/IFD0", Key: "UserComment"}, exif)
+
+	png := EmbeddedProvenance(imagemeta.TagInfo{
+		Namespace: "PNG/tEXt",
+		Tag:       "parameters",
+	})
+	assert.Equal(t, Provenance{Location: LocationEmbedded, Container: "PNG/tEXt", Key: "parameters"}, png)
+}
+
+func TestPrivateLogProvenance(t *testing.T) {
+	assert.Equal(t, Provenance{
+		Location:  LocationPrivateLog,
+		Path:      "outputs/log.html",
+		Container: "HTML",
+		Key:       "image.png",
+	}, PrivateLogProvenance("outputs/log.html", "image.png"))
+}
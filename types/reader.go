@@ -17,18 +17,42 @@ type Reader[T any] interface {
 
 // FileMetadataExtractor is a common base for file-based metadata extractors.
 type FileMetadataExtractor struct {
-	DateLayout  string
-	LogfileName string
+	// Name of the software, used to look up user-defined
+	// file name patterns in the ImageMetadataContext.
+	Software string
+	// Default patterns to extract information from the file name.
+	FilenamePatterns []*FilenamePattern
+	LogfileName      string
+
+	// Deprecated: Use FilenamePatterns instead.
+	DateLayout string
 }
 
+// ParseDateFromFilename parses the date prefix of the filename in UTC.
+//
+// Deprecated: Fooocus names files in local time, use
+// ParseDateFromFilenameIn with the appropriate location instead.
 func (e *FileMetadataExtractor) ParseDateFromFilename(filename string) (time.Time, error) {
+	return e.ParseDateFromFilenameIn(filename, time.UTC)
+}
+
+// ParseDateFromFilenameIn parses the date prefix of the filename
+// in the given location. The layout is DateLayout if set, or otherwise
+// the date layout of the first file name pattern.
+func (e *FileMetadataExtractor) ParseDateFromFilenameIn(filename string, loc *time.Location) (time.Time, error) {
 
 	layoutIn := e.DateLayout
+	if layoutIn == "" && len(e.FilenamePatterns) > 0 {
+		layoutIn = e.FilenamePatterns[0].DateLayout
+	}
+	if layoutIn == "" {
+		return time.Time{}, fmt.Errorf("failed to parse date from filename: no date layout")
+	}
 
 	if len(filename) < len(layoutIn) {
 		return time.Time{}, fmt.Errorf("failed to parse date from filename: too short")
 	}
 
 	datepart := filename[:len(layoutIn)]
-	return time.Parse(layoutIn, datepart)
+	return time.ParseInLocation(layoutIn, datepart, loc)
 }
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateFromFilenameIn(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+
+	t.Run("FilenamePattern", func(t *testing.T) {
+		extractor := FileMetadataExtractor{
+			FilenamePatterns: []*FilenamePattern{MustFilenameTemplate("{date:20060102}-{time:150405}")},
+		}
+		date, err := extractor.ParseDateFromFilenameIn("20250120-142203.png", loc)
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 1, 20, 0, 0, 0, 0, loc), date)
+	})
+
+	t.Run("DateLayout", func(t *testing.T) {
+		extractor := FileMetadataExtractor{
+			FilenamePatterns: []*FilenamePattern{MustFilenameTemplate("{date:20060102}{*}")},
+			DateLayout:       DefaultFilenameDateLayout,
+		}
+		date, err := extractor.ParseDateFromFilename("2025-01-20_14-22-03_1234.png")
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), date)
+	})
+
+	t.Run("NoLayout", func(t *testing.T) {
+		var extractor FileMetadataExtractor
+		_, err := extractor.ParseDateFromFilenameIn("2025-01-20_14-22-03_1234.png", loc)
+		assert.ErrorContains(t, err, "no date layout")
+	})
+
+	t.Run("TooShort", func(t *testing.T) {
+		extractor := FileMetadataExtractor{
+			FilenamePatterns: []*FilenamePattern{MustFilenameTemplate("{date}_{time}{*}")},
+		}
+		_, err := extractor.ParseDateFromFilenameIn("2025.png", loc)
+		assert.ErrorContains(t, err, "too short")
+	})
+}
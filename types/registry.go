@@ -1,8 +1,9 @@
 package types
 
 import (
-	"fmt"
+	"errors"
 	"log/slog"
+	"slices"
 	"sync"
 )
 
@@ -15,6 +16,7 @@ type format struct {
 var (
 	formatsMu sync.Mutex
 	formats   []format = make([]format, 0, 3)
+	fallbacks []format
 )
 
 func RegisterReader(name string, decode func(ImageMetadataContext) (StructuredMetadata, error)) {
@@ -23,16 +25,53 @@ func RegisterReader(name string, decode func(ImageMetadataContext) (StructuredMe
 	formatsMu.Unlock()
 }
 
+// RegisterFallbackReader registers a reader that is only tried if none
+// of the readers registered with RegisterReader found metadata, e.g. for
+// generic metadata that is not specific to the software.
+func RegisterFallbackReader(name string, decode func(ImageMetadataContext) (StructuredMetadata, error)) {
+	formatsMu.Lock()
+	fallbacks = append(fallbacks, format{name, decode})
+	formatsMu.Unlock()
+}
+
 func Decode(ctx ImageMetadataContext) (StructuredMetadata, error) {
 	slog.Debug("Decoding metadata", "mime", ctx.MIME, "count", len(ctx.EmbeddedMetadata))
 
-	for _, format := range formats {
+	formatsMu.Lock()
+	readers := slices.Concat(formats, fallbacks)
+	formatsMu.Unlock()
+
+	var errs []error
+	var parsed bool
+	for _, format := range readers {
 		slog.Debug("Trying to decode with", "software", format.name)
-		if params, err := format.decode(ctx); err == nil {
+		params, err := format.decode(ctx)
+		if err == nil {
 			slog.Debug("Found metadata", "software", format.name)
+			params.Image = ctx.Properties
+			params.Label = ReadLabel(ctx.EmbeddedMetadata)
+			if params.Params != nil {
+				width, height := params.Params.Size()
+				params.Size = CheckSize(width, height, ctx.Properties)
+			}
 			return params, nil
 		}
+		errs = append(errs, err)
+
+		var parseErr *ParseError
+		parsed = parsed || errors.As(err, &parseErr)
 	}
 
-	return StructuredMetadata{}, fmt.Errorf("no metadata found")
+	// Report the errors of all readers, e.g. to find parse errors.
+	// Metadata that failed to parse was found, so the errors of the
+	// readers that did not find their metadata are left out.
+	if parsed {
+		errs = slices.DeleteFunc(errs, func(err error) bool {
+			var parseErr *ParseError
+			return errors.Is(err, ErrNoMetadata) && !errors.As(err, &parseErr)
+		})
+	} else {
+		errs = append([]error{ErrNoMetadata}, errs...)
+	}
+	return StructuredMetadata{}, errors.Join(errs...)
 }
@@ -1,19 +1,34 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
+// resetReaders removes the registered readers for the duration of the test.
+func resetReaders(t *testing.T) {
+	formatsMu.Lock()
+	registered, registeredFallbacks := formats, fallbacks
+	formats, fallbacks = nil, nil
+	formatsMu.Unlock()
+
+	t.Cleanup(func() {
+		formatsMu.Lock()
+		formats, fallbacks = registered, registeredFallbacks
+		formatsMu.Unlock()
+	})
+}
+
 func TestDecodeWithoutReader(t *testing.T) {
 	ctx := ImageMetadataContext{
 		Filepath: "testdata/sample.jpg",
 		MIME:     "image/jpeg",
 	}
 	_, err := Decode(ctx)
-	require.Error(t, err, "no metadata found")
+	require.ErrorIs(t, err, ErrNoMetadata)
 }
 
 func TestDecodeWithReader(t *testing.T) {
@@ -36,6 +51,20 @@ func TestDecodeWithReader(t *testing.T) {
 	require.Equal(t, source, meta.Source)
 }
 
+func TestDecodeReadsLabel(t *testing.T) {
+	RegisterReader("TestSource", func(ctx ImageMetadataContext) (StructuredMetadata, error) {
+		return StructuredMetadata{
+			Source: "TestSource",
+		}, nil
+	})
+
+	meta, err := Decode(ImageMetadataContext{
+		EmbeddedMetadata: Tags{{Namespace: NamespaceXMPIptcExt, Tag: "DigitalSourceType", Value: DigitalSourceTrainedAlgorithmicMedia}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &Label{DigitalSourceType: DigitalSourceTrainedAlgorithmicMedia}, meta.Label)
+}
+
 func TestDecodeWithMultipleReaders(t *testing.T) {
 
 	RegisterReader("TestErrorSource", func(ctx ImageMetadataContext) (StructuredMetadata, error) {
@@ -51,3 +80,67 @@ func TestDecodeWithMultipleReaders(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "TestSource", meta.Source)
 }
+
+func TestDecodeJoinsReaderErrors(t *testing.T) {
+	resetReaders(t)
+
+	parseErr := NewParseError("TestParseSource", "parameters", fmt.Errorf("invalid"))
+	RegisterReader("TestParseSource", func(ctx ImageMetadataContext) (StructuredMetadata, error) {
+		return StructuredMetadata{}, parseErr
+	})
+	RegisterReader("TestSchemeSource", func(ctx ImageMetadataContext) (StructuredMetadata, error) {
+		return StructuredMetadata{}, fmt.Errorf("TestSchemeSource: %w: a1111", ErrUnsupportedScheme)
+	})
+	RegisterReader("TestOtherSource", func(ctx ImageMetadataContext) (StructuredMetadata, error) {
+		return StructuredMetadata{}, fmt.Errorf("TestOtherSource: Parameters not found: %w", ErrNoMetadata)
+	})
+
+	_, err := Decode(ImageMetadataContext{})
+	require.NotErrorIs(t, err, ErrNoMetadata)
+	require.NotContains(t, err.Error(), "TestOtherSource")
+	require.ErrorIs(t, err, ErrUnsupportedScheme)
+
+	var target *ParseError
+	require.ErrorAs(t, err, &target)
+	require.Equal(t, "TestParseSource", target.Software)
+}
+
+func TestDecodeJoinsNoMetadata(t *testing.T) {
+	resetReaders(t)
+
+	RegisterReader("TestSchemeSource", func(ctx ImageMetadataContext) (StructuredMetadata, error) {
+		return StructuredMetadata{}, fmt.Errorf("TestSchemeSource: %w: a1111", ErrUnsupportedScheme)
+	})
+
+	_, err := Decode(ImageMetadataContext{})
+	require.ErrorIs(t, err, ErrNoMetadata)
+	require.ErrorIs(t, err, ErrUnsupportedScheme)
+
+	var target *ParseError
+	require.False(t, errors.As(err, &target))
+}
+
+func TestDecodeFallbackReader(t *testing.T) {
+	resetReaders(t)
+
+	found := true
+	RegisterFallbackReader("TestFallbackSource", func(ctx ImageMetadataContext) (StructuredMetadata, error) {
+		return StructuredMetadata{Source: "TestFallbackSource"}, nil
+	})
+	RegisterReader("TestSource", func(ctx ImageMetadataContext) (StructuredMetadata, error) {
+		if !found {
+			return StructuredMetadata{}, ErrNoMetadata
+		}
+		return StructuredMetadata{Source: "TestSource"}, nil
+	})
+
+	// Tried after the other readers, regardless of the registration order
+	meta, err := Decode(ImageMetadataContext{})
+	require.NoError(t, err)
+	require.Equal(t, "TestSource", meta.Source)
+
+	found = false
+	meta, err = Decode(ImageMetadataContext{})
+	require.NoError(t, err)
+	require.Equal(t, "TestFallbackSource", meta.Source)
+}
@@ -0,0 +1,220 @@
+package types
+
+import (
+	"strings"
+	"sync"
+)
+
+// samplerName maps a ComfyUI/Fooocus identifier to its A1111 display name.
+type samplerName struct {
+	id    string
+	a1111 string
+}
+
+// DefaultScheduler is the scheduler of A1111 sampler names without
+// a scheduler suffix, e.g. "Euler a".
+const DefaultScheduler = "normal"
+
+var (
+	samplersMu sync.RWMutex
+
+	// Sampler names as used by ComfyUI and Fooocus (k-diffusion identifiers)
+	// and their equivalent display name in AUTOMATIC1111.
+	//
+	// Where multiple identifiers map to the same A1111 name, the first
+	// entry is used for the reverse mapping.
+	//
+	// Reference implementation:
+	//   - [Fooocus flags]
+	//   - [A1111 samplers]
+	//
+	// [Fooocus flags]: https://github.com/lllyasviel/Fooocus/blob/v2.5.5/modules/flags.py#L11
+	// [A1111 samplers]: https://github.com/AUTOMATIC1111/stable-diffusion-webui/blob/v1.10.1/modules/sd_samplers_kdiffusion.py#L16
+	samplers = []samplerName{
+		{"euler", "Euler"},
+		{"euler_ancestral", "Euler a"},
+		{"heun", "Heun"},
+		{"dpm_2", "DPM2"},
+		{"dpm_2_ancestral", "DPM2 a"},
+		{"lms", "LMS"},
+		{"dpm_fast", "DPM fast"},
+		{"dpm_adaptive", "DPM adaptive"},
+		{"dpmpp_2s_ancestral", "DPM++ 2S a"},
+		{"dpmpp_sde_gpu", "DPM++ SDE"},
+		{"dpmpp_sde", "DPM++ SDE"},
+		{"dpmpp_2m", "DPM++ 2M"},
+		{"dpmpp_2m_sde_gpu", "DPM++ 2M SDE"},
+		{"dpmpp_2m_sde", "DPM++ 2M SDE"},
+		{"dpmpp_3m_sde_gpu", "DPM++ 3M SDE"},
+		{"dpmpp_3m_sde", "DPM++ 3M SDE"},
+		{"ddpm", "DDPM"},
+		{"lcm", "LCM"},
+		{"tcd", "TCD"},
+		{"restart", "Restart"},
+		{"ddim", "DDIM"},
+		{"uni_pc", "UniPC"},
+		{"uni_pc_bh2", "UniPC"},
+	}
+
+	// Scheduler names as used by ComfyUI and Fooocus and their equivalent
+	// A1111 "Schedule type", which older A1111 versions append to the
+	// sampler name, e.g. "DPM++ 2M Karras".
+	//
+	// A1111 "Automatic" uses the default schedule of the sampler and maps
+	// to the default scheduler. A1111 "Uniform" has no ComfyUI equivalent
+	// and keeps the A1111 identifier.
+	//
+	// Reference implementation:
+	//   - [A1111 schedulers]
+	//
+	// [A1111 schedulers]: https://github.com/AUTOMATIC1111/stable-diffusion-webui/blob/v1.10.1/modules/sd_schedulers.py
+	schedulers = []samplerName{
+		{"normal", "Normal"},
+		{DefaultScheduler, "Automatic"},
+		{"uniform", "Uniform"},
+		{"karras", "Karras"},
+		{"exponential", "Exponential"},
+		{"polyexponential", "Polyexponential"},
+		{"sgm_uniform", "SGM Uniform"},
+		{"kl_optimal", "KL Optimal"},
+		{"simple", "Simple"},
+		{"ddim_uniform", "DDIM"},
+		{"beta", "Beta"},
+		{"align_your_steps", "Align Your Steps"},
+	}
+)
+
+// RegisterSampler adds the A1111 display name of a sampler identifier,
+// e.g. for samplers of extensions. Existing entries take precedence.
+func RegisterSampler(id string, a1111 string) {
+	samplersMu.Lock()
+	samplers = append(samplers, samplerName{id, a1111})
+	samplersMu.Unlock()
+}
+
+// RegisterScheduler adds the A1111 display name of a scheduler identifier.
+// Existing entries take precedence.
+func RegisterScheduler(id string, a1111 string) {
+	samplersMu.Lock()
+	schedulers = append(schedulers, samplerName{id, a1111})
+	samplersMu.Unlock()
+}
+
+// NormaliseSampler returns the identifier of a sampler given either its
+// identifier or A1111 display name, e.g. "Euler a" to "euler_ancestral".
+// Identifiers of the same sampler are unified, e.g. "dpmpp_2m_sde" of
+// ComfyUI to "dpmpp_2m_sde_gpu" of Fooocus.
+//
+// The boolean result is false if the name is unknown, in which
+// case it is returned as is.
+func NormaliseSampler(name string) (string, bool) {
+	return normalise(&samplers, name)
+}
+
+// NormaliseScheduler returns the identifier of a scheduler given either its
+// identifier or A1111 display name, e.g. "SGM Uniform" to "sgm_uniform".
+//
+// The boolean result is false if the name is unknown, in which
+// case it is returned as is.
+func NormaliseScheduler(name string) (string, bool) {
+	return normalise(&schedulers, name)
+}
+
+// A1111Sampler returns the A1111 display name of a sampler identifier,
+// e.g. "dpmpp_2m_sde_gpu" to "DPM++ 2M SDE".
+func A1111Sampler(sampler string) (string, bool) {
+	return lookup(&samplers, sampler, false)
+}
+
+// A1111Scheduler returns the A1111 display name of a scheduler identifier,
+// e.g. "karras" to "Karras".
+func A1111Scheduler(scheduler string) (string, bool) {
+	return lookup(&schedulers, scheduler, false)
+}
+
+// JoinA1111Sampler combines a sampler and scheduler identifier into the
+// A1111 sampler name, e.g. "dpmpp_2m_sde_gpu" and "karras" to
+// "DPM++ 2M SDE Karras". The default scheduler does not add a suffix.
+//
+// The boolean result is false if either name is unknown, in which
+// case the identifiers are returned as is.
+func JoinA1111Sampler(sampler string, scheduler string) (string, bool) {
+	name, ok := A1111Sampler(sampler)
+	if !ok {
+		return strings.TrimSpace(sampler + " " + scheduler), false
+	}
+	if strings.EqualFold(scheduler, DefaultScheduler) {
+		return name, true
+	}
+	suffix, ok := A1111Scheduler(scheduler)
+	if !ok {
+		return strings.TrimSpace(name + " " + scheduler), false
+	}
+	return name + " " + suffix, true
+}
+
+// SplitA1111Sampler splits an A1111 sampler name into the sampler and
+// scheduler identifier, e.g. "DPM++ 2M SDE Karras" to "dpmpp_2m_sde_gpu"
+// and "karras". Names without a scheduler suffix use the default scheduler.
+//
+// The boolean result is false if the name could not be mapped.
+func SplitA1111Sampler(name string) (sampler string, scheduler string, ok bool) {
+	samplersMu.RLock()
+	defer samplersMu.RUnlock()
+
+	// Find the longest sampler name that is a prefix of the name,
+	// followed by the scheduler suffix if any
+	var match samplerName
+	for _, s := range samplers {
+		rest, found := strings.CutPrefix(name, s.a1111)
+		if !found || (rest != "" && rest[0] != ' ') {
+			continue
+		}
+		if len(s.a1111) > len(match.a1111) {
+			match = s
+		}
+	}
+	if match.a1111 == "" {
+		return name, "", false
+	}
+
+	suffix := strings.TrimSpace(strings.TrimPrefix(name, match.a1111))
+	if suffix == "" {
+		return match.id, DefaultScheduler, true
+	}
+	for _, s := range schedulers {
+		if strings.EqualFold(s.a1111, suffix) {
+			return match.id, s.id, true
+		}
+	}
+	return match.id, suffix, false
+}
+
+// normalise maps an identifier or display name to the identifier of
+// the first entry with the same display name.
+func normalise(table *[]samplerName, name string) (string, bool) {
+	if a1111, ok := lookup(table, name, false); ok {
+		name = a1111
+	}
+	if id, ok := lookup(table, name, true); ok {
+		return id, true
+	}
+	return name, false
+}
+
+// lookup finds the first entry whose identifier, or display name if
+// reverse is set, matches key, and returns the other name.
+func lookup(table *[]samplerName, key string, reverse bool) (string, bool) {
+	samplersMu.RLock()
+	defer samplersMu.RUnlock()
+
+	for _, entry := range *table {
+		if reverse && strings.EqualFold(entry.a1111, key) {
+			return entry.id, true
+		}
+		if !reverse && strings.EqualFold(entry.id, key) {
+			return entry.a1111, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,153 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinA1111Sampler(t *testing.T) {
+	testCases := []struct {
+		sampler   string
+		scheduler string
+		expected  string
+		ok        bool
+	}{
+		{"dpmpp_2m_sde_gpu", "karras", "DPM++ 2M SDE Karras", true},
+		{"euler_ancestral", "normal", "Euler a", true},
+		{"euler", "uniform", "Euler Uniform", true},
+		{"dpmpp_2m_sde", "karras", "DPM++ 2M SDE Karras", true},
+		{"euler", "sgm_uniform", "Euler SGM Uniform", true},
+		{"lcm", "lcm", "LCM lcm", false},
+		{"unknown", "karras", "unknown karras", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			name, ok := JoinA1111Sampler(tc.sampler, tc.scheduler)
+			assert.Equal(t, tc.expected, name)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestSplitA1111Sampler(t *testing.T) {
+	testCases := []struct {
+		name      string
+		sampler   string
+		scheduler string
+		ok        bool
+	}{
+		{"DPM++ 2M SDE Karras", "dpmpp_2m_sde_gpu", "karras", true},
+		{"DPM++ 2M Karras", "dpmpp_2m", "karras", true},
+		{"DPM++ 2M", "dpmpp_2m", "normal", true},
+		{"Euler a", "euler_ancestral", "normal", true},
+		{"Euler", "euler", "normal", true},
+		{"Euler Uniform", "euler", "uniform", true},
+		{"Euler Automatic", "euler", "normal", true},
+		{"DPM2 a Karras", "dpm_2_ancestral", "karras", true},
+		{"DPM++ 2M SDE Heun", "dpmpp_2m_sde_gpu", "Heun", false},
+		{"dpm++2mv2 karras", "dpm++2mv2 karras", "", false},
+		{"Euler ancestral", "euler", "ancestral", false},
+		{"LMSKarras", "LMSKarras", "", false},
+		{"DPM++ 2M SDEKarras", "dpmpp_2m", "SDEKarras", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sampler, scheduler, ok := SplitA1111Sampler(tc.name)
+			assert.Equal(t, tc.sampler, sampler)
+			assert.Equal(t, tc.scheduler, scheduler)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestNormaliseSampler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"dpmpp_2m_sde_gpu", "dpmpp_2m_sde_gpu", true},
+		{"dpmpp_2m_sde", "dpmpp_2m_sde_gpu", true},
+		{"DPMPP_2M_SDE", "dpmpp_2m_sde_gpu", true},
+		{"dpmpp_sde", "dpmpp_sde_gpu", true},
+		{"Euler a", "euler_ancestral", true},
+		{"DPM++ 2M SDE", "dpmpp_2m_sde_gpu", true},
+		{"UniPC", "uni_pc", true},
+		{"unknown", "unknown", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sampler, ok := NormaliseSampler(tc.name)
+			assert.Equal(t, tc.expected, sampler)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestNormaliseScheduler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"karras", "karras", true},
+		{"Karras", "karras", true},
+		{"SGM Uniform", "sgm_uniform", true},
+		{"Normal", "normal", true},
+		{"Uniform", "uniform", true},
+		{"uniform", "uniform", true},
+		{"DDIM", "ddim_uniform", true},
+		{"Automatic", "normal", true},
+		{"automatic", "normal", true},
+		{"turbo", "turbo", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			scheduler, ok := NormaliseScheduler(tc.name)
+			assert.Equal(t, tc.expected, scheduler)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestA1111Names(t *testing.T) {
+	name, ok := A1111Sampler("dpmpp_3m_sde_gpu")
+	assert.True(t, ok)
+	assert.Equal(t, "DPM++ 3M SDE", name)
+
+	name, ok = A1111Scheduler("align_your_steps")
+	assert.True(t, ok)
+	assert.Equal(t, "Align Your Steps", name)
+
+	name, ok = A1111Scheduler("normal")
+	assert.True(t, ok)
+	assert.Equal(t, "Normal", name)
+
+	name, ok = A1111Scheduler("uniform")
+	assert.True(t, ok)
+	assert.Equal(t, "Uniform", name)
+
+	_, ok = A1111Scheduler("turbo")
+	assert.False(t, ok)
+}
+
+func TestRegisterSampler(t *testing.T) {
+	RegisterSampler("test_sampler", "Test Sampler")
+	RegisterScheduler("test_scheduler", "Test Scheduler")
+	// Existing entries take precedence
+	RegisterSampler("test_euler", "Euler")
+
+	sampler, scheduler, ok := SplitA1111Sampler("Test Sampler Test Scheduler")
+	assert.True(t, ok)
+	assert.Equal(t, "test_sampler", sampler)
+	assert.Equal(t, "test_scheduler", scheduler)
+
+	sampler, ok = NormaliseSampler("Euler")
+	assert.True(t, ok)
+	assert.Equal(t, "euler", sampler)
+}
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SeedRange describes the seeds of a tool.
+type SeedRange struct {
+	// Largest valid seed, seeds of a batch wrap around after it.
+	Max uint64
+	// Value that selects a random seed, e.g. "-1" in A1111,
+	// empty if the tool always records the seed it used.
+	Random string
+}
+
+// Parse parses a seed as recorded by the tool.
+func (r SeedRange) Parse(s string) (Seed, error) {
+	s = strings.TrimSpace(s)
+	if r.Random != "" && s == r.Random {
+		return Seed{Random: true, Range: r}, nil
+	}
+
+	value, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return Seed{}, fmt.Errorf("invalid seed: %q", s)
+	}
+	if value > r.Max {
+		return Seed{}, fmt.Errorf("seed out of range 0-%d: %d", r.Max, value)
+	}
+	return Seed{Value: value, Range: r}, nil
+}
+
+// Seed is the seed of a generation.
+type Seed struct {
+	// Value of the seed, zero for random seeds.
+	Value uint64
+	// Random is set if the tool was asked to pick a random
+	// seed, and the seed it picked is not known.
+	Random bool
+	// Range of the seeds of the tool.
+	Range SeedRange
+}
+
+// IsZero returns true if the seed is not known.
+func (s Seed) IsZero() bool {
+	return s == Seed{}
+}
+
+// Offset returns the seed of the image at index i of a batch that starts
+// with this seed, or that of the first image for negative indices. Like
+// Fooocus, seeds are incremented and wrap around after the largest seed
+// of the tool. Random seeds stay random.
+func (s Seed) Offset(i int64) Seed {
+	if s.Random || s.IsZero() || i <= 0 {
+		return s
+	}
+	if s.Range.Max == math.MaxUint64 {
+		s.Value += uint64(i)
+		return s
+	}
+	n := s.Range.Max + 1
+	s.Value = addModulo(s.Value%n, uint64(i)%n, n)
+	return s
+}
+
+// First returns the seed of the first image of a batch given this seed
+// of the image at index i, the inverse of Offset. Negative indices
+// return this seed.
+func (s Seed) First(i int64) Seed {
+	if s.Random || s.IsZero() || i <= 0 {
+		return s
+	}
+	if s.Range.Max == math.MaxUint64 {
+		s.Value -= uint64(i)
+		return s
+	}
+	// Subtract by adding n-i modulo n
+	n := s.Range.Max + 1
+	s.Value = addModulo(s.Value%n, (n-uint64(i)%n)%n, n)
+	return s
+}
+
+// addModulo returns a+b modulo n without overflow, for a, b < n.
+func addModulo(a uint64, b uint64, n uint64) uint64 {
+	if a >= n-b {
+		return a - (n - b)
+	}
+	return a + b
+}
+
+// Batch returns the seeds of a batch of n images starting with this seed,
+// see Offset, or nil if the seed is not known.
+func (s Seed) Batch(n int) []Seed {
+	if s.IsZero() {
+		return nil
+	}
+	seeds := make([]Seed, max(n, 0))
+	for i := range seeds {
+		seeds[i] = s.Offset(int64(i))
+	}
+	return seeds
+}
+
+// String returns the seed as recorded by the tool, e.g. "1234" or "-1"
+// for random seeds. Unknown seeds are empty.
+func (s Seed) String() string {
+	if s.IsZero() {
+		return ""
+	}
+	if s.Random {
+		return s.Range.Random
+	}
+	return strconv.FormatUint(s.Value, 10)
+}
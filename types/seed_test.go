@@ -0,0 +1,130 @@
+package types
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	fooocusSeeds = SeedRange{Max: math.MaxInt64}
+	a1111Seeds   = SeedRange{Max: math.MaxInt64, Random: "-1"}
+)
+
+func TestSeedRange_Parse(t *testing.T) {
+	testCases := []struct {
+		seeds    SeedRange
+		in       string
+		expected Seed
+	}{
+		{fooocusSeeds, "0", Seed{Value: 0, Range: fooocusSeeds}},
+		{fooocusSeeds, "127589946317439009", Seed{Value: 127589946317439009, Range: fooocusSeeds}},
+		{fooocusSeeds, " 42 ", Seed{Value: 42, Range: fooocusSeeds}},
+		{fooocusSeeds, "9223372036854775807", Seed{Value: math.MaxInt64, Range: fooocusSeeds}},
+		{a1111Seeds, "-1", Seed{Random: true, Range: a1111Seeds}},
+		{SeedRange{Max: math.MaxUint64}, "18446744073709551615", Seed{Value: math.MaxUint64, Range: SeedRange{Max: math.MaxUint64}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			seed, err := tc.seeds.Parse(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, seed)
+			assert.False(t, seed.IsZero())
+		})
+	}
+
+	for _, invalid := range []string{"", "abc", "-1", "-2", "1.5", "9223372036854775808"} {
+		t.Run("invalid "+invalid, func(t *testing.T) {
+			seed, err := fooocusSeeds.Parse(invalid)
+			assert.Error(t, err)
+			assert.True(t, seed.IsZero())
+		})
+	}
+
+	_, err := a1111Seeds.Parse("-2")
+	assert.Error(t, err)
+}
+
+func TestSeed_Offset(t *testing.T) {
+	testCases := []struct {
+		name     string
+		seed     Seed
+		offset   int64
+		expected uint64
+	}{
+		{"increment", Seed{Value: 1234, Range: fooocusSeeds}, 3, 1237},
+		{"negative", Seed{Value: 1234, Range: fooocusSeeds}, -4, 1234},
+		{"wrap", Seed{Value: math.MaxInt64, Range: fooocusSeeds}, 1, 0},
+		{"negative at zero", Seed{Value: 0, Range: fooocusSeeds}, -2, 0},
+		{"wrap small range", Seed{Value: 8, Range: SeedRange{Max: 9}}, 25, 3},
+		{"min offset", Seed{Value: 5, Range: SeedRange{Max: 9}}, math.MinInt64, 5},
+		{"max offset", Seed{Value: 5, Range: SeedRange{Max: 9}}, math.MaxInt64, 2},
+		{"full range", Seed{Value: math.MaxUint64, Range: SeedRange{Max: math.MaxUint64}}, 1, 0},
+		{"large range", Seed{Value: math.MaxUint64 - 2, Range: SeedRange{Max: math.MaxUint64 - 1}}, 3, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			seed := tc.seed.Offset(tc.offset)
+			assert.Equal(t, tc.expected, seed.Value)
+			assert.Equal(t, tc.seed.Range, seed.Range)
+		})
+	}
+
+	random := Seed{Random: true, Range: a1111Seeds}
+	assert.Equal(t, random, random.Offset(1))
+	assert.Equal(t, Seed{}, Seed{}.Offset(1))
+}
+
+func TestSeed_First(t *testing.T) {
+	testCases := []struct {
+		name     string
+		seed     Seed
+		index    int64
+		expected uint64
+	}{
+		{"decrement", Seed{Value: 1234, Range: fooocusSeeds}, 4, 1230},
+		{"first", Seed{Value: 1234, Range: fooocusSeeds}, 0, 1234},
+		{"negative", Seed{Value: 1234, Range: fooocusSeeds}, -4, 1234},
+		{"wrap below zero", Seed{Value: 1, Range: fooocusSeeds}, 2, math.MaxInt64},
+		{"wrap small range", Seed{Value: 3, Range: SeedRange{Max: 9}}, 25, 8},
+		{"max index", Seed{Value: 2, Range: SeedRange{Max: 9}}, math.MaxInt64, 5},
+		{"full range", Seed{Value: 0, Range: SeedRange{Max: math.MaxUint64}}, 1, math.MaxUint64},
+		{"large range", Seed{Value: 1, Range: SeedRange{Max: math.MaxUint64 - 1}}, 3, math.MaxUint64 - 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			seed := tc.seed.First(tc.index)
+			assert.Equal(t, tc.expected, seed.Value)
+			assert.Equal(t, tc.seed, seed.Offset(tc.index))
+		})
+	}
+
+	random := Seed{Random: true, Range: fooocusSeeds}
+	assert.Equal(t, random, random.First(1))
+	assert.Equal(t, Seed{}, Seed{}.First(1))
+}
+
+func TestSeed_Batch(t *testing.T) {
+	seed := Seed{Value: math.MaxInt64 - 1, Range: fooocusSeeds}
+
+	assert.Equal(t, []Seed{
+		{Value: math.MaxInt64 - 1, Range: fooocusSeeds},
+		{Value: math.MaxInt64, Range: fooocusSeeds},
+		{Value: 0, Range: fooocusSeeds},
+	}, seed.Batch(3))
+	assert.Empty(t, seed.Batch(0))
+	assert.Empty(t, seed.Batch(-1))
+	assert.Nil(t, Seed{}.Batch(3))
+}
+
+func TestSeed_String(t *testing.T) {
+	assert.Equal(t, "1234", Seed{Value: 1234, Range: fooocusSeeds}.String())
+	assert.Equal(t, "0", Seed{Range: fooocusSeeds}.String())
+	assert.Equal(t, "-1", Seed{Random: true, Range: a1111Seeds}.String())
+	assert.Equal(t, "", Seed{}.String())
+}
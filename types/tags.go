@@ -0,0 +1,85 @@
+package types
+
+import (
+	"strings"
+
+	"github.com/bep/imagemeta"
+)
+
+// Namespaces of embedded tags. A namespace also matches the namespaces
+// nested below it, e.g. NamespaceEXIF matches "IFD0/ExifIFDP" and
+// NamespacePNG matches "PNG/iTXt".
+const (
+	// EXIF tags of IFD0 and its sub-IFDs.
+	NamespaceEXIF = "IFD0"
+	// PNG text chunks of any type.
+	NamespacePNG = "PNG"
+	// PNG uncompressed Latin-1 text chunks.
+	NamespacePNGText = "PNG/tEXt"
+	// PNG compressed Latin-1 text chunks.
+	NamespacePNGCompressedText = "PNG/zTXt"
+	// PNG international UTF-8 text chunks.
+	NamespacePNGInternationalText = "PNG/iTXt"
+	// PNG last modification time chunk.
+	NamespacePNGTime = "PNG/tIME"
+	// GIF comment extensions, see GifCommentTag.
+	NamespaceGIFComment = "GIF/Comment"
+	// XMP properties of any schema. The namespace of an XMP property
+	// is "XMP/" followed by the namespace URI of its schema.
+	NamespaceXMP = "XMP"
+	// XMP Dublin Core schema, e.g. "description", "title" and "subject".
+	NamespaceXMPDublinCore = "XMP/http://purl.org/dc/elements/1.1/"
+	// XMP basic schema, e.g. "CreatorTool" and "CreateDate".
+	NamespaceXMPBasic = "XMP/http://ns.adobe.com/xap/1.0/"
+	// XMP rights management schema, e.g. "UsageTerms".
+	NamespaceXMPRights = "XMP/http://ns.adobe.com/xap/1.0/rights/"
+	// Photoshop schema, e.g. "Headline" and "Credit".
+	NamespaceXMPPhotoshop = "XMP/http://ns.adobe.com/photoshop/1.0/"
+	// IPTC Extension schema, e.g. "DigitalSourceType".
+	NamespaceXMPIptcExt = "XMP/http://iptc.org/std/Iptc4xmpExt/2008-02-29/"
+)
+
+// PngXMPKeyword is the keyword of the PNG iTXt chunk with the XMP packet.
+const PngXMPKeyword = "XML:com.adobe.xmp"
+
+// GifCommentTag is the name of the tags of GIF comment extensions, which
+// have no name of their own. Each comment is a separate tag.
+const GifCommentTag = "Comment"
+
+// Tags are the tags embedded in an image, in the order they were read.
+// Tags with the same name may exist in several namespaces, and several
+// times in the same namespace, e.g. in multiple PNG text chunks.
+type Tags []imagemeta.TagInfo
+
+// Add appends a tag.
+func (t *Tags) Add(tag imagemeta.TagInfo) {
+	*t = append(*t, tag)
+}
+
+// Lookup returns all tags with the given name in the given namespace.
+func (t Tags) Lookup(namespace string, name string) []imagemeta.TagInfo {
+	var tags []imagemeta.TagInfo
+	for _, tag := range t {
+		if tag.Tag == name && inNamespace(tag.Namespace, namespace) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Get returns the first tag with the given name in the given namespace,
+// or false if the tag does not exist.
+func (t Tags) Get(namespace string, name string) (imagemeta.TagInfo, bool) {
+	for _, tag := range t {
+		if tag.Tag == name && inNamespace(tag.Namespace, namespace) {
+			return tag, true
+		}
+	}
+	return imagemeta.TagInfo{}, false
+}
+
+// inNamespace returns true if namespace is the expected namespace or
+// nested below it.
+func inNamespace(namespace string, expected string) bool {
+	return namespace == expected || strings.HasPrefix(namespace, expected+"/")
+}
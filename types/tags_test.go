@@ -0,0 +1,36 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/bep/imagemeta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags_Lookup(t *testing.T) {
+	ifd0 := imagemeta.TagInfo{Source: imagemeta.EXIF, Namespace: "IFD0", Tag: "UserComment", Value: "ifd0"}
+	exifIFD := imagemeta.TagInfo{Source: imagemeta.EXIF, Namespace: "IFD0/ExifIFDP", Tag: "UserComment", Value: "exif"}
+	text := imagemeta.TagInfo{Namespace: NamespacePNGText, Tag: "parameters", Value: "tEXt"}
+	itxt := imagemeta.TagInfo{Namespace: NamespacePNGInternationalText, Tag: "parameters", Value: "iTXt"}
+	xmp := imagemeta.TagInfo{Source: imagemeta.XMP, Namespace: "http://ns.adobe.com/exif/1.0/", Tag: "UserComment", Value: "xmp"}
+
+	var tags Tags
+	for _, tag := range []imagemeta.TagInfo{ifd0, exifIFD, text, itxt, xmp} {
+		tags.Add(tag)
+	}
+
+	assert.Equal(t, []imagemeta.TagInfo{ifd0, exifIFD}, tags.Lookup(NamespaceEXIF, "UserComment"))
+	assert.Equal(t, []imagemeta.TagInfo{exifIFD}, tags.Lookup("IFD0/ExifIFDP", "UserComment"))
+	assert.Equal(t, []imagemeta.TagInfo{text, itxt}, tags.Lookup(NamespacePNG, "parameters"))
+	assert.Equal(t, []imagemeta.TagInfo{itxt}, tags.Lookup(NamespacePNGInternationalText, "parameters"))
+	assert.Equal(t, []imagemeta.TagInfo{xmp}, tags.Lookup("http://ns.adobe.com/exif/1.0/", "UserComment"))
+	assert.Empty(t, tags.Lookup(NamespacePNG, "UserComment"))
+	assert.Empty(t, tags.Lookup("IFD", "UserComment"))
+
+	tag, ok := tags.Get(NamespacePNG, "parameters")
+	assert.True(t, ok)
+	assert.Equal(t, text, tag)
+
+	_, ok = tags.Get(NamespaceEXIF, "parameters")
+	assert.False(t, ok)
+}
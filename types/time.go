@@ -0,0 +1,132 @@
+package types
+
+import (
+	"time"
+
+	"github.com/bep/imagemeta"
+)
+
+// TimeSource identifies where the creation time of an image was found.
+type TimeSource string
+
+const (
+	// Date and time in the file name, e.g. "2024-01-05_23-11-48_9167.png",
+	// see FilenamePattern.
+	TimeFromFilename TimeSource = "filename"
+	// EXIF DateTimeOriginal, with the zone from OffsetTimeOriginal if present.
+	TimeFromEXIF TimeSource = "exif"
+	// PNG tIME chunk, which records the last modification in UTC.
+	TimeFromPNG TimeSource = "png"
+	// Date in the title of the private log, without time of day.
+	TimeFromPrivateLog TimeSource = "log"
+	// Modification time of the image file.
+	TimeFromModTime TimeSource = "mtime"
+)
+
+// DefaultTimeSources is the default order in which the
+// sources of the creation time are considered.
+var DefaultTimeSources = []TimeSource{
+	TimeFromFilename,
+	TimeFromEXIF,
+	TimeFromPNG,
+	TimeFromPrivateLog,
+	TimeFromModTime,
+}
+
+// Name of the pseudo tag holding the PNG tIME chunk
+// in the embedded metadata, with a time.Time value.
+const PngTimeTag = "tIME"
+
+// TimeOptions configure how the creation time of an image is resolved.
+type TimeOptions struct {
+	// Sources to consider, in order of precedence.
+	// Defaults to DefaultTimeSources if nil.
+	Sources []TimeSource
+	// Location of times without zone information, such as the date in
+	// the file name. Fooocus uses local time, so this defaults to time.Local.
+	Location *time.Location
+}
+
+// Loc returns the configured location, or time.Local.
+func (o TimeOptions) Loc() *time.Location {
+	if o.Location == nil {
+		return time.Local
+	}
+	return o.Location
+}
+
+func (o TimeOptions) sources() []TimeSource {
+	if o.Sources == nil {
+		return DefaultTimeSources
+	}
+	return o.Sources
+}
+
+const (
+	exifDateLayout   = "2006:01:02 15:04:05"
+	exifOffsetLayout = "-07:00"
+)
+
+// ResolveCreated returns the creation time of the image from the first
+// configured source that provides one, and the source it was found in.
+//
+// The date of the private log is only considered if logDate is not zero,
+// i.e. if the metadata was read from the private log.
+//
+// The time is returned in the configured location, except for EXIF times
+// with an OffsetTimeOriginal, which keep the recorded offset.
+func (e *FileMetadataExtractor) ResolveCreated(file ImageMetadataContext, logDate time.Time) (time.Time, TimeSource) {
+	loc := file.Time.Loc()
+
+	for _, source := range file.Time.sources() {
+		var created time.Time
+		switch source {
+		case TimeFromFilename:
+			created = e.ParseFilename(file).Date
+		case TimeFromEXIF:
+			// Already in the recorded zone, or else the location
+			if created = exifDateTime(file.EmbeddedMetadata, loc); !created.IsZero() {
+				return created, source
+			}
+		case TimeFromPNG:
+			if tag, ok := file.EmbeddedMetadata.Get(NamespacePNGTime, PngTimeTag); ok {
+				created, _ = tag.Value.(time.Time)
+			}
+		case TimeFromPrivateLog:
+			created = logDate
+		case TimeFromModTime:
+			created = file.ModTime
+		}
+		if !created.IsZero() {
+			return created.In(loc), source
+		}
+	}
+	return time.Time{}, ""
+}
+
+// exifDateTime parses the EXIF DateTimeOriginal tag, using the zone of the
+// OffsetTimeOriginal tag if present, or the given location otherwise.
+func exifDateTime(data Tags, loc *time.Location) time.Time {
+	tag, ok := data.Get(NamespaceEXIF, "DateTimeOriginal")
+	if !ok || tag.Source != imagemeta.EXIF {
+		return time.Time{}
+	}
+	value, ok := tag.Value.(string)
+	if !ok {
+		return time.Time{}
+	}
+
+	if offsetTag, ok := data.Get(NamespaceEXIF, "OffsetTimeOriginal"); ok {
+		if offset, ok := offsetTag.Value.(string); ok {
+			if zone, err := time.Parse(exifOffsetLayout, offset); err == nil {
+				loc = zone.Location()
+			}
+		}
+	}
+
+	created, err := time.ParseInLocation(exifDateLayout, value, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return created
+}
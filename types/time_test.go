@@ -0,0 +1,105 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bep/imagemeta"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveCreated(t *testing.T) {
+	extractor := &FileMetadataExtractor{
+		FilenamePatterns: []*FilenamePattern{MustFilenameTemplate("{date}_{time}{*}")},
+	}
+	loc := time.FixedZone("UTC+2", 2*60*60)
+
+	exif := Tags{
+		{Source: imagemeta.EXIF, Namespace: "IFD0/ExifIFDP", Tag: "DateTimeOriginal", Value: "2024:01:05 23:11:48"},
+	}
+	exifOffset := Tags{
+		{Source: imagemeta.EXIF, Namespace: "IFD0/ExifIFDP", Tag: "DateTimeOriginal", Value: "2024:01:05 23:11:48"},
+		{Source: imagemeta.EXIF, Namespace: "IFD0/ExifIFDP", Tag: "OffsetTimeOriginal", Value: "-05:00"},
+	}
+	png := Tags{
+		{Namespace: NamespacePNGTime, Tag: PngTimeTag, Value: time.Date(2024, 1, 5, 22, 0, 0, 0, time.UTC)},
+	}
+	// Not an EXIF tag, e.g. PNG tEXt
+	text := Tags{
+		{Namespace: NamespacePNGText, Tag: "DateTimeOriginal", Value: "2024:01:05 23:11:48"},
+	}
+	mtime := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+	logDate := time.Date(2024, 1, 5, 0, 0, 0, 0, loc)
+
+	testCases := []struct {
+		name     string
+		file     ImageMetadataContext
+		logDate  time.Time
+		expected time.Time
+		source   TimeSource
+	}{
+		{
+			"filename",
+			ImageMetadataContext{Filepath: "/out/2024-01-05_23-11-48_9167.png", EmbeddedMetadata: exif, ModTime: mtime},
+			logDate, time.Date(2024, 1, 5, 23, 11, 48, 0, loc), TimeFromFilename,
+		},
+		{
+			"exif",
+			ImageMetadataContext{Filepath: "/out/image.jpg", EmbeddedMetadata: exif, ModTime: mtime},
+			logDate, time.Date(2024, 1, 5, 23, 11, 48, 0, loc), TimeFromEXIF,
+		},
+		{
+			"exif with offset",
+			ImageMetadataContext{Filepath: "/out/image.jpg", EmbeddedMetadata: exifOffset},
+			time.Time{}, time.Date(2024, 1, 5, 23, 11, 48, 0, time.FixedZone("", -5*60*60)), TimeFromEXIF,
+		},
+		{
+			"png",
+			ImageMetadataContext{Filepath: "/out/image.png", EmbeddedMetadata: png, ModTime: mtime},
+			logDate, time.Date(2024, 1, 5, 22, 0, 0, 0, time.UTC), TimeFromPNG,
+		},
+		{
+			"log",
+			ImageMetadataContext{Filepath: "/out/image.png", EmbeddedMetadata: text, ModTime: mtime},
+			logDate, logDate, TimeFromPrivateLog,
+		},
+		{
+			"mtime",
+			ImageMetadataContext{Filepath: "/out/image.png", ModTime: mtime},
+			time.Time{}, mtime, TimeFromModTime,
+		},
+		{
+			"none",
+			ImageMetadataContext{},
+			time.Time{}, time.Time{}, "",
+		},
+		{
+			"custom order",
+			ImageMetadataContext{Filepath: "/out/2024-01-05_23-11-48_9167.png", ModTime: mtime,
+				Time: TimeOptions{Sources: []TimeSource{TimeFromModTime, TimeFromFilename}}},
+			time.Time{}, mtime, TimeFromModTime,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.file.Time.Location == nil {
+				tc.file.Time.Location = loc
+			}
+			created, source := extractor.ResolveCreated(tc.file, tc.logDate)
+			assert.True(t, tc.expected.Equal(created), "expected %s, got %s", tc.expected, created)
+			assert.Equal(t, tc.source, source)
+			if tc.source == TimeFromEXIF {
+				// The recorded offset is kept
+				assert.Equal(t, tc.expected.Format(time.RFC3339), created.Format(time.RFC3339))
+			} else if !created.IsZero() {
+				assert.Equal(t, loc, created.Location())
+			}
+		})
+	}
+}
+
+func TestTimeOptions_Loc(t *testing.T) {
+	assert.Equal(t, time.Local, TimeOptions{}.Loc())
+	assert.Equal(t, time.UTC, TimeOptions{Location: time.UTC}.Loc())
+}
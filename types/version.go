@@ -0,0 +1,234 @@
+package types
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SoftwareVersion is the parsed version of the software that generated
+// the metadata. Versions are recorded in several shapes, e.g.:
+//
+//   - "Fooocus v2.5.5" or "v2.1.865" (Fooocus)
+//   - "FooocusPlus 1.0.0"
+//   - "RuinedFooocus" (no version)
+//   - "v1.10.1" or "f2.0.1v1.10.1-previous" (A1111 and Forge)
+type SoftwareVersion struct {
+	// Name of the product, e.g. "Fooocus", empty if not recorded.
+	Product string `json:"product,omitempty"`
+	// Semantic version with major, minor and patch, e.g. "2.5.5",
+	// empty if not recorded.
+	Version string `json:"version,omitempty"`
+	// Version of the fork the product is based on, e.g. "f2.0.1" for
+	// Forge, which records the version of A1111 it is based on.
+	Fork string `json:"fork,omitempty"`
+	// Suffix after the semantic version, e.g. "previous" or the
+	// fourth component of "2.0.78.5".
+	Build string `json:"build,omitempty"`
+}
+
+var (
+	// Version of Forge, followed by the version of A1111.
+	forkVersion = regexp.MustCompile(`^(f\d+(?:\.\d+)*)(v\d.*)$`)
+	// Semantic version with optional "v" prefix and build suffix.
+	semVersion = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:[.\-+](.+))?$`)
+)
+
+// ParseSoftwareVersion parses a version as recorded in the metadata.
+// The product is the text before the version, which is empty for
+// versions such as "v2.1.865". Text without a version is taken as
+// the product, e.g. "RuinedFooocus".
+func ParseSoftwareVersion(s string) (v SoftwareVersion) {
+	s = strings.TrimSpace(s)
+
+	product, version := "", s
+	if i := strings.LastIndex(s, " "); i >= 0 {
+		product, version = strings.TrimSpace(s[:i]), s[i+1:]
+	}
+
+	if m := forkVersion.FindStringSubmatch(version); m != nil {
+		v.Fork, version = m[1], m[2]
+	}
+
+	m := semVersion.FindStringSubmatch(version)
+	if m == nil {
+		return SoftwareVersion{Product: s}
+	}
+
+	v.Product = product
+	v.Version = fmt.Sprintf("%s.%s.%s", m[1], cmp.Or(m[2], "0"), cmp.Or(m[3], "0"))
+	v.Build = m[4]
+	return v
+}
+
+// WithProduct returns the version with the given product
+// if it does not record one, e.g. for "v2.1.865".
+func (v SoftwareVersion) WithProduct(product string) SoftwareVersion {
+	if v.Product == "" {
+		v.Product = product
+	}
+	return v
+}
+
+// IsZero returns true if neither product nor version are known.
+func (v SoftwareVersion) IsZero() bool {
+	return v == SoftwareVersion{}
+}
+
+// String returns the normalised version, e.g. "Fooocus 2.5.5"
+// or "A1111 f2.0.1v1.10.1-previous".
+func (v SoftwareVersion) String() string {
+	version := v.Fork
+	if v.Version != "" {
+		if v.Fork != "" {
+			version += "v"
+		}
+		version += v.Version
+	}
+	if v.Build != "" {
+		version += "-" + v.Build
+	}
+	return strings.TrimSpace(v.Product + " " + version)
+}
+
+// Compare returns -1, 0 or +1 depending on whether the semantic version
+// of v is less than, equal to or greater than the one of other.
+// Unknown versions are less than any known version. The product is
+// not compared, and builds are only compared if the versions are equal,
+// see compareBuild.
+func (v SoftwareVersion) Compare(other SoftwareVersion) int {
+	if c := compareDotted(v.Version, other.Version); c != 0 {
+		return c
+	}
+	return compareBuild(v.Build, other.Build)
+}
+
+// compareBuild compares the suffixes of equal versions. Numeric suffixes
+// are further components, e.g. "2.0.78.5" after "2.0.78". Other suffixes
+// are pre-releases as in semantic versioning, e.g. "1.10.1-previous"
+// before "1.10.1", and before numeric suffixes.
+func compareBuild(a string, b string) int {
+	if ra, rb := buildRank(a), buildRank(b); ra != rb {
+		return cmp.Compare(ra, rb)
+	}
+	return compareDotted(a, b)
+}
+
+// buildRank orders pre-releases before no suffix before further components.
+func buildRank(build string) int {
+	switch {
+	case build == "":
+		return 0
+	case build[0] >= '0' && build[0] <= '9':
+		return 1
+	default:
+		return -1
+	}
+}
+
+// compareDotted compares dot-separated components,
+// numerically if both are numbers.
+func compareDotted(a string, b string) int {
+	if a == "" || b == "" {
+		return cmp.Compare(len(a), len(b))
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range min(len(as), len(bs)) {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+
+		var c int
+		if aErr == nil && bErr == nil {
+			c = cmp.Compare(an, bn)
+		} else {
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// VersionConstraint selects software versions,
+// e.g. "Fooocus >= 2.3" or "< 2.5".
+type VersionConstraint struct {
+	// Case-insensitive product, empty to match any product.
+	Product string
+	// Comparison operator, one of "=", "!=", "<", "<=", ">" or ">=",
+	// empty to match any version of the product.
+	Op string
+	// Version to compare to.
+	Version SoftwareVersion
+}
+
+var versionConstraint = regexp.MustCompile(`^(.*?)\s*(?:(>=|<=|!=|==|=|>|<)\s*(v?\d\S*))?$`)
+
+// ParseVersionConstraint parses a constraint of format
+// "[product] [operator version]", e.g. "Fooocus >= 2.3".
+func ParseVersionConstraint(s string) (c VersionConstraint, err error) {
+	m := versionConstraint.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || (m[1] == "" && m[2] == "") || strings.ContainsAny(m[1], "<>=!") {
+		return c, fmt.Errorf("invalid version constraint: %q", s)
+	}
+
+	c.Product = m[1]
+	c.Op = strings.Replace(m[2], "==", "=", 1)
+	if c.Op != "" {
+		c.Version = ParseSoftwareVersion(m[3])
+		if c.Version.Version == "" {
+			return c, fmt.Errorf("invalid version in constraint: %q", s)
+		}
+	}
+	return c, nil
+}
+
+// IsZero returns true if the constraint matches any version.
+func (c VersionConstraint) IsZero() bool {
+	return c.Product == "" && c.Op == ""
+}
+
+// Match returns true if the version satisfies the constraint, ordered
+// as by SoftwareVersion.Compare including the build, e.g. "2.0.78.5"
+// is greater than "2.0.78". Versions without a semantic version only
+// satisfy constraints without an operator.
+func (c VersionConstraint) Match(v SoftwareVersion) bool {
+	if c.Product != "" && !strings.EqualFold(c.Product, v.Product) {
+		return false
+	}
+	if c.Op == "" {
+		return true
+	}
+	if v.Version == "" {
+		return false
+	}
+
+	order := v.Compare(c.Version)
+	switch c.Op {
+	case "=":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+// String returns the constraint, e.g. "Fooocus >= 2.3.0".
+func (c VersionConstraint) String() string {
+	if c.Op == "" {
+		return c.Product
+	}
+	version := SoftwareVersion{Version: c.Version.Version, Build: c.Version.Build}
+	return strings.TrimSpace(c.Product + " " + c.Op + " " + version.String())
+}
@@ -0,0 +1,135 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSoftwareVersion(t *testing.T) {
+	testCases := []struct {
+		version  string
+		expected SoftwareVersion
+	}{
+		{"", SoftwareVersion{}},
+		{"Fooocus v2.5.5", SoftwareVersion{Product: "Fooocus", Version: "2.5.5"}},
+		{"v2.1.865", SoftwareVersion{Version: "2.1.865"}},
+		{"FooocusPlus 1.0.0", SoftwareVersion{Product: "FooocusPlus", Version: "1.0.0"}},
+		{"RuinedFooocus", SoftwareVersion{Product: "RuinedFooocus"}},
+		{"RuinedFooocus 2.1", SoftwareVersion{Product: "RuinedFooocus", Version: "2.1.0"}},
+		{"Fooocus-MRE v2.0.78.5", SoftwareVersion{Product: "Fooocus-MRE", Version: "2.0.78", Build: "5"}},
+		{"v1.10.1", SoftwareVersion{Version: "1.10.1"}},
+		{"f2.0.1v1.10.1-previous", SoftwareVersion{Version: "1.10.1", Fork: "f2.0.1", Build: "previous"}},
+		{"Simple SDXL", SoftwareVersion{Product: "Simple SDXL"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ParseSoftwareVersion(tc.version))
+		})
+	}
+}
+
+func TestSoftwareVersion_String(t *testing.T) {
+	assert.Equal(t, "Fooocus 2.5.5", ParseSoftwareVersion("Fooocus v2.5.5").String())
+	assert.Equal(t, "f2.0.1v1.10.1-previous", ParseSoftwareVersion("f2.0.1v1.10.1-previous").String())
+	assert.Equal(t, "RuinedFooocus", ParseSoftwareVersion("RuinedFooocus").String())
+	assert.Equal(t, "", SoftwareVersion{}.String())
+}
+
+func TestSoftwareVersion_WithProduct(t *testing.T) {
+	assert.Equal(t, "Fooocus", ParseSoftwareVersion("v2.1.865").WithProduct("Fooocus").Product)
+	assert.Equal(t, "FooocusPlus", ParseSoftwareVersion("FooocusPlus 1.0.0").WithProduct("Fooocus").Product)
+}
+
+func TestSoftwareVersion_Compare(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"v2.1.865", "v2.1.865", 0},
+		{"v2.1.865", "Fooocus v2.5.5", -1},
+		{"v2.10.0", "v2.9.0", 1},
+		{"2.3", "v2.3.0", 0},
+		{"RuinedFooocus", "v0.0.1", -1},
+		{"RuinedFooocus", "Fooocus", 0},
+		{"v2.0.78.5", "v2.0.78", 1},
+		{"v2.0.78.5", "v2.0.78.10", -1},
+		{"f2.0.1v1.10.1-previous", "f2.0.1v1.10.1", -1},
+		{"v1.10.1-previous", "v1.10.0", 1},
+		{"v1.10.1-rc1", "v1.10.1-rc2", -1},
+		{"v1.10.1-rc1", "v1.10.1.1", -1},
+		{"v1.10.1-rc1", "v1.10.1-rc1", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			a, b := ParseSoftwareVersion(tc.a), ParseSoftwareVersion(tc.b)
+			assert.Equal(t, tc.expected, a.Compare(b))
+			assert.Equal(t, -tc.expected, b.Compare(a))
+		})
+	}
+}
+
+func TestParseVersionConstraint(t *testing.T) {
+	testCases := []struct {
+		constraint string
+		expected   VersionConstraint
+	}{
+		{"Fooocus >= 2.3", VersionConstraint{Product: "Fooocus", Op: ">=", Version: SoftwareVersion{Version: "2.3.0"}}},
+		{"Fooocus>=v2.3", VersionConstraint{Product: "Fooocus", Op: ">=", Version: SoftwareVersion{Version: "2.3.0"}}},
+		{"< 2.5", VersionConstraint{Op: "<", Version: SoftwareVersion{Version: "2.5.0"}}},
+		{"== 1.0.0", VersionConstraint{Op: "=", Version: SoftwareVersion{Version: "1.0.0"}}},
+		{"RuinedFooocus", VersionConstraint{Product: "RuinedFooocus"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.constraint, func(t *testing.T) {
+			c, err := ParseVersionConstraint(tc.constraint)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, c)
+		})
+	}
+
+	for _, invalid := range []string{"", "  ", "Fooocus >= x"} {
+		t.Run("invalid "+invalid, func(t *testing.T) {
+			_, err := ParseVersionConstraint(invalid)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVersionConstraint_Match(t *testing.T) {
+	testCases := []struct {
+		constraint string
+		version    SoftwareVersion
+		expected   bool
+	}{
+		{"Fooocus >= 2.3", SoftwareVersion{Product: "Fooocus", Version: "2.5.5"}, true},
+		{"fooocus >= 2.3", SoftwareVersion{Product: "Fooocus", Version: "2.3.0"}, true},
+		{"Fooocus >= 2.3", SoftwareVersion{Product: "Fooocus", Version: "2.1.865"}, false},
+		{"Fooocus >= 2.3", SoftwareVersion{Product: "FooocusPlus", Version: "2.5.5"}, false},
+		{"Fooocus", SoftwareVersion{Product: "Fooocus"}, true},
+		{"> 1.0", SoftwareVersion{Product: "RuinedFooocus"}, false},
+		{"!= 1.0", SoftwareVersion{Version: "1.0.1"}, true},
+		{"<= 2.0.78", SoftwareVersion{Version: "2.0.78", Build: "5"}, false},
+		{"<= 2.0.78.5", SoftwareVersion{Version: "2.0.78", Build: "5"}, true},
+		{"= 2.0.78", SoftwareVersion{Version: "2.0.78", Build: "5"}, false},
+		{"> 2.0.78", SoftwareVersion{Version: "2.0.78", Build: "5"}, true},
+		{"< 1.10.1", SoftwareVersion{Version: "1.10.1", Fork: "f2.0.1", Build: "previous"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.constraint, func(t *testing.T) {
+			c, err := ParseVersionConstraint(tc.constraint)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, c.Match(tc.version))
+
+			// The constraint is kept when formatted
+			formatted, err := ParseVersionConstraint(c.String())
+			require.NoError(t, err)
+			assert.Equal(t, c, formatted)
+		})
+	}
+}
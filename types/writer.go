@@ -3,6 +3,7 @@ package types
 import (
 	"bytes"
 	_ "embed"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
@@ -61,7 +62,7 @@ func (e *PngMetadataWriter) Embed(source io.Reader, target io.Writer, values map
 	for k, v := range values {
 		data, err = pngembed.Embed(data, k, v)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to embed %s: %w", k, err)
 		}
 	}
 
@@ -70,14 +71,16 @@ func (e *PngMetadataWriter) Embed(source io.Reader, target io.Writer, values map
 }
 
 func convertToPng(in io.Reader) (out io.ReadSeeker, err error) {
-	image, format, err := image.Decode(in)
-	if err != nil {
+	img, format, err := image.Decode(in)
+	if errors.Is(err, image.ErrFormat) {
+		return nil, fmt.Errorf("%w: %w", ErrUnsupportedMIME, err)
+	} else if err != nil {
 		return
 	}
 
 	slog.Debug("Decoded source image", "format", format)
 
 	buf := new(bytes.Buffer)
-	err = png.Encode(buf, image)
+	err = png.Encode(buf, img)
 	return bytes.NewReader(buf.Bytes()), err
 }
@@ -64,3 +64,12 @@ func TestEmbedWithSource(t *testing.T) {
 	assert.Equal(t, image.Bounds().Dx(), 512)
 	assert.Equal(t, image.Bounds().Dy(), 512)
 }
+
+func TestEmbedWithUnsupportedSource(t *testing.T) {
+	writer := NewPngMetadataWriter()
+
+	var buf bytes.Buffer
+	err := writer.Embed(bytes.NewReader([]byte("not an image")), &buf, map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrUnsupportedMIME)
+	assert.Empty(t, buf.Bytes())
+}
@@ -0,0 +1,325 @@
+// Package watch monitors output folders and extracts the image
+// generation parameters of new images as they are written.
+//
+// Folders are polled, so this works on network shares and does not
+// depend on platform-specific file system notifications. A folder is only
+// listed again if its modification time changed, and only new images are
+// checked for partial writes. New sub-folders, e.g. the date folders
+// created by Fooocus, are picked up automatically:
+//
+//	events, err := watch.Watch(ctx, []string{"outputs"})
+//	for event := range events {
+//		if event.Err != nil {
+//			log.Println(event.Path, event.Err)
+//			continue
+//		}
+//		fmt.Println(event.Path, event.Metadata.Params.Seed())
+//	}
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	metadata "github.com/fkleon/fooocus-metadata"
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Event is emitted once for each new image.
+type Event struct {
+	// Path of the new image.
+	Path string
+	// Metadata extracted from the image, or its private log entry.
+	Metadata types.StructuredMetadata
+	// Error if the metadata could not be extracted before the log timeout.
+	Err error
+}
+
+type Config struct {
+	// How often the folders are scanned.
+	Interval time.Duration
+	// How long the size and modification time of a new image
+	// must remain unchanged before it is considered complete.
+	Settle time.Duration
+	// How long to retry the extraction of an image without metadata,
+	// waiting for its entry to be appended to the private log.
+	LogTimeout time.Duration
+	Extract    func(path string) (types.StructuredMetadata, error)
+}
+type Option func(*Config)
+
+// To configure how often the folders are scanned, defaults to 500ms.
+func WithInterval(interval time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.Interval = interval
+	}
+}
+
+// To configure the debounce duration for partial writes, defaults to 1s.
+func WithSettle(settle time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.Settle = settle
+	}
+}
+
+// To configure how long to wait for the private log entry
+// of an image without embedded metadata, defaults to 10s.
+func WithLogTimeout(timeout time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.LogTimeout = timeout
+	}
+}
+
+// To use a custom function to extract the metadata of an image file,
+// defaults to metadata.ExtractFromFile.
+func WithExtractor(extract func(path string) (types.StructuredMetadata, error)) Option {
+	return func(cfg *Config) {
+		cfg.Extract = extract
+	}
+}
+
+// State of a new image that was not yet emitted.
+type pending struct {
+	size    int64
+	modTime time.Time
+	// Time the size and modification time were last seen to change.
+	changed time.Time
+	// Time of the first failed extraction, if any.
+	failed time.Time
+}
+
+// Listing of a folder, see watcher.list.
+type folder struct {
+	modTime time.Time
+	// Time the folder was listed.
+	listed  time.Time
+	images  []string
+	folders []string
+}
+
+// Folders modified less than this before they were listed are listed
+// again, as file systems with a coarse modification time may not record
+// changes made in the same interval.
+const racyListing = 2 * time.Second
+
+type watcher struct {
+	dirs   []string
+	config Config
+
+	// Images that existed when watching started or were already emitted.
+	known   map[string]bool
+	pending map[string]*pending
+	folders map[string]*folder
+}
+
+// Watch monitors the given folders and their sub-folders for new images.
+// Images that exist when watching starts are ignored.
+//
+// Events are delivered through the returned channel, which is closed
+// when the context is cancelled.
+func Watch(ctx context.Context, dirs []string, opts ...Option) (<-chan Event, error) {
+	cfg := Config{
+		Interval:   500 * time.Millisecond,
+		Settle:     time.Second,
+		LogTimeout: 10 * time.Second,
+		Extract:    extractFromFile,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	w, err := newWatcher(dirs, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go w.run(ctx, events)
+	return events, nil
+}
+
+// newWatcher returns a watcher of the folders, which knows the
+// images that exist in the folders.
+func newWatcher(dirs []string, cfg Config) (*watcher, error) {
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no folders to watch")
+	}
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil {
+			return nil, err
+		} else if !info.IsDir() {
+			return nil, fmt.Errorf("not a folder: %s", dir)
+		}
+	}
+
+	w := &watcher{
+		dirs:    dirs,
+		config:  cfg,
+		known:   make(map[string]bool),
+		pending: make(map[string]*pending),
+		folders: make(map[string]*folder),
+	}
+
+	// Existing images are known
+	w.scan(func(path string) {
+		w.known[path] = true
+	})
+	return w, nil
+}
+
+func (w *watcher) run(ctx context.Context, events chan<- Event) {
+	defer close(events)
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.poll(now)
+			for _, event := range w.process(now) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// poll scans the folders for new or changed images.
+func (w *watcher) poll(now time.Time) {
+	seen := make(map[string]bool)
+	w.scan(func(path string) {
+		seen[path] = true
+		if w.known[path] {
+			return
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			// Removed while scanning
+			return
+		}
+		p, ok := w.pending[path]
+		if !ok {
+			slog.Debug("New image", "path", path)
+			w.pending[path] = &pending{size: info.Size(), modTime: info.ModTime(), changed: now}
+		} else if p.size != info.Size() || !p.modTime.Equal(info.ModTime()) {
+			*p = pending{size: info.Size(), modTime: info.ModTime(), changed: now}
+		}
+	})
+
+	// Forget removed images, so they are emitted again if re-created
+	for path := range w.known {
+		if !seen[path] {
+			delete(w.known, path)
+		}
+	}
+	for path := range w.pending {
+		if !seen[path] {
+			delete(w.pending, path)
+		}
+	}
+}
+
+// process extracts the metadata of all settled images.
+func (w *watcher) process(now time.Time) (events []Event) {
+	for _, path := range slices.Sorted(maps.Keys(w.pending)) {
+		p := w.pending[path]
+		if now.Sub(p.changed) < w.config.Settle {
+			continue
+		}
+
+		meta, err := w.config.Extract(path)
+		if err != nil {
+			if p.failed.IsZero() {
+				p.failed = now
+			}
+			if now.Sub(p.failed) < w.config.LogTimeout {
+				// Retry, the private log may not be written yet
+				slog.Debug("Retrying metadata extraction", "path", path, "err", err)
+				continue
+			}
+		}
+
+		events = append(events, Event{Path: path, Metadata: meta, Err: err})
+		delete(w.pending, path)
+		w.known[path] = true
+	}
+	return events
+}
+
+// scan calls fn for every image in the watched folders.
+func (w *watcher) scan(fn func(path string)) {
+	seen := make(map[string]bool)
+	for _, dir := range w.dirs {
+		w.walk(dir, seen, fn)
+	}
+
+	// Forget removed folders
+	for dir := range w.folders {
+		if !seen[dir] {
+			delete(w.folders, dir)
+		}
+	}
+}
+
+// walk calls fn for every image in the folder and its sub-folders.
+func (w *watcher) walk(dir string, seen map[string]bool, fn func(path string)) {
+	seen[dir] = true
+	f, err := w.list(dir)
+	if err != nil {
+		// Folders may disappear while scanning
+		slog.Debug("Failed to scan", "path", dir, "err", err)
+		return
+	}
+	for _, path := range f.images {
+		fn(path)
+	}
+	for _, sub := range f.folders {
+		w.walk(sub, seen, fn)
+	}
+}
+
+// list returns the images and sub-folders of the folder, which is
+// only read again if its modification time changed.
+func (w *watcher) list(dir string) (*folder, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := w.folders[dir]
+	if ok && f.modTime.Equal(info.ModTime()) && f.listed.Sub(f.modTime) >= racyListing {
+		return f, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	f = &folder{modTime: info.ModTime(), listed: time.Now()}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			f.folders = append(f.folders, path)
+		} else if metadata.IsImageFile(path) {
+			f.images = append(f.images, path)
+		}
+	}
+	w.folders[dir] = f
+	return f, nil
+}
+
+func extractFromFile(path string) (types.StructuredMetadata, error) {
+	return metadata.ExtractFromFile(path)
+}
@@ -0,0 +1,242 @@
+package watch
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/fkleon/fooocus-metadata/fooocus"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Configure logging during testing
+func TestMain(m *testing.M) {
+	slog.SetLogLoggerLevel(slog.LevelWarn)
+	exitVal := m.Run()
+	os.Exit(exitVal)
+}
+
+const (
+	testSettle     = time.Second
+	testLogTimeout = 10 * time.Second
+)
+
+func readFile(t *testing.T, path string) []byte {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return data
+}
+
+// clock drives a watcher with manual ticks instead of a ticker.
+type clock struct {
+	w   *watcher
+	now time.Time
+}
+
+func newClock(t *testing.T, dirs ...string) *clock {
+	w, err := newWatcher(dirs, Config{
+		Settle:     testSettle,
+		LogTimeout: testLogTimeout,
+		Extract:    extractFromFile,
+	})
+	require.NoError(t, err)
+	return &clock{w: w, now: time.Now()}
+}
+
+// tick advances the clock and returns the events of the tick.
+func (c *clock) tick(d time.Duration) []Event {
+	c.now = c.now.Add(d)
+	c.w.poll(c.now)
+	return c.w.process(c.now)
+}
+
+func TestWatch_NewDateFolder(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing.png")
+	require.NoError(t, os.WriteFile(existing, readFile(t, "../fooocus/testdata/fooocus-meta.png"), 0o644))
+
+	c := newClock(t, root)
+	assert.Empty(t, c.tick(testSettle))
+
+	// Write a new image into a new folder in two parts
+	dir := filepath.Join(root, "2024-01-05")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+
+	data := readFile(t, "../fooocus/testdata/fooocus-meta.png")
+	path := filepath.Join(dir, "image.png")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = file.Write(data[:len(data)/2])
+	require.NoError(t, err)
+
+	assert.Empty(t, c.tick(0))
+	assert.Empty(t, c.tick(testSettle/2))
+
+	// The size changed, so the image must settle again
+	_, err = file.Write(data[len(data)/2:])
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	assert.Empty(t, c.tick(testSettle*3/4))
+	assert.Empty(t, c.tick(testSettle/2))
+
+	events := c.tick(testSettle / 2)
+	require.Len(t, events, 1)
+	assert.Equal(t, path, events[0].Path)
+	require.NoError(t, events[0].Err)
+	assert.Equal(t, "Fooocus", events[0].Metadata.Source)
+	assert.Equal(t, "127589946317439009", events[0].Metadata.Params.Seed())
+
+	// Each image is emitted only once
+	assert.Empty(t, c.tick(testSettle))
+}
+
+func TestWatch_PrivateLog(t *testing.T) {
+	root := t.TempDir()
+	c := newClock(t, root)
+
+	// Image without embedded metadata, followed by its log entry
+	path := filepath.Join(root, "2024-01-05_23-11-48_9167.png")
+	require.NoError(t, os.WriteFile(path, readFile(t, "../internal/image/testdata/sample.png"), 0o644))
+
+	assert.Empty(t, c.tick(0))
+	assert.Empty(t, c.tick(testSettle))
+	assert.Empty(t, c.tick(testLogTimeout/2))
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "log.html"), readFile(t, "../fooocus/testdata/log.html"), 0o644))
+
+	events := c.tick(time.Second)
+	require.Len(t, events, 1)
+	assert.Equal(t, path, events[0].Path)
+	require.NoError(t, events[0].Err)
+	assert.Equal(t, "Fooocus", events[0].Metadata.Source)
+}
+
+func TestWatch_LogTimeout(t *testing.T) {
+	root := t.TempDir()
+	c := newClock(t, root)
+
+	path := filepath.Join(root, "sample.png")
+	require.NoError(t, os.WriteFile(path, readFile(t, "../internal/image/testdata/sample.png"), 0o644))
+
+	assert.Empty(t, c.tick(0))
+	assert.Empty(t, c.tick(testSettle))
+	assert.Empty(t, c.tick(testLogTimeout-time.Second))
+
+	events := c.tick(time.Second)
+	require.Len(t, events, 1)
+	assert.Equal(t, path, events[0].Path)
+	assert.Error(t, events[0].Err)
+}
+
+func TestWatch_MultipleFolders(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	c := newClock(t, a, b)
+
+	data := readFile(t, "../fooocus/testdata/fooocus-meta.jpeg")
+	require.NoError(t, os.WriteFile(filepath.Join(a, "a.jpeg"), data, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(b, "b.jpeg"), data, 0o644))
+
+	assert.Empty(t, c.tick(0))
+	events := c.tick(testSettle)
+	require.Len(t, events, 2)
+	assert.Equal(t, filepath.Join(a, "a.jpeg"), events[0].Path)
+	assert.Equal(t, filepath.Join(b, "b.jpeg"), events[1].Path)
+}
+
+func TestWatch_Recreated(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "image.jpeg")
+	data := readFile(t, "../fooocus/testdata/fooocus-meta.jpeg")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	c := newClock(t, root)
+
+	// Removed images are emitted again if re-created
+	require.NoError(t, os.Remove(path))
+	assert.Empty(t, c.tick(testSettle))
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+	assert.Empty(t, c.tick(0))
+
+	events := c.tick(testSettle)
+	require.Len(t, events, 1)
+	assert.Equal(t, path, events[0].Path)
+}
+
+func TestWatch_ListsChangedFolders(t *testing.T) {
+	root := t.TempDir()
+	c := newClock(t, root)
+
+	listing, ok := c.w.folders[root]
+	require.True(t, ok)
+	assert.Empty(t, listing.images)
+
+	// Listed long after the last change, so the listing is reused
+	listing.listed = listing.modTime.Add(time.Hour)
+	f, err := c.w.list(root)
+	require.NoError(t, err)
+	assert.Same(t, listing, f)
+
+	// New images change the modification time of the folder
+	path := filepath.Join(root, "image.png")
+	require.NoError(t, os.WriteFile(path, readFile(t, "../fooocus/testdata/fooocus-meta.png"), 0o644))
+	modTime := listing.modTime.Add(time.Minute)
+	require.NoError(t, os.Chtimes(root, modTime, modTime))
+
+	f, err = c.w.list(root)
+	require.NoError(t, err)
+	assert.NotSame(t, listing, f)
+	assert.Equal(t, []string{path}, f.images)
+
+	// Removed folders are forgotten
+	sub := filepath.Join(root, "2024-01-05")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+	c.tick(0)
+	assert.Contains(t, c.w.folders, sub)
+	require.NoError(t, os.Remove(sub))
+	c.tick(0)
+	assert.NotContains(t, c.w.folders, sub)
+}
+
+func TestWatch(t *testing.T) {
+	root := t.TempDir()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	events, err := Watch(ctx, []string{root},
+		WithInterval(10*time.Millisecond),
+		WithSettle(10*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	path := filepath.Join(root, "image.png")
+	require.NoError(t, os.WriteFile(path, readFile(t, "../fooocus/testdata/fooocus-meta.png"), 0o644))
+
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "channel closed")
+		assert.Equal(t, path, event.Path)
+		require.NoError(t, event.Err)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout waiting for event")
+	}
+
+	// The channel is closed when the context is cancelled
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatch_InvalidFolder(t *testing.T) {
+	_, err := Watch(t.Context(), nil)
+	assert.Error(t, err)
+
+	_, err = Watch(t.Context(), []string{filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+}
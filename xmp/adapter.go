@@ -0,0 +1,90 @@
+package xmp
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Adapter that implements the types.GenerationParameters
+// interface on top of XMP Metadata. Only the prompt and software
+// are known unless the Generation schema was written.
+type Parameters struct {
+	Metadata
+	Created time.Time
+}
+
+// Version returns the version of the Generation schema,
+// or else the creator tool.
+func (m Parameters) Version() string {
+	if m.Metadata.Version != "" {
+		return m.Metadata.Version
+	}
+	return m.Software
+}
+
+func (m Parameters) SoftwareVersion() types.SoftwareVersion {
+	if m.Version() == "" {
+		return types.SoftwareVersion{}
+	}
+	return types.ParseSoftwareVersion(m.Version())
+}
+
+func (m Parameters) Model() string {
+	return m.Metadata.Model
+}
+
+// LoRAs returns the LoRAs of the "name:weight" values,
+// with weight 1 if it is missing.
+func (m Parameters) LoRAs() []types.Lora {
+	var loras = make([]types.Lora, len(m.Loras))
+	for i, lora := range m.Loras {
+		loras[i] = types.Lora{Name: lora, Weight: 1}
+		if j := strings.LastIndex(lora, ":"); j >= 0 {
+			if weight, err := strconv.ParseFloat(lora[j+1:], 32); err == nil {
+				loras[i] = types.Lora{Name: lora[:j], Weight: float32(weight)}
+			}
+		}
+	}
+	return loras
+}
+
+func (m Parameters) PositivePrompt() string {
+	return m.Prompt
+}
+
+func (m Parameters) NegativePrompt() string {
+	return m.Metadata.NegativePrompt
+}
+
+func (m Parameters) Seed() string {
+	return m.Metadata.Seed
+}
+
+// SeedValue returns the parsed seed, see Seeds.
+func (m Parameters) SeedValue() types.Seed {
+	seed, _ := Seeds.Parse(m.Seed())
+	return seed
+}
+
+func (m Parameters) Sampler() string {
+	return m.Metadata.Sampler
+}
+
+func (m Parameters) Scheduler() string {
+	return m.Metadata.Scheduler
+}
+
+func (m Parameters) Size() (width int, height int) {
+	return m.Width, m.Height
+}
+
+func (m Parameters) CreatedTime() time.Time {
+	return m.Created
+}
+
+func (m Parameters) Raw() interface{} {
+	return m.Metadata
+}
@@ -0,0 +1,108 @@
+// Package xmp implements reading generation parameters from standard
+// [XMP] properties, e.g. the prompt in the Dublin Core "description".
+//
+// XMP is written by some generators and by digital asset management
+// tools after generation. The reader is registered as a fallback, so it
+// only applies if no software-specific metadata was found.
+//
+// [XMP]: https://developer.adobe.com/xmp/docs/
+package xmp
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/bep/imagemeta"
+
+	"github.com/fkleon/fooocus-metadata/types"
+)
+
+// Seeds is the range of the seeds of the Generation schema, which is
+// that of any tool as the software that generated the image is unknown.
+var Seeds = types.SeedRange{Max: math.MaxUint64}
+
+// Metadata are the generation parameters recovered from standard
+// XMP properties.
+type Metadata struct {
+	// Prompt from dc:description.
+	Prompt string `json:"prompt,omitempty"`
+	// Title from dc:title.
+	Title string `json:"title,omitempty"`
+	// Software from xmp:CreatorTool, e.g. "Fooocus v2.5.5".
+	Software string `json:"software,omitempty"`
+	// Creators from dc:creator.
+	Creators []string `json:"creators,omitempty"`
+	// Keywords from dc:subject.
+	Keywords []string `json:"keywords,omitempty"`
+	// Creation date from xmp:CreateDate, e.g. "2025-01-20T10:00:00+01:00".
+	CreateDate string `json:"create_date,omitempty"`
+	// Type of the source of the image from Iptc4xmpExt:DigitalSourceType,
+	// e.g. "http://cv.iptc.org/newscodes/digitalsourcetype/trainedAlgorithmicMedia".
+	DigitalSourceType string `json:"digital_source_type,omitempty"`
+
+	// Parameters of the Generation schema, if written by this module.
+	Version        string   `json:"version,omitempty"`
+	NegativePrompt string   `json:"negative_prompt,omitempty"`
+	Model          string   `json:"model,omitempty"`
+	Loras          []string `json:"loras,omitempty"`
+	Seed           string   `json:"seed,omitempty"`
+	Sampler        string   `json:"sampler,omitempty"`
+	Scheduler      string   `json:"scheduler,omitempty"`
+	Width          int      `json:"width,omitempty"`
+	Height         int      `json:"height,omitempty"`
+}
+
+// ParseTags reads the metadata from the XMP tags of an image.
+func ParseTags(tags types.Tags) (meta Metadata) {
+	meta.Prompt = stringTag(tags, types.NamespaceXMPDublinCore, "description")
+	meta.Title = stringTag(tags, types.NamespaceXMPDublinCore, "title")
+	meta.Software = stringTag(tags, types.NamespaceXMPBasic, "CreatorTool")
+	meta.Creators = stringsTag(tags, types.NamespaceXMPDublinCore, "creator")
+	meta.Keywords = stringsTag(tags, types.NamespaceXMPDublinCore, "subject")
+	meta.CreateDate = stringTag(tags, types.NamespaceXMPBasic, "CreateDate")
+	meta.DigitalSourceType = stringTag(tags, types.NamespaceXMPIptcExt, "DigitalSourceType")
+
+	gen := Generation.Tags()
+	meta.Version = stringTag(tags, gen, "Version")
+	meta.NegativePrompt = stringTag(tags, gen, "NegativePrompt")
+	meta.Model = stringTag(tags, gen, "Model")
+	meta.Loras = stringsTag(tags, gen, "LoRAs")
+	meta.Seed = stringTag(tags, gen, "Seed")
+	meta.Sampler = stringTag(tags, gen, "Sampler")
+	meta.Scheduler = stringTag(tags, gen, "Scheduler")
+	meta.Width, _ = strconv.Atoi(stringTag(tags, gen, "Width"))
+	meta.Height, _ = strconv.Atoi(stringTag(tags, gen, "Height"))
+	return
+}
+
+func stringTag(tags types.Tags, namespace string, name string) string {
+	for _, tag := range tags.Lookup(namespace, name) {
+		if value, ok := tag.Value.(string); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// stringsTag returns the values of an array, or of a simple
+// property as a single value.
+func stringsTag(tags types.Tags, namespace string, name string) []string {
+	for _, tag := range tags.Lookup(namespace, name) {
+		switch value := tag.Value.(type) {
+		case []string:
+			return value
+		case string:
+			return []string{value}
+		}
+	}
+	return nil
+}
+
+// sourceTag returns the tag the metadata was recovered from,
+// the prompt or else the digital source type.
+func sourceTag(tags types.Tags) (tag imagemeta.TagInfo, ok bool) {
+	if tag, ok = tags.Get(types.NamespaceXMPDublinCore, "description"); ok {
+		return tag, true
+	}
+	return tags.Get(types.NamespaceXMPIptcExt, "DigitalSourceType")
+}
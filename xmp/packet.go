@@ -0,0 +1,142 @@
+package xmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"slices"
+)
+
+// Namespace is an XMP schema with its preferred prefix.
+type Namespace struct {
+	Prefix string
+	URI    string
+}
+
+// Tags returns the namespace of the tags of the schema,
+// e.g. types.NamespaceXMPDublinCore.
+func (ns Namespace) Tags() string {
+	return "XMP/" + ns.URI
+}
+
+var (
+	DublinCore = Namespace{"dc", "http://purl.org/dc/elements/1.1/"}
+	XMPBasic   = Namespace{"xmp", "http://ns.adobe.com/xap/1.0/"}
+	IptcExt    = Namespace{"Iptc4xmpExt", "http://iptc.org/std/Iptc4xmpExt/2008-02-29/"}
+	// Generation is the schema of the generation parameters, with the
+	// properties Version, NegativePrompt, Model, LoRAs, Seed, Sampler,
+	// Scheduler, Width and Height. LoRAs is an ordered array of
+	// "name:weight" values, the prompt is written to dc:description.
+	Generation = Namespace{"gen", "https://github.com/fkleon/fooocus-metadata/xmp/generation/1.0/"}
+)
+
+const rdfURI = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// Array types of XMP properties.
+const (
+	// Language alternative, written with the default language.
+	Alt = "Alt"
+	// Unordered array.
+	Bag = "Bag"
+	// Ordered array.
+	Seq = "Seq"
+)
+
+type property struct {
+	ns    Namespace
+	name  string
+	array string
+	// Values of an array, a simple property has a single value
+	values []string
+}
+
+// Packet is an XMP packet with the properties of a single
+// rdf:Description, written in the order they were set.
+type Packet struct {
+	properties []property
+}
+
+// Set sets a simple property. Empty values are skipped.
+func (p *Packet) Set(ns Namespace, name string, value string) {
+	if value == "" {
+		return
+	}
+	p.set(property{ns: ns, name: name, values: []string{value}})
+}
+
+// SetArray sets an array property of the given type, e.g. Bag.
+// Empty values are skipped, and so is the property if none remain.
+func (p *Packet) SetArray(ns Namespace, name string, array string, values ...string) {
+	values = slices.DeleteFunc(slices.Clone(values), func(v string) bool { return v == "" })
+	if len(values) == 0 {
+		return
+	}
+	p.set(property{ns: ns, name: name, array: array, values: values})
+}
+
+func (p *Packet) set(prop property) {
+	for i, existing := range p.properties {
+		if existing.ns == prop.ns && existing.name == prop.name {
+			p.properties[i] = prop
+			return
+		}
+	}
+	p.properties = append(p.properties, prop)
+}
+
+// Clone returns a copy of the packet.
+func (p *Packet) Clone() *Packet {
+	return &Packet{properties: slices.Clone(p.properties)}
+}
+
+// Bytes returns the serialised packet, with a namespace declaration
+// for each schema in use.
+func (p *Packet) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	buf.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	buf.WriteString(" <rdf:RDF xmlns:rdf=\"" + rdfURI + "\">\n")
+	buf.WriteString("  <rdf:Description rdf:about=\"\"")
+
+	var declared []Namespace
+	for _, prop := range p.properties {
+		if !slices.Contains(declared, prop.ns) {
+			declared = append(declared, prop.ns)
+			buf.WriteString("\n    xmlns:" + prop.ns.Prefix + "=\"")
+			escape(&buf, prop.ns.URI)
+			buf.WriteString("\"")
+		}
+	}
+	buf.WriteString(">\n")
+
+	for _, prop := range p.properties {
+		element := prop.ns.Prefix + ":" + prop.name
+		buf.WriteString("   <" + element + ">")
+		switch prop.array {
+		case "":
+			escape(&buf, prop.values[0])
+		case Alt:
+			buf.WriteString("<rdf:Alt><rdf:li xml:lang=\"x-default\">")
+			escape(&buf, prop.values[0])
+			buf.WriteString("</rdf:li></rdf:Alt>")
+		default:
+			buf.WriteString("<rdf:" + prop.array + ">")
+			for _, value := range prop.values {
+				buf.WriteString("<rdf:li>")
+				escape(&buf, value)
+				buf.WriteString("</rdf:li>")
+			}
+			buf.WriteString("</rdf:" + prop.array + ">")
+		}
+		buf.WriteString("</" + element + ">\n")
+	}
+
+	buf.WriteString("  </rdf:Description>\n")
+	buf.WriteString(" </rdf:RDF>\n")
+	buf.WriteString("</x:xmpmeta>\n")
+	buf.WriteString("<?xpacket end=\"w\"?>")
+	return buf.Bytes()
+}
+
+func escape(buf *bytes.Buffer, s string) {
+	_ = xml.EscapeText(buf, []byte(s))
+}
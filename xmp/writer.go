@@ -0,0 +1,127 @@
+package xmp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	m "github.com/fkleon/fooocus-metadata/types"
+)
+
+// NewPacket returns an XMP packet with the generation parameters, for
+// digital asset management tools that cannot read the tool-specific
+// metadata. The prompt is written to dc:description, the model and
+// LoRAs as keywords to dc:subject, the software to xmp:CreatorTool,
+// the parameters to the Generation schema and the IPTC digital source
+// type types.DigitalSourceTrainedAlgorithmicMedia, see SetLabel.
+func NewPacket(params m.GenerationParameters) *Packet {
+	var packet Packet
+	packet.Set(XMPBasic, "CreatorTool", params.Version())
+	if created, ok := params.(interface{ CreatedTime() time.Time }); ok && !created.CreatedTime().IsZero() {
+		packet.Set(XMPBasic, "CreateDate", created.CreatedTime().Format(time.RFC3339))
+	}
+	packet.SetArray(DublinCore, "description", Alt, params.PositivePrompt())
+
+	keywords := []string{params.Model()}
+	loras := make([]string, 0, len(params.LoRAs()))
+	for _, lora := range params.LoRAs() {
+		keywords = append(keywords, lora.Name)
+		loras = append(loras, lora.Name+":"+strconv.FormatFloat(float64(lora.Weight), 'g', -1, 32))
+	}
+	packet.SetArray(DublinCore, "subject", Bag, keywords...)
+	packet.Set(IptcExt, "DigitalSourceType", m.DigitalSourceTrainedAlgorithmicMedia)
+
+	packet.Set(Generation, "Version", params.Version())
+	packet.Set(Generation, "NegativePrompt", params.NegativePrompt())
+	packet.Set(Generation, "Model", params.Model())
+	packet.SetArray(Generation, "LoRAs", Seq, loras...)
+	packet.Set(Generation, "Seed", params.Seed())
+	packet.Set(Generation, "Sampler", params.Sampler())
+	packet.Set(Generation, "Scheduler", params.Scheduler())
+	if width, height := params.Size(); width > 0 && height > 0 {
+		packet.Set(Generation, "Width", strconv.Itoa(width))
+		packet.Set(Generation, "Height", strconv.Itoa(height))
+	}
+	return &packet
+}
+
+// SetLabel sets the properties of the label of an image: the digital
+// source type, the artist to dc:creator, the copyright to dc:rights and
+// the software to xmp:CreatorTool. The description is not set, as
+// dc:description holds the prompt. Empty fields are skipped.
+func (p *Packet) SetLabel(label m.Label) {
+	p.Set(IptcExt, "DigitalSourceType", label.DigitalSourceType)
+	p.SetArray(DublinCore, "creator", Seq, label.Artist)
+	p.SetArray(DublinCore, "rights", Alt, label.Copyright)
+	p.Set(XMPBasic, "CreatorTool", label.Software)
+}
+
+// XMPMetadataWriter can write generation parameters as an XMP
+// sidecar, or embed them into a PNG, JPEG or WebP image.
+type XMPMetadataWriter struct{}
+
+// Write writes the XMP packet, e.g. to a sidecar file, see SidecarPath.
+func (w XMPMetadataWriter) Write(target io.Writer, params m.GenerationParameters) error {
+	_, err := target.Write(NewPacket(params).Bytes())
+	return err
+}
+
+// CopyWrite copies the image with the XMP packet embedded,
+// replacing any XMP packet of the source image.
+//
+// JPEG images hold the packet in a single APP1 segment of at most
+// 65502 bytes, as Extended XMP is not written. Larger packets, e.g. of
+// very long prompts, fail with types.ErrMetadataTooLarge; write them
+// to a sidecar instead, see WriteSidecar.
+func (w XMPMetadataWriter) CopyWrite(source io.Reader, target io.Writer, params m.GenerationParameters) error {
+	return image.EmbedXMP(source, target, NewPacket(params).Bytes())
+}
+
+func NewXMPMetadataWriter() m.Writer[m.GenerationParameters] {
+	return XMPMetadataWriter{}
+}
+
+// SidecarStyle is the naming convention of XMP sidecar files.
+type SidecarStyle uint8
+
+const (
+	// The image path with ".xmp" appended, e.g. "image.png.xmp",
+	// as used by darktable and digiKam.
+	SidecarAppend SidecarStyle = iota
+	// The image path with its extension replaced by ".xmp", e.g.
+	// "image.xmp", as used by Lightroom and Capture One.
+	SidecarReplace
+)
+
+// Path returns the path of the XMP sidecar of an image in the style.
+func (s SidecarStyle) Path(imagePath string) string {
+	if s == SidecarReplace {
+		return strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + ".xmp"
+	}
+	return imagePath + ".xmp"
+}
+
+// SidecarPath returns the path of the XMP sidecar of an image in the
+// SidecarAppend style, e.g. "image.png.xmp".
+func SidecarPath(imagePath string) string {
+	return SidecarAppend.Path(imagePath)
+}
+
+// WriteSidecar writes the XMP sidecar of the image, with the path in
+// the given style, see SidecarStyle.
+func WriteSidecar(imagePath string, style SidecarStyle, params m.GenerationParameters) error {
+	target, err := os.Create(style.Path(imagePath))
+	if err != nil {
+		return fmt.Errorf("failed to create sidecar: %w", err)
+	}
+	if err := NewXMPMetadataWriter().Write(target, params); err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}
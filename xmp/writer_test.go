@@ -0,0 +1,164 @@
+package xmp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fkleon/fooocus-metadata/fooocus"
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	"github.com/fkleon/fooocus-metadata/stablediffusion"
+	m "github.com/fkleon/fooocus-metadata/types"
+)
+
+func generationParameters(t *testing.T) m.GenerationParameters {
+	meta, err := stablediffusion.ParseParameters("a cat & a <dog> <lora:cat:0.8>\nNegative prompt: blurry\nSteps: 20, Sampler: DPM++ 2M Karras, Seed: 4051576822, Size: 512x768, Model: sd_xl_base_1.0, Version: v1.10.1")
+	require.NoError(t, err)
+	return stablediffusion.Parameters{
+		Metadata: meta,
+		Created:  time.Date(2025, 1, 20, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+// assertRoundTrip checks that the parameters read from a
+// written packet match those written.
+func assertRoundTrip(t *testing.T, want m.GenerationParameters, meta Metadata) {
+	assert.Equal(t, m.DigitalSourceTrainedAlgorithmicMedia, meta.DigitalSourceType)
+	assert.Equal(t, "2025-01-20T10:00:00Z", meta.CreateDate)
+	assert.Equal(t, []string{"sd_xl_base_1.0", "cat"}, meta.Keywords)
+
+	got := Parameters{Metadata: meta}
+	assert.Equal(t, want.Version(), got.Version())
+	assert.Equal(t, want.PositivePrompt(), got.PositivePrompt())
+	assert.Equal(t, want.NegativePrompt(), got.NegativePrompt())
+	assert.Equal(t, want.Model(), got.Model())
+	assert.Equal(t, want.LoRAs(), got.LoRAs())
+	assert.Equal(t, want.Seed(), got.Seed())
+	assert.Equal(t, want.SeedValue().Value, got.SeedValue().Value)
+	assert.Equal(t, want.Sampler(), got.Sampler())
+	assert.Equal(t, want.Scheduler(), got.Scheduler())
+	width, height := got.Size()
+	assert.Equal(t, 512, width)
+	assert.Equal(t, 768, height)
+}
+
+func TestNewPacket(t *testing.T) {
+	params := generationParameters(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, NewXMPMetadataWriter().Write(&buf, params))
+	assert.True(t, strings.HasPrefix(buf.String(), "<?xpacket begin="))
+	assert.Contains(t, buf.String(), "a cat &amp; a &lt;dog&gt;")
+
+	path := filepath.Join(t.TempDir(), "packet.xmp")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	tags, err := image.ReadXMPSidecar(path)
+	require.NoError(t, err)
+	assertRoundTrip(t, params, ParseTags(tags))
+}
+
+func TestPacket_Set(t *testing.T) {
+	var packet Packet
+	packet.Set(DublinCore, "title", "")
+	packet.SetArray(DublinCore, "subject", Bag, "", "")
+	assert.Empty(t, packet.properties)
+
+	packet.Set(DublinCore, "title", "Cats")
+	packet.Set(DublinCore, "title", "Dogs")
+	assert.Equal(t, []property{{ns: DublinCore, name: "title", values: []string{"Dogs"}}}, packet.properties)
+}
+
+func TestPacket_SetLabel(t *testing.T) {
+	packet := NewPacket(generationParameters(t))
+	clone := packet.Clone()
+	clone.SetLabel(m.Label{
+		DigitalSourceType: m.DigitalSourceCompositeWithTrainedAlgorithmicMedia,
+		Artist:            "Jane Doe",
+		Copyright:         "CC BY 4.0",
+		Description:       "A cat",
+	})
+
+	path := filepath.Join(t.TempDir(), "packet.xmp")
+	require.NoError(t, os.WriteFile(path, clone.Bytes(), 0644))
+	tags, err := image.ReadXMPSidecar(path)
+	require.NoError(t, err)
+	meta := ParseTags(tags)
+	assert.Equal(t, m.DigitalSourceCompositeWithTrainedAlgorithmicMedia, meta.DigitalSourceType)
+	assert.Equal(t, []string{"Jane Doe"}, meta.Creators)
+	assert.Equal(t, "CC BY 4.0", stringTag(tags, m.NamespaceXMPDublinCore, "rights"))
+	assert.Equal(t, "v1.10.1", meta.Software)
+	assert.Equal(t, "a cat & a <dog> <lora:cat:0.8>", meta.Prompt)
+
+	// The original packet is unchanged
+	assert.NotContains(t, string(packet.Bytes()), "Jane Doe")
+}
+
+func TestSidecarStylePath(t *testing.T) {
+	assert.Equal(t, "dir/image.png.xmp", SidecarAppend.Path("dir/image.png"))
+	assert.Equal(t, "dir/image.xmp", SidecarReplace.Path("dir/image.png"))
+	assert.Equal(t, "dir/image.v2.xmp", SidecarReplace.Path("dir/image.v2.jpeg"))
+	assert.Equal(t, "dir/image.xmp", SidecarReplace.Path("dir/image"))
+	assert.Equal(t, SidecarAppend.Path("image.png"), SidecarPath("image.png"))
+}
+
+func TestWriteSidecar(t *testing.T) {
+	params := generationParameters(t)
+
+	for _, tc := range []struct {
+		style   SidecarStyle
+		sidecar string
+	}{
+		{SidecarAppend, "image.png.xmp"},
+		{SidecarReplace, "image.xmp"},
+	} {
+		t.Run(tc.sidecar, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "image.png")
+			require.NoError(t, WriteSidecar(path, tc.style, params))
+			require.FileExists(t, filepath.Join(dir, tc.sidecar))
+
+			meta, err := NewXMPMetadataExtractor().Extract(m.ImageMetadataContext{Filepath: path})
+			require.NoError(t, err)
+			assert.Equal(t, m.Provenance{
+				Location:  m.LocationSidecar,
+				Path:      filepath.Join(dir, tc.sidecar),
+				Container: m.NamespaceXMPDublinCore,
+				Key:       "description",
+				Scheme:    Scheme,
+			}, meta.Provenance)
+			assertRoundTrip(t, params, meta.Params.Raw().(Metadata))
+		})
+	}
+}
+
+func TestCopyWrite(t *testing.T) {
+	params := generationParameters(t)
+	source, err := os.ReadFile("../fooocus/testdata/fooocus-meta.jpeg")
+	require.NoError(t, err)
+
+	var target bytes.Buffer
+	require.NoError(t, NewXMPMetadataWriter().CopyWrite(bytes.NewReader(source), &target, params))
+
+	ctx, err := image.NewContextFromReader(bytes.NewReader(target.Bytes()))
+	require.NoError(t, err)
+	assertRoundTrip(t, params, ParseTags(ctx.EmbeddedMetadata))
+
+	// The Fooocus metadata is kept
+	_, err = fooocus.NewFooocusMetadataExtractor().Decode(*ctx)
+	assert.NoError(t, err)
+
+	// Images that cannot hold XMP
+	err = NewXMPMetadataWriter().CopyWrite(strings.NewReader("GIF89a"), &target, params)
+	assert.ErrorIs(t, err, m.ErrUnsupportedMIME)
+
+	// Packets that do not fit into a JPEG segment
+	long := fooocus.Parameters{Metadata: fooocus.Metadata{Prompt: strings.Repeat("a sunflower field, ", 4000)}}
+	err = NewXMPMetadataWriter().CopyWrite(bytes.NewReader(source), &target, long)
+	assert.ErrorIs(t, err, m.ErrMetadataTooLarge)
+}
@@ -0,0 +1,113 @@
+package xmp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fkleon/fooocus-metadata/internal/image"
+	m "github.com/fkleon/fooocus-metadata/types"
+)
+
+const (
+	Software = "XMP"
+	// Scheme is the name of the standard XMP properties.
+	Scheme = "xmp"
+)
+
+// XMPMetadataExtractor can decode the standard XMP properties
+// embedded in an image file.
+type XMPMetadataExtractor struct {
+	*m.FileMetadataExtractor
+}
+
+func (e XMPMetadataExtractor) Decode(file m.ImageMetadataContext) (meta Metadata, err error) {
+	meta, _, err = e.decode(file)
+	return
+}
+
+// decode reads the embedded XMP properties, or else those of an XMP
+// sidecar, see readSidecar, and returns their provenance. An image must have a prompt or
+// a digital source type, other properties such as the software are also
+// written by tools unrelated to generation.
+func (e XMPMetadataExtractor) decode(file m.ImageMetadataContext) (meta Metadata, provenance m.Provenance, err error) {
+	tags := file.EmbeddedMetadata
+	tag, ok := sourceTag(tags)
+	if ok {
+		provenance = m.EmbeddedProvenance(tag)
+	} else if file.Filepath != "" {
+		tags, provenance, ok = readSidecar(file.Filepath)
+	}
+	if !ok {
+		return meta, provenance, fmt.Errorf("%s: Prompt not found: %w", Software, m.ErrNoMetadata)
+	}
+
+	meta = ParseTags(tags)
+	provenance.Scheme = Scheme
+	return meta, provenance, nil
+}
+
+// readSidecar reads the XMP properties of the first sidecar of an image
+// that has a prompt or a digital source type, in the order of the
+// sidecar styles.
+func readSidecar(imagePath string) (tags m.Tags, provenance m.Provenance, ok bool) {
+	for _, style := range []SidecarStyle{SidecarAppend, SidecarReplace} {
+		sidecar := style.Path(imagePath)
+		tags, err := image.ReadXMPSidecar(sidecar)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				slog.Warn("Failed to read XMP sidecar", "path", sidecar, "error", err)
+			}
+			continue
+		}
+		if tag, ok := sourceTag(tags); ok {
+			return tags, m.Provenance{
+				Location:  m.LocationSidecar,
+				Path:      sidecar,
+				Container: tag.Namespace,
+				Key:       tag.Tag,
+			}, true
+		}
+	}
+	return nil, provenance, false
+}
+
+func (e XMPMetadataExtractor) Extract(file m.ImageMetadataContext) (m.StructuredMetadata, error) {
+
+	var meta = m.StructuredMetadata{
+		Source: Software,
+	}
+
+	filename := filepath.Base(file.Filepath)
+	meta.Filename = e.ParseFilename(file)
+
+	slog.Debug("Checking embedded XMP..", "file", filename)
+	params, provenance, err := e.decode(file)
+	if err == nil {
+		meta.Created, meta.CreatedSource = e.ResolveCreated(file, time.Time{})
+		meta.Provenance = provenance
+		meta.Params = &Parameters{
+			Metadata: params,
+			Created:  meta.Created,
+		}
+		return meta, nil
+	}
+
+	return meta, err
+}
+
+func NewXMPMetadataExtractor() m.Reader[Metadata] {
+	return XMPMetadataExtractor{
+		FileMetadataExtractor: &m.FileMetadataExtractor{
+			Software: Software,
+		},
+	}
+}
+
+func init() {
+	extractor := NewXMPMetadataExtractor()
+	m.RegisterFallbackReader(Software, extractor.Extract)
+}
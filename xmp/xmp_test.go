@@ -0,0 +1,88 @@
+package xmp
+
+import (
+	"testing"
+
+	"github.com/bep/imagemeta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	m "github.com/fkleon/fooocus-metadata/types"
+)
+
+const trainedAlgorithmicMedia = "http://cv.iptc.org/newscodes/digitalsourcetype/trainedAlgorithmicMedia"
+
+var xmpTags = m.Tags{
+	{Source: imagemeta.XMP, Namespace: m.NamespaceXMPBasic, Tag: "CreatorTool", Value: "Fooocus v2.5.5"},
+	{Source: imagemeta.XMP, Namespace: m.NamespaceXMPBasic, Tag: "CreateDate", Value: "2025-01-20T10:00:00+01:00"},
+	{Source: imagemeta.XMP, Namespace: m.NamespaceXMPDublinCore, Tag: "description", Value: "A sunflower field"},
+	{Source: imagemeta.XMP, Namespace: m.NamespaceXMPDublinCore, Tag: "title", Value: "Sunflowers"},
+	{Source: imagemeta.XMP, Namespace: m.NamespaceXMPDublinCore, Tag: "creator", Value: []string{"Jane Doe"}},
+	{Source: imagemeta.XMP, Namespace: m.NamespaceXMPDublinCore, Tag: "subject", Value: []string{"sunflower", "juggernautXL_v8Rundiffusion"}},
+	{Source: imagemeta.XMP, Namespace: m.NamespaceXMPIptcExt, Tag: "DigitalSourceType", Value: trainedAlgorithmicMedia},
+}
+
+func TestParseTags(t *testing.T) {
+	assert.Equal(t, Metadata{
+		Prompt:            "A sunflower field",
+		Title:             "Sunflowers",
+		Software:          "Fooocus v2.5.5",
+		Creators:          []string{"Jane Doe"},
+		Keywords:          []string{"sunflower", "juggernautXL_v8Rundiffusion"},
+		CreateDate:        "2025-01-20T10:00:00+01:00",
+		DigitalSourceType: trainedAlgorithmicMedia,
+	}, ParseTags(xmpTags))
+
+	// Simple properties instead of arrays
+	meta := ParseTags(m.Tags{{Namespace: m.NamespaceXMPDublinCore, Tag: "subject", Value: "sunflower"}})
+	assert.Equal(t, []string{"sunflower"}, meta.Keywords)
+}
+
+func TestExtract(t *testing.T) {
+	extractor := NewXMPMetadataExtractor()
+
+	meta, err := extractor.Extract(m.ImageMetadataContext{EmbeddedMetadata: xmpTags})
+	require.NoError(t, err)
+	assert.Equal(t, Software, meta.Source)
+	assert.Equal(t, m.Provenance{
+		Location:  m.LocationEmbedded,
+		Container: m.NamespaceXMPDublinCore,
+		Key:       "description",
+		Scheme:    Scheme,
+	}, meta.Provenance)
+
+	params := meta.Params
+	assert.Equal(t, "A sunflower field", params.PositivePrompt())
+	assert.Equal(t, "Fooocus v2.5.5", params.Version())
+	assert.Equal(t, "Fooocus", params.SoftwareVersion().Product)
+	assert.Empty(t, params.Model())
+	assert.Zero(t, params.SeedValue())
+	assert.IsType(t, Metadata{}, params.Raw())
+}
+
+func TestExtract_DigitalSourceType(t *testing.T) {
+	extractor := NewXMPMetadataExtractor()
+
+	// Labelled as generated, without a prompt
+	meta, err := extractor.Extract(m.ImageMetadataContext{EmbeddedMetadata: xmpTags[6:]})
+	require.NoError(t, err)
+	assert.Empty(t, meta.Params.PositivePrompt())
+	assert.Equal(t, "DigitalSourceType", meta.Provenance.Key)
+}
+
+func TestExtractErrors(t *testing.T) {
+	extractor := NewXMPMetadataExtractor()
+
+	_, err := extractor.Decode(m.ImageMetadataContext{})
+	assert.ErrorIs(t, err, m.ErrNoMetadata)
+
+	// Software alone is also written by image editors
+	_, err = extractor.Decode(m.ImageMetadataContext{EmbeddedMetadata: xmpTags[:2]})
+	assert.ErrorIs(t, err, m.ErrNoMetadata)
+
+	// Not XMP
+	_, err = extractor.Decode(m.ImageMetadataContext{EmbeddedMetadata: m.Tags{
+		{Namespace: m.NamespacePNGText, Tag: "description", Value: "A sunflower field"},
+	}})
+	assert.ErrorIs(t, err, m.ErrNoMetadata)
+}